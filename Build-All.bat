@@ -22,8 +22,14 @@ echo Done.
 :: Define the base output name for your executable (without extension)
 setlocal
 set OUTPUT_BASE_NAME=fsh24
-set LDFLAGS="-s"
-set GO_SOURCE_FILE=main.go
+:: Version from the first argument, Build-All.bat v1.2.0. Shows up in fsh24 version and the .fsh24 files it makes
+set VERSION=%1
+if "%VERSION%"=="" set VERSION=dev
+set COMMIT=
+for /f %%i in ('git rev-parse HEAD') do set COMMIT=%%i
+for /f "usebackq" %%i in (`powershell -NoProfile -Command "(Get-Date).ToUniversalTime().ToString('yyyy-MM-ddTHH:mm:ssZ')"`) do set BUILD_DATE=%%i
+set LDFLAGS="-s -X main.version=%VERSION% -X main.commit=%COMMIT% -X main.buildDate=%BUILD_DATE%"
+set GO_SOURCE_FILE=.
 
 :: Build project wihtout debug symbols.
 ::go build -ldflags "-s"
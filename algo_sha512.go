@@ -0,0 +1,22 @@
+//go:build sha512
+
+package main
+
+import (
+	"crypto/sha512"
+	"hash"
+
+	"fsh24/pkg/fsh24"
+)
+
+// A -tags sha512 build adds --algo sha512, the samples run through plain
+// SHA-512. It's here as an example of adding an algorithm without touching
+// pkg/fsh24, the files it makes say algo=sha512 and only a fsh24 built with
+// the same tag can check them.
+func init() {
+	fsh24.RegisterAlgorithm(fsh24.HashAlgorithm{
+		Name:        "sha512",
+		New:         func(size int, key []byte) (hash.Hash, error) { return sha512.New(), nil },
+		DigestBytes: sha512.Size,
+	})
+}
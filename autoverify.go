@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+
+	"github.com/spf13/pflag"
+)
+
+// A folder dropped on fsh24 that has its hash file in it is nearly always
+// dropped there to be checked, not hashed again over the top of it. So with
+// no flags that only mean something when hashing, fsh24 verifies the hash
+// file instead, see --no-auto-verify.
+
+// hashOnlyFlags are the flags that say the folder is to be hashed.
+var hashOnlyFlags = []string{
+	"output", "recursive", "max-depth", "exclude", "include", "ext", "absolute", "db", "update", "prune", "incremental", "resume",
+	"per-dir", "dir-hash", "merkle", "metadata", "adaptive", "sfv", "torrent", "chain", "sign",
+	"find-dupes", "tag-filename", "verify-tags", "dedupe",
+}
+
+// wantsHashing reports whether the flags given only make sense for hashing,
+// report being the report format of the run.
+func wantsHashing(report string) bool {
+	if slices.ContainsFunc(hashOnlyFlags, pflag.CommandLine.Changed) || slices.Contains(exportFormats, report) {
+		return true
+	}
+	return pflag.CommandLine.Changed("format") && report == "" // A hash file format
+}
+
+// folderHashFile returns the one .fsh24 (or .fsh24.gz) file in dir, or ""
+// if dir isn't a folder or doesn't have exactly one.
+func folderHashFile(dir string) string {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	found := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(strings.ToLower(fsh24.TrimGzipExt(name)), ".fsh24") {
+			continue
+		}
+		if found != "" {
+			return "" // Which one? Hash the folder like before
+		}
+		found = name
+	}
+	if found == "" {
+		return ""
+	}
+	return filepath.Join(dir, found)
+}
+
+// autoVerify says hashFile was found and asks whether to verify it, unless
+// ask is false, then it's verified without asking. It reports whether to.
+func autoVerify(hashFile string, ask, quiet bool) bool {
+	if !ask {
+		if !quiet {
+			fmt.Printf("Found %s, verifying it. Use --no-auto-verify to hash the folder instead\n\n", hashFile)
+		}
+		return true
+	}
+	fmt.Printf("Found %s in the folder.\nPress Enter to verify it, or 'h' to hash the folder instead: ", hashFile)
+
+	var input string
+	fmt.Scanln(&input)
+	fmt.Println()
+	return strings.ToLower(strings.TrimSpace(input)) != "h"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// matchByName points every entry of m at a file with the same name somewhere
+// under root, for collections that got sorted into new folders since they
+// were hashed. When a name is found more than once the file with the recorded
+// size wins, then the one whose folders look most like the recorded path.
+// Entries with no match are pointed at root so they come up as missing.
+func matchByName(m *fsh24.Manifest, root string, walk walkOptions) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	walk.Recursive = true
+	files, err := walk.walk(root)
+	if err != nil {
+		return fmt.Errorf("could not read directory %s: %w", root, err)
+	}
+
+	byName := map[string][]string{}
+	for _, f := range files {
+		key := nameKey(filepath.Base(f))
+		byName[key] = append(byName[key], f)
+	}
+
+	for i, e := range m.Entries {
+		candidates := byName[nameKey(baseName(e.Path))]
+		if len(candidates) == 0 {
+			m.Entries[i].Path = filepath.Join(root, baseName(e.Path))
+			continue
+		}
+		m.Entries[i].Path = bestMatch(e, candidates)
+	}
+	return nil
+}
+
+// bestMatch picks the candidate most likely to be the file e recorded.
+func bestMatch(e fsh24.Entry, candidates []string) string {
+	best, bestScore := candidates[0], -1
+	for _, c := range candidates {
+		score := commonSuffix(splitPath(e.Path), splitPath(c))
+		if e.Size >= 0 {
+			if fi, err := os.Stat(c); err == nil && fi.Size() == e.Size {
+				score += 1000 // Size matching beats any folder names
+			}
+		}
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+// commonSuffix counts how many trailing path parts a and b share.
+func commonSuffix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && nameKey(a[len(a)-1-n]) == nameKey(b[len(b)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// splitPath splits a recorded path on both kinds of slash, the hash file
+// may have been made on another OS.
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' })
+}
+
+// baseName is filepath.Base for a path from any OS.
+func baseName(p string) string {
+	parts := splitPath(p)
+	if len(parts) == 0 {
+		return p
+	}
+	return parts[len(parts)-1]
+}
+
+// nameKey is what file names are compared by, ignoring case on Windows or
+// with --ignore-case, and the Unicode form with --normalize-unicode.
+func nameKey(name string) string {
+	if normalizeUnicode {
+		name = fsh24.NormalizePath(name)
+	}
+	if runtime.GOOS == "windows" || ignoreCase {
+		return fsh24.FoldPath(name)
+	}
+	return name
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// checkpointInterval is how often the files hashed so far are saved to the
+// .partial file. Often enough that a crash costs little, rare enough that
+// rewriting it doesn't slow a run with a million files down.
+const checkpointInterval = time.Minute
+
+// fieldCRC32 is the extra FSH24-2 column the .partial file keeps CRC32s in,
+// for --sfv and --format sfv runs.
+const fieldCRC32 = "crc32"
+
+// checkpoint saves the files hashed so far to a .partial file next to the
+// output, so a run that crashes can carry on with --resume. It's an FSH24-2
+// file with absolute paths, whatever the output format is.
+type checkpoint struct {
+	file     string
+	manifest *fsh24.Manifest
+	saved    time.Time
+}
+
+// partialFile is the checkpoint file for output.
+func partialFile(output string) string {
+	return output + ".partial"
+}
+
+// newCheckpoint starts an empty checkpoint for files hashed with hasher.
+func newCheckpoint(file string, hasher *fsh24.Hasher) *checkpoint {
+	m := &fsh24.Manifest{
+		Algorithm:   hasher.Algorithm,
+		DigestBytes: hasher.DigestBytes,
+		SampleSize:  hasher.SampleSize,
+		Full:        hasher.Full,
+		Seed:        hasher.Seed,
+		Keyed:       len(hasher.Key) > 0,
+		SHA256:      hasher.SHA256,
+		Format:      fsh24.FormatFSH24v2,
+		Comments:    []string{"Unfinished fsh24 run, carry on with --resume"},
+	}
+	fields := []string{fsh24.FieldHash, fsh24.FieldChunks, fsh24.FieldSize}
+	if hasher.Adaptive {
+		fields = append(fields, fsh24.FieldOffsets)
+	}
+	if hasher.SHA256 {
+		fields = append(fields, fsh24.FieldSHA256)
+	}
+	if hasher.CRC32 {
+		fields = append(fields, fieldCRC32)
+	}
+	if hasher.Metadata {
+		fields = append(fields, fsh24.FieldMode, fsh24.FieldOwner, fsh24.FieldXattrs)
+	}
+	m.Fields = append(fields, fsh24.FieldMtime, fsh24.FieldPath)
+	return &checkpoint{file: file, manifest: m, saved: time.Now()}
+}
+
+// add records a hashed file, saving the checkpoint when it's been a while.
+func (c *checkpoint) add(r fsh24.FileHashResult) error {
+	e := fsh24.Entry{
+		Hash:     strings.ToUpper(r.FSH24),
+		Chunks:   r.Chunks,
+		Size:     r.FileSize,
+		Path:     r.Filepath,
+		SHA256:   strings.ToUpper(r.SHA256),
+		ModTime:  r.ModTime,
+		Metadata: r.Metadata,
+		Offsets:  r.Offsets,
+	}
+	if abs, err := absPath(r.Filepath); err == nil {
+		e.Path = abs
+	}
+	if r.CRC32 != "" {
+		e.Extra = map[string]string{fieldCRC32: strings.ToUpper(r.CRC32)}
+	}
+	c.manifest.Entries = append(c.manifest.Entries, e)
+
+	if time.Since(c.saved) < checkpointInterval {
+		return nil
+	}
+	return c.save()
+}
+
+// save writes the checkpoint out, see replaceFile.
+func (c *checkpoint) save() error {
+	c.saved = time.Now()
+	return replaceFile(c.manifest, c.file)
+}
+
+// remove deletes the checkpoint once the real output is written.
+func (c *checkpoint) remove() {
+	if err := os.Remove(c.file); err != nil && !os.IsNotExist(err) {
+		warnf(c.file, "Could not remove %s: %v", c.file, err)
+	}
+}
+
+// resume reads the checkpoint left by an earlier run and splits files into
+// the results it already has and the files still to hash. Files that changed
+// since, going by size and modification time, are hashed again. Results for
+// files no longer in files are dropped.
+func (c *checkpoint) resume(files []string) ([]fsh24.FileHashResult, []string, error) {
+	x, err := loadExisting(c.file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if x == nil {
+		return nil, files, nil
+	}
+	if c.manifest.Seed != 0 && x.Seed != 0 {
+		c.manifest.Seed = x.Seed // --random-offsets picked a new one, the samples go where they went before
+	}
+	if !slices.Equal(x.Params(), c.manifest.Params()) {
+		return nil, nil, fmt.Errorf("%s was made with different settings, use the same flags to resume or delete it", c.file)
+	}
+
+	var (
+		done []fsh24.FileHashResult
+		todo []string
+	)
+	for _, f := range files {
+		if !x.contains(f) || x.changed(f) {
+			todo = append(todo, f)
+			continue
+		}
+		abs, _ := absPath(f)
+		e := x.Entries[x.index[abs]]
+		c.manifest.Entries = append(c.manifest.Entries, e) // The next save would lose it otherwise
+		done = append(done, fsh24.FileHashResult{
+			Filepath: f,
+			Filename: filepath.Base(f),
+			FSH24:    e.Hash,
+			Chunks:   e.Chunks,
+			FileSize: e.Size,
+			SHA256:   e.SHA256,
+			CRC32:    e.Extra[fieldCRC32],
+			ModTime:  e.ModTime,
+			Metadata: e.Metadata,
+			Offsets:  e.Offsets,
+		})
+	}
+
+	return done, todo, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"fsh24/pkg/fsh24"
+)
+
+// A file hashed with --adaptive and saved to the .partial file comes back
+// from --resume with its offsets, or it fails to verify.
+func TestResumeKeepsOffsets(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "disk.img")
+	data := make([]byte, 4<<20) // Zeros, data in the middle
+	rand.New(rand.NewSource(1)).Read(data[1<<20 : 3<<20/2])
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	hasher := fsh24.NewHasher()
+	hasher.SampleSize = fsh24.MinSampleSize
+	hasher.Adaptive = true
+	r, err := hasher.HashFile(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Offsets == nil {
+		t.Fatal("the samples weren't moved, nothing to test")
+	}
+
+	partial := filepath.Join(dir, "checksums.fsh24.partial")
+	c := newCheckpoint(partial, hasher)
+	if err := c.add(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	done, todo, err := newCheckpoint(partial, hasher).resume([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || len(todo) != 0 {
+		t.Fatalf("resumed %d and left %d to hash, want 1 and 0", len(done), len(todo))
+	}
+	if !slices.Equal(done[0].Offsets, r.Offsets) {
+		t.Fatalf("resumed with offsets %v, hashed with %v", done[0].Offsets, r.Offsets)
+	}
+
+	// Saved and read back like the real output, it still verifies
+	output := filepath.Join(dir, "checksums.fsh24")
+	m := newManifest(hasher, fsh24.FormatFSH24v2)
+	if err := m.Add(done[0], dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile(output); err != nil {
+		t.Fatal(err)
+	}
+	read, err := fsh24.ReadManifestFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyHasher := fsh24.NewHasher()
+	read.ApplySettings(verifyHasher)
+	summary, _, err := (&fsh24.Verifier{Hasher: verifyHasher}).Verify(ctx, read, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Verified != 1 {
+		t.Fatalf("%d verified, want 1: %+v", summary.Verified, summary)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// cmp statuses, one per file found in either folder.
+const (
+	cmpSame      = "same"
+	cmpDifferent = "different"
+	cmpOnlyLeft  = "only_left"  // Only in the first folder
+	cmpOnlyRight = "only_right" // Only in the second folder
+	cmpError     = "error"      // Couldn't be hashed on one side
+)
+
+// cmpFile is how a file compares between the two folders.
+type cmpFile struct {
+	Path   string `json:"path" yaml:"path"` // Relative to both folders
+	Status string `json:"status" yaml:"status"`
+}
+
+// cmpSummary counts the cmpFile statuses.
+type cmpSummary struct {
+	Same      int  `json:"same" yaml:"same"`
+	Different int  `json:"different" yaml:"different"`
+	OnlyLeft  int  `json:"only_left" yaml:"only_left"`
+	OnlyRight int  `json:"only_right" yaml:"only_right"`
+	Errors    int  `json:"errors" yaml:"errors"`
+	Match     bool `json:"match" yaml:"match"` // Both folders have the same files with the same content
+}
+
+// cmpOutput is the report printed by cmp with -j and the other report formats.
+type cmpOutput struct {
+	Left    string     `json:"left" yaml:"left"`
+	Right   string     `json:"right" yaml:"right"`
+	Summary cmpSummary `json:"summary" yaml:"summary"`
+	Files   []cmpFile  `json:"files" yaml:"files"`
+}
+
+// compareFolders hashes every file under left and right and lines them up
+// by their path inside the folder. Files are the same when size and FSH24 match.
+func compareFolders(ctx context.Context, hasher *fsh24.Hasher, walk walkOptions, left, right string) (cmpOutput, error) {
+	out := cmpOutput{Left: left, Right: right}
+
+	leftHashes, err := hashFolder(ctx, hasher, walk, left)
+	if err != nil {
+		return out, err
+	}
+	rightHashes, err := hashFolder(ctx, hasher, walk, right)
+	if err != nil {
+		return out, err
+	}
+
+	for rel, l := range leftHashes {
+		r, ok := rightHashes[rel]
+		status := cmpSame
+		switch {
+		case !ok:
+			status = cmpOnlyLeft
+		case l == nil || r == nil:
+			status = cmpError
+		case l.FileSize != r.FileSize || !strings.EqualFold(l.FSH24, r.FSH24):
+			status = cmpDifferent
+		}
+		out.Files = append(out.Files, cmpFile{Path: rel, Status: status})
+	}
+	for rel := range rightHashes {
+		if _, ok := leftHashes[rel]; !ok {
+			out.Files = append(out.Files, cmpFile{Path: rel, Status: cmpOnlyRight})
+		}
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].Path < out.Files[j].Path })
+	out.Summary = cmpSummaryOf(out.Files)
+	return out, ctx.Err()
+}
+
+// cmpSummaryOf counts the statuses of files.
+func cmpSummaryOf(files []cmpFile) cmpSummary {
+	var s cmpSummary
+	for _, f := range files {
+		switch f.Status {
+		case cmpSame:
+			s.Same++
+		case cmpDifferent:
+			s.Different++
+		case cmpOnlyLeft:
+			s.OnlyLeft++
+		case cmpOnlyRight:
+			s.OnlyRight++
+		case cmpError:
+			s.Errors++
+		}
+	}
+	s.Match = s.Same == len(files)
+	return s
+}
+
+// isFile reports whether path is a file, not a folder or nothing.
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// hashFolder hashes the files under root, keyed by their path inside it.
+// Files that couldn't be hashed are warned about and kept as nil.
+func hashFolder(ctx context.Context, hasher *fsh24.Hasher, walk walkOptions, root string) (map[string]*fsh24.FileHashResult, error) {
+	files, err := walk.walk(root)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory %s: %w", root, err)
+	}
+
+	hashes := make(map[string]*fsh24.FileHashResult, len(files))
+	results, errs := hasher.HashFiles(ctx, files)
+	for i := range results {
+		if rel, err := filepath.Rel(root, results[i].Filepath); err == nil {
+			hashes[filepath.ToSlash(rel)] = &results[i]
+		}
+	}
+	for _, err := range errs {
+		fe := err.(*fsh24.FileError)
+		warnf(fe.Path, "Could not hash %s: %v", fe.Path, fe.Err)
+		if rel, err := filepath.Rel(root, fe.Path); err == nil {
+			hashes[filepath.ToSlash(rel)] = nil
+		}
+	}
+	return hashes, nil
+}
+
+// printCompare prints the cmp results to the console. Matching files are
+// only listed with -v.
+func printCompare(out cmpOutput, verbose int, quiet bool) {
+	for _, f := range out.Files {
+		switch f.Status {
+		case cmpSame:
+			if verbose >= verboseInfo && !quiet {
+				fmt.Println(colorize(colorGreen, "Same: "+f.Path))
+			}
+		case cmpDifferent:
+			fmt.Println(colorize(colorRed, "DIFFERENT: "+f.Path))
+		case cmpOnlyLeft:
+			fmt.Println(colorize(colorYellow, "Only in "+out.Left+": "+f.Path))
+		case cmpOnlyRight:
+			fmt.Println(colorize(colorYellow, "Only in "+out.Right+": "+f.Path))
+		case cmpError:
+			fmt.Println(colorize(colorRed, "!ERROR: "+f.Path))
+		}
+	}
+
+	s := out.Summary
+	color := colorGreen
+	if !s.Match {
+		color = colorRed
+	}
+	fmt.Println(colorize(color, fmt.Sprintf(
+		"Compare: %d same, %d different, %d only in %s, %d only in %s",
+		s.Same, s.Different, s.OnlyLeft, out.Left, s.OnlyRight, out.Right,
+	)))
+}
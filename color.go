@@ -0,0 +1,40 @@
+package main
+
+import "os"
+
+// ANSI colours for the console results, so failures stand out in a wall of text.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColor is set by setupColor, leave it false and everything prints plain.
+var useColor bool
+
+// setupColor turns colours on for a console, unless --no-color or the
+// NO_COLOR environment variable (https://no-color.org) say otherwise.
+func setupColor(noColor bool) {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(os.Stdout) {
+		return
+	}
+	useColor = enableANSI()
+}
+
+// colorize wraps s in color when colours are on.
+func colorize(color, s string) string {
+	if !useColor {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// colorizeStderr is colorize for stderr, which may be going to a log file
+// even when stdout is the console.
+func colorizeStderr(color, s string) string {
+	if !isTerminal(os.Stderr) {
+		return s
+	}
+	return colorize(color, s)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// enableANSI reports if the console understands colour codes, every
+// terminal outside Windows does.
+func enableANSI() bool {
+	return true
+}
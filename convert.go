@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+	"fsh24/pkg/fsh24db"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats convert --to takes on top of the hash file and report formats.
+const (
+	convertSHA256sum = "sha256sum" // Plain sha256sum file of the --sha256 column, for coreutils
+	convertSQLite    = "sqlite"    // A --db database
+)
+
+// convertTargets are the formats convert can write. ndjson is left out, it's
+// for watching a run as it goes, a finished hash file has nothing to stream.
+var convertTargets = slices.Concat(fsh24.Formats, []string{convertSHA256sum, reportJSON, reportCSV, reportYAML, reportHashdeep, reportDFXML, convertSQLite})
+
+// convertExt is the extension of the file convert writes when there's no -o.
+func convertExt(to string) string {
+	switch to {
+	case fsh24.FormatSFV:
+		return ".sfv"
+	case convertSHA256sum:
+		return ".sha256"
+	case reportHashdeep:
+		return ".hashdeep"
+	case reportDFXML:
+		return ".xml"
+	case reportJSON, reportCSV, reportYAML, convertSQLite:
+		return "." + to
+	default:
+		return ".fsh24"
+	}
+}
+
+// convertHashFile reads in, whatever it is, and writes it to out in the
+// format to, without hashing anything again. It returns how many files were
+// converted. CSV reports don't record the hash settings, those come from h.
+func convertHashFile(in, out, to string, h *fsh24.Hasher) (int, error) {
+	if same, _ := sameFile(in, out); same {
+		return 0, fmt.Errorf("%s would be overwritten, give another -o", in)
+	}
+	m, err := readAnyHashFile(in, h)
+	if err != nil {
+		return 0, err
+	}
+	for _, inv := range m.Invalid {
+		warnf(in, "Skipping broken line in %s: %s", in, inv.Line)
+	}
+	if m.SignedBy != nil && signingKey == nil && slices.Contains(fsh24.Formats, to) {
+		warnf(in, "The signature of %s doesn't carry over to %s, sign it again with --sign-key", in, out)
+	}
+
+	// Every target but sfv and sha256sum needs the sampled hash, most the sizes too
+	switch to {
+	case fsh24.FormatSFV:
+		if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return e.CRC32 == "" }); i >= 0 {
+			return 0, fmt.Errorf("%s has no CRC32 for %s, hash with --sfv to get them", in, m.Entries[i].Path)
+		}
+	case convertSHA256sum:
+		if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return sha256Of(e) == "" }); i >= 0 {
+			return 0, fmt.Errorf("%s has no SHA-256 for %s, hash with --sha256 to get them", in, m.Entries[i].Path)
+		}
+	case fsh24.FormatGNU, fsh24.FormatBSD:
+		// md5sum style entries keep their hash, gnu and bsd can have those
+		if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return e.Hash == "" }); i >= 0 {
+			return 0, fmt.Errorf("%s has no FSH24 hash for %s, converting it to %s would take a re-hash", in, m.Entries[i].Path, to)
+		}
+	default:
+		if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return e.Hash == "" || e.Checksum != "" || e.Size < 0 }); i >= 0 {
+			return 0, fmt.Errorf("%s has no FSH24 hash or file size for %s, converting it to %s would take a re-hash", in, m.Entries[i].Path, to)
+		}
+	}
+	if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return e.Offsets != nil }); i >= 0 {
+		if slices.Contains([]string{fsh24.FormatFSH24, fsh24.FormatGNU, fsh24.FormatBSD, reportCSV, convertSQLite}, to) {
+			return 0, fmt.Errorf("%s was hashed with --adaptive, %s has no room for where the samples of %s are", in, to, m.Entries[i].Path)
+		}
+	}
+	m.Chained = (m.Chained || chained) && to == fsh24.FormatFSH24
+	m.Invalid = nil
+
+	switch to {
+	case reportJSON, reportCSV, reportYAML, reportHashdeep, reportDFXML:
+		data, err := hashReport(to, manifestSummary(m))
+		if err != nil {
+			return 0, err
+		}
+		return len(m.Entries), os.WriteFile(out, data, 0644)
+	case convertSQLite:
+		db, err := fsh24db.Open(out)
+		if err != nil {
+			return 0, err
+		}
+		err = db.Write(m)
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+		return len(m.Entries), err
+	case convertSHA256sum:
+		sums := &fsh24.Manifest{Format: fsh24.FormatGNU, NullTerminated: m.NullTerminated}
+		for _, e := range m.Entries {
+			sums.Entries = append(sums.Entries, fsh24.Entry{Hash: sha256Of(e), Size: e.Size, Path: e.Path})
+		}
+		m = sums
+	default:
+		m.Format = to
+		m.SHA256 = len(m.Entries) > 0 && !slices.ContainsFunc(m.Entries, func(e fsh24.Entry) bool { return e.SHA256 == "" })
+	}
+	m.Gzip = fsh24.IsGzipName(out) // Going by the new name, not the old one
+	return len(m.Entries), writeHashFile(m, out)
+}
+
+// sha256Of is the whole file SHA-256 of e, from the --sha256 column or a sha256sum line.
+func sha256Of(e fsh24.Entry) string {
+	if e.Checksum == fsh24.ChecksumSHA256 {
+		return e.Hash
+	}
+	return e.SHA256
+}
+
+// readAnyHashFile reads a hash file in any format fsh24 writes: the hash
+// file formats, a --db database, or a json, csv or yaml report. Hash files
+// have their signature checked like they would when verifying.
+func readAnyHashFile(filename string, h *fsh24.Hasher) (*fsh24.Manifest, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("hash file not found: %s", filename)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read hash file %s: %w", filename, err)
+	}
+
+	start := bytes.TrimLeft(content, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(content, []byte("SQLite format 3\x00")):
+		db, err := fsh24db.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		return db.Manifest()
+	case bytes.HasPrefix(start, []byte("{")):
+		var summary fsh24.TotalHashSummary
+		if err := json.Unmarshal(content, &summary); err != nil {
+			return nil, fmt.Errorf("%s is not a fsh24 JSON report: %w", filename, err)
+		}
+		return summaryManifest(filename, summary)
+	case bytes.HasPrefix(start, []byte("schema_version:")), bytes.HasPrefix(start, []byte("magic:")):
+		var summary fsh24.TotalHashSummary
+		if err := yaml.Unmarshal(content, &summary); err != nil {
+			return nil, fmt.Errorf("%s is not a fsh24 YAML report: %w", filename, err)
+		}
+		return summaryManifest(filename, summary)
+	case bytes.HasPrefix(start, []byte("path,size,fsh24,chunks")):
+		return csvManifest(filename, content, h)
+	}
+	m, _, err := readHashFile(filename)
+	return m, err
+}
+
+// summaryManifest turns a json or yaml hash report back into a hash file.
+// The reports don't carry the digest length, it's the length of the hashes.
+func summaryManifest(filename string, summary fsh24.TotalHashSummary) (*fsh24.Manifest, error) {
+	m := &fsh24.Manifest{
+		Algorithm:  summary.Algorithm,
+		SampleSize: summary.SampleSize,
+		Full:       summary.Full,
+		Keyed:      summary.Keyed,
+	}
+	if summary.Seed != "" {
+		seed, err := fsh24.ParseSeed(summary.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		m.Seed = seed
+	}
+	if summary.Magic == fsh24.MagicXXH3 {
+		m.Algorithm = fsh24.AlgoXXH3
+	}
+	for _, r := range summary.Files {
+		if err := m.Add(r, ""); err != nil {
+			return nil, err
+		}
+	}
+	return m, setDigestBytes(filename, m)
+}
+
+// csvManifest turns a csv hash report back into a hash file. The csv has no
+// settings, so it gets the ones given on the command line, --algo and so on.
+func csvManifest(filename string, content []byte, h *fsh24.Hasher) (*fsh24.Manifest, error) {
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a fsh24 CSV report: %w", filename, err)
+	}
+	m := &fsh24.Manifest{
+		Algorithm:  h.Algorithm,
+		SampleSize: h.SampleSize,
+		Full:       h.Full,
+		Seed:       h.Seed,
+		Keyed:      len(h.Key) > 0,
+	}
+	for i, row := range rows[1:] {
+		size, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: bad size %q", filename, i+2, row[1])
+		}
+		chunks, err := strconv.Atoi(row[3])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: bad chunks %q", filename, i+2, row[3])
+		}
+		m.Entries = append(m.Entries, fsh24.Entry{Hash: strings.ToUpper(row[2]), Chunks: chunks, Size: size, Path: row[0]})
+	}
+	return m, setDigestBytes(filename, m)
+}
+
+// setDigestBytes sets the digest length of m from its hashes.
+func setDigestBytes(filename string, m *fsh24.Manifest) error {
+	if algo, _ := fsh24.LookupAlgorithm(m.Algorithm); len(m.Entries) == 0 || algo.DigestBytes != 0 {
+		return nil // Fixed length ones like xxh3 have nothing to set
+	}
+	m.DigestBytes = len(m.Entries[0].Hash) / 2
+	if m.DigestBytes == fsh24.DefaultDigestBytes {
+		m.DigestBytes = 0
+	}
+	if err := fsh24.ValidateDigestBytes(m.Algorithm, m.DigestBytes); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// manifestSummary is the hash report of m, as if its files were just hashed.
+// There are no times, nothing was hashed.
+func manifestSummary(m *fsh24.Manifest) fsh24.TotalHashSummary {
+	algo := m.Algorithm
+	if algo == "" {
+		algo = fsh24.AlgoBLAKE2b
+	}
+	sampleSize := m.SampleSize
+	if sampleSize == 0 {
+		sampleSize = fsh24.SampleSize
+	}
+	summary := fsh24.TotalHashSummary{
+		Magic:      fsh24.MagicFor(algo),
+		Algorithm:  algo,
+		SampleSize: sampleSize,
+		Full:       m.Full,
+		Keyed:      m.Keyed,
+		TotalFiles: len(m.Entries),
+		Files:      []fsh24.FileHashResult{},
+	}
+	if m.Seed != 0 {
+		summary.Seed = fsh24.FormatSeed(m.Seed)
+	}
+	for _, e := range m.Entries {
+		coverage := 0.0
+		if m.Full {
+			coverage = 100
+		} else if e.Size > 0 {
+			coverage = float64(e.Chunks) * float64(sampleSize) / float64(e.Size) * 100
+		}
+		summary.Files = append(summary.Files, fsh24.FileHashResult{
+			Filename:        filepath.Base(e.Path),
+			Filepath:        e.Path,
+			FileSize:        e.Size,
+			FSH24:           e.Hash,
+			SHA256:          e.SHA256,
+			CRC32:           e.CRC32,
+			Chunks:          e.Chunks,
+			CoveragePercent: coverage,
+			ModTime:         e.ModTime,
+			Metadata:        e.Metadata,
+			Offsets:         e.Offsets,
+		})
+	}
+	return summary
+}
+
+// sameFile reports whether a and b are the same file, false if either doesn't exist.
+func sameFile(a, b string) (bool, error) {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(fa, fb), nil
+}
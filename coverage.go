@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+
+	"fsh24/pkg/fsh24"
+)
+
+// fsh24 coverage says how much of each file the samples of a hash file
+// read, and how likely damage in between is to go unnoticed, so you can
+// pick a --sample-size, or --sha256, for data where that matters. Given
+// files or folders instead of a hash file it works out what hashing them
+// with the flags given would cover, nothing gets read.
+
+// Runs of damaged bytes the miss chances are for, a flipped bit, a bad 4 KB
+// disk sector and a bad MB.
+const (
+	burstByte   = 1
+	burstSector = 4 << 10
+	burstMB     = 1 << 20
+)
+
+// coverageFile is the coverage of one file.
+type coverageFile struct {
+	Path       string  `json:"path,omitempty" yaml:"path,omitempty"` // Empty for the summary
+	Size       int64   `json:"size" yaml:"size"`
+	Sampled    int64   `json:"sampled" yaml:"sampled"` // Bytes read when verifying
+	Percent    float64 `json:"sampled_percent" yaml:"sampled_percent"`
+	LargestGap int64   `json:"largest_gap" yaml:"largest_gap"` // Longest stretch never read
+
+	// Chances, 0 to 1, that a damaged byte, 4 KB sector or MB somewhere in
+	// the file isn't noticed, see fsh24.Coverage.MissChance
+	MissByte   float64 `json:"miss_byte" yaml:"miss_byte"`
+	MissSector float64 `json:"miss_sector" yaml:"miss_sector"`
+	MissMB     float64 `json:"miss_mb" yaml:"miss_mb"`
+}
+
+// coverageOutput is the report printed by coverage with -j and the other
+// report formats. Summary is all the files together.
+type coverageOutput struct {
+	Source     string         `json:"source" yaml:"source"` // The hash file, or "" for files hashed with the flags given
+	SampleSize int            `json:"sample_size" yaml:"sample_size"`
+	Unknown    int            `json:"unknown_size,omitempty" yaml:"unknown_size,omitempty"` // Files the hash file has no size for, left out
+	Summary    coverageFile   `json:"summary" yaml:"summary"`
+	Files      []coverageFile `json:"files" yaml:"files"`
+}
+
+// coverageOf fills in a coverageFile from c.
+func coverageOf(path string, c fsh24.Coverage) coverageFile {
+	return coverageFile{
+		Path:       path,
+		Size:       c.Size,
+		Sampled:    c.Sampled,
+		Percent:    c.Percent(),
+		LargestGap: c.LargestGap(),
+		MissByte:   c.MissChance(burstByte),
+		MissSector: c.MissChance(burstSector),
+		MissMB:     c.MissChance(burstMB),
+	}
+}
+
+// hashFileCoverage is the coverage of verifying the hash file hashFile.
+func hashFileCoverage(hashFile string) (coverageOutput, error) {
+	m, _, err := readHashFile(hashFile)
+	if err != nil {
+		return coverageOutput{}, err
+	}
+	hasher := fsh24.NewHasher()
+	m.ApplySettings(hasher)
+
+	out := coverageOutput{Source: hashFile, SampleSize: cmp.Or(hasher.SampleSize, fsh24.SampleSize)}
+	var total fsh24.Coverage
+	for _, e := range m.Entries {
+		if e.Size < 0 {
+			out.Unknown++
+			continue
+		}
+		c := hasher.EntryCoverage(e)
+		total.Add(c)
+		out.Files = append(out.Files, coverageOf(e.Path, c))
+	}
+	out.Summary = coverageOf("", total)
+	return out, nil
+}
+
+// filesCoverage is the coverage of hashing files with hasher. Only their
+// sizes are looked at, --adaptive ones come out evenly spaced.
+func filesCoverage(hasher *fsh24.Hasher, files []string) coverageOutput {
+	if hasher.Adaptive {
+		warnf("", "--adaptive places the samples by what's in the files, this is for them evenly spaced. Run coverage on the hash file to see where they went")
+	}
+	out := coverageOutput{SampleSize: cmp.Or(hasher.SampleSize, fsh24.SampleSize)}
+	var total fsh24.Coverage
+	for _, file := range files {
+		if fsh24.IsRemote(file) || fsh24.IsDevice(file) {
+			warnf(file, "Skipping %s, coverage only works out the size of local files", file)
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			warnf(file, "Skipping %s: %v", file, err)
+			continue
+		}
+		c := hasher.Coverage(info.Size())
+		if hasher.SHA256 || hasher.CRC32 {
+			c = fsh24.FullCoverage(info.Size())
+		}
+		total.Add(c)
+		out.Files = append(out.Files, coverageOf(file, c))
+	}
+	out.Summary = coverageOf("", total)
+	return out
+}
+
+// printCoverage prints the coverage to the console, each file unless quiet.
+func printCoverage(out coverageOutput, quiet bool) {
+	if !quiet {
+		for _, f := range out.Files {
+			if f.Sampled == f.Size {
+				fmt.Printf("%s| all %s read\n", f.Path, formatBytes(f.Size))
+				continue
+			}
+			fmt.Printf(
+				"%s| %.2f%% of %s read, gaps up to %s, misses a bad 4 KB sector %.1f%% of the time\n",
+				f.Path, f.Percent, formatBytes(f.Size), formatBytes(f.LargestGap), f.MissSector*100,
+			)
+		}
+		fmt.Println()
+	}
+
+	s := out.Summary
+	fmt.Printf("Coverage: %d files, %s of %s read (%.2f%%), samples of %s\n",
+		len(out.Files), formatBytes(s.Sampled), formatBytes(s.Size), s.Percent, formatBytes(int64(out.SampleSize)))
+	if out.Unknown > 0 {
+		fmt.Printf("%d files have no size in the hash file and are left out\n", out.Unknown)
+	}
+	if s.Sampled == s.Size {
+		fmt.Println("Every byte of every file is read, nothing can go unnoticed")
+		return
+	}
+	fmt.Printf("The longest stretch never read is %s, damage longer than that is always caught, and so is anything that changes a file's size\n", formatBytes(s.LargestGap))
+	fmt.Printf("Damage somewhere in the files is missed: a byte %.1f%% of the time, a 4 KB sector %.1f%%, a MB in a row %.1f%%\n",
+		s.MissByte*100, s.MissSector*100, s.MissMB*100)
+	fmt.Println("A bigger --sample-size reads more, --sha256 (and --deep to verify it) reads every byte")
+}
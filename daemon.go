@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// logTime is the timestamp in front of the lines of the long running modes.
+const logTime = "2006-01-02 15:04:05"
+
+// defaultInterval is how often daemon re-verifies, once a week. Bit rot is
+// slow, reading everything every day would wear the drives more than it finds.
+const defaultInterval = 7 * 24 * time.Hour
+
+// daemonOptions are the daemon settings besides what to verify.
+type daemonOptions struct {
+	Interval time.Duration
+
+	// OnFailure is a shell command run when a check fails, with the
+	// daemonReport as JSON on stdin and the check in the same FSH24_
+	// variables as --on-fail gets for a run, see runHook.
+	OnFailure string
+
+	// NotifyURL gets the daemonReport POSTed as JSON when a check fails.
+	NotifyURL string
+
+	// Metrics, if set, is the address to serve Prometheus metrics on.
+	Metrics string
+
+	// Mail gets a report of failed checks, or every check, see mailOptions.
+	Mail mailOptions
+}
+
+// daemonFailure is a file that didn't verify, or couldn't be hashed.
+type daemonFailure struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// daemonReport is what the notifications get for a failed check.
+type daemonReport struct {
+	HashFile string          `json:"hash_file"`
+	Time     time.Time       `json:"time"`
+	Verified int             `json:"verified"`
+	Failed   int             `json:"failed"`
+	Failures []daemonFailure `json:"failures"`
+	Error    string          `json:"error,omitempty"` // The hash file itself couldn't be read
+}
+
+// runDaemon verifies every hash file in files, and the database db if set,
+// straight away and then again every Interval, until ctx is cancelled.
+// Failed checks are logged and sent to the notifications set in d.
+func runDaemon(ctx context.Context, files []string, db string, opts verifyOptions, d daemonOptions) {
+	if d.Metrics != "" {
+		go func() {
+			if err := serveMetrics(d.Metrics); err != nil {
+				fatalf(exitError, "--metrics: %v", err)
+			}
+		}()
+	}
+	for {
+		for _, file := range files {
+			if ctx.Err() != nil {
+				return
+			}
+			daemonCheck(ctx, file, verifyHashFile, opts, d)
+		}
+		if db != "" && ctx.Err() == nil {
+			daemonCheck(ctx, db, verifyDatabase, opts, d)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		next := time.Now().Add(d.Interval)
+		fmt.Printf("%s Next check at %s\n", time.Now().Format(logTime), next.Format(logTime))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.Interval):
+		}
+	}
+}
+
+// verifyFunc is verifyHashFile or verifyDatabase.
+type verifyFunc func(context.Context, string, verifyOptions) (fsh24.VerificationSummary, []fsh24.FileVerificationResult, error)
+
+// daemonCheck verifies one hash file and sends out notifications if it failed.
+func daemonCheck(ctx context.Context, target string, verify verifyFunc, opts verifyOptions, d daemonOptions) {
+	fmt.Printf("%s Verifying %s\n", time.Now().Format(logTime), target)
+
+	runMu.Lock()
+	warnings := len(run.Warnings)
+	runMu.Unlock()
+	start := time.Now()
+	summary, results, err := verify(ctx, target, opts)
+	if ctx.Err() != nil {
+		return // Stopped, not failed
+	}
+	stats.addVerified(results)
+	stats.addCheck(target, time.Since(start), summary.Failed, err)
+
+	report := daemonReport{
+		HashFile: target,
+		Time:     time.Now(),
+		Verified: summary.Verified,
+		Failed:   summary.Failed,
+	}
+	if err != nil {
+		report.Error = err.Error()
+		fmt.Println(colorize(colorRed, fmt.Sprintf("%s Could not verify %s: %v", time.Now().Format(logTime), target, err)))
+	}
+	report.Failures = failuresOf(results)
+	check := runSummary{
+		Mode:     "daemon",
+		ExitCode: verifyExitCode(results),
+		Total:    summary.Total,
+		OK:       summary.Verified,
+		Failed:   summary.Failed,
+		Error:    report.Error,
+	}
+	if err != nil {
+		check.ExitCode = exitError
+	}
+	runMu.Lock()
+	check.Warnings = slices.Clone(run.Warnings[warnings:])
+	runMu.Unlock()
+	run.Total += summary.Total
+	run.OK += summary.Verified
+	run.Failed += summary.Failed
+	if err := mailCheck(d.Mail, report); err != nil {
+		warnf(target, "Could not mail the report for %s: %v", target, err)
+	}
+	if report.Error == "" && report.Failed == 0 {
+		return
+	}
+
+	if err := notify(report, check, d); err != nil {
+		warnf(target, "Could not send the failure notification for %s: %v", target, err)
+	}
+}
+
+// failuresOf returns the files in results that didn't verify.
+func failuresOf(results []fsh24.FileVerificationResult) []daemonFailure {
+	var failures []daemonFailure
+	for _, r := range results {
+		if r.Status != fsh24.StatusVerified {
+			failures = append(failures, daemonFailure{Path: r.Filepath, Status: r.Status})
+		}
+	}
+	return failures
+}
+
+// notify sends a failed check, that ended like check, to the notifications
+// set in d.
+func notify(report daemonReport, check runSummary, d daemonOptions) error {
+	if d.OnFailure != "" {
+		if err := runHook(d.OnFailure, check, report.HashFile, report.HashFile, report.Failures, report); err != nil {
+			return fmt.Errorf("--on-fail command: %w", err)
+		}
+	}
+
+	if d.NotifyURL != "" {
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		client := http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Post(d.NotifyURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s answered %s", d.NotifyURL, resp.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// dupeSet is a group of files with the same content.
+type dupeSet struct {
+	Size   int64    `json:"size" yaml:"size"`
+	FSH24  string   `json:"fsh24" yaml:"fsh24"`
+	Files  []string `json:"files" yaml:"files"`
+	Wasted int64    `json:"wasted" yaml:"wasted"`                     // Size of every copy but one
+	Linked []string `json:"linked,omitempty" yaml:"linked,omitempty"` // Copies --dedupe replaced with Files[0]
+}
+
+// dupesOutput is the report printed by --find-dupes with -j and the other report formats.
+type dupesOutput struct {
+	Sets        []dupeSet `json:"sets" yaml:"sets"`
+	Files       int       `json:"files" yaml:"files"` // Files in all sets
+	TotalWasted int64     `json:"total_wasted" yaml:"total_wasted"`
+	Confirmed   bool      `json:"confirmed" yaml:"confirmed"`               // Checked with a full SHA-256
+	Dedupe      string    `json:"dedupe,omitempty" yaml:"dedupe,omitempty"` // hardlink or reflink
+	DryRun      bool      `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	Freed       int64     `json:"freed,omitempty" yaml:"freed,omitempty"` // Space --dedupe gave back, or would have with DryRun
+}
+
+// What --dedupe replaces the copies with.
+const (
+	dedupeHardlink = "hardlink" // Same file under more than one name
+	dedupeReflink  = "reflink"  // Own file sharing the blocks, copy on write
+)
+
+var dedupeModes = []string{dedupeHardlink, dedupeReflink}
+
+// groupDupes groups hash results by size and FSH24. Empty files are left out,
+// they are all the same and take no space. With confirm every candidate is
+// read in full for a SHA-256, so files that only match in the sampled parts
+// don't get reported.
+func groupDupes(ctx context.Context, results []fsh24.FileHashResult, confirm bool) (dupesOutput, error) {
+	type key struct {
+		size int64
+		hash string
+	}
+	groups := map[key][]string{}
+	for _, r := range results {
+		if r.FileSize == 0 {
+			continue
+		}
+		k := key{r.FileSize, strings.ToUpper(r.FSH24)}
+		groups[k] = append(groups[k], r.Filepath)
+	}
+
+	out := dupesOutput{Confirmed: confirm}
+	for k, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		sets := [][]string{files}
+		if confirm {
+			var err error
+			if sets, err = splitBySHA256(ctx, files); err != nil {
+				return out, err
+			}
+		}
+		for _, set := range sets {
+			if len(set) < 2 {
+				continue
+			}
+			sort.Strings(set)
+			wasted := k.size * int64(len(set)-1)
+			out.Sets = append(out.Sets, dupeSet{Size: k.size, FSH24: k.hash, Files: set, Wasted: wasted})
+			out.Files += len(set)
+			out.TotalWasted += wasted
+		}
+	}
+
+	// Biggest waste first, that's what you want to clean up
+	sort.Slice(out.Sets, func(i, j int) bool {
+		if out.Sets[i].Wasted != out.Sets[j].Wasted {
+			return out.Sets[i].Wasted > out.Sets[j].Wasted
+		}
+		return out.Sets[i].Files[0] < out.Sets[j].Files[0]
+	})
+	return out, nil
+}
+
+// splitBySHA256 splits files that look the same by their full SHA-256.
+// Files that can't be read are warned about and left out.
+func splitBySHA256(ctx context.Context, files []string) ([][]string, error) {
+	bySum := map[string][]string{}
+	var order []string
+	for _, f := range files {
+		sum, err := fsh24.SumSHA256(ctx, f)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			warnf(f, "Could not confirm %s: %v", f, err)
+			continue
+		}
+		if _, ok := bySum[sum]; !ok {
+			order = append(order, sum)
+		}
+		bySum[sum] = append(bySum[sum], f)
+	}
+	sets := make([][]string, len(order))
+	for i, sum := range order {
+		sets[i] = bySum[sum]
+	}
+	return sets, nil
+}
+
+// dedupeSets replaces every copy in out's sets with a link to the first file of
+// the set, going by out.Dedupe, and fills in Linked and Freed. With dryRun
+// nothing is touched, it's what would be done. The copy is linked under a
+// temporary name next to it and renamed over it, so it's never half gone.
+// It returns how many copies couldn't be replaced.
+func dedupeSets(out *dupesOutput, quiet bool) (failed int) {
+	verb := "Linked"
+	if out.DryRun {
+		verb = "Would link"
+	}
+	for i := range out.Sets {
+		set := &out.Sets[i]
+		keep := set.Files[0]
+		if fsh24.IsRemote(keep) || fsh24.IsArchiveMember(keep) || fsh24.IsDevice(keep) {
+			warnf(keep, "Can't dedupe the copies of %s, only local files can be linked", keep)
+			failed += len(set.Files) - 1
+			continue
+		}
+		keepInfo, err := os.Stat(keep)
+		if err != nil {
+			warnf(keep, "Can't dedupe the copies of %s: %v", keep, err)
+			failed += len(set.Files) - 1
+			continue
+		}
+		for _, f := range set.Files[1:] {
+			info, err := os.Lstat(f)
+			switch {
+			case err != nil:
+				warnf(f, "Can't link %s: %v", f, err)
+				failed++
+				continue
+			case !info.Mode().IsRegular():
+				warnf(f, "Can't link %s, it's not a plain file", f)
+				failed++
+				continue
+			case os.SameFile(keepInfo, info):
+				continue // Already a hard link to it, nothing to free
+			case info.Size() != set.Size:
+				warnf(f, "%s changed size since it was hashed, left as it is", f)
+				failed++
+				continue
+			}
+			if !out.DryRun {
+				if err := linkCopy(keep, f, info, out.Dedupe); err != nil {
+					warnf(f, "Could not link %s: %v", f, err)
+					failed++
+					continue
+				}
+			}
+			set.Linked = append(set.Linked, f)
+			out.Freed += set.Size
+			if !quiet {
+				fmt.Println(colorize(colorGreen, fmt.Sprintf("%s: %s -> %s", verb, f, keep)))
+			}
+		}
+	}
+	return failed
+}
+
+// linkCopy replaces the file copy with a hard link to or a reflink of keep.
+// A reflink is a file of its own, it keeps the permissions and modified time
+// of the copy. A hard link is keep, it has keep's.
+func linkCopy(keep, copy string, info os.FileInfo, mode string) error {
+	tmp := filepath.Join(filepath.Dir(copy), "."+filepath.Base(copy)+".fsh24-dedupe")
+	if mode == dedupeReflink {
+		if err := reflink(keep, tmp); err != nil {
+			return err
+		}
+		os.Chmod(tmp, info.Mode().Perm())
+		os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	} else if err := os.Link(keep, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, copy); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// printDupes prints the duplicate sets to the console.
+func printDupes(out dupesOutput) {
+	for _, set := range out.Sets {
+		fmt.Println(colorize(colorYellow, fmt.Sprintf(
+			"%d copies of %s (%s wasted):", len(set.Files), formatBytes(set.Size), formatBytes(set.Wasted),
+		)))
+		for _, f := range set.Files {
+			fmt.Printf("  %s\n", f)
+		}
+		fmt.Println()
+	}
+
+	if len(out.Sets) == 0 {
+		fmt.Println("No duplicates found")
+		return
+	}
+	confirmed := ""
+	if !out.Confirmed {
+		confirmed = " (by sampled hash, --confirm-dupes to check every byte)"
+	}
+	fmt.Printf(
+		"Found %d sets of duplicates, %d files, %s wasted%s\n",
+		len(out.Sets), out.Files, formatBytes(out.TotalWasted), confirmed,
+	)
+	if out.Dedupe == "" {
+		return
+	}
+	linked := 0
+	for _, set := range out.Sets {
+		linked += len(set.Linked)
+	}
+	if out.DryRun {
+		fmt.Printf("Would %s %d copies, freeing %s. Nothing was changed, run it again without --dry-run to do it\n", out.Dedupe, linked, formatBytes(out.Freed))
+	} else {
+		fmt.Printf("Replaced %d copies with %ss, freed %s\n", linked, out.Dedupe, formatBytes(out.Freed))
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// Forensic export formats for --format. Like the report formats they are
+// printed (or saved with -o) instead of a .fsh24 file, but only hashing has
+// them, there's nothing forensic tools want from a verify or cmp.
+const (
+	reportHashdeep = "hashdeep" // hashdeep's known file layout
+	reportDFXML    = "dfxml"    // Digital Forensics XML, the way hashdeep -d writes it
+)
+
+var exportFormats = []string{reportHashdeep, reportDFXML}
+
+// hashdeepReport writes the results in the layout hashdeep uses for its
+// known files, a %%%% header naming the columns then one line per file:
+//
+//	%%%% HASHDEEP-1.0
+//	%%%% size,fsh24,sha256,filename
+//	## Invoked from: /home/me
+//	## $ fsh24 --format hashdeep -r photos
+//	##
+//	5000000,8F3208...,811A06...,photos/a.jpg
+//
+// The sha256 column is only there if the files were hashed with --sha256.
+func hashdeepReport(summary fsh24.TotalHashSummary) []byte {
+	withSHA256 := len(summary.Files) > 0
+	for _, r := range summary.Files {
+		withSHA256 = withSHA256 && r.SHA256 != ""
+	}
+	columns := "size,fsh24,"
+	if withSHA256 {
+		columns += "sha256,"
+	}
+
+	var buf bytes.Buffer
+	cwd, _ := os.Getwd()
+	fmt.Fprintf(&buf, "%%%%%%%% HASHDEEP-1.0\n%%%%%%%% %sfilename\n", columns)
+	fmt.Fprintf(&buf, "## Invoked from: %s\n## $ %s\n##\n", cwd, commandLine())
+	for _, r := range summary.Files {
+		line := strconv.FormatInt(r.FileSize, 10) + "," + strings.ToLower(r.FSH24) + ","
+		if withSHA256 {
+			line += strings.ToLower(r.SHA256) + ","
+		}
+		buf.WriteString(line + r.Filepath + "\n")
+	}
+	return buf.Bytes()
+}
+
+// DFXML, only the parts a hash list needs.
+type (
+	dfxmlDocument struct {
+		XMLName  xml.Name     `xml:"dfxml"`
+		Version  string       `xml:"xmloutputversion,attr"`
+		Xmlns    string       `xml:"xmlns,attr"`
+		XmlnsDC  string       `xml:"xmlns:dc,attr"`
+		Type     string       `xml:"metadata>dc:type"`
+		Creator  dfxmlCreator `xml:"creator"`
+		Files    []dfxmlFile  `xml:"fileobject"`
+		RunStats dfxmlStats   `xml:"rusage"`
+	}
+	dfxmlCreator struct {
+		Program     string `xml:"program"`
+		CommandLine string `xml:"execution_environment>command_line"`
+		StartTime   string `xml:"execution_environment>start_time"`
+		Algorithm   string `xml:"execution_environment>fsh24_algorithm"`
+		SampleSize  int    `xml:"execution_environment>fsh24_sample_size"`
+		Full        bool   `xml:"execution_environment>fsh24_full,omitempty"`
+	}
+	dfxmlFile struct {
+		Filename string        `xml:"filename"`
+		Filesize int64         `xml:"filesize"`
+		Mtime    string        `xml:"mtime,omitempty"`
+		Hashes   []dfxmlDigest `xml:"hashdigest"`
+	}
+	dfxmlDigest struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	}
+	dfxmlStats struct {
+		Seconds float64 `xml:"clocktime"`
+	}
+)
+
+// dfxmlReport writes the results as DFXML, a <fileobject> per file with its
+// size, mtime and a <hashdigest type="FSH24">, plus SHA256 and CRC32 if they
+// were worked out too. The FSH24 settings go in <execution_environment>,
+// without them the hashes can't be checked again.
+func dfxmlReport(summary fsh24.TotalHashSummary) ([]byte, error) {
+	doc := dfxmlDocument{
+		Version: "1.0",
+		Xmlns:   "http://www.forensicswiki.org/wiki/Category:Digital_Forensics_XML",
+		XmlnsDC: "http://purl.org/dc/elements/1.1/",
+		Type:    "Hash List",
+		Creator: dfxmlCreator{
+			Program:     "fsh24",
+			CommandLine: commandLine(),
+			StartTime:   time.Now().UTC().Format(time.RFC3339),
+			Algorithm:   summary.Algorithm,
+			SampleSize:  summary.SampleSize,
+			Full:        summary.Full,
+		},
+		RunStats: dfxmlStats{Seconds: summary.TotalProcessingTime},
+	}
+	for _, r := range summary.Files {
+		f := dfxmlFile{
+			Filename: r.Filepath,
+			Filesize: r.FileSize,
+			Hashes:   []dfxmlDigest{{"FSH24", strings.ToLower(r.FSH24)}},
+		}
+		if !r.ModTime.IsZero() {
+			f.Mtime = r.ModTime.UTC().Format(time.RFC3339)
+		}
+		if r.SHA256 != "" {
+			f.Hashes = append(f.Hashes, dfxmlDigest{"SHA256", strings.ToLower(r.SHA256)})
+		}
+		if r.CRC32 != "" {
+			f.Hashes = append(f.Hashes, dfxmlDigest{"CRC32", strings.ToLower(r.CRC32)})
+		}
+		doc.Files = append(doc.Files, f)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// commandLine is how fsh24 was started, for the export headers.
+func commandLine() string {
+	return "fsh24 " + strings.Join(os.Args[1:], " ")
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"fsh24/pkg/fsh24"
+	"fsh24/pkg/fsh24pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer is the gRPC API of serve, see pkg/fsh24pb/fsh24.proto.
+type grpcServer struct {
+	fsh24pb.UnimplementedFSH24Server
+
+	hasher *fsh24.Hasher
+	walk   walkOptions
+}
+
+// serveGRPC runs the gRPC API on listen until ctx is cancelled.
+func serveGRPC(ctx context.Context, listen string, hasher *fsh24.Hasher, walk walkOptions) error {
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.StreamInterceptor(logStreams))
+	fsh24pb.RegisterFSH24Server(srv, &grpcServer{hasher: hasher, walk: walk})
+	go func() {
+		<-ctx.Done()
+		srv.Stop() // Cancels the calls still running, they could take hours
+	}()
+
+	fmt.Printf("gRPC listening on %s\n", listen)
+	return srv.Serve(lis)
+}
+
+// Hash hashes files and folders on the server, sending every file as it's done.
+func (g *grpcServer) Hash(req *fsh24pb.HashRequest, stream grpc.ServerStreamingServer[fsh24pb.HashResponse]) error {
+	if len(req.Paths) == 0 {
+		return status.Error(codes.InvalidArgument, "paths needs at least one file or folder")
+	}
+	walk := g.walk
+	walk.Recursive = walk.Recursive || req.Recursive
+	files, err := expandFilePaths(req.Paths, walk)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if len(files) == 0 {
+		return status.Error(codes.NotFound, "no files found to hash")
+	}
+
+	ctx := stream.Context()
+	total := int32(len(files))
+	done := int32(0)
+	var sendErr error
+	hasher := *g.hasher
+	hasher.OnResult = func(r fsh24.FileHashResult) { // Called one at a time
+		done++
+		if sendErr != nil {
+			return
+		}
+		sendErr = stream.Send(&fsh24pb.HashResponse{Done: done, Total: total, Result: &fsh24pb.HashResponse_File{
+			File: &fsh24pb.FileHash{
+				Path:           r.Filepath,
+				Size:           r.FileSize,
+				Fsh24:          r.FSH24,
+				Chunks:         int32(r.Chunks),
+				Sha256:         r.SHA256,
+				Crc32:          r.CRC32,
+				ProcessingTime: r.ProcessingTime,
+				MtimeUnixNano:  r.ModTime.UnixNano(),
+			},
+		}})
+	}
+
+	startTime := time.Now()
+	stats.jobs(1)
+	results, errs := hasher.HashFiles(ctx, files)
+	stats.jobs(-1)
+	stats.addHashed(&hasher, results, len(errs))
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	for _, err := range errs {
+		done++
+		fe := err.(*fsh24.FileError)
+		if err := stream.Send(&fsh24pb.HashResponse{Done: done, Total: total, Result: &fsh24pb.HashResponse_Error{
+			Error: &fsh24pb.FileError{Path: fe.Path, Error: fe.Err.Error()},
+		}}); err != nil {
+			return err
+		}
+	}
+	sampleSize := hasher.SampleSize
+	if sampleSize == 0 {
+		sampleSize = fsh24.SampleSize
+	}
+	return stream.Send(&fsh24pb.HashResponse{Done: done, Total: total, Result: &fsh24pb.HashResponse_Summary{
+		Summary: &fsh24pb.HashSummary{
+			Algorithm:  hasher.Algorithm,
+			SampleSize: int32(sampleSize),
+			Full:       hasher.Full,
+			Hashed:     int32(len(results)),
+			Failed:     int32(len(errs)),
+			TotalTime:  time.Since(startTime).Seconds(),
+		},
+	}})
+}
+
+// Verify checks the files in the hash file sent, sending every file as it's done.
+func (g *grpcServer) Verify(req *fsh24pb.VerifyRequest, stream grpc.ServerStreamingServer[fsh24pb.VerifyResponse]) error {
+	m, err := fsh24.ParseManifest(bytes.NewReader(req.HashFile))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "bad hash file: %v", err)
+	}
+	baseDir := req.BaseDir
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	ctx := stream.Context()
+	total := int32(len(m.Entries) + len(m.Invalid))
+	done := int32(0)
+	var sendErr error
+	send := func(r fsh24.FileVerificationResult) {
+		done++
+		if sendErr != nil {
+			return
+		}
+		sendErr = stream.Send(&fsh24pb.VerifyResponse{Done: done, Total: total, Result: &fsh24pb.VerifyResponse_File{
+			File: &fsh24pb.FileVerification{
+				Path:           r.Filepath,
+				Status:         r.Status,
+				ExpectedSize:   r.ExpectedSize,
+				ActualSize:     r.ActualSize,
+				ExpectedHash:   r.ExpectedHash,
+				ActualHash:     r.ActualHash,
+				ProcessingTime: r.ProcessingTime,
+			},
+		}})
+	}
+	for _, inv := range m.Invalid {
+		send(fsh24.FileVerificationResult{Status: inv.Status})
+	}
+
+	verifier := &fsh24.Verifier{
+		Hasher:   g.hasher,
+		FailFast: req.FailFast,
+		OnResult: func(e fsh24.Entry, r fsh24.FileVerificationResult) { send(r) }, // One at a time
+	}
+	stats.jobs(1)
+	summary, results, err := verifier.Verify(ctx, m, baseDir)
+	stats.jobs(-1)
+	stats.addVerified(results)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return stream.Send(&fsh24pb.VerifyResponse{Done: done, Total: total, Result: &fsh24pb.VerifyResponse_Summary{
+		Summary: &fsh24pb.VerifySummary{
+			Verified:        int32(summary.Verified),
+			Failed:          int32(summary.Failed),
+			Total:           int32(summary.Total),
+			Success:         summary.Success,
+			TotalTime:       summary.TotalTime,
+			TotalSize:       summary.TotalSize,
+			TotalHashedSize: summary.TotalHashedSize,
+		},
+	}})
+}
+
+// logStreams prints a timestamped line for every call, like logRequests.
+func logStreams(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	fmt.Printf("%s gRPC %s\n", time.Now().Format(logTime), info.FullMethod)
+	return handler(srv, ss)
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// guiPage is the whole GUI, one page with the drop zone, progress bars and
+// results tables.
+//
+//go:embed gui.html
+var guiPage []byte
+
+// guiByDefault opens the GUI when fsh24 is started with no files, like a
+// double click on the exe. Builds with -tags gui set it, see gui_build.go.
+var guiByDefault bool
+
+// guiListen is where the GUI listens without --listen, any free port on
+// this machine.
+const guiListen = "localhost:0"
+
+// dropScheme is the URL scheme of the files dropped on the GUI page. The
+// browser doesn't say where a dropped file is, only what it's called, so
+// they're read through the page: drop://3/folder/file.iso is file.iso of
+// the third drop, and reading it asks the page for just those bytes.
+const dropScheme = "drop"
+
+// gui is the browser GUI of fsh24 gui. Files dropped on the page are hashed
+// where they are, the page sends over the sampled bytes and nothing else,
+// so checking a 50GB download reads a few MB of it.
+type gui struct {
+	ctx    context.Context
+	hasher *fsh24.Hasher
+
+	mu     sync.Mutex
+	drops  map[string]*drop
+	nextID int
+}
+
+// drop is a lot of files dropped on the page at once.
+type drop struct {
+	id     string
+	files  map[string]droppedFile // By slash separated path, folder/file.iso
+	events chan any               // To the page, closed when the drop is done
+	ctx    context.Context
+	cancel context.CancelFunc
+	start  sync.Once
+
+	mu       sync.Mutex
+	reads    map[int]chan []byte // Reads waiting for the page to send the bytes
+	nextRead int
+}
+
+// droppedFile is what the page says about a file that was dropped on it.
+type droppedFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Modified int64  `json:"modified"` // Unix milliseconds, what browsers have
+}
+
+// Events sent to the page, each as {"type": ..., ...}.
+type (
+	// readEvent asks for bytes of a file, sent back with POST /drops/{id}/reads/{read}.
+	readEvent struct {
+		Type string `json:"type"` // read
+		Read int    `json:"read"`
+		Path string `json:"path"`
+		Off  int64  `json:"off"`
+		N    int64  `json:"n"`
+	}
+	// startEvent starts a table, a hash file being verified or the dropped
+	// files being hashed.
+	startEvent struct {
+		Type  string `json:"type"` // start
+		Kind  string `json:"kind"` // hash or verify
+		Name  string `json:"name"` // The hash file, for verify
+		Files int    `json:"files"`
+	}
+	// fileEvent is a file done.
+	fileEvent struct {
+		Type   string `json:"type"` // file
+		Path   string `json:"path"`
+		Size   int64  `json:"size"`
+		Status string `json:"status"` // A fsh24.Status*, or hashed
+		Hash   string `json:"hash,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+	// endEvent ends a table. A hash table comes with the hash file for it.
+	endEvent struct {
+		Type     string                     `json:"type"` // end
+		Summary  *fsh24.VerificationSummary `json:"summary,omitempty"`
+		HashFile string                     `json:"hash_file,omitempty"`
+		Error    string                     `json:"error,omitempty"`
+	}
+)
+
+// runGUI serves the GUI on listen and opens it in the browser, until ctx is
+// cancelled.
+func runGUI(ctx context.Context, listen string, hasher *fsh24.Hasher) error {
+	g := &gui{ctx: ctx, hasher: hasher, drops: map[string]*drop{}}
+	fsh24.RegisterScheme(dropScheme, g)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(guiPage)
+	})
+	mux.HandleFunc("POST /drops", g.handleDrop)
+	mux.HandleFunc("GET /drops/{id}/events", g.handleEvents)
+	mux.HandleFunc("POST /drops/{id}/reads/{read}", g.handleRead)
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	url := "http://" + ln.Addr().String() + "/"
+	fmt.Printf("The GUI is at %s, open it in your browser if it didn't pop up. Ctrl+C to stop\n", url)
+	if err := openBrowser(url); err != nil {
+		warnf("", "Could not open a browser: %v", err)
+	}
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// openBrowser opens url in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// handleDrop takes the list of files dropped on the page, as a JSON list
+// of droppedFile, and answers with the ID of the drop. The work starts when
+// the page listens to its events.
+func (g *gui) handleDrop(w http.ResponseWriter, r *http.Request) {
+	var files []droppedFile
+	if err := json.NewDecoder(r.Body).Decode(&files); err != nil {
+		httpError(w, http.StatusBadRequest, "bad request: %v", err)
+		return
+	}
+	if len(files) == 0 {
+		httpError(w, http.StatusBadRequest, "no files dropped")
+		return
+	}
+	ctx, cancel := context.WithCancel(g.ctx)
+	d := &drop{files: map[string]droppedFile{}, events: make(chan any), ctx: ctx, cancel: cancel, reads: map[int]chan []byte{}}
+	for _, f := range files {
+		f.Path = path.Clean(strings.TrimPrefix(f.Path, "/"))
+		d.files[f.Path] = f
+	}
+
+	g.mu.Lock()
+	g.nextID++
+	d.id = strconv.Itoa(g.nextID)
+	g.drops[d.id] = d
+	g.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"id": d.id})
+}
+
+// handleEvents runs the drop and streams its events to the page as
+// server-sent events. Closing the page cancels it.
+func (g *gui) handleEvents(w http.ResponseWriter, r *http.Request) {
+	d := g.drop(w, r)
+	if d == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, "can't stream events")
+		return
+	}
+	started := false
+	d.start.Do(func() {
+		started = true
+		go g.run(d)
+	})
+	if !started {
+		httpError(w, http.StatusConflict, "drop %s is already running", d.id)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		select {
+		case ev, ok := <-d.events:
+			if !ok {
+				fmt.Fprint(w, "data: {\"type\":\"done\"}\n\n")
+				flusher.Flush()
+				g.forget(d)
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			d.cancel() // Nobody's looking any more
+			for range d.events {
+			}
+			g.forget(d)
+			return
+		}
+	}
+}
+
+// handleRead takes the bytes the page sent for a read.
+func (g *gui) handleRead(w http.ResponseWriter, r *http.Request) {
+	d := g.drop(w, r)
+	if d == nil {
+		return
+	}
+	id, _ := strconv.Atoi(r.PathValue("read"))
+	d.mu.Lock()
+	reply := d.reads[id]
+	delete(d.reads, id)
+	d.mu.Unlock()
+	if reply == nil {
+		httpError(w, http.StatusNotFound, "no read %s", r.PathValue("read"))
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		data = nil // Read as nothing, the hash gets a read error
+	}
+	reply <- data
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// drop looks up the drop in the URL, answering 404 if there's no such drop.
+func (g *gui) drop(w http.ResponseWriter, r *http.Request) *drop {
+	g.mu.Lock()
+	d := g.drops[r.PathValue("id")]
+	g.mu.Unlock()
+	if d == nil {
+		httpError(w, http.StatusNotFound, "no drop %s", r.PathValue("id"))
+	}
+	return d
+}
+
+// forget drops d once it's done, its files can't be read any more.
+func (g *gui) forget(d *drop) {
+	g.mu.Lock()
+	delete(g.drops, d.id)
+	g.mu.Unlock()
+}
+
+// run verifies the hash files in the drop against the files dropped with
+// them, or hashes the files if there are none, sending the results to the
+// page as it goes.
+func (g *gui) run(d *drop) {
+	defer close(d.events)
+	defer d.cancel()
+
+	var hashFiles, files []string
+	for p := range d.files {
+		name := strings.ToLower(path.Base(p))
+		if strings.HasSuffix(name, ".fsh24") || strings.HasSuffix(name, ".fsh24.gz") || strings.HasSuffix(name, ".sfv") || fsh24.IsChecksumFile(name) {
+			hashFiles = append(hashFiles, p)
+		} else {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(hashFiles)
+	sort.Strings(files)
+
+	if len(hashFiles) == 0 {
+		g.hash(d, files)
+		return
+	}
+	for _, p := range hashFiles {
+		if d.ctx.Err() != nil {
+			return
+		}
+		g.verify(d, p)
+	}
+}
+
+// verify checks the files of the hash file at p, relative paths going by
+// where it is in the drop.
+func (g *gui) verify(d *drop, p string) {
+	m, err := readDroppedManifest(d.ctx, d.url(p))
+	if err != nil {
+		d.send(startEvent{Type: "start", Kind: "verify", Name: p})
+		d.send(endEvent{Type: "end", Error: err.Error()})
+		return
+	}
+	d.send(startEvent{Type: "start", Kind: "verify", Name: p, Files: len(m.Entries) + len(m.Invalid)})
+
+	// Whoever made the hash file picked its paths, not the one who dropped
+	// it. A /home/user/.ssh/id_ed25519 or http://192.168.0.1/ line isn't
+	// read, the page would get its hash, only what's in the drop is
+	outside := outsideDrop(m)
+	for _, r := range outside {
+		d.send(fileEvent{Type: "file", Path: r.Filepath, Size: r.ExpectedSize, Status: r.Status, Error: "not in the drop, not checked"})
+	}
+
+	verifier := &fsh24.Verifier{Hasher: g.hasher, Rebase: true, OnResult: func(e fsh24.Entry, r fsh24.FileVerificationResult) {
+		d.send(fileEvent{Type: "file", Path: d.rel(r.Filepath), Size: e.Size, Status: r.Status, Hash: r.ActualHash})
+	}}
+	summary, results, err := verifier.Verify(d.ctx, m, d.url(path.Dir(p)))
+	for _, r := range results {
+		if r.Filepath == "" { // Broken lines don't go through OnResult
+			d.send(fileEvent{Type: "file", Status: r.Status})
+		}
+	}
+	if err != nil {
+		d.send(endEvent{Type: "end", Error: err.Error()})
+		return
+	}
+	if len(outside) > 0 {
+		summary = fsh24.Summarize(append(results, outside...), summary.TotalTime)
+	}
+	d.send(endEvent{Type: "end", Summary: &summary})
+}
+
+// outsideDrop takes the entries of m with an absolute path or a URL out of
+// it, they're somewhere on this machine or network and not in the drop. It
+// returns them as missing.
+func outsideDrop(m *fsh24.Manifest) []fsh24.FileVerificationResult {
+	var outside []fsh24.FileVerificationResult
+	entries := m.Entries[:0]
+	for _, e := range m.Entries {
+		if !filepath.IsAbs(e.Path) && !fsh24.IsRemote(e.Path) {
+			entries = append(entries, e)
+			continue
+		}
+		outside = append(outside, fsh24.FileVerificationResult{
+			Filepath:     e.Path,
+			Filename:     path.Base(filepath.ToSlash(e.Path)),
+			ExpectedHash: e.Hash,
+			ExpectedSize: e.Size,
+			Status:       fsh24.StatusMissing,
+		})
+	}
+	m.Entries = entries
+	return outside
+}
+
+// readDroppedManifest reads the hash file at url, one that was dropped.
+func readDroppedManifest(ctx context.Context, url string) (*fsh24.Manifest, error) {
+	f, err := fsh24.Open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := fsh24.ParseManifest(io.NewSectionReader(f, 0, f.Size()))
+	if err != nil {
+		return nil, err
+	}
+	m.DetectChecksums(path.Base(url))
+	return m, nil
+}
+
+// hash hashes the files of the drop and makes a hash file of them, paths
+// relative to the top of the drop.
+func (g *gui) hash(d *drop, files []string) {
+	d.send(startEvent{Type: "start", Kind: "hash", Files: len(files)})
+	hasher := *g.hasher
+	hasher.OnResult = func(r fsh24.FileHashResult) {
+		d.send(fileEvent{Type: "file", Path: d.rel(r.Filepath), Size: r.FileSize, Status: "hashed", Hash: strings.ToUpper(r.FSH24)})
+	}
+	urls := make([]string, len(files))
+	for i, p := range files {
+		urls[i] = d.url(p)
+	}
+	results, errs := hasher.HashFiles(d.ctx, urls)
+	for _, err := range errs {
+		fe := err.(*fsh24.FileError)
+		d.send(fileEvent{Type: "file", Path: d.rel(fe.Path), Status: fsh24.StatusHashError, Error: fe.Err.Error()})
+	}
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	m := newManifest(&hasher, fsh24.FormatFSH24)
+	for _, r := range results {
+		r.Filepath = d.rel(r.Filepath)
+		m.Add(r, "")
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		d.send(endEvent{Type: "end", Error: err.Error()})
+		return
+	}
+	d.send(endEvent{Type: "end", HashFile: buf.String()})
+}
+
+// url is the drop:// URL of the dropped file at p.
+func (d *drop) url(p string) string {
+	if p == "." {
+		p = "" // The top of the drop
+	}
+	return dropScheme + "://" + d.id + "/" + p
+}
+
+// rel is the path in the drop of url, as it was dropped.
+func (d *drop) rel(url string) string {
+	return strings.TrimPrefix(url, dropScheme+"://"+d.id+"/")
+}
+
+// send sends ev to the page, unless the drop was cancelled.
+func (d *drop) send(ev any) {
+	select {
+	case d.events <- ev:
+	case <-d.ctx.Done():
+	}
+}
+
+// read asks the page for n bytes of the file at p from off, and waits for them.
+func (d *drop) read(p string, off, n int64) ([]byte, error) {
+	reply := make(chan []byte, 1)
+	d.mu.Lock()
+	d.nextRead++
+	id := d.nextRead
+	d.reads[id] = reply
+	d.mu.Unlock()
+
+	d.send(readEvent{Type: "read", Read: id, Path: p, Off: off, N: n})
+	select {
+	case data := <-reply:
+		return data, nil
+	case <-d.ctx.Done():
+		return nil, d.ctx.Err()
+	}
+}
+
+// Open opens a dropped file, url being drop://{drop}/{path}.
+func (g *gui) Open(ctx context.Context, url string) (fsh24.File, error) {
+	rest := strings.TrimPrefix(url, dropScheme+"://")
+	id, p, _ := strings.Cut(rest, "/")
+	g.mu.Lock()
+	d := g.drops[id]
+	g.mu.Unlock()
+	if d == nil {
+		return nil, &fs.PathError{Op: "open", Path: url, Err: fs.ErrNotExist}
+	}
+	f, ok := d.files[path.Clean(p)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: url, Err: fs.ErrNotExist}
+	}
+	return &dropFile{drop: d, droppedFile: f}, nil
+}
+
+// dropFile is a dropped file, read through the page.
+type dropFile struct {
+	drop *drop
+	droppedFile
+}
+
+func (f *dropFile) Size() int64 { return f.droppedFile.Size }
+
+func (f *dropFile) ModTime() time.Time {
+	if f.Modified == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(f.Modified)
+}
+
+func (f *dropFile) Close() error { return nil }
+
+func (f *dropFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.droppedFile.Size {
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), f.droppedFile.Size-off)
+	data, err := f.drop.read(f.Path, off, want)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if int64(n) < want {
+		return n, fmt.Errorf("%s: got %d bytes of %d from the browser, did it change?", f.Path, n, want)
+	} else if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// isGUIArg reports whether args ask for the GUI, fsh24 gui or nothing at all
+// in a -tags gui build.
+func isGUIArg(args []string) bool {
+	return len(args) > 0 && args[0] == "gui" || len(args) == 0 && guiByDefault
+}
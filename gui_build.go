@@ -0,0 +1,9 @@
+//go:build gui
+
+package main
+
+// A -tags gui build opens the GUI when started with no files, so double
+// clicking it gets the drop zone instead of the usage line.
+func init() {
+	guiByDefault = true
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fsh24/pkg/fsh24"
+)
+
+// A dropped hash file can't point the GUI at files outside the drop.
+func TestOutsideDrop(t *testing.T) {
+	abs, err := filepath.Abs(filepath.Join("home", "user", ".ssh", "id_ed25519"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.Repeat("0", 48)
+	m, err := fsh24.ParseManifest(strings.NewReader("FSH24-1\n" +
+		hash + "|1|10|folder/file.iso\n" +
+		hash + "|1|10|" + abs + "\n" +
+		hash + "|1|10|http://192.168.0.1/admin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := outsideDrop(m)
+	if len(m.Entries) != 1 || m.Entries[0].Path != "folder/file.iso" {
+		t.Fatalf("left %+v to verify, want just folder/file.iso", m.Entries)
+	}
+	if len(outside) != 2 {
+		t.Fatalf("%d outside the drop, want 2", len(outside))
+	}
+	for _, r := range outside {
+		if r.Status != fsh24.StatusMissing {
+			t.Errorf("%s is %s, want %s", r.Filepath, r.Status, fsh24.StatusMissing)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// isHidden reports whether a folder entry is a dotfile.
+func isHidden(entry fs.DirEntry) bool {
+	return strings.HasPrefix(entry.Name(), ".")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	onFail     string // --on-fail, or --on-failure
+	onComplete string // --on-complete
+
+	runHashFile string // The hash file or database the run wrote or checked, for the hooks
+)
+
+// shellCommand runs line through the shell, cmd on Windows, so hooks can
+// use pipes and the like.
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// runHooks runs --on-fail if the run that's about to exit with code went
+// wrong, then --on-complete whatever happened. They get the mail report
+// JSON on stdin. A hook that fails is an error on stderr, it doesn't change
+// the exit code. daemon runs --on-fail after each check instead, see
+// notify, the others that keep running don't run them at all.
+func runHooks(code int) {
+	switch run.Mode {
+	case "daemon", "serve", "watch", "gui":
+		return
+	}
+	type hook struct{ flag, line string }
+	var hooks []hook
+	if code != exitOK && onFail != "" {
+		hooks = append(hooks, hook{"--on-fail", onFail})
+	}
+	if onComplete != "" {
+		hooks = append(hooks, hook{"--on-complete", onComplete})
+	}
+
+	summary := run
+	summary.Mode = cmp.Or(run.Mode, "run")
+	summary.ExitCode = code
+	report := mailReport{Host: hostname(), Target: runTarget, Time: time.Now(), runSummary: run, Failures: runFailures}
+	for _, h := range hooks {
+		if err := runHook(h.line, summary, runTarget, runHashFile, runFailures, report); err != nil {
+			fmt.Fprintln(os.Stderr, colorizeStderr(colorRed, fmt.Sprintf("Error: %s command: %v", h.flag, err)))
+		}
+	}
+}
+
+// runHook runs line for a run, or daemon check, that ended like summary,
+// with stdin as JSON on its stdin and the rest in FSH24_ variables, the
+// same ones for both.
+func runHook(line string, summary runSummary, target, hashFile string, failures []daemonFailure, stdin any) error {
+	body, err := json.Marshal(stdin)
+	if err != nil {
+		return err
+	}
+	failedList, err := writeFailedList(failures)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(failedList)
+
+	result := "ok"
+	if summary.ExitCode != exitOK {
+		result = "failed"
+	}
+	cmd := shellCommand(line)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if structured {
+		cmd.Stdout = os.Stderr // stdout is for the report
+	}
+	cmd.Env = append(os.Environ(),
+		"FSH24_RESULT="+result,
+		"FSH24_EXIT_CODE="+strconv.Itoa(summary.ExitCode),
+		"FSH24_MODE="+summary.Mode,
+		"FSH24_TARGET="+target,
+		"FSH24_HASH_FILE="+hashFile,
+		"FSH24_TOTAL="+strconv.Itoa(summary.Total),
+		"FSH24_OK="+strconv.Itoa(summary.OK),
+		"FSH24_FAILED="+strconv.Itoa(summary.Failed),
+		"FSH24_WARNINGS="+strconv.Itoa(len(summary.Warnings)),
+		"FSH24_FAILED_LIST="+failedList,
+		"FSH24_ERROR="+summary.Error,
+	)
+	return cmd.Run()
+}
+
+// writeFailedList saves the files that failed, one path per line, to a temp
+// file for FSH24_FAILED_LIST. It's empty when nothing failed.
+func writeFailedList(failures []daemonFailure) (string, error) {
+	f, err := os.CreateTemp("", "fsh24-failed-*.txt")
+	if err != nil {
+		return "", err
+	}
+	var lines strings.Builder
+	for _, failure := range failures {
+		lines.WriteString(failure.Path + "\n")
+	}
+	if _, err := f.WriteString(lines.String()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
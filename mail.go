@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// mailOnModes are the values --mail-on takes.
+var mailOnModes = []string{"failure", "always"}
+
+// mailTimeout is how long sending a report may take, a dead mail server
+// shouldn't keep a cron job hanging.
+const mailTimeout = time.Minute
+
+// mailOptions are the SMTP settings for mailing reports, see --mail-to.
+// The login comes from FSH24_SMTP_USER and FSH24_SMTP_PASSWORD, so the
+// password doesn't end up in the process list or a crontab.
+type mailOptions struct {
+	To     []string
+	From   string // Empty means fsh24@ this machine
+	SMTP   string // host:port, 465 is TLS from the start, anything else STARTTLS if offered
+	Always bool   // Mail every run, not just the ones that failed
+}
+
+var (
+	mail mailOptions // --mail-to and friends
+
+	runTarget   string          // What the run was about, for the mail
+	runFailures []daemonFailure // The files that failed verification, for the mail
+)
+
+// wants reports whether a run that failed (or didn't) gets mailed.
+func (m mailOptions) wants(failed bool) bool {
+	return len(m.To) > 0 && (failed || m.Always)
+}
+
+// mailReport is the JSON attached to the mail of a one off run.
+type mailReport struct {
+	Host   string    `json:"host"`
+	Target string    `json:"target"`
+	Time   time.Time `json:"time"`
+	runSummary
+	Failures []daemonFailure `json:"failures,omitempty"`
+}
+
+// mailRun mails the summary of the run that's about to exit with code.
+// Long running modes mail per check instead, see daemonCheck.
+func mailRun(code int) {
+	switch run.Mode {
+	case "daemon", "serve", "watch":
+		return
+	}
+	failed := code != exitOK
+	if !mail.wants(failed) {
+		return
+	}
+
+	report := mailReport{Host: hostname(), Target: runTarget, Time: time.Now(), runSummary: run, Failures: runFailures}
+	result := "OK"
+	if failed {
+		result = "FAILED"
+	}
+	subject := fmt.Sprintf("fsh24 %s of %s on %s: %s", cmp.Or(run.Mode, "run"), runTarget, report.Host, result)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "fsh24 %s of %s on %s finished %s: %s\n\n", cmp.Or(run.Mode, "run"), runTarget, report.Host, report.Time.Format(logTime), result)
+	fmt.Fprintf(&body, "Files: %d, OK: %d, failed: %d\nExit code: %d\n", run.Total, run.OK, run.Failed, code)
+	if run.Error != "" {
+		fmt.Fprintf(&body, "Error: %s\n", run.Error)
+	}
+	if len(runFailures) > 0 {
+		body.WriteString("\nFailed files:\n")
+		for _, f := range runFailures {
+			fmt.Fprintf(&body, "  %-14s %s\n", f.Status, f.Path)
+		}
+	}
+	if len(run.Warnings) > 0 {
+		body.WriteString("\nWarnings:\n")
+		for _, w := range run.Warnings {
+			fmt.Fprintf(&body, "  %s\n", w.Message)
+		}
+	}
+
+	if err := sendReport(mail, subject, body.String(), report); err != nil {
+		fmt.Fprintln(os.Stderr, colorizeStderr(colorRed, "Error: could not mail the report: "+err.Error()))
+	}
+}
+
+// mailCheck mails a finished daemon check, if m wants it.
+func mailCheck(m mailOptions, report daemonReport) error {
+	failed := report.Error != "" || report.Failed > 0
+	if !m.wants(failed) {
+		return nil
+	}
+	host := hostname()
+	result := "OK"
+	if failed {
+		result = "FAILED"
+	}
+	subject := fmt.Sprintf("fsh24 check of %s on %s: %s", report.HashFile, host, result)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "fsh24 daemon checked %s on %s at %s: %s\n\n", report.HashFile, host, report.Time.Format(logTime), result)
+	fmt.Fprintf(&body, "Verified: %d, failed: %d\n", report.Verified, report.Failed)
+	if report.Error != "" {
+		fmt.Fprintf(&body, "Error: %s\n", report.Error)
+	}
+	if len(report.Failures) > 0 {
+		body.WriteString("\nFailed files:\n")
+		for _, f := range report.Failures {
+			fmt.Fprintf(&body, "  %-14s %s\n", f.Status, f.Path)
+		}
+	}
+	return sendReport(m, subject, body.String(), report)
+}
+
+// sendReport mails body with report attached as fsh24-report.json.
+func sendReport(m mailOptions, subject, body string, report any) error {
+	attachment, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	from := cmp.Or(m.From, "fsh24@"+hostname())
+	msg, err := mailMessage(from, m.To, subject, body, "fsh24-report.json", attachment)
+	if err != nil {
+		return err
+	}
+	return sendMail(m.SMTP, from, m.To, msg)
+}
+
+// mailMessage builds a text mail with one JSON attachment.
+func mailMessage(from string, to []string, subject, body, name string, attachment []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(strings.ReplaceAll(body, "\n", "\r\n")))
+
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json; name=" + name},
+		"Content-Disposition":       {"attachment; filename=" + name},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, attachment)
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data as base64 in lines of 76, like mail wants.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(w, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(w, "%s\r\n", encoded)
+}
+
+// sendMail sends msg through the SMTP server at addr.
+func sendMail(addr, from string, to []string, msg []byte) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("--smtp %s: %w", addr, err)
+	}
+	dialer := &net.Dialer{Timeout: mailTimeout}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(mailTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if user := os.Getenv("FSH24_SMTP_USER"); user != "" {
+		// PlainAuth refuses to send the password without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", user, os.Getenv("FSH24_SMTP_PASSWORD"), host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("%s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// hostname is this machine's name, for telling the mails of several apart.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
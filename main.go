@@ -1,981 +1,2431 @@
-// Built with and for 
-// go version go1.24.4 windows/amd64
-
-// FSH24 - Fast Sample Hash 24-byte
-// Super fast integrity hash using strategic 4MB sampling
-// This go code is a port from the python code.
-
-// MobCat 2025
-
-package main
-
-import (
-	"encoding/hex"
-	"encoding/json"
-	"fmt"
-	"io"
-	"math"
-	"os"
-	"path/filepath" // Ensure this is imported for filepath.Base
-	"sort"
-	"strconv"
-	"strings"
-	"sync"
-	"time"
-
-	"github.com/spf13/pflag" // More powerful flag parsing than standard library
-	"golang.org/x/crypto/blake2b"
-)
-
-const (
-	sampleSize = 4 * 1024 * 1024 // 4MB
-)
-
-// Result struct for a single file's hash information
-type FileHashResult struct {
-	Filename        string  `json:"filename"`
-	Filepath        string  `json:"filepath"`
-	FileSize        int64   `json:"file_size"`
-	FSH24           string  `json:"fsh24"`
-	Chunks          int     `json:"chunks"`
-	CoveragePercent float64 `json:"coverage_percent"`
-	ProcessingTime  float64 `json:"processing_time"`
-}
-
-// VerificationResult struct for a single file's verification outcome
-type FileVerificationResult struct {
-	Filepath       string  `json:"filepath"`
-	Filename       string  `json:"filename"`
-	ExpectedHash   string  `json:"expected_hash"`
-	ExpectedSize   int64   `json:"expected_size"`
-	ActualSize     int64   `json:"actual_size,omitempty"`
-	ActualHash     string  `json:"actual_hash,omitempty"`
-	Status         string  `json:"status"`
-	ProcessingTime float64 `json:"processing_time,omitempty"`
-	HashedSize     int64   `json:"hashed_size,omitempty"`
-}
-
-// VerificationSummary struct for overall verification statistics
-type VerificationSummary struct {
-	Verified              int     `json:"verified"`
-	Failed                int     `json:"failed"`
-	Total                 int     `json:"total"`
-	Success               bool    `json:"success"`
-	TotalTime             float64 `json:"total_time"`
-	AverageTimePerFile    float64 `json:"average_time_per_file"`
-	TotalSize             int64   `json:"total_size"`
-	TotalHashedSize       int64   `json:"total_hashed_size"`
-	TotalHashedPercentage float64 `json:"total_hashed_percentage"`
-}
-
-// TotalHashSummary for the overall hashing process
-type TotalHashSummary struct {
-	Magic               string           `json:"magic"`
-	TotalFiles          int              `json:"total_files"`
-	TotalProcessingTime float64          `json:"total_processing_time"`
-	AverageTimePerFile  float64          `json:"average_time_per_file"`
-	Files               []FileHashResult `json:"files"`
-}
-
-// calculateOptimalChunks determines the number of middle chunks.
-func calculateOptimalChunks(fileSize int64, sampleSize int, targetCoverage float64) int {
-	fileSizeMB := float64(fileSize) / (1024 * 1024)
-
-	if fileSizeMB < 100 {
-		return 2
-	}
-
-	// Calculate total chunks needed to achieve at least target coverage
-	targetTotalChunksFloat := (targetCoverage * float64(fileSize)) / float64(sampleSize)
-	targetTotalChunks := int(math.Ceil(targetTotalChunksFloat))
-
-	// Ensure at least 4 total chunks
-	targetTotalChunks = max(4, targetTotalChunks)
-
-	middleChunks := targetTotalChunks - 2
-	middleChunks = max(2, middleChunks) // Ensure middle chunks is at least 2
-
-	return middleChunks
-}
-
-// fastSampleHash calculates a sampled BLAKE2b hash of a file.
-func fastSampleHash(filepath string, targetCoverage float64) (string, int, error) {
-	fileInfo, err := os.Stat(filepath)
-	if err != nil {
-		return "", 0, fmt.Errorf("could not get file info for %s: %w", filepath, err)
-	}
-	fileSize := fileInfo.Size()
-
-	middleChunks := calculateOptimalChunks(fileSize, sampleSize, targetCoverage)
-	totalChunks := middleChunks + 2 // first + middle + last
-
-	hasher, err := blake2b.New(24, nil)
-	if err != nil {
-		return "", 0, fmt.Errorf("failed to create blake2b hasher: %w", err)
-	}
-
-	f, err := os.Open(filepath)
-	if err != nil {
-		return "", 0, fmt.Errorf("failed to open file %s: %w", filepath, err)
-	}
-	defer f.Close()
-
-	buffer := make([]byte, sampleSize)
-
-	// Hash first chunk
-	n, err := f.Read(buffer)
-	if err != nil && err != io.EOF {
-		return "", 0, fmt.Errorf("failed to read first chunk of %s: %w", filepath, err)
-	}
-	hasher.Write(buffer[:n])
-
-	// Hash multiple middle chunks for better coverage
-	// Only apply if file is large enough to contain distinct middle chunks
-	if fileSize > int64(sampleSize)*int64(totalChunks) {
-		for i := 0; i < middleChunks; i++ {
-			// Distribute middle chunks evenly across the file
-			position := fileSize * int64(i+2) / int64(middleChunks+2)
-			_, err = f.Seek(position, io.SeekStart)
-			if err != nil {
-				return "", 0, fmt.Errorf("failed to seek to middle chunk in %s: %w", filepath, err)
-			}
-			n, err = f.Read(buffer)
-			if err != nil && err != io.EOF {
-				return "", 0, fmt.Errorf("failed to read middle chunk of %s: %w", filepath, err)
-			}
-			hasher.Write(buffer[:n])
-		}
-	}
-
-	// Hash last chunk (avoid overlap with middle chunks)
-	if fileSize > int64(sampleSize)*int64(totalChunks) {
-		// Seek to 4MB from the end, ensuring it's not before the start of the file
-		_, err = f.Seek(maxInt64(0, fileSize-int64(sampleSize)), io.SeekStart)
-		if err != nil {
-			return "", 0, fmt.Errorf("failed to seek to last chunk in %s: %w", filepath, err)
-		}
-		// Read to EOF, as the last chunk might be smaller than sampleSize
-		n, err = io.ReadFull(f, buffer)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-			return "", 0, fmt.Errorf("failed to read last chunk of %s: %w", filepath, err)
-		}
-		hasher.Write(buffer[:n])
-	}
-
-	// Include file size in hash for extra integrity
-	sizeBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		sizeBytes[7-i] = byte(fileSize >> (8 * i))
-	}
-	hasher.Write(sizeBytes)
-
-	return hex.EncodeToString(hasher.Sum(nil)), totalChunks, nil
-}
-
-// expandFilePaths processes input paths, expanding directories and handling recursion.
-func expandFilePaths(inputPaths []string, recursive bool) ([]string, error) {
-	expandedFiles := make([]string, 0)
-
-	for _, inputPath := range inputPaths {
-		fileInfo, err := os.Stat(inputPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("Warning: Path not found: %s\n", inputPath)
-				continue
-			}
-			return nil, fmt.Errorf("could not get file info for %s: %w", inputPath, err)
-		}
-
-		if fileInfo.IsDir() {
-			var files []string
-			if recursive {
-				err = filepath.Walk(inputPath, func(path string, info os.FileInfo, err error) error {
-					if err != nil {
-						return err
-					}
-					if !info.IsDir() {
-						files = append(files, path)
-					}
-					return nil
-				})
-			} else {
-				entries, err := os.ReadDir(inputPath)
-				if err != nil {
-					return nil, fmt.Errorf("could not read directory %s: %w", inputPath, err)
-				}
-				for _, entry := range entries {
-					if !entry.IsDir() {
-						files = append(files, filepath.Join(inputPath, entry.Name()))
-					}
-				}
-			}
-			sort.Strings(files) // Sort for consistent ordering
-			expandedFiles = append(expandedFiles, files...)
-		} else {
-			expandedFiles = append(expandedFiles, inputPath)
-		}
-	}
-	return expandedFiles, nil
-}
-
-// processSingleFile calculates and returns hash results for a single file.
-func processSingleFile(filepath string, verbose, jsonOutput bool, targetCoverage float64) (FileHashResult, error) {
-	fileInfo, err := os.Stat(filepath)
-	if err != nil {
-		return FileHashResult{}, fmt.Errorf("file not found: %s", filepath)
-	}
-
-	fileSize := fileInfo.Size()
-	filename := fileInfo.Name()
-
-	if !jsonOutput {
-		fmt.Printf("Processing: %s\n", filename)
-	}
-
-	startTime := time.Now()
-	hashHex, chunks, err := fastSampleHash(filepath, targetCoverage)
-	if err != nil {
-		return FileHashResult{}, fmt.Errorf("error hashing %s: %w", filepath, err)
-	}
-	elapsedTime := time.Since(startTime).Seconds()
-
-	coveragePercent := 0.0
-	if fileSize > 0 {
-		coveragePercent = (float64(chunks) * float64(sampleSize) / float64(fileSize)) * 100
-	}
-
-	result := FileHashResult{
-		Filename:        filename,
-		Filepath:        filepath,
-		FileSize:        fileSize,
-		FSH24:           strings.ToUpper(hashHex),
-		Chunks:          chunks,
-		CoveragePercent: coveragePercent,
-		ProcessingTime:  elapsedTime,
-	}
-
-	if jsonOutput {
-		return result, nil
-	}
-
-	// Console output
-	if verbose {
-		sizeStr := ""
-		if fileSize < 1024*1024*1024 { // Less than 1GB
-			sizeStr = fmt.Sprintf("File size: %s bytes (%.1f MB)", formatNumber(fileSize), float64(fileSize)/(1024*1024))
-		} else {
-			sizeStr = fmt.Sprintf("File size: %s bytes (%.1f GB)", formatNumber(fileSize), float64(fileSize)/(1024*1024*1024))
-		}
-		fmt.Println(sizeStr)
-		fmt.Printf("FSH24: %s\n", result.FSH24)
-		fmt.Printf("Chunks: %d, Coverage: %.4f%%, Time: %.3fs\n", chunks, coveragePercent, elapsedTime)
-	} else {
-		fmt.Printf("FSH24: %s\n", result.FSH24)
-	}
-
-	return result, nil
-}
-
-// generateHashFileMultiple writes hash information to a .fsh24 file.
-func generateHashFileMultiple(
-	filepaths []string,
-	outputFilename string,
-	targetCoverage float64,
-	absolutePaths bool,
-	baseDir string,
-) error {
-	f, err := os.Create(outputFilename)
-	if err != nil {
-		return fmt.Errorf("failed to create output file %s: %w", outputFilename, err)
-	}
-	defer f.Close()
-
-	_, err = f.WriteString("FSH24-1\n")
-	if err != nil {
-		return fmt.Errorf("failed to write header to %s: %w", outputFilename, err)
-	}
-
-	// Use a wait group to process files concurrently for hash file generation
-	var wg sync.WaitGroup
-	fileResultsChan := make(chan struct {
-		filepath string
-		hashHex  string
-		chunks   int
-		fileSize int64
-		err      error
-	}, len(filepaths)) // Buffered channel
-
-	for _, fp := range filepaths {
-		wg.Add(1)
-		go func(filePath string) {
-			defer wg.Done()
-			fileInfo, err := os.Stat(filePath)
-			if err != nil {
-				fileResultsChan <- struct {
-					filepath string
-					hashHex  string
-					chunks   int
-					fileSize int64
-					err      error
-				}{filepath: filePath, err: fmt.Errorf("could not get file info: %w", err)}
-				return
-			}
-			fileSize := fileInfo.Size()
-			hashHex, chunks, err := fastSampleHash(filePath, targetCoverage)
-			fileResultsChan <- struct {
-				filepath string
-				hashHex  string
-				chunks   int
-				fileSize int64
-				err      error
-			}{filepath: filePath, hashHex: hashHex, chunks: chunks, fileSize: fileSize, err: err}
-		}(fp)
-	}
-
-	// Close the channel once all goroutines are done
-	go func() {
-		wg.Wait()
-		close(fileResultsChan)
-	}()
-
-	// Collect results and write to file in a consistent order (based on original filepaths slice)
-	// Create a map to store results by filepath for quick lookup
-	resultsMap := make(map[string]struct {
-		hashHex  string
-		chunks   int
-		fileSize int64
-		err      error
-	})
-
-	for res := range fileResultsChan {
-		if res.err != nil {
-			fmt.Printf("Warning: Skipping file %s due to error: %v\n", res.filepath, res.err)
-			continue
-		}
-		resultsMap[res.filepath] = struct {
-			hashHex  string
-			chunks   int
-			fileSize int64
-			err      error
-		}{hashHex: res.hashHex, chunks: res.chunks, fileSize: res.fileSize, err: res.err}
-	}
-
-	// Iterate original filepaths to ensure consistent output order
-	for _, fp := range filepaths {
-		res, ok := resultsMap[fp]
-		if !ok {
-			// This file was skipped due to an error, already warned.
-			continue
-		}
-
-		outputPath := fp
-		if !absolutePaths {
-			// Make path relative to base directory
-			relPath, err := filepath.Rel(baseDir, fp)
-			if err != nil {
-				fmt.Printf(
-					"Warning: Could not make path %s relative to %s: %v. Using absolute path.\n",
-					fp,
-					baseDir,
-					err,
-				)
-			} else {
-				outputPath = relPath
-			}
-		}
-
-		line := fmt.Sprintf(
-			"%s|%d|%d|%s\n",
-			strings.ToUpper(res.hashHex),
-			res.chunks,
-			res.fileSize,
-			outputPath,
-		)
-		_, err = f.WriteString(line)
-		if err != nil {
-			return fmt.Errorf("failed to write line for %s to %s: %w", fp, outputFilename, err)
-		}
-	}
-
-	return nil
-}
-
-// verifyHashFile reads a .fsh24 file and verifies associated files.
-func verifyHashFile(
-	hashFilename string,
-	verbose, jsonOutput bool,
-) (VerificationSummary, []FileVerificationResult, error) {
-	_, err := os.Stat(hashFilename)
-	if err != nil {
-		return VerificationSummary{}, nil, fmt.Errorf("hash file not found: %s", hashFilename)
-	}
-
-	content, err := os.ReadFile(hashFilename)
-	if err != nil {
-		return VerificationSummary{}, nil, fmt.Errorf("failed to read hash file %s: %w", hashFilename, err)
-	}
-	lines := strings.Split(string(content), "\n")
-
-	if len(lines) == 0 || !strings.HasPrefix(strings.TrimSpace(lines[0]), "FSH24") {
-		return VerificationSummary{}, nil, fmt.Errorf(
-			"invalid checksum file. This file is not a FSH24 checksum v1 file",
-		)
-	}
-
-	results := []FileVerificationResult{}
-	var (
-		verified        int
-		failed          int
-		totalSize       int64
-		totalHashedSize int64
-	)
-
-	startTime := time.Now()
-
-	// Determine the base directory for relative paths.
-	// This should be the directory where the .fsh24 file resides.
-	hashFileDir := filepath.Dir(hashFilename)
-
-	var wg sync.WaitGroup
-	fileChan := make(chan FileVerificationResult, len(lines)-1) // Buffered channel for results
-
-	for _, line := range lines[1:] { // Skip header
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Split(line, "|")
-		if len(parts) != 4 {
-			if !jsonOutput {
-				fmt.Printf("Invalid line format: %s\n", line)
-			}
-			fileChan <- FileVerificationResult{Status: "invalid_line_format"} // Add to channel to count as failed for summary
-			continue
-		}
-
-		expectedHash := parts[0]
-		chunks, err := strconv.Atoi(parts[1])
-		if err != nil {
-			if !jsonOutput {
-				fmt.Printf("Invalid chunks value in line: %s\n", line)
-			}
-			fileChan <- FileVerificationResult{Status: "invalid_chunks_value"}
-			continue
-		}
-		fileSize, err := strconv.ParseInt(parts[2], 10, 64)
-		if err != nil {
-			if !jsonOutput {
-				fmt.Printf("Invalid file size value in line: %s\n", line)
-			}
-			fileChan <- FileVerificationResult{Status: "invalid_file_size_value"}
-			continue
-		}
-		pathFromFile := parts[3]
-
-		// Resolve the file path: if it's relative, join it with the hash file's directory
-		currentPath := pathFromFile
-		if !filepath.IsAbs(pathFromFile) {
-			currentPath = filepath.Join(hashFileDir, pathFromFile)
-		}
-
-		wg.Add(1)
-		go func(expHash string, chk int, fSize int64, currentPath string) {
-			defer wg.Done()
-
-			result := FileVerificationResult{
-				Filepath:     currentPath,
-				Filename:     filepath.Base(currentPath),
-				ExpectedHash: expHash,
-				ExpectedSize: fSize,
-			}
-
-			fileInfo, err := os.Stat(currentPath)
-			if err != nil {
-				result.Status = "missing"
-				if !jsonOutput {
-					fmt.Printf("!MISSING: %s\n", currentPath)
-				}
-				fileChan <- result
-				return
-			}
-
-			currentSize := fileInfo.Size()
-			result.ActualSize = currentSize
-
-			// This happens inside the goroutine, so we need a mutex for shared variables
-			// Or, sum them up after all goroutines finish processing their result.
-			// Let's collect results and sum them up outside the goroutines for simplicity and less locking.
-
-			if currentSize != fSize {
-				result.Status = "size_mismatch"
-				if !jsonOutput {
-					fmt.Printf(
-						"!SIZE MISMATCH: %s (expected: %d, actual: %d)\n",
-						currentPath,
-						fSize,
-						currentSize,
-					)
-				}
-				fileChan <- result
-				return
-			}
-
-			// Show "Checking..." message in verbose mode
-			if verbose && !jsonOutput {
-				fmt.Printf(
-					"%s|%d|%d|%s| Checking...      \r",
-					expHash,
-					chk,
-					fSize,
-					currentPath,
-				) // spaces to clear previous line
-			} else {
-				fmt.Printf("%s| Checking...      \r", currentPath)
-			}
-
-			fileStartTime := time.Now()
-			currentHash, _, hashErr := fastSampleHash(currentPath, 0.01) // targetCoverage is not critical here as chunk count is known
-			fileTime := time.Since(fileStartTime).Seconds()
-			result.ProcessingTime = fileTime
-
-			hashedSize := int64(chk) * sampleSize
-			result.HashedSize = hashedSize
-
-			if hashErr != nil {
-				result.Status = "hash_error"
-				if !jsonOutput {
-					fmt.Printf("!ERROR: %s during hashing: %v\n", currentPath, hashErr)
-				}
-				fileChan <- result
-				return
-			}
-
-			result.ActualHash = strings.ToUpper(currentHash)
-
-			if strings.ToUpper(currentHash) != strings.ToUpper(expHash) {
-				result.Status = "hash_mismatch"
-				if !jsonOutput {
-					if verbose {
-						fmt.Printf(
-							"%s|%d|%d|%s| HASH MISMATCH X\n",
-							expHash,
-							chk,
-							fSize,
-							currentPath,
-						)
-					} else {
-						fmt.Printf("HASH MISMATCH: %s\n", currentPath)
-					}
-				}
-			} else {
-				result.Status = "verified"
-				if verbose && !jsonOutput {
-					fmt.Printf("%s|%d|%d|%s| Verified √       \n", expHash, chk, fSize, currentPath)
-				} else {
-					fmt.Printf("%s| Verified √         \n", currentPath)
-				}
-			}
-			fileChan <- result
-		}(expectedHash, chunks, fileSize, currentPath)
-	}
-
-	// Wait for all goroutines to complete and close the channel
-	go func() {
-		wg.Wait()
-		close(fileChan)
-	}()
-
-	// Collect results from the channel
-	for res := range fileChan {
-		results = append(results, res)
-		if res.Status == "verified" {
-			verified++
-		} else {
-			failed++
-		}
-		// Summing up totals after collecting all results to avoid mutexes
-		if res.ActualSize > 0 { // Use ActualSize if available, otherwise ExpectedSize for calculation
-			totalSize += res.ActualSize
-		} else { // For missing files, use expected size for total size calculation
-			totalSize += res.ExpectedSize
-		}
-		totalHashedSize += res.HashedSize
-	}
-
-	totalTime := time.Since(startTime).Seconds()
-	totalHashedPercentage := 0.0
-	if totalSize > 0 {
-		totalHashedPercentage = (float64(totalHashedSize) / float64(totalSize)) * 100
-	}
-
-	summary := VerificationSummary{
-		Verified:              verified,
-		Failed:                failed,
-		Total:                 verified + failed,
-		Success:               failed == 0,
-		TotalTime:             totalTime,
-		AverageTimePerFile:    totalTime / float64(verified+failed),
-		TotalSize:             totalSize,
-		TotalHashedSize:       totalHashedSize,
-		TotalHashedPercentage: totalHashedPercentage,
-	}
-
-	if jsonOutput {
-		return summary, results, nil
-	}
-
-	if verbose {
-		fmt.Printf("\nVerification complete: %d verified, %d failed\n", verified, failed)
-		fmt.Printf("Total time: %.3fs\n", totalTime)
-		if (verified + failed) > 0 {
-			fmt.Printf("Average time per file: %.3fs\n", totalTime/float64(verified+failed))
-		}
-		fmt.Printf(
-			"Total file size: %s bytes (%.2f GB)\n",
-			formatNumber(totalSize),
-			float64(totalSize)/(1024*1024*1024),
-		)
-		fmt.Printf(
-			"Total hashed size: %s bytes (%.2f GB)\n",
-			formatNumber(totalHashedSize),
-			float64(totalHashedSize)/(1024*1024*1024),
-		)
-		fmt.Printf("Total hash percentage: %.4f%%\n", totalHashedPercentage)
-	} else {
-		fmt.Printf("Verification: %d verified, %d failed\n", verified, failed)
-	}
-
-	return summary, results, nil
-}
-
-// formatNumber adds commas to a number for readability.
-func formatNumber(n int64) string {
-	s := strconv.FormatInt(n, 10)
-	le := len(s)
-	if le <= 3 { // No commas needed for 3 digits or less
-		return s
-	}
-
-	numCommas := (le - 1) / 3
-
-	// A simpler way to count commas is: (length - 1) / 3, but this needs careful handling of the first segment
-	// Let's adjust for more robust segment handling.
-	// The first segment might be 1, 2, or 3 digits.
-	firstSegmentLen := le % 3
-	if firstSegmentLen == 0 {
-		firstSegmentLen = 3 // If divisible by 3, the first segment is 3 digits
-	}
-
-	// Total length of the output string including commas
-	outputLen := le + numCommas
-	out := make([]byte, outputLen)
-
-	outIdx := 0 // Start filling from the beginning of the output byte slice
-	sIdx := 0   // Start reading from the beginning of the source string
-
-	// Handle the first segment (1, 2, or 3 digits)
-	copy(out[outIdx:outIdx+firstSegmentLen], s[sIdx:sIdx+firstSegmentLen])
-	outIdx += firstSegmentLen
-	sIdx += firstSegmentLen
-
-	// Add commas and subsequent 3-digit segments
-	for i := 0; i < numCommas; i++ {
-		out[outIdx] = ','
-		outIdx++
-		copy(out[outIdx:outIdx+3], s[sIdx:sIdx+3])
-		outIdx += 3
-		sIdx += 3
-	}
-
-	return string(out)
-}
-func showHelp() {
-	fmt.Println(`Usage: fsh24 [flags] <file(s)|folder(s)|.fsh24 file>
-Flags:
-  -o, --output string   Output .fsh24 file name (default: checksums.fsh24)
-  -v, --verbose         Verbose output
-  -j, --json            JSON output (prints to console)
-  -r, --recursive       Recursively process folders
-  -a, --absolute        Use absolute paths in .fsh24 file
-  -h, --help            Show this help message
-Examples:
-  fsh24 file.txt
-  fsh24 checksums.fsh24
-  fsh24 -r folder/
-  fsh24 -o output.fsh24 file.txt
-  fsh24 -a my_file.zip  // Generates .fsh24 with absolute path
-
-  You can also just drag'n'drop files and folders to fsh24
-
-Press Enter to exit...`)
-	fmt.Scanln()
-}
-
-func main() {
-
-	var (
-		outputFile   string
-		verbose      bool
-		jsonOutput   bool
-		recursive    bool
-		absolutePaths bool
-		showHelpFlag bool
-	)
-
-	pflag.StringVarP(
-		&outputFile,
-		"output",
-		"o",
-		"",
-		"Output .fsh24 file name (default: checksums.fsh24)",
-	)
-	pflag.BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
-	pflag.BoolVarP(&jsonOutput, "json", "j", false, "JSON output")
-	pflag.BoolVarP(&recursive, "recursive", "r", false, "Recursively process folders")
-	pflag.BoolVarP(
-		&absolutePaths,
-		"absolute",
-		"a",
-		false,
-		"Use absolute paths in .fsh24 file",
-	) // New flag
-	pflag.BoolVarP(&showHelpFlag, "help", "h", false, "Show help message")
-	pflag.Parse()
-
-	// Handle help flag
-	if showHelpFlag {
-		showHelp()
-		return
-	}
-
-	args := pflag.Args()
-
-	if !jsonOutput {
-		fmt.Println("FSH24 - Fast Sample based Hash 24-byte.\nMobCat 20250715\n")
-	}
-
-	if len(args) == 0 {
-		fmt.Println("Usage: fsh24 [flags] <file(s)|folder(s)|.fsh24 file>")
-		fmt.Print("\nPress 'h' for help or any other key to exit: ")
-
-		var input string
-		fmt.Scanln(&input)
-
-		if strings.ToLower(strings.TrimSpace(input)) == "h" {
-			fmt.Println()
-			showHelp()
-			return
-		}
-
-		os.Exit(1)
-	}
-
-	// Get the current working directory. This will be the base for relative paths.
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting current working directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Check if we have a single .fsh24 file (verify mode)
-	if len(args) == 1 && strings.HasSuffix(strings.ToLower(args[0]), ".fsh24") {
-		// Verify mode
-		summary, results, err := verifyHashFile(args[0], verbose, jsonOutput)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		if jsonOutput {
-			output := struct {
-				Summary VerificationSummary      `json:"summary"`
-				Results []FileVerificationResult `json:"results"`
-			}{
-				Summary: summary,
-				Results: results,
-			}
-			jsonBytes, err := json.MarshalIndent(output, "", "  ")
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error marshalling JSON: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Println(string(jsonBytes))
-		}
-		if !jsonOutput {
-			fmt.Print("\nPress Enter to exit...")
-			fmt.Scanln() // Wait for user input
-		}
-	} else {
-		// Hash mode (files and/or folders)
-		expandedFiles, err := expandFilePaths(args, recursive)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error expanding file paths: %v\n", err)
-			os.Exit(1)
-		}
-
-		if len(expandedFiles) == 0 {
-			fmt.Println("No files found to process.")
-			os.Exit(1)
-		}
-
-		if jsonOutput {
-			fileResults := make([]FileHashResult, 0, len(expandedFiles))
-			totalStartTime := time.Now()
-
-			var wg sync.WaitGroup
-			resultChan := make(chan FileHashResult, len(expandedFiles)) // Buffered channel
-
-			for _, fp := range expandedFiles {
-				wg.Add(1)
-				go func(filePath string) {
-					defer wg.Done()
-					result, err := processSingleFile(filePath, verbose, true, 0.01)
-					if err != nil {
-						fmt.Fprintf(os.Stderr,
-							"Warning: Skipping file %s due to error: %v\n",
-							filePath,
-							err,
-						)
-						return
-					}
-					resultChan <- result
-				}(fp)
-			}
-
-			go func() {
-				wg.Wait()
-				close(resultChan)
-			}()
-
-			for res := range resultChan {
-				fileResults = append(fileResults, res)
-			}
-			sort.Slice(fileResults, func(i, j int) bool { // Sort results by filepath for consistent JSON output
-				return fileResults[i].Filepath < fileResults[j].Filepath
-			})
-
-			totalProcessingTime := time.Since(totalStartTime).Seconds()
-
-			outputData := TotalHashSummary{
-				Magic:               "FSH24-1",
-				TotalFiles:          len(fileResults),
-				TotalProcessingTime: totalProcessingTime,
-				AverageTimePerFile:  totalProcessingTime / float64(len(fileResults)),
-				Files:               fileResults,
-			}
-
-			jsonBytes, err := json.MarshalIndent(outputData, "", "  ")
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error marshalling JSON: %v\n", err)
-				os.Exit(1)
-			}
-
-			if outputFile != "" {
-				err = os.WriteFile(outputFile, jsonBytes, 0644)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error saving JSON to file: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Printf("JSON saved to: %s\n", outputFile)
-			} else {
-				fmt.Println(string(jsonBytes))
-			}
-
-		} else {
-			// Process files with console output
-			processedFiles := make([]string, 0)
-			totalStartTime := time.Now()
-
-			for i, fp := range expandedFiles {
-				_, err := processSingleFile(fp, verbose, false, 0.01)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Skipping file %s due to error: %v\n", fp, err)
-					continue
-				}
-				processedFiles = append(processedFiles, fp)
-
-				if i < len(expandedFiles)-1 && len(expandedFiles) > 1 { // Add separator for multiple files
-					fmt.Println()
-				}
-			}
-
-			totalProcessingTime := time.Since(totalStartTime).Seconds()
-
-			if len(processedFiles) > 0 {
-				outputFileActual := outputFile
-				if outputFileActual == "" {
-					outputFileActual = "checksums.fsh24"
-				}
-
-				// Pass absolutePaths and cwd to generateHashFileMultiple
-				err := generateHashFileMultiple(processedFiles, outputFileActual, 0.01, absolutePaths, cwd)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error generating hash file: %v\n", err)
-					os.Exit(1)
-				}
-
-				if len(processedFiles) > 1 {
-					totalFileSize := int64(0)
-					totalHashedSize := int64(0)
-
-					for _, fp := range processedFiles {
-						fileInfo, err := os.Stat(fp)
-						if err != nil {
-							// Should not happen as files were successfully processed earlier, but defensive
-							continue
-						}
-						fileSize := fileInfo.Size()
-						middleChunks := calculateOptimalChunks(fileSize, sampleSize, 0.01)
-						chunks := middleChunks + 2
-						hashedSize := int64(chunks) * sampleSize
-
-						totalFileSize += fileSize
-						totalHashedSize += hashedSize
-					}
-
-					totalHashPercentage := 0.0
-					if totalFileSize > 0 {
-						totalHashPercentage = (float64(totalHashedSize) / float64(totalFileSize)) * 100
-					}
-
-					fmt.Printf("\nProcessed %d files in %.3fs\n", len(processedFiles), totalProcessingTime)
-					fmt.Printf(
-						"Total file size: %s bytes (%.2f GB)\n",
-						formatNumber(totalFileSize),
-						float64(totalFileSize)/(1024*1024*1024),
-					)
-					fmt.Printf(
-						"Total hashed size: %s bytes (%.2f GB)\n",
-						formatNumber(totalHashedSize),
-						float64(totalHashedSize)/(1024*1024*1024),
-					)
-					fmt.Printf("Total hash percentage: %.4f%%\n", totalHashPercentage)
-				}
-
-				if !verbose {
-					fmt.Printf("Hash file saved: %s\n", outputFileActual)
-				}
-
-				fmt.Print("\nPress Enter to exit...")
-				fmt.Scanln() // Wait for user input
-			}
-		}
-	}
-}
-
-// Helper function to return the maximum of two integers
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
-// Helper function to return the maximum of two int64s
-func maxInt64(a, b int64) int64 {
-	if a > b {
-		return a
-	}
-	return b
-}
\ No newline at end of file
+// Built with and for
+// go version go1.24.4 windows/amd64
+
+// FSH24 - Fast Sample Hash 24-byte
+// Super fast integrity hash using strategic 4MB sampling
+// This go code is a port from the python code.
+// The hashing itself lives in pkg/fsh24, this file is just the CLI.
+
+// MobCat 2025
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath" // Ensure this is imported for filepath.Base
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsh24/pkg/fsh24"
+	"fsh24/pkg/fsh24db"
+
+	"github.com/spf13/pflag" // More powerful flag parsing than standard library
+)
+
+// Exit codes, so scripts can tell what happened without reading the output.
+const (
+	exitOK          = 0   // Everything hashed / verified fine
+	exitFailed      = 1   // Verification found mismatched files or broken lines
+	exitMissing     = 2   // Verification found missing files, nothing mismatched
+	exitUsage       = 3   // Bad flags or arguments
+	exitError       = 4   // Something couldn't be read or written
+	exitInterrupted = 130 // Ctrl+C, same as shells use
+)
+
+// verifyExitCode picks the exit code for a finished verification.
+// Mismatches win over missing files, a file that changed is the bigger worry.
+func verifyExitCode(results []fsh24.FileVerificationResult) int {
+	code := exitOK
+	for _, r := range results {
+		switch r.Status {
+		case fsh24.StatusVerified:
+		case fsh24.StatusMissing:
+			if code != exitFailed {
+				code = exitMissing
+			}
+		case fsh24.StatusHashError, fsh24.StatusLocked:
+			if code == exitOK {
+				code = exitError
+			}
+		default:
+			code = exitFailed
+		}
+	}
+	return code
+}
+
+// Verbosity levels for -v, -vv and -vvv.
+const (
+	verboseInfo    = 1 // File sizes, chunk counts, coverage and totals
+	verboseTimings = 2 // Per file verify times and why files were skipped
+	verboseChunks  = 3 // The offset of every sampled chunk
+)
+
+// printHashResult prints the console output for a single hashed file.
+// hasher is only used to work out the chunk offsets for -vvv.
+func printHashResult(result fsh24.FileHashResult, verbose int, hasher *fsh24.Hasher) {
+	if verbose >= verboseInfo {
+		sizeStr := ""
+		if result.FileSize < 1024*1024*1024 { // Less than 1GB
+			sizeStr = fmt.Sprintf("File size: %s bytes (%.1f MB)", formatNumber(result.FileSize), float64(result.FileSize)/(1024*1024))
+		} else {
+			sizeStr = fmt.Sprintf("File size: %s bytes (%.1f GB)", formatNumber(result.FileSize), float64(result.FileSize)/(1024*1024*1024))
+		}
+		fmt.Println(sizeStr)
+		fmt.Printf("FSH24: %s\n", result.FSH24)
+		if result.SHA256 != "" {
+			fmt.Printf("SHA256: %s\n", result.SHA256)
+		}
+		if result.CRC32 != "" {
+			fmt.Printf("CRC32: %s\n", result.CRC32)
+		}
+		fmt.Printf("Chunks: %d, Coverage: %.4f%%, Time: %.3fs\n", result.Chunks, result.CoveragePercent, result.ProcessingTime)
+		if verbose >= verboseChunks && !hasher.Full {
+			h := *hasher
+			h.Offsets = result.Offsets
+			fmt.Printf("Chunk offsets: %s\n", formatOffsets(h.ChunkOffsets(result.FileSize)))
+		}
+	} else {
+		fmt.Printf("FSH24: %s\n", result.FSH24)
+	}
+}
+
+// printDirHashes prints the folder hash of the top folder, and with -v
+// every folder under it too.
+func printDirHashes(dirs []fsh24.DirHash, verbose int) {
+	for i, d := range dirs {
+		if i > 0 && verbose < verboseInfo {
+			break
+		}
+		fmt.Printf("Folder hash: %s %s (%s files)\n", d.FSH24, d.Path, formatNumber(int64(d.Files)))
+	}
+}
+
+// verifyOptions are the command line settings used when verifying.
+type verifyOptions struct {
+	Hasher  *fsh24.Hasher
+	Verbose int
+	Report  string // Report format, empty for the normal console output
+	Quiet   bool   // Only print failures and the summary line
+
+	// BaseDir, if set, is put in front of every recorded path instead of
+	// resolving them from where the .fsh24 file is, see --base-dir.
+	BaseDir string
+
+	// ByName, with BaseDir, finds the files anywhere under BaseDir by their
+	// name instead of their recorded path, see --by-name.
+	ByName bool
+	Walk   walkOptions
+
+	// FailFast stops at the first file that fails, see --fail-fast.
+	FailFast bool
+
+	// Deep checks the samples of every file first and the full hashes
+	// after, see --deep and fsh24.Verifier.Deep.
+	Deep bool
+
+	// ShortHashes takes hashes that are only the start of the FSH24, see
+	// --verify-tags and fsh24.Verifier.ShortHashes.
+	ShortHashes bool
+}
+
+// verifyHashFile reads a .fsh24 file and verifies associated files, printing progress to the console.
+// When ctx is cancelled the partial summary is still printed and returned along with ctx's error.
+func verifyHashFile(
+	ctx context.Context,
+	hashFilename string,
+	opts verifyOptions,
+) (fsh24.VerificationSummary, []fsh24.FileVerificationResult, error) {
+	runHashFile = hashFilename
+	manifest, signer, err := readHashFile(hashFilename)
+	if err != nil {
+		return fsh24.VerificationSummary{}, nil, err
+	}
+	if signer != "" && opts.Report == "" && !opts.Quiet {
+		fmt.Println(colorize(colorGreen, "Good signature from "+signer))
+	}
+
+	// This should be the directory where the .fsh24 file resides.
+	summary, results, err := verifyManifest(ctx, manifest, filepath.Dir(hashFilename), opts)
+	if manifest.Meta[fsh24.MetaIndex] == indexPerDir && err == nil {
+		return verifyPerDir(ctx, hashFilename, summary, results, opts)
+	}
+	return summary, results, err
+}
+
+// verifyDatabase verifies the files listed in a --db database, see verifyHashFile.
+func verifyDatabase(
+	ctx context.Context,
+	dbFilename string,
+	opts verifyOptions,
+) (fsh24.VerificationSummary, []fsh24.FileVerificationResult, error) {
+	if _, err := os.Stat(dbFilename); err != nil {
+		return fsh24.VerificationSummary{}, nil, fmt.Errorf("database not found: %s", dbFilename)
+	}
+	db, err := fsh24db.Open(dbFilename)
+	if err != nil {
+		return fsh24.VerificationSummary{}, nil, err
+	}
+	defer db.Close()
+
+	manifest, err := db.Manifest()
+	if err != nil {
+		return fsh24.VerificationSummary{}, nil, fmt.Errorf("failed to read database %s: %w", dbFilename, err)
+	}
+	return verifyManifest(ctx, manifest, filepath.Dir(dbFilename), opts)
+}
+
+// verifyManifest verifies the files of an already loaded manifest, see verifyHashFile.
+func verifyManifest(
+	ctx context.Context,
+	manifest *fsh24.Manifest,
+	baseDir string,
+	opts verifyOptions,
+) (fsh24.VerificationSummary, []fsh24.FileVerificationResult, error) {
+	verbose, report, quiet := opts.Verbose, opts.Report, opts.Quiet
+	if want := manifest.Meta[fsh24.MetaDirHash]; want != "" {
+		// The lines are checked one by one, this catches lines that went missing
+		if dirs := fsh24.DirHashes(manifest); len(dirs) == 0 || !strings.EqualFold(dirs[0].FSH24, want) {
+			warnf("", "The folder hash of the hash file doesn't match its files, lines were added or removed since it was written")
+		}
+	}
+	if manifest.Meta[fsh24.MetaMerkle] != "" {
+		if _, err := merkleTree("the hash file", manifest); err != nil {
+			warnf("", "%v", err)
+		}
+	}
+	if opts.Deep && !slices.ContainsFunc(manifest.Entries, func(e fsh24.Entry) bool { return e.SHA256 != "" || e.CRC32 != "" }) {
+		warnf("", "None of the files in the hash file have a full hash, --deep can only check their samples. Hash them with --sha256 for that")
+	}
+	verifier := &fsh24.Verifier{Hasher: opts.Hasher, FailFast: opts.FailFast, Deep: opts.Deep, ShortHashes: opts.ShortHashes, NormalizeUnicode: normalizeUnicode, IgnoreCase: ignoreCase, Maps: pathMaps}
+	var (
+		caseMu    sync.Mutex
+		caseFound []string // "recorded as found" of the files only found with --ignore-case
+	)
+	verifier.OnCaseMismatch = func(e fsh24.Entry, path string) {
+		caseMu.Lock()
+		defer caseMu.Unlock()
+		caseFound = append(caseFound, e.Path+" as "+path)
+	}
+	if opts.ByName {
+		if err := matchByName(manifest, opts.BaseDir, opts.Walk); err != nil {
+			return fsh24.VerificationSummary{}, nil, err
+		}
+	} else if opts.BaseDir != "" {
+		baseDir = opts.BaseDir
+		verifier.Rebase = true
+	}
+
+	reporting := report != ""
+	if report == reportNDJSON {
+		ndjson := &ndjsonWriter{w: os.Stdout}
+		for _, inv := range manifest.Invalid {
+			ndjson.write(fsh24.FileVerificationResult{Status: inv.Status})
+		}
+		verifier.OnResult = func(e fsh24.Entry, result fsh24.FileVerificationResult) {
+			ndjson.write(result)
+		}
+	}
+	if !reporting {
+		for _, inv := range manifest.Invalid {
+			var line string
+			switch inv.Status {
+			case fsh24.StatusInvalidChunksValue:
+				line = "Invalid chunks value in line: " + inv.Line
+			case fsh24.StatusInvalidFileSizeValue:
+				line = "Invalid file size value in line: " + inv.Line
+			default:
+				line = "Invalid line format: " + inv.Line
+			}
+			fmt.Println(colorize(colorRed, line))
+		}
+	}
+
+	var bar *progress
+	if !reporting {
+		totalSize := int64(0)
+		for _, e := range manifest.Entries {
+			totalSize += max(e.Size, 0)
+		}
+		if !quiet {
+			bar = newProgress(len(manifest.Entries), totalSize)
+		}
+		if bar == nil && !quiet { // The progress line takes the place of this
+			verifier.OnCheck = func(e fsh24.Entry, currentPath string) {
+				// Show "Checking..." message, spaces to clear previous line
+				if verbose >= verboseInfo {
+					fmt.Printf("%s|%d|%d|%s| Checking...      \r", e.Hash, e.Chunks, e.Size, currentPath)
+				} else {
+					fmt.Printf("%s| Checking...      \r", currentPath)
+				}
+			}
+		}
+		// Same sample size as the verifier will use, for the -vvv chunk offsets
+		offsets := *opts.Hasher
+		manifest.ApplySettings(&offsets)
+		verifier.OnResult = func(e fsh24.Entry, result fsh24.FileVerificationResult) {
+			bar.fileDone(e.Size, func() {
+				if quiet && result.Status == fsh24.StatusVerified && len(result.MetadataChanges) == 0 {
+					return
+				}
+				printVerificationResult(e, result, verbose, opts.Deep)
+				if verbose >= verboseChunks && e.Chunks > 0 && !offsets.Full && result.Status != fsh24.StatusMissing {
+					h := offsets
+					h.Chunks = e.Chunks
+					h.Offsets = e.Offsets
+					fmt.Printf("  Chunk offsets: %s\n", formatOffsets(h.ChunkOffsets(e.Size)))
+				}
+			})
+		}
+		verifier.OnDeep = func(entries []fsh24.Entry) {
+			bar.finish()
+			if quiet {
+				return
+			}
+			totalSize := int64(0)
+			for _, e := range entries {
+				totalSize += max(e.Size, 0)
+			}
+			fmt.Printf("\nChecking every byte of the %d files with a full hash\n", len(entries))
+			bar = newProgress(len(entries), totalSize)
+		}
+	}
+
+	summary, results, verifyErr := verifier.Verify(ctx, manifest, baseDir)
+	bar.finish()
+	if len(caseFound) > 0 {
+		slices.Sort(caseFound)
+		warnf("", "%d files were only found with their names in another case, eg. %s. Check they're the right ones", len(caseFound), caseFound[0])
+	}
+	if verifyErr != nil && ctx.Err() == nil {
+		return summary, results, verifyErr
+	}
+
+	if reporting {
+		return summary, results, verifyErr
+	}
+
+	if total := len(manifest.Entries) + len(manifest.Invalid); verifyErr != nil {
+		fmt.Printf("\nInterrupted, %d of %d files were checked\n", summary.Total, total)
+	} else if opts.FailFast && summary.Failed > 0 && summary.Total < total {
+		fmt.Printf("Stopped at the first failure, %d of %d files were checked\n", summary.Total, total)
+	}
+
+	if verbose >= verboseInfo && !quiet {
+		color := colorGreen
+		if summary.Failed > 0 {
+			color = colorRed
+		}
+		fmt.Println()
+		fmt.Println(colorize(color, fmt.Sprintf("Verification complete: %d verified, %d failed%s", summary.Verified, summary.Failed, changeNote(summary))))
+		if opts.Deep && summary.Verified > 0 {
+			fmt.Println(depthNote(summary))
+		}
+		fmt.Printf("Total time: %.3fs\n", summary.TotalTime)
+		if summary.Total > 0 {
+			fmt.Printf("Average time per file: %.3fs\n", summary.AverageTimePerFile)
+		}
+		fmt.Printf(
+			"Total file size: %s bytes (%.2f GB)\n",
+			formatNumber(summary.TotalSize),
+			float64(summary.TotalSize)/(1024*1024*1024),
+		)
+		fmt.Printf(
+			"Total hashed size: %s bytes (%.2f GB)\n",
+			formatNumber(summary.TotalHashedSize),
+			float64(summary.TotalHashedSize)/(1024*1024*1024),
+		)
+		fmt.Printf("Total hash percentage: %.4f%%\n", summary.TotalHashedPercentage)
+	} else {
+		color := colorGreen
+		if summary.Failed > 0 {
+			color = colorRed
+		}
+		fmt.Println(colorize(color, fmt.Sprintf("Verification: %d verified, %d failed%s", summary.Verified, summary.Failed, changeNote(summary))))
+		if opts.Deep && summary.Verified > 0 {
+			fmt.Println(depthNote(summary))
+		}
+	}
+
+	return summary, results, verifyErr
+}
+
+// depthNote says how many of the verified files were checked every byte
+// and how many only by their samples, for --deep.
+func depthNote(summary fsh24.VerificationSummary) string {
+	return fmt.Sprintf("Of those verified, %d every byte, %d only by their samples", summary.VerifiedFull, summary.Verified-summary.VerifiedFull)
+}
+
+// finishVerify prints the report of a verification that's done, if there's
+// one, and exits with the code for how it went.
+func finishVerify(
+	ctx context.Context,
+	summary fsh24.VerificationSummary,
+	results []fsh24.FileVerificationResult,
+	err error,
+	report string,
+	noPause bool,
+) {
+	if err != nil && ctx.Err() == nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	if jsonOut != "" {
+		reportBytes, err := verifyReport(reportJSON, summary, results)
+		if err == nil {
+			err = writeReportFile(jsonOut, reportBytes)
+		}
+		if err != nil {
+			fatalf(exitError, "could not save JSON report: %v", err)
+		}
+		if report == "" {
+			fmt.Printf("JSON report saved: %s\n", jsonOut)
+		}
+	}
+	if report != "" && report != reportNDJSON { // ndjson was printed as it went
+		reportBytes, err := verifyReport(report, summary, results)
+		if err != nil {
+			fatalf(exitError, "could not marshal %s: %v", report, err)
+		}
+		fmt.Print(string(reportBytes))
+		if report == reportJSON {
+			fmt.Println()
+		}
+	}
+	run.Total, run.OK, run.Failed = summary.Total, summary.Verified, summary.Failed
+	runFailures = failuresOf(results)
+	if ctx.Err() != nil {
+		run.Error = "interrupted"
+		exit(exitInterrupted)
+	}
+	if report == "" {
+		pause(noPause)
+	}
+	exit(verifyExitCode(results))
+}
+
+// changeNote is the bit of the verify summary line about what happened to
+// the files, how many were edited, corrupted, had their metadata changed or
+// were locked.
+// "" if nothing did, or the hash file has no modification times to tell.
+func changeNote(summary fsh24.VerificationSummary) string {
+	var notes []string
+	if summary.Modified > 0 {
+		notes = append(notes, fmt.Sprintf("%d edited", summary.Modified))
+	}
+	if summary.Corrupted > 0 {
+		notes = append(notes, fmt.Sprintf("%d corrupted", summary.Corrupted))
+	}
+	if summary.MetadataChanged > 0 {
+		notes = append(notes, fmt.Sprintf("%d with changed metadata", summary.MetadataChanged))
+	}
+	if summary.Locked > 0 {
+		notes = append(notes, fmt.Sprintf("%d locked", summary.Locked))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// chained is --chain, new hash files get a link column, see fsh24.Manifest.Chained.
+var chained bool
+
+// normalizeUnicode is --normalize-unicode, paths are stored in NFC and
+// looked up in whatever form they are on disk, see fsh24.NormalizePath.
+var normalizeUnicode bool
+
+// ignoreCase is --ignore-case, verify finds files whose names are in
+// another case on disk than in the hash file, see fsh24.Verifier.IgnoreCase.
+var ignoreCase bool
+
+// pathMaps are the --map rules, the paths of a hash file are rewritten
+// with them before verify looks for the files.
+var pathMaps []fsh24.PathMap
+
+// newManifest starts an empty hash file in format for files hashed with hasher.
+func newManifest(hasher *fsh24.Hasher, format string) *fsh24.Manifest {
+	manifest := &fsh24.Manifest{
+		Algorithm:   hasher.Algorithm,
+		DigestBytes: hasher.DigestBytes,
+		SampleSize:  hasher.SampleSize,
+		Full:        hasher.Full,
+		Seed:        hasher.Seed,
+		Keyed:       len(hasher.Key) > 0,
+		SHA256:      hasher.SHA256,
+		Chained:     chained && format == fsh24.FormatFSH24,
+		Metadata:    hasher.Metadata,
+		Adaptive:    hasher.Adaptive,
+		Format:      format,
+	}
+	if fsh24.HasFields(format) {
+		manifest.Meta = map[string]string{
+			"created":         time.Now().UTC().Format(time.RFC3339),
+			fsh24.MetaTool:    "fsh24",
+			fsh24.MetaVersion: version,
+		}
+	}
+	if format == fsh24.FormatSFV {
+		manifest.Comments = []string{"Generated by fsh24"}
+	}
+	return manifest
+}
+
+// sfvName is the .sfv --sfv writes next to the hash file hashFile, which
+// is never gzipped, sfv checkers wouldn't know what to do with it.
+func sfvName(hashFile string) string {
+	hashFile = fsh24.TrimGzipExt(hashFile)
+	return strings.TrimSuffix(hashFile, filepath.Ext(hashFile)) + ".sfv"
+}
+
+// printVerificationResult prints the console line for a single verified file.
+// Verified is green, missing yellow and anything else that went wrong red.
+// With deep, files only checked by their samples say so.
+func printVerificationResult(e fsh24.Entry, result fsh24.FileVerificationResult, verbose int, deep bool) {
+	currentPath := result.Filepath
+	line, color := "", colorRed
+	switch result.Status {
+	case fsh24.StatusMissing:
+		line, color = fmt.Sprintf("!MISSING: %s", currentPath), colorYellow
+	case fsh24.StatusSizeMismatch:
+		line = fmt.Sprintf(
+			"!SIZE MISMATCH: %s (expected: %d, actual: %d)",
+			currentPath,
+			result.ExpectedSize,
+			result.ActualSize,
+		)
+	case fsh24.StatusHashError:
+		line = fmt.Sprintf("!ERROR: %s during hashing", currentPath)
+	case fsh24.StatusLocked:
+		line, color = fmt.Sprintf("!SKIPPED: locked: %s", currentPath), colorYellow
+	case fsh24.StatusHashMismatch:
+		if verbose >= verboseInfo {
+			line = fmt.Sprintf("%s|%d|%d|%s| HASH MISMATCH X", e.Hash, e.Chunks, e.Size, currentPath)
+		} else {
+			line = fmt.Sprintf("HASH MISMATCH: %s", currentPath)
+		}
+	case fsh24.StatusCRC32Mismatch:
+		line = fmt.Sprintf("CRC32 MISMATCH: %s", currentPath)
+	case fsh24.StatusSHA256Mismatch:
+		if verbose >= verboseInfo {
+			line = fmt.Sprintf("%s|%d|%d|%s| SHA256 MISMATCH X", e.Hash, e.Chunks, e.Size, currentPath)
+		} else {
+			line = fmt.Sprintf("SHA256 MISMATCH: %s", currentPath)
+		}
+	case fsh24.StatusVerified:
+		color = colorGreen
+		if verbose >= verboseInfo {
+			line = fmt.Sprintf("%s|%d|%d|%s| Verified √       ", e.Hash, e.Chunks, e.Size, currentPath)
+		} else {
+			line = fmt.Sprintf("%s| Verified √         ", currentPath)
+		}
+		if len(result.MetadataChanges) > 0 {
+			// Same content, but someone touched it
+			color = colorYellow
+			line = strings.TrimRight(line, " ") + " but metadata changed: " + strings.Join(result.MetadataChanges, ", ")
+		}
+		if deep && result.Depth == fsh24.DepthSampled {
+			line = strings.TrimRight(line, " ") + " (samples)"
+		}
+	default:
+		return
+	}
+	switch result.Change {
+	case fsh24.ChangeModified:
+		line += " (edited, its modified time changed too)"
+	case fsh24.ChangeCorrupted:
+		line += " (modified time is the same, corrupted?)"
+	}
+	if verbose >= verboseTimings && result.Status != fsh24.StatusMissing {
+		line += fmt.Sprintf(" (%.3fs)", result.ProcessingTime)
+	}
+	fmt.Println(colorize(color, line))
+}
+
+// formatOffsets lists chunk offsets for -vvv, eg. "0, 52428800, 104853504".
+func formatOffsets(offsets []int64) string {
+	parts := make([]string, len(offsets))
+	for i, off := range offsets {
+		parts[i] = strconv.FormatInt(off, 10)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatNumber adds commas to a number for readability.
+func formatNumber(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	le := len(s)
+	if le <= 3 { // No commas needed for 3 digits or less
+		return s
+	}
+
+	numCommas := (le - 1) / 3
+
+	// A simpler way to count commas is: (length - 1) / 3, but this needs careful handling of the first segment
+	// Let's adjust for more robust segment handling.
+	// The first segment might be 1, 2, or 3 digits.
+	firstSegmentLen := le % 3
+	if firstSegmentLen == 0 {
+		firstSegmentLen = 3 // If divisible by 3, the first segment is 3 digits
+	}
+
+	// Total length of the output string including commas
+	outputLen := le + numCommas
+	out := make([]byte, outputLen)
+
+	outIdx := 0 // Start filling from the beginning of the output byte slice
+	sIdx := 0   // Start reading from the beginning of the source string
+
+	// Handle the first segment (1, 2, or 3 digits)
+	copy(out[outIdx:outIdx+firstSegmentLen], s[sIdx:sIdx+firstSegmentLen])
+	outIdx += firstSegmentLen
+	sIdx += firstSegmentLen
+
+	// Add commas and subsequent 3-digit segments
+	for i := 0; i < numCommas; i++ {
+		out[outIdx] = ','
+		outIdx++
+		copy(out[outIdx:outIdx+3], s[sIdx:sIdx+3])
+		outIdx += 3
+		sIdx += 3
+	}
+
+	return string(out)
+}
+
+// parseSize reads a byte count like "4194304", "512KB", "4MB" or "1G".
+// Units are powers of 1024.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("not a size: %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// showHelp prints the usage text and waits for Enter, unless noPause.
+func showHelp(noPause bool) {
+	fmt.Println(`Usage: fsh24 [flags] <file(s)|folder(s)|URL(s)|.fsh24 file>
+       fsh24 merge [flags] <.fsh24 files> -o combined.fsh24
+       fsh24 convert [flags] <hash file> --to format
+       fsh24 cmp [flags] <folder 1> <folder 2>
+       fsh24 watch [flags] <folder> -o folder.fsh24
+       fsh24 daemon [flags] <.fsh24 files>
+       fsh24 serve [flags]
+       fsh24 gui  // Drop files on a page in your browser to check or hash them
+       fsh24 proof <.fsh24 file> <path> [-o proof.json]
+       fsh24 check-proof [--root hash] <proof.json> [file]
+       fsh24 coverage [flags] <.fsh24 file | files and folders>  // How much the samples read
+       fsh24 keygen [-o name]  // Makes name.key and name.pub (default: fsh24)
+       fsh24 self-update [--dry-run]  // Gets the latest release, checked against its signed hash file
+       fsh24 selftest  // Checks this build still makes the right hashes
+       fsh24 version [-j]  // Version, commit and the hash file formats it knows
+       fsh24 schema <hash|verify>  // JSON Schema of the -j output
+Flags:
+  -o, --output string   Output .fsh24 file name (default: checksums.fsh24),
+                        ending it in .gz gzips it (checksums.fsh24.gz)
+  -v, --verbose         Verbose output, -vv adds verify times and why files
+                        were skipped, -vvv the offset of every chunk sampled
+  -j, --json            JSON output (prints to console)
+  -q, --quiet           Only print errors, failed files and the summary
+  -c, --check           Verify the file given, whatever it's called. Hash
+                        files ending in .fsh24 or .sfv, and checksum files
+                        like SHA256SUMS or file.md5, are verified anyway
+      --no-auto-verify  Hash a folder given on its own even if it has one
+                        .fsh24 file in it. Without flags for hashing like -r
+                        or -o, that file is verified instead (asks first)
+      --no-pause        Don't wait for Enter before exiting, for scripts
+      --no-color        Plain output, no green/red/yellow (or set NO_COLOR)
+  -r, --recursive       Recursively process folders
+      --max-depth n     Only go n folder levels deep, 1 being just the files
+                        in the folder given (implies -r, default: no limit)
+      --follow-symlinks Also go into symlinked folders (each folder only once)
+      --skip-symlinks   Ignore symlinked files and folders completely
+      --follow-junctions
+                        Also go into Windows junctions and volume mount points
+                        (each folder only once, skipped by default)
+      --hydrate         Hash OneDrive and other cloud files that aren't
+                        downloaded, which downloads them (skipped by default)
+  -0, --print0          Read the - path list NUL separated (find -print0) and
+                        end gnu/bsd lines with a NUL (sha256sum -z)
+      --skip-hidden     Ignore hidden files and folders (dotfiles, or the
+                        Hidden attribute on Windows)
+      --archive-contents
+                        Also hash every file inside .zip, .tar(.gz/.bz2/.xz)
+                        and .7z archives and .iso disc images, written as
+                        archive.zip!path/in/it, without extracting them
+  -a, --absolute        Use absolute paths in .fsh24 file
+      --normalize-unicode
+                        Store paths in NFC and find files whatever Unicode
+                        form their names are in, for hash files made on macOS
+      --ignore-case     Verify: find files whatever case their names are in,
+                        for hash files made on Windows checked on Linux
+      --exclude pattern Skip files and folders matching the pattern, eg.
+                        Thumbs.db or "*.tmp". Can be given more than once
+      --include pattern Only pick up files matching the pattern from folders,
+                        eg. "*.mkv,*.iso". Can be given more than once
+      --ext list        Only pick up files with these extensions from folders,
+                        any case, eg. "mkv,iso,zip". Adds to --include
+      --sort order      Order of the files in the hash file and reports: name
+                        (default, each folder by name), natural (file2 before
+                        file10), size (smallest first) or none (as found)
+      --algo string     Hash algorithm: blake2b (default), blake3 or xxh3
+      --digest-bytes n  Hash length in bytes, 16 to 64 (default: 24)
+      --sample-size n   Size of each sample, eg. 1MB or 16MB (default: 4MB)
+      --key string      Secret key for keyed (tamper-evident) hashes
+      --key-file path   Read the secret key from a file instead
+      --sign [key]      Sign the hash file with GPG, a detached file.fsh24.asc
+                        next to it. Uses gpg's default key unless one is given,
+                        eg. --sign=me@example.com
+      --clearsign       With --sign, put the signature in the hash file itself
+      --require-signature
+                        Only verify hash files with a good GPG signature, a
+                        .asc next to them or clear-signed, or signed by one
+                        of the --trusted-key keys
+      --chain           Tamper-evident hash file, every line gets a hash of
+                        the line before it so editing earlier lines shows.
+                        --update only adds to the end of it
+      --sign-key path   Sign the hash file with a key made by fsh24 keygen,
+                        no GPG needed. The signature goes on the last line
+      --trusted-key path
+                        Public key (.pub) to trust hash files signed with,
+                        can be given more than once
+      --full            Hash every byte instead of sampling (slow, same output)
+      --random-offsets  Put the samples between the first and last at random
+                        places instead of evenly spaced, so nobody can know
+                        where they'll be. The seed is stored in the hash file
+      --adaptive        Look over each file first and put the samples between
+                        the first and last where the data is, not in runs of
+                        zeros like the padding of disk images. Where they went
+                        is stored with each hash, needs --format fsh24-2
+                        (the default with it)
+      --sha256          Also store a full file SHA-256, checked on verify (slow)
+      --metadata        Also store the mode, owner and xattrs of every file.
+                        Verify lists what changed apart from the content.
+                        Needs --format fsh24-2 (the default with it)
+      --per-dir         Write a checksums.fsh24 into every folder covering just
+                        its files, so a folder can be moved with its hashes,
+                        and an index of them as -o (default: index.fsh24)
+      --dir-hash        Also work out one hash per folder from the hashes and
+                        paths of the files in it, to compare two copies of a
+                        tree. Stored in fsh24-2 files and json/yaml reports
+      --merkle          Also store the Merkle root of all the files, so fsh24
+                        proof can show a file is in the hash file without
+                        handing out all of it. Needs --format fsh24-2
+      --root hash       check-proof: the Merkle root that was published, the
+                        proof has to be for it
+      --jobs n          How many files to work on at once when verifying or
+                        with -j (default: CPU count, at most 4). Use 1 for
+                        a spinning disk, more for SSDs and network shares
+      --net-mode mode   auto (default), on or off. Net mode reads samples that
+                        are close together in one go, fewer round trips on
+                        SMB/NFS shares and URLs, and retries failed reads.
+                        auto uses it for network shares and URLs only
+      --retries n       Net mode: how many times to try a failed read again,
+                        waiting longer each time (default: 3)
+      --lock-retries n  How many times to try a file another program has
+                        locked again, waiting longer each time (default: 3)
+      --lock-delay d    Wait before the first retry of a locked file, doubling
+                        every time after (default: 2s)
+      --mmap            Map local files into memory instead of reading them,
+                        can be quicker on NVMe. Same hashes either way
+      --io-uring        Linux: queue up the sample reads of a file with
+                        io_uring, quicker on SSDs that do many reads at once
+      --direct          Read around the OS file cache (O_DIRECT), so a big
+                        verify doesn't push out what other programs cached
+      --update          Only hash the files that aren't in the -o .fsh24 file
+                        yet and add them to it, using its settings
+      --incremental     Like --update, but also re-hash the files whose size or
+                        modification time changed. Needs --format fsh24-2
+      --prune           Drop the files that no longer exist from the .fsh24
+                        file given, or from the -o file with --update or watch
+      --resume          Carry on with a run that crashed or was stopped,
+                        using the .partial file saved next to the output
+      --base-dir path   When verifying, look for the files under this folder,
+                        eg. a backup restored to another drive, or URL
+      --map from=to     When verifying, read paths starting with from as
+                        starting with to, eg. "D:\Archive=/mnt/archive".
+                        Can be given more than once, the first match is used
+      --by-name         With --base-dir, find the files anywhere under it by
+                        their name, for collections moved to new folders
+      --fail-fast       Stop verifying at the first missing, mismatched or
+                        unreadable file instead of checking the rest
+      --deep            Verify in two passes, the samples of every file first
+                        for a quick answer, then every byte of the files that
+                        passed and have a full hash (--sha256)
+      --db path         Write the hashes into an SQLite database instead of a
+                        .fsh24 file. With no files given, verify the database
+      --sfv             Also write a CRC32 .sfv next to the .fsh24 file (slow)
+      --torrent         Also write a .torrent of the files next to the .fsh24
+                        file, with BitTorrent piece hashes (slow)
+      --piece-length n  With --torrent, the piece length, a power of two from
+                        16KB to 16MB (default: about 1500 pieces)
+      --announce url    With --torrent, the tracker to put in it
+      --format string   .fsh24 file format: fsh24 (FSH24-1, default), fsh24-2
+                        fsh24-bin (FSH24-2 in binary, for millions of files)
+                        gnu (sha256sum style "HASH  path" lines)
+                        bsd (openssl style "FSH24 (path) = HASH" lines)
+                        sfv (CRC32 only), or one of these to print the
+                        results instead: json (same as -j), csv, ndjson
+                        or yaml, or for forensic tools when hashing:
+                        hashdeep or dfxml
+      --conflict mode   merge: when hash files have different hashes for the
+                        same file, error (default) or keep the newest
+      --to format       convert: what to turn the hash file into, one of the
+                        --format hash file formats, sha256sum (needs a
+                        --sha256 hash file), json, csv, yaml, hashdeep,
+                        dfxml or sqlite
+      --find-dupes      List sets of files with the same size and hash, and
+                        how much space the extra copies take, instead of
+                        writing a .fsh24 file
+      --confirm-dupes   --find-dupes, but read the duplicates in full for a
+                        SHA-256 first so only real copies get listed (slow)
+      --dedupe how      --confirm-dupes, then replace every copy with a link
+                        to the first file of its set, hardlink or reflink
+                        (copy on write, Btrfs/XFS/APFS)
+      --dry-run         With --dedupe, only list what would be linked and how
+                        much space that frees, nothing is changed. With
+                        self-update, only say if there's a newer release
+      --tag-filename    Rename files to have their hash in the name, like
+                        movie.[A1B2C3...].mkv, instead of writing a .fsh24 file
+      --tag-length n    With --tag-filename, only use the first n characters
+                        of the hash, at least 8 (default: all of it)
+      --verify-tags     Check files against the hash in their name, a
+                        --tag-filename tag or a [A1B2C3D4] CRC32, no hash
+                        file needed
+      --interval time   daemon: how long to wait between checks, eg. 24h
+                        (default: 168h, once a week)
+      --on-fail cmd     Run cmd when the run fails (anything but exit code 0),
+                        or with daemon when a check fails. It gets the counts,
+                        hash file and a file listing the failed files in
+                        FSH24_ variables, JSON on stdin. --on-failure is the
+                        same
+      --on-complete cmd Run cmd when the run is done, failed or not
+      --notify-url url  daemon: POST the failed files as JSON to this URL
+      --metrics addr    daemon: serve Prometheus metrics at /metrics on this
+                        address, eg. :9124. serve always has them on --listen
+      --mail-to addr    Mail a report (text and JSON) when done, or after each
+                        daemon check. Login from FSH24_SMTP_USER and
+                        FSH24_SMTP_PASSWORD
+      --mail-on when    failure (default), only mail when something failed,
+                        or always
+      --smtp host:port  Mail server to send through (default: localhost:25)
+      --mail-from addr  Sender of the mail (default: fsh24@ this machine)
+      --listen addr     serve: address for the HTTP API to listen on
+                        (default: localhost:8080, use :8080 for everyone).
+                        gui: address for the page (default: any free port)
+      --grpc addr       serve: also run the gRPC API on this address,
+                        eg. localhost:9090
+      --json-out path   Also save the JSON report (what -j prints) to path,
+                        while still writing the hash file and printing the
+                        normal output. Works with --format too
+      --summary-file path
+                        Save a JSON summary of the run (counts, warnings,
+                        exit code). With -j or another report format the
+                        summary goes to stderr instead of Warning: lines
+      --version         Show the version, same as fsh24 version
+  -h, --help            Show this help message
+Exit codes:
+  0 all good, 1 mismatched files, 2 missing files, 3 bad flags or arguments,
+  4 a file couldn't be read or written, 130 interrupted with Ctrl+C
+Examples:
+  fsh24 file.txt
+  fsh24 checksums.fsh24
+  fsh24 --deep archive.fsh24  // Samples of everything first, then every byte
+  fsh24 coverage archive.fsh24  // How much of the files verifying it reads
+  fsh24 -r --adaptive -o images.fsh24 D:\images  // Samples where the data is
+  fsh24 --base-dir E:\restore checksums.fsh24
+  fsh24 --map "D:\Archive=/mnt/archive" archive.fsh24  // Made on Windows, checked on Linux
+  fsh24 https://example.com/big.iso  // Only downloads the samples
+  fsh24 -r -o bucket.fsh24 s3://my-archive/photos/
+  fsh24 release.sfv
+  fsh24 SHA256SUMS  // md5sum, sha1sum, sha256sum, sha512sum and b2sum files
+  fsh24 --archive-contents -r backups/  // Also hashes the files in the .zips and tarballs
+  fsh24 --archive-contents -o discs.fsh24 *.iso  // Every file on every disc
+  sudo fsh24 -o clone.fsh24 /dev/sda /dev/sdb  // A disk and its clone, \\.\PhysicalDrive1 on Windows
+  fsh24 --db archive.sqlite -r folder/
+  fsh24 -r --torrent -o release.fsh24 release/  // Also writes release.torrent
+  fsh24 -r --per-dir D:\photos  // A checksums.fsh24 in every folder and index.fsh24
+  fsh24 --db archive.sqlite  // Verifies everything in the database
+  fsh24 -r folder/
+  fsh24 -o output.fsh24 file.txt
+  fsh24 -a my_file.zip  // Generates .fsh24 with absolute path
+  fsh24 --algo blake3 -r folder/
+  fsh24 merge c.fsh24 d.fsh24 -o all.fsh24  // Combines hash files into one
+  fsh24 convert all.fsh24 --to json  // Writes all.json, no re-hashing
+  fsh24 cmp D:\photos E:\backup\photos  // Compares two folders by content
+  fsh24 cmp photos.fsh24 backup.fsh24  // Same, from their hash files
+  fsh24 -r --dedupe hardlink --dry-run D:\photos  // How much linking the copies would free
+  fsh24 proof release.fsh24 disc1.iso -o disc1.proof  // Proves disc1.iso is in it
+  fsh24 watch D:\inbox -o inbox.fsh24  // Hashes files as they are added
+  fsh24 -r -o photos.fsh24 --json-out photos.json photos/  // Hash file and JSON report
+  fsh24 schema verify > verify.schema.json  // To check the -j output against
+  fsh24 --on-fail "alert.sh" archive.fsh24  // Runs alert.sh if anything failed
+  fsh24 daemon --interval 168h --on-fail "mail.bat" archive.fsh24
+  fsh24 serve --listen :8080  // Hash and verify over HTTP
+  fsh24 gui  // Drop a download and its .fsh24 on the page to check it
+  fsh24 -r --exclude Thumbs.db --exclude "*.tmp" folder/
+  fsh24 -r --ext mkv,iso,zip -o media.fsh24 D:\media  // Just those kinds of files
+  fsh24 -r --sort natural -o show.fsh24 "My Show/"  // Episode 2 before episode 10
+  fsh24 -r --hydrate -o onedrive.fsh24 C:\Users\me\OneDrive  // Downloads what isn't local
+  find . -name "*.iso" | fsh24 -  // Reads the file list from stdin
+
+  You can also just drag'n'drop files and folders to fsh24`)
+	pause(noPause)
+}
+
+// isHashFileName reports whether name is verified rather than hashed when
+// it's given on its own, a .fsh24 (or .fsh24.gz), .sfv or md5sum style file.
+func isHashFileName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(fsh24.TrimGzipExt(lower), ".fsh24") || strings.HasSuffix(lower, ".sfv") || fsh24.IsChecksumFile(name)
+}
+
+// pause waits for Enter so a drag'n'dropped window doesn't close before
+// you can read it. noPause (--no-pause) skips it for scripts.
+func pause(noPause bool) {
+	if noPause {
+		return
+	}
+	fmt.Print("\nPress Enter to exit...")
+	fmt.Scanln() // Wait for user input
+}
+
+func main() {
+
+	var (
+		outputFile      string
+		verbose         int
+		jsonOutput      bool
+		recursive       bool
+		absolutePaths   bool
+		algorithm       string
+		digestBytes     int
+		sampleSizeStr   string
+		fullSHA256      bool
+		fullMode        bool
+		randomOffsets   bool
+		adaptive        bool
+		keyString       string
+		keyFile         string
+		signKeyFile     string
+		trustedKeyFiles []string
+		mapRules        []string
+		format          string
+		sfvOutput       bool
+		torrent         bool
+		pieceLengthStr  string
+		announce        string
+		dbFile          string
+		excludes        []string
+		includes        []string
+		exts            []string
+		maxDepth        int
+		followLinks     bool
+		skipLinks       bool
+		followJunctions bool
+		hydrate         bool
+		skipHidden      bool
+		archiveContents bool
+		nullDelim       bool
+		baseDir         string
+		byName          bool
+		failFast        bool
+		deep            bool
+		jobs            int
+		netMode         string
+		retries         int
+		lockRetries     int
+		lockDelay       time.Duration
+		useMmap         bool
+		useIOUring      bool
+		direct          bool
+		quiet           bool
+		noPause         bool
+		noAutoVerify    bool
+		noColor         bool
+		update          bool
+		prune           bool
+		incremental     bool
+		recordMetadata  bool
+		dirHash         bool
+		perDir          bool
+		merkle          bool
+		merkleRoot      string
+		resume          bool
+		interval        time.Duration
+		notifyURL       string
+		metricsListen   string
+		mailTo          []string
+		mailFrom        string
+		smtpServer      string
+		mailOn          string
+		listen          string
+		grpcListen      string
+		conflict        string
+		convertTo       string
+		checkFile       bool
+		findDupes       bool
+		tagFilenames    bool
+		verifyTags      bool
+		tagLength       int
+		confirmDupes    bool
+		dedupeMode      string
+		dryRun          bool
+		showHelpFlag    bool
+		showVersion     bool
+		sortOrder       string
+	)
+
+	pflag.StringVarP(
+		&outputFile,
+		"output",
+		"o",
+		"",
+		"Output .fsh24 file name, .fsh24.gz to gzip it (default: checksums.fsh24)",
+	)
+	pflag.CountVarP(&verbose, "verbose", "v", "Verbose output, -vv and -vvv for more")
+	pflag.BoolVarP(&jsonOutput, "json", "j", false, "JSON output")
+	pflag.BoolVarP(&quiet, "quiet", "q", false, "Only print errors and the summary")
+	pflag.BoolVarP(&checkFile, "check", "c", false, "Verify the file given as a hash file, whatever its name")
+	pflag.BoolVar(&noPause, "no-pause", false, "Don't wait for Enter before exiting")
+	pflag.BoolVar(&noAutoVerify, "no-auto-verify", false, "Hash a folder even if it has a .fsh24 file in it, instead of verifying that")
+	pflag.BoolVar(&noColor, "no-color", false, "Don't colour the results")
+	pflag.BoolVarP(&recursive, "recursive", "r", false, "Recursively process folders")
+	pflag.BoolVar(&followLinks, "follow-symlinks", false, "Also go into symlinked folders")
+	pflag.BoolVar(&skipLinks, "skip-symlinks", false, "Ignore symlinks completely")
+	pflag.BoolVar(&followJunctions, "follow-junctions", false, "Also go into Windows junctions and mount points")
+	pflag.BoolVar(&hydrate, "hydrate", false, "Hash cloud files that aren't downloaded, downloading them")
+	pflag.BoolVarP(&nullDelim, "print0", "0", false, "NUL separated stdin path list and gnu/bsd lines")
+	pflag.BoolVar(&skipHidden, "skip-hidden", false, "Ignore hidden files and folders")
+	pflag.BoolVar(&archiveContents, "archive-contents", false, "Also hash the files inside .zip, .tar(.gz/.bz2/.xz), .7z and .iso files, as archive.zip!path")
+	pflag.IntVar(&maxDepth, "max-depth", 0, "How many folder levels deep -r goes, 0 for no limit")
+	pflag.StringSliceVar(&includes, "include", nil, "Only pick up files matching this pattern, eg. \"*.mkv,*.iso\"")
+	pflag.StringSliceVar(&exts, "ext", nil, "Only pick up files with these extensions, eg. \"mkv,iso,zip\"")
+	pflag.StringSliceVar(&excludes, "exclude", nil, "Skip files and folders matching this pattern (repeatable)")
+	pflag.BoolVarP(
+		&absolutePaths,
+		"absolute",
+		"a",
+		false,
+		"Use absolute paths in .fsh24 file",
+	) // New flag
+	pflag.StringVar(
+		&algorithm,
+		"algo",
+		fsh24.AlgoBLAKE2b,
+		"Hash algorithm: "+strings.Join(fsh24.Algorithms, ", "),
+	)
+	pflag.IntVar(
+		&digestBytes,
+		"digest-bytes",
+		fsh24.DefaultDigestBytes,
+		fmt.Sprintf("Hash length in bytes (%d-%d)", fsh24.MinDigestBytes, fsh24.MaxDigestBytes),
+	)
+	pflag.StringVar(
+		&sampleSizeStr,
+		"sample-size",
+		"4MB",
+		"Size of each sampled chunk, eg. 1MB or 16MB",
+	)
+	pflag.StringVar(&keyString, "key", "", "Secret key for keyed (tamper-evident) hashes")
+	pflag.StringVar(&keyFile, "key-file", "", "Read the secret key from a file")
+	pflag.StringVar(&signKey, "sign", "", "Sign the hash file with this GPG key (default key if no key is given)")
+	pflag.Lookup("sign").NoOptDefVal = defaultSignKey
+	pflag.BoolVar(&clearSign, "clearsign", false, "With --sign, put the signature in the hash file instead of a .asc")
+	pflag.BoolVar(&requireSignature, "require-signature", false, "Refuse to verify hash files without a good GPG signature")
+	pflag.BoolVar(&chained, "chain", false, "Append-only hash file, every line has a hash of the line before it")
+	pflag.StringVar(&signKeyFile, "sign-key", "", "Sign the hash file with this key from fsh24 keygen")
+	pflag.StringSliceVar(&trustedKeyFiles, "trusted-key", nil, "Public key from fsh24 keygen to trust signatures from (repeatable)")
+	pflag.BoolVar(&fullMode, "full", false, "Hash every byte of the file instead of sampling")
+	pflag.BoolVar(&randomOffsets, "random-offsets", false, "Put the middle samples at random places, from a seed stored in the hash file")
+	pflag.BoolVar(&adaptive, "adaptive", false, "Put the middle samples where the data is, not in runs of zeros, stored with each hash")
+	pflag.BoolVar(&fullSHA256, "sha256", false, "Also store a full file SHA-256 (reads every byte)")
+	pflag.BoolVar(&recordMetadata, "metadata", false, "Also store the mode, owner and xattrs of every file")
+	pflag.BoolVar(&merkle, "merkle", false, "Also store the Merkle root of the files, for proofs that a file is in the hash file")
+	pflag.StringVar(&merkleRoot, "root", "", "check-proof: the published Merkle root the proof has to be for")
+	pflag.BoolVar(&perDir, "per-dir", false, "Write a hash file into every folder for its own files, and an index of them as -o")
+	pflag.BoolVar(&dirHash, "dir-hash", false, "Also work out one hash for every folder, from the hashes and paths of its files")
+	pflag.IntVar(&jobs, "jobs", 0, "How many files to work on at once (default: CPU count, at most 4)")
+	pflag.StringVar(&netMode, "net-mode", "auto", "Read files the network share way: auto, on or off")
+	pflag.IntVar(&retries, "retries", 3, "How many times to retry a failed read in net mode")
+	pflag.IntVar(&lockRetries, "lock-retries", 3, "How many times to retry a file another program has locked")
+	pflag.DurationVar(&lockDelay, "lock-delay", fsh24.DefaultLockDelay, "Wait before the first retry of a locked file, doubling after")
+	pflag.BoolVar(&useMmap, "mmap", false, "Map local files into memory instead of reading them")
+	pflag.BoolVar(&useIOUring, "io-uring", false, "Linux: read the samples of a file through io_uring")
+	pflag.BoolVar(&direct, "direct", false, "Read files around the OS page cache")
+	pflag.StringVar(&baseDir, "base-dir", "", "Verify the files under this folder or URL instead of where they were hashed")
+	pflag.BoolVar(&normalizeUnicode, "normalize-unicode", false, "Store paths in NFC and find files whatever Unicode form their names are in")
+	pflag.StringArrayVar(&mapRules, "map", nil, "Verify paths starting with from under to instead, from=to (repeatable)")
+	pflag.BoolVar(&ignoreCase, "ignore-case", false, "Find files to verify whatever case their names are in")
+	pflag.BoolVar(&byName, "by-name", false, "With --base-dir, find the files by name anywhere under it")
+	pflag.BoolVar(&failFast, "fail-fast", false, "Stop verifying at the first missing or mismatched file")
+	pflag.BoolVar(&deep, "deep", false, "Verify the samples of every file first, then every byte of the ones with a full hash")
+	pflag.StringVar(&dbFile, "db", "", "Write the hashes to an SQLite database instead, or verify it")
+	pflag.BoolVar(&sfvOutput, "sfv", false, "Also write a CRC32 .sfv file (reads every byte)")
+	pflag.BoolVar(&torrent, "torrent", false, "Also write a .torrent of the files, with their BitTorrent piece hashes (reads every byte)")
+	pflag.StringVar(&pieceLengthStr, "piece-length", "", "With --torrent, the piece length, eg. 256KB or 4MB (default: picked from the total size)")
+	pflag.StringVar(&announce, "announce", "", "With --torrent, the tracker URL to put in it")
+	pflag.StringVar(
+		&format,
+		"format",
+		fsh24.FormatFSH24,
+		".fsh24 file format: "+strings.Join(fsh24.Formats, ", "),
+	)
+	pflag.BoolVar(&update, "update", false, "Only hash files not in the -o .fsh24 file yet and add them to it")
+	pflag.BoolVar(&incremental, "incremental", false, "--update that also re-hashes files whose size or mtime changed")
+	pflag.BoolVar(&prune, "prune", false, "Drop files that no longer exist from a .fsh24 file")
+	pflag.BoolVar(&resume, "resume", false, "Carry on with a run that crashed, skipping the files it already hashed")
+	pflag.StringVar(&conflict, "conflict", conflictError, "merge: what to do when hash files disagree, error or newest")
+	pflag.StringVar(&convertTo, "to", "", "convert: format to convert the hash file to")
+	pflag.BoolVar(&findDupes, "find-dupes", false, "List files with the same content instead of writing a .fsh24 file")
+	pflag.BoolVar(&tagFilenames, "tag-filename", false, "Rename files to have their FSH24 in the name, movie.[A1B2C3...].mkv")
+	pflag.BoolVar(&verifyTags, "verify-tags", false, "Check files against the FSH24 or CRC32 in their names, no hash file needed")
+	pflag.IntVar(&tagLength, "tag-length", 0, "With --tag-filename, only put this many characters of the hash in the name")
+	pflag.BoolVar(&confirmDupes, "confirm-dupes", false, "Read duplicates in full to make sure before listing them")
+	pflag.StringVar(&dedupeMode, "dedupe", "", "Replace confirmed duplicates with links to one of them, hardlink or reflink")
+	pflag.BoolVar(&dryRun, "dry-run", false, "With --dedupe, only report what would be linked. self-update: only check for a new release")
+	pflag.DurationVar(&interval, "interval", defaultInterval, "daemon: time between checks")
+	pflag.StringVar(&onFail, "on-fail", "", "Command to run when the run, or a daemon check, fails, with the results in FSH24_ variables")
+	pflag.StringVar(&onFail, "on-failure", "", "Same as --on-fail, what daemon called it first")
+	pflag.StringVar(&onComplete, "on-complete", "", "Command to run when the run is done, failed or not")
+	pflag.StringVar(&notifyURL, "notify-url", "", "daemon: URL to POST failed checks to")
+	pflag.StringVar(&metricsListen, "metrics", "", "daemon: address to serve Prometheus metrics on")
+	pflag.StringSliceVar(&mailTo, "mail-to", nil, "Mail a report to this address when done, eg. \"me@example.com,ops@example.com\"")
+	pflag.StringVar(&mailFrom, "mail-from", "", "Sender address of the report mail (default: fsh24@ this machine)")
+	pflag.StringVar(&smtpServer, "smtp", "localhost:25", "SMTP server to send the report mail through, host:port")
+	pflag.StringVar(&mailOn, "mail-on", "failure", "When to mail the report: failure or always")
+	pflag.StringVar(&listen, "listen", defaultListen, "serve: address to listen on")
+	pflag.StringVar(&grpcListen, "grpc", "", "serve: address for the gRPC API")
+	pflag.StringVar(&summaryFile, "summary-file", "", "Save a JSON summary of the run, warnings included")
+	pflag.StringVar(&sortOrder, "sort", sortName, "Order of the files in the hash file and reports: "+strings.Join(sortModes, ", "))
+	pflag.StringVar(&jsonOut, "json-out", "", "Also save the -j JSON report to this file, next to the hash file and console output")
+	pflag.BoolVarP(&showHelpFlag, "help", "h", false, "Show help message")
+	pflag.BoolVar(&showVersion, "version", false, "Show the version of fsh24 and the hash file formats it knows")
+	pflag.CommandLine.Init(os.Args[0], pflag.ContinueOnError) // pflag would exit with 2, that's exitMissing
+	if err := pflag.CommandLine.Parse(os.Args[1:]); err != nil {
+		os.Exit(exitUsage)
+	}
+
+	// Report formats keep stdout for the report and stderr for the run summary
+	structured = jsonOutput || slices.Contains(reportFormats, format)
+	setupColor(noColor || structured)
+
+	// Handle help flag
+	if showHelpFlag {
+		showHelp(noPause)
+		return
+	}
+
+	args := pflag.Args()
+	// \\?\ paths (long paths pasted from Explorer) are worked with in the
+	// plain form, the ? would be a wildcard and the manifest couldn't make
+	// them relative. Long ones get the prefix back when files are opened
+	for i, arg := range args {
+		args[i] = fsh24.PlainPath(arg)
+	}
+	outputFile = fsh24.PlainPath(outputFile)
+	baseDir = fsh24.PlainPath(baseDir)
+
+	if showVersion || (len(args) > 0 && args[0] == "version") {
+		if len(args) > 1 {
+			fatalf(exitUsage, "version takes no files")
+		}
+		printVersion(jsonOutput)
+		return
+	}
+	if len(args) > 0 && args[0] == "schema" {
+		// Print the JSON Schema of the -j output, no banner so it can be piped to a file
+		if len(args) != 2 {
+			fatalf(exitUsage, "schema takes the name of one, use one of: %s", strings.Join(schemaNames, ", "))
+		}
+		if err := printSchema(args[1]); err != nil {
+			fatalf(exitUsage, "%v", err)
+		}
+		return
+	}
+
+	if !slices.Contains(mailOnModes, mailOn) {
+		fatalf(exitUsage, "unknown --mail-on %q, use one of: %s", mailOn, strings.Join(mailOnModes, ", "))
+	}
+	if len(mailTo) == 0 && (pflag.CommandLine.Changed("mail-from") || pflag.CommandLine.Changed("smtp") || pflag.CommandLine.Changed("mail-on")) {
+		fatalf(exitUsage, "--mail-from, --smtp and --mail-on need --mail-to, who to send the report to")
+	}
+	mail = mailOptions{To: mailTo, From: mailFrom, SMTP: smtpServer, Always: mailOn == "always"}
+	runTarget = strings.Join(args, " ")
+	if dbFile != "" {
+		runTarget = strings.TrimSpace(runTarget + " " + dbFile)
+		runHashFile = dbFile
+	}
+
+	if !fsh24.ValidAlgorithm(algorithm) {
+		fatalf(exitUsage, "unsupported hash algorithm %q, use one of: %s", algorithm, strings.Join(fsh24.Algorithms, ", "))
+	}
+	if algo, _ := fsh24.LookupAlgorithm(algorithm); algo.DigestBytes != 0 && !pflag.CommandLine.Changed("digest-bytes") {
+		digestBytes = 0 // xxh3 and the like have their own fixed length
+	}
+	if err := fsh24.ValidateDigestBytes(algorithm, digestBytes); err != nil {
+		fatalf(exitUsage, "%v", err)
+	}
+
+	if !slices.Contains(fsh24.Formats, format) && !slices.Contains(reportFormats, format) {
+		fatalf(
+			exitUsage,
+			"unknown --format %q, use one of: %s",
+			format,
+			strings.Join(append(fsh24.Formats, reportFormats...), ", "),
+		)
+	}
+
+	// Report formats print the results rather than writing a .fsh24 file
+	report := ""
+	if jsonOutput {
+		report = reportJSON
+	}
+	if slices.Contains(reportFormats, format) {
+		report = format
+	}
+
+	if !slices.Contains(sortModes, sortOrder) {
+		fatalf(exitUsage, "unknown --sort %q, use one of: %s", sortOrder, strings.Join(sortModes, ", "))
+	}
+	if followLinks && skipLinks {
+		fatalf(exitUsage, "--follow-symlinks and --skip-symlinks can't be used together")
+	}
+	if maxDepth < 0 {
+		fatalf(exitUsage, "--max-depth can't be negative")
+	}
+	if jobs < 0 {
+		fatalf(exitUsage, "--jobs can't be negative")
+	}
+	if retries < 0 {
+		fatalf(exitUsage, "--retries can't be negative")
+	}
+	if lockRetries < 0 {
+		fatalf(exitUsage, "--lock-retries can't be negative")
+	}
+	if lockDelay <= 0 {
+		fatalf(exitUsage, "--lock-delay has to be more than 0")
+	}
+	if useMmap && useIOUring {
+		fatalf(exitUsage, "--mmap and --io-uring can't be used together, pick one")
+	}
+	if direct && (useMmap || useIOUring) {
+		fatalf(exitUsage, "--direct can't be used with --mmap or --io-uring, those go through the file cache")
+	}
+	if useIOUring {
+		if err := fsh24.IOUringError(); err != nil {
+			warnf("", "Can't use io_uring, reading files the normal way: %v", err)
+			useIOUring = false
+		}
+	}
+	readMode, err := fsh24.ParseNetMode(netMode)
+	if err != nil {
+		fatalf(exitUsage, "invalid --net-mode: %v", err)
+	}
+	if byName && baseDir == "" {
+		fatalf(exitUsage, "--by-name needs --base-dir, the folder to look for the files in")
+	}
+	if byName && fsh24.IsRemote(baseDir) {
+		fatalf(exitUsage, "--by-name can't search a URL, only a local folder")
+	}
+	if incremental {
+		update = true
+		if !fsh24.HasFields(format) {
+			if pflag.CommandLine.Changed("format") {
+				fatalf(exitUsage, "--incremental needs --format fsh24-2 or fsh24-bin, the only formats that record modification times")
+			}
+			format = fsh24.FormatFSH24v2
+		}
+	}
+	if recordMetadata {
+		if dbFile != "" {
+			fatalf(exitUsage, "--metadata can't be stored in a --db, only in fsh24-2 hash files and reports")
+		}
+		if report == "" && !fsh24.HasFields(format) {
+			if pflag.CommandLine.Changed("format") {
+				fatalf(exitUsage, "--metadata needs --format fsh24-2 or fsh24-bin, the only formats with room for it")
+			}
+			format = fsh24.FormatFSH24v2
+		}
+	}
+	if adaptive {
+		if dbFile != "" || report == reportCSV {
+			fatalf(exitUsage, "--adaptive stores where the samples went with every hash, a --db and csv reports have no room for it")
+		}
+		if report == "" && !fsh24.HasFields(format) {
+			if pflag.CommandLine.Changed("format") {
+				fatalf(exitUsage, "--adaptive needs --format fsh24-2 or fsh24-bin, the only formats with room for where the samples went")
+			}
+			format = fsh24.FormatFSH24v2
+		}
+	}
+	if merkle {
+		if dbFile != "" || report != "" {
+			fatalf(exitUsage, "--merkle is stored in fsh24-2 hash files, not a --db or report")
+		}
+		if !fsh24.HasFields(format) {
+			if pflag.CommandLine.Changed("format") {
+				fatalf(exitUsage, "--merkle needs --format fsh24-2 or fsh24-bin, the only formats with room for it")
+			}
+			format = fsh24.FormatFSH24v2
+		}
+	}
+	if dirHash {
+		if dbFile != "" {
+			fatalf(exitUsage, "--dir-hash can't be stored in a --db, only in fsh24-2 hash files and reports")
+		}
+		if report == "" && !fsh24.HasFields(format) {
+			if pflag.CommandLine.Changed("format") {
+				fatalf(exitUsage, "--dir-hash needs --format fsh24-2 or fsh24-bin, the only formats with room for it")
+			}
+			format = fsh24.FormatFSH24v2
+		}
+	}
+	if perDir {
+		if dbFile != "" || report != "" || update || prune || sfvOutput || torrent || merkle || dirHash || resume {
+			fatalf(exitUsage, "--per-dir writes plain hash files, it can't be used with --db, a report format, --update, --prune, --sfv, --torrent, --merkle, --dir-hash or --resume")
+		}
+		if outputFile == "" {
+			outputFile = perDirIndex
+		}
+	}
+	if dedupeMode != "" {
+		if !slices.Contains(dedupeModes, dedupeMode) {
+			fatalf(exitUsage, "unknown --dedupe %q, use one of: %s", dedupeMode, strings.Join(dedupeModes, ", "))
+		}
+		confirmDupes = true // Only files that are the same to the byte get replaced
+	} else if dryRun && (len(args) == 0 || args[0] != "self-update") {
+		fatalf(exitUsage, "--dry-run goes with --dedupe or fsh24 self-update, the only things that change files")
+	}
+	if confirmDupes {
+		findDupes = true
+	}
+	if findDupes && slices.Contains(exportFormats, report) {
+		fatalf(exitUsage, "--format %s is only for hashing, --find-dupes can print json, csv, ndjson or yaml", report)
+	}
+	if findDupes && (update || prune || dbFile != "" || sfvOutput) {
+		fatalf(exitUsage, "--find-dupes only lists duplicates, it can't be used with --update, --prune, --db or --sfv")
+	}
+	if tagFilenames && (findDupes || update || prune || report != "" || dbFile != "" || sfvOutput || resume) {
+		fatalf(exitUsage, "--tag-filename only renames files, it can't be used with --find-dupes, --update, --prune, --resume, --db, --sfv or a report format")
+	}
+	if verifyTags && (tagFilenames || findDupes || update || prune || dbFile != "" || sfvOutput || resume || byName || baseDir != "") {
+		fatalf(exitUsage, "--verify-tags checks files by their names, it can't be used with --tag-filename, --find-dupes, --update, --prune, --resume, --db, --sfv, --base-dir or --by-name")
+	}
+	if verifyTags && slices.Contains(exportFormats, report) {
+		fatalf(exitUsage, "--format %s is only for hashing, verify can print json, csv, ndjson or yaml", report)
+	}
+	if tagLength != 0 && (tagLength < minTagLength || !tagFilenames) {
+		fatalf(exitUsage, "--tag-length has to be at least %d, and goes with --tag-filename", minTagLength)
+	}
+	if torrent && (findDupes || tagFilenames || verifyTags || update || prune || resume || report != "" || dbFile != "") {
+		fatalf(exitUsage, "--torrent goes next to a new .fsh24 file, it can't be used with --find-dupes, --tag-filename, --verify-tags, --update, --prune, --resume, --db or a report format")
+	}
+	if (pieceLengthStr != "" || announce != "") && !torrent {
+		fatalf(exitUsage, "--piece-length and --announce go with --torrent")
+	}
+	pieceLength := int64(0)
+	if pieceLengthStr != "" {
+		pieceLength, err = parseSize(pieceLengthStr)
+		if err == nil {
+			err = fsh24.ValidatePieceLength(pieceLength)
+		}
+		if err != nil {
+			fatalf(exitUsage, "invalid --piece-length: %v", err)
+		}
+	}
+	if (update || prune) && (report != "" || dbFile != "" || sfvOutput) {
+		fatalf(exitUsage, "--update and --prune only work on .fsh24 files, not with --db, --sfv or a report format")
+	}
+	if resume && (report != "" || findDupes) {
+		fatalf(exitUsage, "--resume only works when writing a .fsh24 file or --db, not with --find-dupes or a report format")
+	}
+
+	sampleSize, err := parseSize(sampleSizeStr)
+	if err == nil {
+		err = fsh24.ValidateSampleSize(int(sampleSize))
+	}
+	if err != nil {
+		fatalf(exitUsage, "invalid --sample-size: %v", err)
+	}
+	if randomOffsets && fullMode {
+		fatalf(exitUsage, "--random-offsets moves the samples, --full has none, it reads everything")
+	}
+	if adaptive && fullMode {
+		fatalf(exitUsage, "--adaptive moves the samples, --full has none, it reads everything")
+	}
+	if adaptive && randomOffsets {
+		fatalf(exitUsage, "--adaptive and --random-offsets both pick where the samples go, use one of them")
+	}
+	if randomOffsets && (format == fsh24.FormatGNU || format == fsh24.FormatBSD || format == fsh24.FormatSFV) {
+		fatalf(exitUsage, "--random-offsets needs a hash file with a header to store the seed in, fsh24, fsh24-2 or fsh24-bin")
+	}
+
+	key := []byte(keyString)
+	if keyFile != "" {
+		var err error
+		if key, err = os.ReadFile(keyFile); err != nil {
+			fatalf(exitError, "could not read key file: %v", err)
+		}
+	}
+	if err := fsh24.ValidateKey(algorithm, key); err != nil {
+		fatalf(exitUsage, "%v", err)
+	}
+	if signKeyFile != "" {
+		if signingKey, err = loadSigningKey(signKeyFile); err != nil {
+			fatalf(exitError, "could not read --sign-key: %v", err)
+		}
+	}
+	for _, rule := range mapRules {
+		m, err := fsh24.ParsePathMap(rule)
+		if err != nil {
+			fatalf(exitUsage, "--map %q: %v", rule, err)
+		}
+		pathMaps = append(pathMaps, m)
+	}
+	for _, f := range trustedKeyFiles {
+		pub, err := loadTrustedKey(f)
+		if err != nil {
+			fatalf(exitError, "could not read --trusted-key: %v", err)
+		}
+		trustedKeys = append(trustedKeys, pub)
+	}
+	if chained && (dbFile != "" || report != "" || format != fsh24.FormatFSH24) {
+		fatalf(exitUsage, "--chain only works for FSH24-1 hash files, not --db, --format %s or a report format", format)
+	}
+	if clearSign && signKey == "" {
+		fatalf(exitUsage, "--clearsign needs --sign")
+	}
+	if (signKey != "" || signingKey != nil) && (dbFile != "" || report != "") {
+		fatalf(exitUsage, "--sign and --sign-key only sign hash files, not --db or a report format")
+	}
+	if requireSignature && dbFile != "" {
+		fatalf(exitUsage, "--require-signature only works for hash files, a --db can't be signed")
+	}
+
+	hasher := fsh24.NewHasher()
+	hasher.Algorithm = algorithm
+	hasher.DigestBytes = digestBytes
+	hasher.SampleSize = int(sampleSize)
+	hasher.Key = key
+	hasher.SHA256 = fullSHA256
+	hasher.Metadata = recordMetadata
+	hasher.Full = fullMode
+	if randomOffsets {
+		hasher.Seed = fsh24.NewSeed()
+	}
+	hasher.Adaptive = adaptive
+	hasher.CRC32 = sfvOutput || format == fsh24.FormatSFV
+	hasher.Jobs = jobs
+	hasher.NetMode = readMode
+	hasher.Retries = retries
+	hasher.LockRetries = lockRetries
+	hasher.LockDelay = lockDelay
+	hasher.Mmap = useMmap
+	hasher.IOUring = useIOUring
+	hasher.Direct = direct
+
+	proofToStdout := len(args) > 0 && args[0] == "proof" && outputFile == "" // The proof is the output, nothing else
+	if report == "" && !quiet && !proofToStdout {
+		fmt.Print("FSH24 - Fast Sample based Hash 24-byte.\nMobCat 20250715\n\n")
+	}
+
+	if len(args) == 0 && dbFile == "" && !guiByDefault {
+		fmt.Println("Usage: fsh24 [flags] <file(s)|folder(s)|URL(s)|.fsh24 file>")
+		if noPause {
+			exit(exitUsage)
+		}
+		fmt.Print("\nPress 'h' for help or any other key to exit: ")
+
+		var input string
+		fmt.Scanln(&input)
+
+		if strings.ToLower(strings.TrimSpace(input)) == "h" {
+			fmt.Println()
+			showHelp(noPause)
+			return
+		}
+
+		exit(exitUsage)
+	}
+
+	// Ctrl+C cancels the run, whatever finished so far is still reported / saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Get the current working directory. This will be the base for relative paths.
+	cwd, err := os.Getwd()
+	if err != nil {
+		fatalf(exitError, "could not get current working directory: %v", err)
+	}
+
+	walk := walkOptions{
+		Recursive:       recursive || maxDepth > 0,
+		Exclude:         excludes,
+		Include:         includes,
+		Exts:            normalizeExts(exts),
+		MaxDepth:        maxDepth,
+		FollowSymlinks:  followLinks,
+		SkipSymlinks:    skipLinks,
+		FollowJunctions: followJunctions,
+		Hydrate:         hydrate,
+		SkipHidden:      skipHidden,
+		ArchiveContents: archiveContents,
+		Hasher:          hasher,
+		NullInput:       nullDelim,
+		Sort:            sortOrder,
+		OnSkip: func(path, reason string) {
+			if verbose >= verboseTimings && !structured {
+				fmt.Printf("Skipped (%s): %s\n", reason, path)
+			}
+		},
+	}
+
+	if len(args) > 0 && args[0] == "cmp" {
+		// Compare mode, hash two folders and line them up
+		run.Mode = "cmp"
+		if len(args) != 3 {
+			fatalf(exitUsage, "cmp needs two folders, fsh24 cmp DIR1 DIR2, or two hash files of them")
+		}
+		if slices.Contains(exportFormats, report) {
+			fatalf(exitUsage, "--format %s is only for hashing, cmp can print json, csv, ndjson or yaml", report)
+		}
+		walk.Recursive = true // Whole tree, --max-depth still applies
+		var out cmpOutput
+		if isFile(args[1]) && isFile(args[2]) {
+			out, err = compareHashFiles(args[1], args[2]) // Nothing to hash, the hash files have it all
+		} else {
+			out, err = compareFolders(ctx, hasher, walk, args[1], args[2])
+		}
+		if err != nil && ctx.Err() == nil {
+			fatalf(exitError, "%v", err)
+		}
+		if ctx.Err() != nil {
+			run.Error = "interrupted"
+			exit(exitInterrupted)
+		}
+
+		if report != "" {
+			reportBytes, err := cmpReport(report, out)
+			if err != nil {
+				fatalf(exitError, "could not marshal %s: %v", report, err)
+			}
+			fmt.Print(string(reportBytes))
+			if report == reportJSON {
+				fmt.Println()
+			}
+		} else {
+			printCompare(out, verbose, quiet)
+		}
+		run.Total = len(out.Files)
+		run.OK = out.Summary.Same
+		run.Failed = run.Total - run.OK
+
+		code := exitOK
+		if !out.Summary.Match {
+			code = exitFailed
+		}
+		if report == "" {
+			pause(noPause)
+		}
+		exit(code)
+	}
+
+	if len(args) > 0 && args[0] == "watch" {
+		// Watch mode, keep -o up to date with a folder until Ctrl+C
+		run.Mode = "watch"
+		if len(args) != 2 {
+			fatalf(exitUsage, "watch needs one folder, fsh24 watch DIR -o folder.fsh24")
+		}
+		if report != "" || dbFile != "" || sfvOutput || torrent {
+			fatalf(exitUsage, "watch only writes .fsh24 files, not --db, --sfv, --torrent or a report format")
+		}
+		if chained {
+			fatalf(exitUsage, "watch can't keep a --chain hash file, it rewrites the lines of files that change")
+		}
+		target := outputFile
+		if target == "" {
+			target = "checksums.fsh24"
+		}
+		if err := watchFolder(ctx, hasher, walk, args[1], target, format, absolutePaths, prune, quiet); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		fmt.Println("Stopped watching")
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "daemon" {
+		// Daemon mode, re-verify the hash files every --interval until Ctrl+C
+		run.Mode = "daemon"
+		if len(args) < 2 && dbFile == "" {
+			fatalf(exitUsage, "daemon needs hash files to check, fsh24 daemon --interval 168h archive.fsh24")
+		}
+		if report != "" {
+			fatalf(exitUsage, "daemon logs to the console, it can't print a report format")
+		}
+		if interval <= 0 {
+			fatalf(exitUsage, "--interval has to be more than 0")
+		}
+		opts := verifyOptions{
+			Hasher:   hasher,
+			Verbose:  verbose,
+			Quiet:    quiet || verbose < verboseInfo, // Only what failed, it's a log
+			BaseDir:  baseDir,
+			ByName:   byName,
+			Walk:     walk,
+			FailFast: failFast,
+			Deep:     deep,
+		}
+		d := daemonOptions{Interval: interval, OnFailure: onFail, NotifyURL: notifyURL, Metrics: metricsListen, Mail: mail}
+		runDaemon(ctx, args[1:], dbFile, opts, d)
+		fmt.Println("Stopped")
+		exit(exitOK)
+	}
+
+	if isGUIArg(args) && dbFile == "" {
+		// GUI mode, a page in the browser to drop files on until Ctrl+C
+		run.Mode = "gui"
+		if len(args) > 1 {
+			fatalf(exitUsage, "gui takes no files, they're dropped on the page")
+		}
+		if !pflag.CommandLine.Changed("listen") {
+			listen = guiListen
+		}
+		if err := runGUI(ctx, listen, hasher); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		fmt.Println("Stopped")
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "serve" {
+		// Server mode, hash and verify over HTTP until Ctrl+C
+		run.Mode = "serve"
+		if len(args) != 1 {
+			fatalf(exitUsage, "serve takes no files, they come in the requests")
+		}
+		if grpcListen != "" {
+			go func() {
+				if err := serveGRPC(ctx, grpcListen, hasher, walk); err != nil {
+					fatalf(exitError, "gRPC: %v", err)
+				}
+			}()
+		}
+		if err := serveHTTP(ctx, listen, hasher, walk); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		fmt.Println("Stopped")
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "proof" {
+		// Proof mode, show a file is in a hash file without handing out the rest
+		run.Mode = "proof"
+		if len(args) != 3 {
+			fatalf(exitUsage, "proof needs a hash file and the path of a file in it, fsh24 proof release.fsh24 disc1.iso")
+		}
+		proof, err := makeProof(args[1], args[2], outputFile)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		run.Total, run.OK = 1, 1
+		if outputFile != "" {
+			fmt.Printf("Proof that %s is in Merkle root %s saved to: %s\n", proof.Path, proof.Root, outputFile)
+		}
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "check-proof" {
+		// Check a proof from fsh24 proof, and the file it's for if given
+		run.Mode = "check-proof"
+		if len(args) != 2 && len(args) != 3 {
+			fatalf(exitUsage, "check-proof needs a proof and optionally the file, fsh24 check-proof --root HASH disc1.proof disc1.iso")
+		}
+		file := ""
+		if len(args) == 3 {
+			file = args[2]
+		}
+		run.Total = 1
+		proof, err := checkProof(ctx, args[1], file, merkleRoot, hasher)
+		if proof == nil && err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		if err != nil {
+			run.Failed = 1
+			fmt.Println(colorize(colorRed, "PROOF FAILED: "+err.Error()))
+			pause(noPause)
+			exit(exitFailed)
+		}
+		run.OK = 1
+		checked := proof.Path
+		if file != "" {
+			checked = file + " (hashed, " + proof.FSH24 + ")"
+		}
+		fmt.Println(colorize(colorGreen, fmt.Sprintf("Proof OK: %s is in the hash file with Merkle root %s", checked, proof.Root)))
+		if merkleRoot == "" {
+			fmt.Println("Check that root is the one that was published, or give it with --root")
+		}
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "self-update" {
+		// Self update, swap this fsh24 for the latest release
+		run.Mode = "self-update"
+		if len(args) != 1 {
+			fatalf(exitUsage, "self-update takes no files, to update a hash file use --update -o file.fsh24")
+		}
+		if err := selfUpdate(ctx, dryRun); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "coverage" {
+		// Coverage mode, how much of the files the samples read, nothing is hashed
+		run.Mode = "coverage"
+		if len(args) < 2 {
+			fatalf(exitUsage, "coverage needs a hash file, or files and folders to see what hashing them would cover, fsh24 coverage archive.fsh24")
+		}
+		if slices.Contains(exportFormats, report) {
+			fatalf(exitUsage, "--format %s is only for hashing, coverage can print json, csv, ndjson or yaml", report)
+		}
+		var out coverageOutput
+		if len(args) == 2 && (checkFile || isHashFileName(args[1])) && !fsh24.IsRemote(args[1]) {
+			if out, err = hashFileCoverage(args[1]); err != nil {
+				fatalf(exitError, "%v", err)
+			}
+		} else {
+			files, err := expandFilePaths(args[1:], walk)
+			if err != nil {
+				fatalf(exitError, "could not expand file paths: %v", err)
+			}
+			out = filesCoverage(hasher, files)
+		}
+		run.Total, run.OK = len(out.Files), len(out.Files)
+
+		if report != "" {
+			reportBytes, err := coverageReport(report, out)
+			if err != nil {
+				fatalf(exitError, "could not marshal %s: %v", report, err)
+			}
+			fmt.Print(string(reportBytes))
+			if report == reportJSON {
+				fmt.Println()
+			}
+			exit(exitOK)
+		}
+		printCoverage(out, quiet)
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "keygen" {
+		// Make a key pair for --sign-key and --trusted-key
+		run.Mode = "keygen"
+		if len(args) != 1 {
+			fatalf(exitUsage, "keygen takes no files, use -o to name the keys, fsh24 keygen -o mykey")
+		}
+		name := outputFile
+		if name == "" {
+			name = "fsh24"
+		}
+		if err := keygen(name); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "merge" {
+		// Merge mode, combine hash files into -o
+		run.Mode = "merge"
+		if len(args) < 3 {
+			fatalf(exitUsage, "merge needs at least two hash files, fsh24 merge a.fsh24 b.fsh24 -o combined.fsh24")
+		}
+		if !slices.Contains(conflictModes, conflict) {
+			fatalf(exitUsage, "unknown --conflict %q, use one of: %s", conflict, strings.Join(conflictModes, ", "))
+		}
+		target := outputFile
+		if target == "" {
+			target = "checksums.fsh24"
+		}
+		merged, conflicts, err := mergeHashFiles(args[1:], target, conflict, absolutePaths)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		if pflag.CommandLine.Changed("format") {
+			merged.Format = format
+		}
+		if i := slices.IndexFunc(merged.Entries, func(e fsh24.Entry) bool { return e.Offsets != nil }); i >= 0 && !fsh24.HasFields(merged.Format) {
+			fatalf(exitUsage, "%s was hashed with --adaptive, --format %s has no room for where its samples are", merged.Entries[i].Path, merged.Format)
+		}
+		if err := writeHashFile(merged, target); err != nil {
+			fatalf(exitError, "could not write hash file: %v", err)
+		}
+		if !quiet {
+			for _, c := range conflicts {
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("Conflict: %s, kept the newer one from %s", c.Path, c.Kept)))
+			}
+		}
+		run.Total, run.OK = len(merged.Entries), len(merged.Entries)
+		fmt.Printf("Merged %d files from %d hash files into: %s\n", len(merged.Entries), len(args)-1, target)
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "convert" {
+		// Convert mode, turn a hash file or report into another format
+		run.Mode = "convert"
+		if len(args) != 2 || convertTo == "" {
+			fatalf(exitUsage, "convert takes one hash file and --to, fsh24 convert checksums.fsh24 --to json")
+		}
+		if !slices.Contains(convertTargets, convertTo) {
+			fatalf(exitUsage, "unknown --to %q, use one of: %s", convertTo, strings.Join(convertTargets, ", "))
+		}
+		if chained && convertTo != fsh24.FormatFSH24 {
+			fatalf(exitUsage, "--chain only works for FSH24-1 hash files, not --to %s", convertTo)
+		}
+		if (signKey != "" || signingKey != nil) && !slices.Contains(fsh24.Formats, convertTo) && convertTo != convertSHA256sum {
+			fatalf(exitUsage, "--sign and --sign-key only sign hash files, not --to %s", convertTo)
+		}
+		target := outputFile
+		if target == "" {
+			name := fsh24.TrimGzipExt(args[1])
+			target = strings.TrimSuffix(name, filepath.Ext(name)) + convertExt(convertTo)
+		}
+		n, err := convertHashFile(args[1], target, convertTo, hasher)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		run.Total, run.OK = n, n
+		fmt.Printf("Converted %d files from %s to %s: %s\n", n, args[1], convertTo, target)
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	if len(args) > 0 && args[0] == "selftest" {
+		// Self test, hash the built in test files and check we still get the known hashes
+		run.Mode = "selftest"
+		if len(args) != 1 {
+			fatalf(exitUsage, "selftest takes no files, it makes its own")
+		}
+		failed, err := selftest(ctx, quiet)
+		if err != nil {
+			fatalf(exitError, "selftest: %v", err)
+		}
+		if failed > 0 {
+			fmt.Println(colorize(colorRed, fmt.Sprintf("\n%d of %d test vectors failed, this fsh24 doesn't make the same hashes as fsh24.py", failed, run.Total)))
+			pause(noPause)
+			exit(exitFailed)
+		}
+		fmt.Println(colorize(colorGreen, fmt.Sprintf("\nAll %d test vectors OK", run.Total)))
+		pause(noPause)
+		exit(exitOK)
+	}
+
+	// Check if we have a single .fsh24 or .sfv file, or just a --db (verify mode)
+	if checkFile && (len(args) != 1 || fsh24.IsRemote(args[0])) {
+		fatalf(exitUsage, "--check verifies one hash file, fsh24 -c sums.txt")
+	}
+	hashFileGiven := len(args) == 1 && !fsh24.IsRemote(args[0]) && (checkFile || isHashFileName(args[0]))
+	// A folder with its hash file in it was most likely dropped on us to be checked
+	if !hashFileGiven && len(args) == 1 && !noAutoVerify && !wantsHashing(report) {
+		if found := folderHashFile(args[0]); found != "" && autoVerify(found, !noPause && report == "", quiet || report != "") {
+			args[0] = found
+			hashFileGiven = true
+		}
+	}
+	if prune && !update {
+		// Prune mode, only clean up the hash file
+		if !hashFileGiven {
+			fatalf(exitUsage, "--prune needs a .fsh24 file, or --update -o file.fsh24 to prune while adding")
+		}
+		run.Mode = "prune"
+		if err := pruneHashFile(args[0], quiet); err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		pause(noPause)
+		exit(exitOK)
+	}
+	if len(args) == 0 || hashFileGiven {
+		// Verify mode
+		var (
+			summary fsh24.VerificationSummary
+			results []fsh24.FileVerificationResult
+		)
+		opts := verifyOptions{
+			Hasher:   hasher,
+			Verbose:  verbose,
+			Report:   report,
+			Quiet:    quiet,
+			BaseDir:  baseDir,
+			ByName:   byName,
+			Walk:     walk,
+			FailFast: failFast,
+			Deep:     deep,
+		}
+		run.Mode = "verify"
+		if slices.Contains(exportFormats, report) {
+			fatalf(exitUsage, "--format %s is only for hashing, verify can print json, csv, ndjson or yaml", report)
+		}
+		if len(args) == 0 {
+			summary, results, err = verifyDatabase(ctx, dbFile, opts)
+		} else {
+			summary, results, err = verifyHashFile(ctx, args[0], opts)
+		}
+		finishVerify(ctx, summary, results, err, report, noPause)
+	} else {
+		// Hash mode (files and/or folders)
+		run.Mode = "hash"
+		if perDir { // Last run's, they'd be changed by the time they're listed
+			walk.Exclude = append(walk.Exclude, perDirFile(outputFile), perDirFile(outputFile)+".asc")
+		}
+		expandedFiles, err := expandFilePaths(args, walk)
+		if err != nil {
+			fatalf(exitError, "could not expand file paths: %v", err)
+		}
+
+		// Don't hash our own output files, they change as soon as we write them
+		outputs := []string{outputFile, dbFile, jsonOut}
+		if outputFile != "" {
+			outputs = append(outputs, outputFile+".asc")
+		}
+		if report == "" && outputFile == "" {
+			outputs = append(outputs, "checksums.fsh24", "checksums.fsh24.asc", "checksums.sfv")
+		} else if sfvOutput && outputFile != "" {
+			outputs = append(outputs, sfvName(outputFile))
+		}
+		if torrent && outputFile == "" {
+			outputs = append(outputs, torrentName("checksums.fsh24"))
+		} else if torrent {
+			outputs = append(outputs, torrentName(outputFile))
+		}
+		// Nor the checkpoint of the run, see --resume
+		partial := ""
+		if report == "" {
+			partial = partialFile("checksums.fsh24")
+			if dbFile != "" {
+				partial = partialFile(dbFile)
+			} else if outputFile != "" {
+				partial = partialFile(outputFile)
+			}
+			outputs = append(outputs, partial, partial+".tmp")
+		}
+		expandedFiles = withoutFiles(expandedFiles, outputs)
+
+		if verifyTags {
+			// Check the files against the hashes in their names, there's no hash file
+			run.Mode = "verify"
+			m, untagged := tagManifest(expandedFiles)
+			if len(m.Entries) == 0 {
+				fatalf(exitUsage, "none of the %d files have a hash in their name", len(expandedFiles))
+			}
+			if untagged > 0 && report == "" && !quiet {
+				fmt.Printf("Skipping %d files without a hash in their name\n", untagged)
+			}
+			opts := verifyOptions{
+				Hasher:      hasher,
+				Verbose:     verbose,
+				Report:      report,
+				Quiet:       quiet,
+				FailFast:    failFast,
+				ShortHashes: true,
+			}
+			summary, results, err := verifyManifest(ctx, m, "", opts)
+			finishVerify(ctx, summary, results, err, report, noPause)
+		}
+
+		// --update only hashes what the .fsh24 file doesn't have yet
+		var existing *existingManifest
+		if update {
+			target := outputFile
+			if target == "" {
+				target = "checksums.fsh24"
+			}
+			existing, err = loadExisting(target)
+			if err != nil {
+				fatalf(exitError, "%v", err)
+			}
+			if existing != nil {
+				if err := existing.setupHasher(hasher); err != nil {
+					fatalf(exitUsage, "%v", err)
+				}
+				if hasher.Adaptive && !fsh24.HasFields(existing.Format) {
+					fatalf(exitUsage, "%s has no room for where --adaptive puts the samples, fsh24 convert it --to fsh24-2 first", target)
+				}
+				if existing.Chained && (incremental || prune) {
+					fatalf(exitUsage, "%s is chained, it can only be added to. --incremental and --prune would change lines already in it", target)
+				}
+				if incremental && !fsh24.HasFields(existing.Format) {
+					if existing.Format != fsh24.FormatFSH24 {
+						fatalf(exitUsage, "--incremental can't update a %s file, it has no modification times", existing.Format)
+					}
+					// Everything gets hashed once more this time, the times are recorded from now on
+					existing.Format = fsh24.FormatFSH24v2
+					fmt.Printf("Converting %s to FSH24-2 so modification times can be recorded\n", target)
+				}
+				var removed []fsh24.Entry
+				if prune {
+					removed = existing.prune()
+					printPruned(removed, quiet)
+				}
+				found := len(expandedFiles)
+				expandedFiles = existing.filesToHash(expandedFiles, incremental)
+				if len(expandedFiles) == 0 && found > 0 {
+					nothing := "No new files"
+					if incremental {
+						nothing = "No new or changed files"
+					}
+					if len(removed) > 0 {
+						if err := writeHashFile(existing.Manifest, target); err != nil {
+							fatalf(exitError, "could not write hash file: %v", err)
+						}
+						fmt.Printf("%s, pruned %d missing files from: %s\n", nothing, len(removed), target)
+					} else {
+						fmt.Printf("%s, %s is up to date\n", nothing, target)
+					}
+					exit(exitOK)
+				}
+			}
+		}
+
+		run.Total = len(expandedFiles)
+		if len(expandedFiles) == 0 {
+			fatalf(exitUsage, "no files found to process")
+		}
+
+		if findDupes {
+			// Dupes mode, hash everything and group it, no hash file is written
+			run.Mode = "dupes"
+			fileResults, errs := hasher.HashFiles(ctx, expandedFiles)
+			for _, err := range errs {
+				fe := err.(*fsh24.FileError)
+				warnSkipped(fe.Path, fe.Err)
+			}
+			out, err := groupDupes(ctx, fileResults, confirmDupes)
+			if ctx.Err() != nil {
+				run.Error = "interrupted"
+				exit(exitInterrupted)
+			}
+			if err != nil {
+				fatalf(exitError, "%v", err)
+			}
+			run.OK, run.Failed = len(fileResults), len(errs)
+			linkFailed := 0
+			if dedupeMode != "" {
+				out.Dedupe, out.DryRun = dedupeMode, dryRun
+				linkFailed = dedupeSets(&out, quiet || report != "")
+				run.Failed += linkFailed
+			}
+
+			if report != "" {
+				reportBytes, err := dupesReport(report, out)
+				if err != nil {
+					fatalf(exitError, "could not marshal %s: %v", report, err)
+				}
+				fmt.Print(string(reportBytes))
+				if report == reportJSON {
+					fmt.Println()
+				}
+			} else {
+				printDupes(out)
+				pause(noPause)
+			}
+			if len(errs) > 0 || linkFailed > 0 {
+				exit(exitError)
+			}
+			exit(exitOK)
+		}
+
+		if tagFilenames {
+			// Tag mode, hash everything and put the hashes in the names, no hash file is written
+			run.Mode = "tag"
+			fileResults, errs := hasher.HashFiles(ctx, expandedFiles)
+			for _, err := range errs {
+				fe := err.(*fsh24.FileError)
+				warnSkipped(fe.Path, fe.Err)
+			}
+			if ctx.Err() != nil {
+				run.Error = "interrupted"
+				exit(exitInterrupted)
+			}
+			tagged, already, failed := tagFiles(fileResults, tagLength, quiet)
+			run.OK, run.Failed = tagged+already, len(errs)+failed
+			fmt.Printf("Tagged %d files, %d already were\n", tagged, already)
+			pause(noPause)
+			if run.Failed > 0 {
+				exit(exitError)
+			}
+			exit(exitOK)
+		}
+
+		if report != "" {
+			totalStartTime := time.Now()
+
+			if report == reportNDJSON {
+				out := os.Stdout
+				if outputFile != "" {
+					out, err = os.Create(outputFile)
+					if err != nil {
+						fatalf(exitError, "could not create output file: %v", err)
+					}
+					defer out.Close()
+				}
+				ndjson := &ndjsonWriter{w: out}
+				hasher.OnResult = func(r fsh24.FileHashResult) {
+					ndjson.write(r)
+				}
+			}
+
+			fileResults, errs := hasher.HashFiles(ctx, expandedFiles)
+			for _, err := range errs {
+				fe := err.(*fsh24.FileError)
+				warnSkipped(fe.Path, fe.Err)
+			}
+			run.OK, run.Failed = len(fileResults), len(errs)
+			if sortOrder != sortName {
+				inOrder(fileResults, expandedFiles)
+			}
+
+			totalProcessingTime := time.Since(totalStartTime).Seconds()
+			outputData := hasher.HashSummary(fileResults, totalProcessingTime)
+			if dirHash {
+				relTo := cwd
+				if absolutePaths {
+					relTo = ""
+				}
+				m := &fsh24.Manifest{}
+				for _, r := range fileResults {
+					m.Add(r, relTo) // Paths it can't make relative are left absolute, fine here
+				}
+				outputData.Directories = fsh24.DirHashes(m)
+			}
+
+			if jsonOut != "" {
+				reportBytes, err := hashReport(reportJSON, outputData)
+				if err == nil {
+					err = writeReportFile(jsonOut, reportBytes)
+				}
+				if err != nil {
+					fatalf(exitError, "could not save JSON report: %v", err)
+				}
+			}
+			if report != reportNDJSON { // ndjson was written out file by file
+				reportBytes, err := hashReport(report, outputData)
+				if err != nil {
+					fatalf(exitError, "could not marshal %s: %v", report, err)
+				}
+
+				if outputFile != "" {
+					err = os.WriteFile(outputFile, reportBytes, 0644)
+					if err != nil {
+						fatalf(exitError, "could not save %s to file: %v", report, err)
+					}
+					fmt.Printf("%s saved to: %s\n", strings.ToUpper(report), outputFile)
+				} else {
+					fmt.Print(string(reportBytes))
+					if report == reportJSON {
+						fmt.Println()
+					}
+				}
+			}
+			if ctx.Err() != nil {
+				fatalf(exitInterrupted, "interrupted, %d of %d files were hashed", len(fileResults), len(expandedFiles))
+			}
+			if len(errs) > 0 {
+				exit(exitError)
+			}
+
+		} else {
+			// Process files with console output
+			processedResults := make([]fsh24.FileHashResult, 0)
+			skipped := 0
+			totalStartTime := time.Now()
+
+			// Save what's done every so often, a crash hours in shouldn't lose it all
+			check := newCheckpoint(partial, hasher)
+			if resume {
+				done, todo, err := check.resume(expandedFiles)
+				if err != nil {
+					fatalf(exitError, "%v", err)
+				}
+				hasher.Seed = check.manifest.Seed
+				if len(done) > 0 {
+					fmt.Printf("Resuming, %d files were already hashed, %d to go\n", len(done), len(todo))
+				} else {
+					fmt.Printf("Nothing to resume from %s, starting from the beginning\n", partial)
+				}
+				processedResults = append(processedResults, done...)
+				expandedFiles = todo
+			} else if _, err := os.Stat(partial); err == nil {
+				fmt.Printf("Found %s from an unfinished run, starting over. Use --resume to carry on from it\n", partial)
+			}
+
+			sizes := make([]int64, len(expandedFiles))
+			totalSize := int64(0)
+			for i, fp := range expandedFiles {
+				sizes[i] = localSize(fp)
+				totalSize += sizes[i]
+			}
+			var bar *progress
+			if !quiet {
+				bar = newProgress(len(expandedFiles), totalSize)
+			}
+
+			for i, fp := range expandedFiles {
+				if ctx.Err() != nil {
+					break
+				}
+				if !quiet {
+					bar.print(func() {
+						fmt.Printf("Processing: %s\n", filepath.Base(fp))
+					})
+				}
+				result, err := hasher.HashFile(ctx, fp)
+				if err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+					skipped++
+					bar.fileDone(sizes[i], func() {
+						warnSkipped(fp, err)
+					})
+					continue
+				}
+				bar.fileDone(sizes[i], func() {
+					if quiet {
+						return
+					}
+					printHashResult(result, verbose, hasher)
+					if i < len(expandedFiles)-1 && len(expandedFiles) > 1 { // Add separator for multiple files
+						fmt.Println()
+					}
+				})
+				processedResults = append(processedResults, result)
+				if err := check.add(result); err != nil {
+					bar.print(func() {
+						warnf(partial, "Could not save progress to %s: %v", partial, err)
+					})
+				}
+			}
+			bar.finish()
+			if ctx.Err() != nil && len(check.manifest.Entries) > 0 {
+				if err := check.save(); err != nil {
+					warnf(partial, "Could not save progress to %s: %v", partial, err)
+				}
+			}
+
+			totalProcessingTime := time.Since(totalStartTime).Seconds()
+			run.OK, run.Failed = len(processedResults), skipped
+
+			if len(processedResults) > 0 {
+				outputFileActual := outputFile
+				if outputFileActual == "" {
+					outputFileActual = "checksums.fsh24"
+				}
+
+				// Relative paths are made relative to cwd unless absolute paths were asked for
+				relTo := cwd
+				if absolutePaths {
+					relTo = ""
+				}
+				manifest := newManifest(hasher, format)
+				manifest.NullTerminated = nullDelim
+				if existing != nil { // Keep its settings and entries, the new files go after them
+					manifest = existing.Manifest
+					if !absolutePaths {
+						relTo = existing.dir
+					}
+				}
+				for _, result := range processedResults {
+					add := manifest.Add
+					if existing != nil {
+						add = existing.add // Changed files replace their old entry
+					}
+					if err := add(result, relTo); err != nil {
+						warnf(result.Filepath, "%v. Using absolute path.", err)
+					}
+				}
+				var dirs []fsh24.DirHash
+				perDirCount := 0
+				if dirHash {
+					if normalizeUnicode {
+						manifest.NormalizeUnicode() // Before, the paths go into the hash
+					}
+					dirs = fsh24.DirHashes(manifest)
+					if manifest.Meta == nil {
+						manifest.Meta = map[string]string{}
+					}
+					if len(dirs) > 0 {
+						manifest.Meta[fsh24.MetaDirHash] = dirs[0].FSH24
+					}
+				}
+				root := ""
+				if merkle {
+					if normalizeUnicode {
+						manifest.NormalizeUnicode()
+					}
+					root = fsh24.NewMerkleTree(manifest).Root()
+					if manifest.Meta == nil {
+						manifest.Meta = map[string]string{}
+					}
+					manifest.Meta[fsh24.MetaMerkle] = root
+				}
+				if dbFile != "" {
+					db, err := fsh24db.Open(dbFile)
+					if err == nil {
+						if normalizeUnicode {
+							manifest.NormalizeUnicode()
+						}
+						err = db.Write(manifest)
+						db.Close()
+					}
+					if err != nil {
+						fatalf(exitError, "could not write database: %v", err)
+					}
+				} else if perDir {
+					if perDirCount, err = writePerDir(ctx, hasher, processedResults, format, outputFileActual); err != nil {
+						fatalf(exitError, "could not write hash files: %v", err)
+					}
+				} else if err := writeHashFile(manifest, outputFileActual); err != nil {
+					fatalf(exitError, "could not write hash file: %v", err)
+				}
+				if sfvOutput && format != fsh24.FormatSFV {
+					// Same entries, just the CRC32s, next to the .fsh24 file
+					sfv := *manifest
+					sfv.Format = fsh24.FormatSFV
+					sfv.Comments = []string{"Generated by fsh24"}
+					if err := sfv.WriteFile(sfvName(outputFileActual)); err != nil {
+						fatalf(exitError, "could not write sfv file: %v", err)
+					}
+				}
+				var tor *fsh24.Torrent
+				if torrent && ctx.Err() == nil {
+					tor, err = writeTorrent(ctx, hasher, processedResults, torrentName(outputFileActual), pieceLength, announce)
+					if err != nil && ctx.Err() == nil {
+						fatalf(exitError, "could not write torrent: %v", err)
+					}
+				}
+				if jsonOut != "" {
+					summary := hasher.HashSummary(processedResults, totalProcessingTime)
+					summary.Directories = dirs
+					reportBytes, err := hashReport(reportJSON, summary)
+					if err == nil {
+						err = writeReportFile(jsonOut, reportBytes)
+					}
+					if err != nil {
+						fatalf(exitError, "could not save JSON report: %v", err)
+					}
+				}
+				if ctx.Err() == nil {
+					check.remove() // All done, nothing left to resume
+				}
+
+				if len(processedResults) > 1 {
+					totalFileSize := int64(0)
+					totalHashedSize := int64(0)
+
+					for _, result := range processedResults {
+						totalFileSize += result.FileSize
+						if result.SHA256 != "" || result.CRC32 != "" || hasher.Full {
+							totalHashedSize += result.FileSize // The full hash read all of it
+						} else {
+							totalHashedSize += int64(result.Chunks) * int64(hasher.SampleSize)
+						}
+					}
+
+					totalHashPercentage := 0.0
+					if totalFileSize > 0 {
+						totalHashPercentage = (float64(totalHashedSize) / float64(totalFileSize)) * 100
+					}
+
+					if !quiet {
+						fmt.Println()
+					}
+					fmt.Printf("Processed %d files in %.3fs\n", len(processedResults), totalProcessingTime)
+					fmt.Printf(
+						"Total file size: %s bytes (%.2f GB)\n",
+						formatNumber(totalFileSize),
+						float64(totalFileSize)/(1024*1024*1024),
+					)
+					fmt.Printf(
+						"Total hashed size: %s bytes (%.2f GB)\n",
+						formatNumber(totalHashedSize),
+						float64(totalHashedSize)/(1024*1024*1024),
+					)
+					fmt.Printf("Total hash percentage: %.4f%%\n", totalHashPercentage)
+				}
+
+				if verbose < verboseInfo || quiet {
+					if dbFile != "" {
+						fmt.Printf("Database updated: %s\n", dbFile)
+					} else if existing != nil && existing.replaced > 0 {
+						fmt.Printf(
+							"Added %d new and re-hashed %d changed files in: %s\n",
+							existing.added,
+							existing.replaced,
+							outputFileActual,
+						)
+					} else if existing != nil {
+						fmt.Printf("Added %d new files to: %s\n", existing.added, outputFileActual)
+					} else if perDir {
+						fmt.Printf("Hash files saved in %d folders, index: %s\n", perDirCount, outputFileActual)
+					} else {
+						fmt.Printf("Hash file saved: %s\n", outputFileActual)
+					}
+				}
+				if jsonOut != "" {
+					fmt.Printf("JSON report saved: %s\n", jsonOut)
+				}
+				printDirHashes(dirs, verbose)
+				if tor != nil {
+					fmt.Printf("Torrent saved: %s (%s pieces of %s)\n", torrentName(outputFileActual), formatNumber(int64(tor.NumPieces())), formatBytes(tor.PieceLength))
+				}
+				if root != "" {
+					fmt.Printf("Merkle root: %s\n", root)
+				}
+
+				if ctx.Err() != nil {
+					fmt.Printf("\nInterrupted, %d of %d files were hashed\n", len(processedResults), len(expandedFiles))
+					run.Error = "interrupted"
+					exit(exitInterrupted)
+				}
+
+				pause(noPause)
+			} else if ctx.Err() != nil {
+				fmt.Println("\nInterrupted before any file was hashed")
+				run.Error = "interrupted"
+				exit(exitInterrupted)
+			}
+			if skipped > 0 {
+				exit(exitError)
+			}
+		}
+	}
+	exit(exitOK)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// What merge does when two hash files disagree about a file, --conflict.
+const (
+	conflictError  = "error"  // Stop, something needs looking at
+	conflictNewest = "newest" // Keep the entry hashed last
+)
+
+var conflictModes = []string{conflictError, conflictNewest}
+
+// mergeConflict is a file two hash files had different hashes for.
+type mergeConflict struct {
+	Path string
+	Kept string // Hash file the kept entry came from
+}
+
+// mergedEntry is an entry on its way into the merged hash file.
+type mergedEntry struct {
+	fsh24.Entry
+	abs    string    // Absolute path of the file
+	source string    // Hash file it came from
+	time   time.Time // When it was hashed, as far as we can tell
+}
+
+// mergeHashFiles combines hash files into one, for output. Relative paths are
+// re-based from each input's folder to output's folder, unless absolute.
+// Every input has to be made with the same settings. A file listed more
+// than once with different hashes is handled as conflict says.
+func mergeHashFiles(files []string, output, conflict string, absolute bool) (*fsh24.Manifest, []mergeConflict, error) {
+	var (
+		merged    *fsh24.Manifest
+		entries   []mergedEntry
+		index     = map[string]int{} // abs -> place in entries
+		conflicts []mergeConflict
+	)
+
+	for _, file := range files {
+		m, err := fsh24.ReadManifestFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, inv := range m.Invalid {
+			warnf(file, "Skipping broken line in %s: %s", file, inv.Line)
+		}
+
+		if merged == nil {
+			merged = m
+		} else if err := sameSettings(merged, m); err != nil {
+			return nil, nil, fmt.Errorf("can't merge %s into %s: %w", file, files[0], err)
+		} else {
+			widen(merged, m)
+		}
+
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			return nil, nil, err
+		}
+		fileTime := time.Time{}
+		if fi, err := os.Stat(file); err == nil {
+			fileTime = fi.ModTime()
+		}
+
+		for _, e := range m.Entries {
+			me := mergedEntry{Entry: e, abs: e.Path, source: file, time: e.ModTime}
+			if !filepath.IsAbs(e.Path) {
+				me.abs = filepath.Join(dir, e.Path)
+			}
+			if me.time.IsZero() {
+				me.time = fileTime // No mtime column, go by when the hash file was written
+			}
+
+			i, ok := index[me.abs]
+			if !ok {
+				index[me.abs] = len(entries)
+				entries = append(entries, me)
+				continue
+			}
+			old := entries[i]
+			if old.Hash == e.Hash && old.Size == e.Size && old.SHA256 == e.SHA256 && old.CRC32 == e.CRC32 {
+				continue // Same file, same hash
+			}
+			if conflict == conflictError {
+				return nil, nil, fmt.Errorf("%s has a different hash in %s than in %s", e.Path, file, old.source)
+			}
+			if me.time.After(old.time) {
+				entries[i] = me
+			}
+			conflicts = append(conflicts, mergeConflict{Path: me.abs, Kept: entries[i].source})
+		}
+	}
+
+	outDir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return nil, nil, err
+	}
+	merged.Invalid = nil
+	merged.Entries = make([]fsh24.Entry, len(entries))
+	for i, me := range entries {
+		e := me.Entry
+		if !absolute && !filepath.IsAbs(e.Path) {
+			if rel, err := filepath.Rel(outDir, me.abs); err == nil {
+				e.Path = rel
+			} else {
+				e.Path = me.abs
+			}
+		} else if absolute {
+			e.Path = me.abs
+		}
+		merged.Entries[i] = e
+	}
+	return merged, conflicts, nil
+}
+
+// widen makes merged, the first hash file, able to hold everything m has,
+// so what's kept doesn't depend on the order they're given in. m's FSH24-2
+// columns are added, and a FSH24-1 file becomes the format of m if that
+// has them. That takes care of --adaptive sample offsets too.
+func widen(merged, m *fsh24.Manifest) {
+	if !fsh24.HasFields(m.Format) {
+		return
+	}
+	if !fsh24.HasFields(merged.Format) {
+		merged.Format = m.Format
+		merged.Fields = slices.Clone(m.Fields) // Everything FSH24-1 has and more
+		merged.Meta = m.Meta
+	} else if merged.Fields != nil {
+		for _, field := range m.Fields {
+			if !slices.Contains(merged.Fields, field) {
+				merged.Fields = slices.Insert(merged.Fields, len(merged.Fields)-1, field) // Path stays last
+			}
+		}
+	}
+	merged.Metadata = merged.Metadata || m.Metadata
+	merged.Adaptive = merged.Adaptive || m.Adaptive // The offsets of --adaptive, only FSH24-2 and FSH24-B have them
+}
+
+// sameSettings checks that entries of b can go in a hash file with a's settings.
+func sameSettings(a, b *fsh24.Manifest) error {
+	headerless := func(m *fsh24.Manifest) bool {
+		return m.Format == fsh24.FormatGNU || m.Format == fsh24.FormatBSD || m.Format == fsh24.FormatSFV
+	}
+	if headerless(a) || headerless(b) {
+		if a.Format != b.Format {
+			return fmt.Errorf("it's a %s file, not %s", b.Format, a.Format)
+		}
+		return nil
+	}
+	if !slices.Equal(a.Params(), b.Params()) {
+		return fmt.Errorf("it was made with different settings")
+	}
+	return nil
+}
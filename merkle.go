@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// merkleTree builds the Merkle tree of m and checks it against the root in
+// the header, if it has one. A different root means lines were added,
+// taken out or changed after it was written.
+func merkleTree(filename string, m *fsh24.Manifest) (*fsh24.MerkleTree, error) {
+	tree := fsh24.NewMerkleTree(m)
+	if want := m.Meta[fsh24.MetaMerkle]; want != "" && !strings.EqualFold(want, tree.Root()) {
+		return tree, fmt.Errorf("the Merkle root in %s doesn't match its files, it was changed since it was written", filename)
+	}
+	return tree, nil
+}
+
+// makeProof writes the proof that path is in the hash file to out, stdout
+// for "".
+func makeProof(hashFile, path, out string) (*fsh24.MerkleProof, error) {
+	m, _, err := readHashFile(hashFile)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := merkleTree(hashFile, m)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Proof(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w, give the path the way %s has it", err, hashFile)
+	}
+	proof.Settings = map[string]string{}
+	for _, p := range m.Params() {
+		if p.Key != "sha256" && p.Key != "chain" { // Nothing to do with the FSH24
+			proof.Settings[p.Key] = p.Value
+		}
+	}
+
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	if out == "" {
+		_, err = os.Stdout.Write(data)
+		return proof, err
+	}
+	return proof, os.WriteFile(out, data, 0644)
+}
+
+// checkProof reads a proof made by makeProof and checks it leads to its
+// root, and to root too if that's given. With file, the file is hashed with
+// the settings in the proof and has to have the FSH24 it names.
+func checkProof(ctx context.Context, proofFile, file, root string, hasher *fsh24.Hasher) (*fsh24.MerkleProof, error) {
+	data, err := os.ReadFile(proofFile)
+	if err != nil {
+		return nil, err
+	}
+	var proof fsh24.MerkleProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return nil, fmt.Errorf("%s is not a fsh24 proof: %w", proofFile, err)
+	}
+	if err := proof.Check(); err != nil {
+		return &proof, err
+	}
+	if root != "" && !strings.EqualFold(root, proof.Root) {
+		return &proof, fmt.Errorf("the proof is for Merkle root %s, not %s", proof.Root, strings.ToUpper(root))
+	}
+	if file == "" {
+		return &proof, nil
+	}
+
+	settings, err := proof.Manifest()
+	if err != nil {
+		return &proof, fmt.Errorf("%s: %w", proofFile, err)
+	}
+	if settings.Keyed && len(hasher.Key) == 0 {
+		return &proof, fmt.Errorf("the hash file was keyed, give the key with --key or --key-file to check %s", file)
+	}
+	h := *hasher
+	settings.ApplySettings(&h)
+	result, err := h.HashFile(ctx, file)
+	if err != nil {
+		return &proof, err
+	}
+	if !strings.EqualFold(result.FSH24, proof.FSH24) {
+		return &proof, fmt.Errorf("%s hashes to %s, not the %s in the proof", file, result.FSH24, proof.FSH24)
+	}
+	return &proof, nil
+}
+
+// compareHashFiles lines up two hash files of the same tree, like
+// compareFolders does with the folders, without hashing anything. When the
+// Merkle roots are the same that's the whole answer, otherwise the trees
+// are walked down to the files that differ.
+func compareHashFiles(left, right string) (cmpOutput, error) {
+	out := cmpOutput{Left: left, Right: right}
+	var trees [2]*fsh24.MerkleTree
+	var sizes [2]map[string]int64
+	for i, name := range []string{left, right} {
+		m, _, err := readHashFile(name)
+		if err != nil {
+			return out, err
+		}
+		if trees[i], err = merkleTree(name, m); err != nil {
+			return out, err
+		}
+		sizes[i] = map[string]int64{}
+		for _, e := range m.Entries {
+			sizes[i][filepath.ToSlash(e.Path)] = e.Size
+		}
+	}
+
+	differ := map[string]bool{}
+	if trees[0].Root() != trees[1].Root() {
+		for _, p := range trees[0].Diff(trees[1]) {
+			differ[p] = true
+		}
+	}
+	all := map[string]bool{}
+	for _, s := range sizes {
+		for p := range s {
+			all[p] = true
+		}
+	}
+	for p := range all {
+		_, inLeft := sizes[0][p]
+		_, inRight := sizes[1][p]
+		status := cmpSame
+		switch {
+		case !inRight:
+			status = cmpOnlyLeft
+		case !inLeft:
+			status = cmpOnlyRight
+		case differ[p] || sizes[0][p] != sizes[1][p]:
+			status = cmpDifferent
+		}
+		out.Files = append(out.Files, cmpFile{Path: p, Status: status})
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].Path < out.Files[j].Path })
+	out.Summary = cmpSummaryOf(out.Files)
+	return out, nil
+}
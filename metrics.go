@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// stats are the Prometheus metrics of daemon and serve, see metrics.
+var stats = &metrics{
+	verified: map[string]int64{},
+	checks:   map[string]int64{},
+	last:     map[string]checkStat{},
+}
+
+// metrics counts what the long running modes did since they started, for
+// /metrics. Everything is added once a check or job is done, not per file.
+type metrics struct {
+	mu sync.Mutex
+
+	hashed        int64            // Files hashed
+	hashErrors    int64            // Files that couldn't be hashed
+	hashSeconds   float64          // Time spent hashing them
+	verified      map[string]int64 // Files verified, by status
+	verifySeconds float64          // Time spent verifying them
+	bytesRead     int64            // Bytes read by both
+	checks        map[string]int64 // Daemon checks by result: ok, failed or error
+	last          map[string]checkStat
+	jobsRunning   int64 // serve jobs, HTTP and gRPC
+}
+
+// checkStat is how the last daemon check of a hash file went.
+type checkStat struct {
+	Time    time.Time
+	Seconds float64
+	Failed  int
+	Error   bool
+}
+
+// addHashed counts the results of hashing with hasher.
+func (m *metrics) addHashed(hasher *fsh24.Hasher, results []fsh24.FileHashResult, errs int) {
+	sampleSize := int64(hasher.SampleSize)
+	if sampleSize == 0 {
+		sampleSize = fsh24.SampleSize
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range results {
+		m.hashed++
+		m.hashSeconds += r.ProcessingTime
+		if hasher.Full {
+			m.bytesRead += r.FileSize
+		} else {
+			m.bytesRead += min(r.FileSize, int64(r.Chunks)*sampleSize)
+		}
+	}
+	m.hashErrors += int64(errs)
+}
+
+// addVerified counts verify results.
+func (m *metrics) addVerified(results []fsh24.FileVerificationResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range results {
+		m.verified[r.Status]++
+		m.verifySeconds += r.ProcessingTime
+		m.bytesRead += r.HashedSize
+	}
+}
+
+// addCheck records a finished daemon check of hashFile.
+func (m *metrics) addCheck(hashFile string, took time.Duration, failed int, err error) {
+	result := "ok"
+	switch {
+	case err != nil:
+		result = "error"
+	case failed > 0:
+		result = "failed"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks[result]++
+	m.last[hashFile] = checkStat{Time: time.Now(), Seconds: took.Seconds(), Failed: failed, Error: err != nil}
+}
+
+// jobs adds n to the count of serve jobs running, 1 when one starts and -1 when it's done.
+func (m *metrics) jobs(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobsRunning += n
+}
+
+// ServeHTTP answers with the metrics in the Prometheus text format.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.writeTo(w)
+}
+
+// writeTo writes the metrics in the Prometheus text format.
+func (m *metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	value := func(name, labels string, v float64) {
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	metric("fsh24_files_hashed_total", "counter", "Files hashed.")
+	value("fsh24_files_hashed_total", "", float64(m.hashed))
+	metric("fsh24_hash_errors_total", "counter", "Files that couldn't be hashed.")
+	value("fsh24_hash_errors_total", "", float64(m.hashErrors))
+
+	metric("fsh24_files_verified_total", "counter", "Files verified, by result.")
+	verifyCount := int64(0)
+	for _, status := range slices.Sorted(maps.Keys(m.verified)) {
+		value("fsh24_files_verified_total", label("status", status), float64(m.verified[status]))
+		verifyCount += m.verified[status]
+	}
+	metric("fsh24_verify_failures_total", "counter", "Files that didn't verify: missing, changed or unreadable.")
+	value("fsh24_verify_failures_total", "", float64(verifyCount-m.verified[fsh24.StatusVerified]))
+
+	metric("fsh24_bytes_read_total", "counter", "Bytes read for hashing and verifying.")
+	value("fsh24_bytes_read_total", "", float64(m.bytesRead))
+
+	metric("fsh24_file_duration_seconds", "summary", "Time spent on each file.")
+	value("fsh24_file_duration_seconds_sum", label("op", "hash"), m.hashSeconds)
+	value("fsh24_file_duration_seconds_count", label("op", "hash"), float64(m.hashed))
+	value("fsh24_file_duration_seconds_sum", label("op", "verify"), m.verifySeconds)
+	value("fsh24_file_duration_seconds_count", label("op", "verify"), float64(verifyCount))
+
+	metric("fsh24_jobs_running", "gauge", "serve jobs running right now.")
+	value("fsh24_jobs_running", "", float64(m.jobsRunning))
+
+	metric("fsh24_checks_total", "counter", "daemon checks of a hash file, by result.")
+	for _, result := range []string{"ok", "failed", "error"} {
+		value("fsh24_checks_total", label("result", result), float64(m.checks[result]))
+	}
+
+	files := slices.Sorted(maps.Keys(m.last))
+	metric("fsh24_last_check_timestamp_seconds", "gauge", "When the last daemon check of a hash file finished.")
+	for _, f := range files {
+		value("fsh24_last_check_timestamp_seconds", label("hash_file", f), float64(m.last[f].Time.UnixMilli())/1000)
+	}
+	metric("fsh24_last_check_duration_seconds", "gauge", "How long the last daemon check of a hash file took.")
+	for _, f := range files {
+		value("fsh24_last_check_duration_seconds", label("hash_file", f), m.last[f].Seconds)
+	}
+	metric("fsh24_last_check_failed_files", "gauge", "Files that failed the last daemon check of a hash file.")
+	for _, f := range files {
+		value("fsh24_last_check_failed_files", label("hash_file", f), float64(m.last[f].Failed))
+	}
+	metric("fsh24_last_check_error", "gauge", "1 if the hash file itself couldn't be read on the last check.")
+	for _, f := range files {
+		v := 0.0
+		if m.last[f].Error {
+			v = 1
+		}
+		value("fsh24_last_check_error", label("hash_file", f), v)
+	}
+}
+
+// label formats {name="value"}, escaped the way Prometheus wants.
+func label(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return "{" + name + `="` + value + `"}`
+}
+
+// serveMetrics serves /metrics on listen, for daemon. It stops with the program.
+func serveMetrics(listen string) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", stats)
+	fmt.Printf("Metrics at /metrics on %s\n", listen)
+	return http.ListenAndServe(listen, mux)
+}
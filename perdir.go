@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"fsh24/pkg/fsh24"
+)
+
+// --per-dir puts a hash file of just its own files in every folder, so a
+// folder can be copied off on its own and still be checked, and writes an
+// index of them as the -o file. Verifying the index checks the hash files
+// haven't changed, then verifies each of them.
+
+// perDirIndex is the -o name of the index when there's no -o.
+const perDirIndex = "index.fsh24"
+
+// perDirName is what the hash file in each folder is called.
+const perDirName = "checksums.fsh24"
+
+// indexPerDir is the fsh24.MetaIndex of a --per-dir index.
+const indexPerDir = "per-dir"
+
+// perDirFile is the name of the hash file in each folder for the index
+// index, gzipped if the index is.
+func perDirFile(index string) string {
+	if fsh24.IsGzipName(index) {
+		return perDirName + fsh24.GzipExt
+	}
+	return perDirName
+}
+
+// writePerDir writes a hash file in format into every folder the results
+// are in, with paths relative to it, and the index of them to index.
+// Files inside archives go with the folder of the archive. URLs and disks
+// have no folder to go in and are left out with a warning. It returns how
+// many folders got one.
+func writePerDir(ctx context.Context, hasher *fsh24.Hasher, results []fsh24.FileHashResult, format, index string) (int, error) {
+	indexAbs, err := filepath.Abs(index)
+	if err != nil {
+		return 0, err
+	}
+	name := perDirFile(index)
+
+	byDir := map[string][]fsh24.FileHashResult{}
+	for _, r := range results {
+		p := r.Filepath
+		if archive, _, ok := fsh24.SplitArchivePath(p); ok {
+			p = archive
+		}
+		if fsh24.IsRemote(p) || fsh24.IsDevice(p) {
+			warnf(r.Filepath, "%s has no folder to put a hash file in, it's left out of --per-dir", r.Filepath)
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return 0, err
+		}
+		dir := filepath.Dir(abs)
+		byDir[dir] = append(byDir[dir], r)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		if filepath.Join(dir, name) == indexAbs {
+			return 0, fmt.Errorf("the index %s would overwrite the hash file of %s, give it another name with -o", index, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	var written []string
+	for _, dir := range dirs {
+		m := newManifest(hasher, format)
+		for _, r := range byDir[dir] {
+			if err := m.Add(r, dir); err != nil {
+				return 0, err
+			}
+		}
+		file := filepath.Join(dir, name)
+		if err := writeHashFile(m, file); err != nil {
+			return 0, err
+		}
+		written = append(written, file)
+	}
+
+	// The index only says which hash files there are and that they're
+	// intact, it needs the FSH24-2 header to say it's an index
+	indexFormat := format
+	if !fsh24.HasFields(indexFormat) {
+		indexFormat = fsh24.FormatFSH24v2
+	}
+	idx := newManifest(hasher, indexFormat)
+	idx.Meta[fsh24.MetaIndex] = indexPerDir
+	for _, file := range written {
+		r, err := hasher.HashFile(ctx, file)
+		if err != nil {
+			return 0, fmt.Errorf("could not hash %s for the index: %w", file, err)
+		}
+		if err := idx.Add(r, filepath.Dir(indexAbs)); err != nil {
+			return 0, err
+		}
+	}
+	if err := writeHashFile(idx, index); err != nil {
+		return 0, err
+	}
+	return len(written), nil
+}
+
+// verifyPerDir verifies the hash files of a --per-dir index, once verifying
+// the index itself has checked them, adding their results to the index's.
+// Hash files that are missing or changed are already failures, they're not
+// gone into.
+func verifyPerDir(
+	ctx context.Context,
+	index string,
+	summary fsh24.VerificationSummary,
+	results []fsh24.FileVerificationResult,
+	opts verifyOptions,
+) (fsh24.VerificationSummary, []fsh24.FileVerificationResult, error) {
+	// --base-dir found the hash files, their own paths go from where they are
+	subOpts := opts
+	subOpts.BaseDir, subOpts.ByName = "", false
+	total := summary.TotalTime
+	checked := 0
+	for _, r := range slices.Clone(results) {
+		if r.Status != fsh24.StatusVerified {
+			continue
+		}
+		if opts.FailFast && summary.Failed > 0 {
+			break
+		}
+		checked++
+		sub, subResults, err := verifyHashFile(ctx, r.Filepath, subOpts)
+		results = append(results, subResults...)
+		total += sub.TotalTime
+		summary = fsh24.Summarize(results, total)
+		if err != nil {
+			runHashFile = index
+			return summary, results, err
+		}
+	}
+	runHashFile = index
+
+	// Every hash file printed its own line, this is all of them
+	if checked > 1 && opts.Report == "" {
+		color := colorGreen
+		if summary.Failed > 0 {
+			color = colorRed
+		}
+		fmt.Println(colorize(color, fmt.Sprintf("Index and %d folders: %d verified, %d failed%s", checked, summary.Verified, summary.Failed, changeNote(summary))))
+	}
+	return summary, results, nil
+}
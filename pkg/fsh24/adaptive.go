@@ -0,0 +1,194 @@
+package fsh24
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Disk images and the like are often mostly zeros, or some other filler
+// that's the same all the way through. Evenly spaced samples spend most of
+// their reads on it, where there's nothing much to damage, and the data in
+// between gets a handful. Hasher.Adaptive has a quick look at the file
+// first, a small probe here and there, and moves the middle samples over
+// to the parts with something in them. Same number of samples, same reads.
+//
+// Where they went is recorded with the hash, Entry.Offsets. Verifying
+// can't look again, damage changes what the probes would see.
+
+const (
+	// probeSize is how much of the file one probe reads.
+	probeSize = 4 << 10
+
+	// Probes per file, two for every middle sample within these.
+	minProbes = 16
+	maxProbes = 1024
+
+	// lowEntropy is where filler stops, in bits per byte. Zeros are 0,
+	// text around 4.5, compressed or encrypted data nearly 8.
+	lowEntropy = 1.0
+
+	// fillerWeight is what a byte of filler counts for when placing the
+	// samples, against up to 8 for data, so long runs of it still get one
+	// now and then.
+	fillerWeight = 0.25
+)
+
+// region is a stretch of a file that one probe speaks for.
+type region struct {
+	off, n int64
+	weight float64 // Per byte
+}
+
+// adaptiveOffsets probes size bytes of r and places the middle samples of
+// the file where the data is. It returns nil for evenly spaced samples, if
+// the file is too small to have middle samples, or the probes found no
+// filler, or nothing but.
+func (h *Hasher) adaptiveOffsets(ctx context.Context, r io.ReaderAt, size int64) ([]int64, error) {
+	even := *h
+	even.Offsets, even.Seed = nil, 0
+	spans, _ := even.samples(size)
+	middle := len(spans) - 2
+	if middle < 1 {
+		return nil, nil
+	}
+	sampleSize := int64(h.sampleSize())
+
+	// The probes cover what's between the first and last samples
+	start, end := sampleSize, size-sampleSize
+	probes := min(max(2*middle, minProbes), maxProbes, int((end-start)/probeSize))
+	if probes < 2 {
+		return nil, nil
+	}
+	regions := make([]region, probes)
+	buffer := make([]byte, probeSize)
+	filler := 0
+	for i := range regions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		from := start + (end-start)*int64(i)/int64(probes)
+		to := start + (end-start)*int64(i+1)/int64(probes)
+		n, err := r.ReadAt(buffer, from+(to-from-probeSize)/2) // From the middle of the region
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read probe at offset %d: %w", from, err)
+		}
+		weight := entropy(buffer[:n])
+		if weight < lowEntropy {
+			weight = fillerWeight
+			filler++
+		}
+		regions[i] = region{off: from, n: to - from, weight: weight}
+	}
+	if filler == 0 || filler == probes {
+		return nil, nil // Evenly spaced does as well
+	}
+	return placeSamples(regions, middle, sampleSize, size), nil
+}
+
+// placeSamples spreads middle samples over regions by weight, each one
+// getting an equal share of it, then shifts them where they'd overlap, or
+// run into the first or last sample.
+func placeSamples(regions []region, middle int, sampleSize, size int64) []int64 {
+	total := 0.0
+	for _, rg := range regions {
+		total += rg.weight * float64(rg.n)
+	}
+
+	offsets := make([]int64, middle)
+	k, before := 0, 0.0 // Region and the weight of the ones before it
+	for i := range offsets {
+		target := total * (float64(i) + 0.5) / float64(middle)
+		for k < len(regions)-1 && before+regions[k].weight*float64(regions[k].n) < target {
+			before += regions[k].weight * float64(regions[k].n)
+			k++
+		}
+		center := regions[k].off + int64((target-before)/regions[k].weight)
+		offsets[i] = center - sampleSize/2
+	}
+
+	// In order they can only be too close, push them apart forwards, then
+	// back from the end. There's always room, see evenLayout
+	lowest := sampleSize
+	for i := range offsets {
+		offsets[i] = max(offsets[i], lowest)
+		lowest = offsets[i] + sampleSize
+	}
+	highest := size - 2*sampleSize
+	for i := len(offsets) - 1; i >= 0; i-- {
+		offsets[i] = min(offsets[i], highest)
+		highest = offsets[i] - sampleSize
+	}
+	return offsets
+}
+
+// entropy is the Shannon entropy of data in bits per byte, 0 to 8.
+func entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	bits := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(data))
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits
+}
+
+// placedLayout moves the middle samples of an evenLayout to offsets, the
+// Hasher.Offsets of a file hashed with Adaptive. Offsets that don't fit a
+// file of fileSize leave it evenly spaced, the size changed and the hash
+// won't match anyway.
+func placedLayout(spans []span, fileSize, sampleSize int64, offsets []int64) {
+	if len(offsets) != len(spans)-2 {
+		return
+	}
+	lowest := sampleSize
+	for _, off := range offsets {
+		if off < lowest {
+			return
+		}
+		lowest = off + sampleSize
+	}
+	if lowest > fileSize-sampleSize {
+		return
+	}
+	for i, off := range offsets {
+		spans[i+1].off = off
+	}
+}
+
+// formatOffsets is the FSH24-2 offsets column, the offsets comma separated.
+func formatOffsets(offsets []int64) string {
+	text := make([]string, len(offsets))
+	for i, off := range offsets {
+		text[i] = strconv.FormatInt(off, 10)
+	}
+	return strings.Join(text, ",")
+}
+
+// parseOffsets reads the FSH24-2 offsets column back. Empty is nil.
+func parseOffsets(column string) ([]int64, error) {
+	if column == "" {
+		return nil, nil
+	}
+	text := strings.Split(column, ",")
+	offsets := make([]int64, len(text))
+	for i, t := range text {
+		off, err := strconv.ParseInt(t, 10, 64)
+		if err != nil || off < 0 {
+			return nil, fmt.Errorf("not an offset: %q", t)
+		}
+		offsets[i] = off
+	}
+	return offsets, nil
+}
@@ -0,0 +1,316 @@
+package fsh24
+
+import (
+	"encoding"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Built in hash algorithms. BLAKE2b is the original and the default.
+const (
+	AlgoBLAKE2b = "blake2b"
+	AlgoBLAKE3  = "blake3"
+	AlgoXXH3    = "xxh3"
+)
+
+// Algorithms lists the algorithm names accepted by Hasher.Algorithm, the
+// built in ones and whatever was added with RegisterAlgorithm.
+var Algorithms = []string{AlgoBLAKE2b, AlgoBLAKE3, AlgoXXH3}
+
+// MagicXXH3 is the header of xxh3 manifests. XXH3 is not a cryptographic hash
+// and only 16 bytes, so these files get their own magic rather than an algo= field,
+// that way older FSH24 tools refuse them instead of reporting every file as a mismatch.
+const MagicXXH3 = "FSHX3-1"
+
+// HashAlgorithm is a hash the samples can be run through, see RegisterAlgorithm.
+// The sampling, the size trailer and the manifests are the same whatever
+// the hash, only the digest of the samples changes.
+type HashAlgorithm struct {
+	// Name is what Hasher.Algorithm, --algo and the algo= header field call it.
+	Name string
+
+	// New makes the hash, size bytes long and keyed with key when it isn't
+	// empty. size and key have already been checked against DigestBytes
+	// and Keyed. Sum has to append exactly size bytes.
+	//
+	// Digest.Sum is cheaper when the hash can be copied mid stream: when it
+	// has a Clone() (hash.Hash, error) method, or is an
+	// encoding.BinaryMarshaler that a fresh New(size, nil) can unmarshal.
+	New func(size int, key []byte) (hash.Hash, error)
+
+	// DigestBytes is the one length the hash comes in. 0 means it can be
+	// cut to anything from MinDigestBytes to MaxDigestBytes, with
+	// DefaultDigestBytes by default.
+	DigestBytes int
+
+	// Keyed says it can do keyed hashing, with keys up to MaxKeyBytes.
+	Keyed bool
+
+	// Magic is the first line of its FSH24-1 files. Empty means Magic
+	// with an algo= field, anything that can't pass for a FSH24 (like a
+	// non-cryptographic hash) should have its own so older tools refuse
+	// the files instead of failing every file in them.
+	Magic string
+}
+
+var (
+	algorithmsMu sync.RWMutex
+	algorithms   = map[string]*HashAlgorithm{
+		AlgoBLAKE2b: {Name: AlgoBLAKE2b, New: newBLAKE2b, Keyed: true},
+		AlgoBLAKE3:  {Name: AlgoBLAKE3, New: newBLAKE3, Keyed: true},
+		AlgoXXH3:    {Name: AlgoXXH3, New: newXXH3, DigestBytes: xxh3DigestBytes, Magic: MagicXXH3},
+	}
+)
+
+// RegisterAlgorithm adds a hash algorithm for Hasher.Algorithm, and for the
+// manifests that name it. Registering a name again replaces it. Call it from
+// an init func, Algorithms isn't safe to change while it's being read.
+//
+// A manifest only records the algorithm's name, so whatever reads it back
+// needs the same algorithm registered under the same name.
+func RegisterAlgorithm(a HashAlgorithm) {
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+	if _, ok := algorithms[a.Name]; !ok {
+		Algorithms = append(Algorithms, a.Name)
+	}
+	algorithms[a.Name] = &a
+}
+
+// algorithm returns the registered algorithm called name, nil if there
+// isn't one. An empty name is BLAKE2b.
+func algorithm(name string) *HashAlgorithm {
+	if name == "" {
+		name = AlgoBLAKE2b
+	}
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	return algorithms[name]
+}
+
+// LookupAlgorithm returns the algorithm called name, built in or registered.
+func LookupAlgorithm(name string) (HashAlgorithm, bool) {
+	if a := algorithm(name); a != nil {
+		return *a, true
+	}
+	return HashAlgorithm{}, false
+}
+
+// algorithmForMagic returns the name of the algorithm whose FSH24-1 files
+// start with magic, "" for none.
+func algorithmForMagic(magic string) string {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	for name, a := range algorithms {
+		if a.Magic != "" && a.Magic == magic {
+			return name
+		}
+	}
+	return ""
+}
+
+// MagicFor returns the header magic of FSH24-1 manifests of algo.
+func MagicFor(algo string) string {
+	if a := algorithm(algo); a != nil && a.Magic != "" {
+		return a.Magic
+	}
+	return Magic
+}
+
+// isMagic reports whether line starts with the header of a FSH24-1 file of
+// any registered algorithm, or with settings.
+func isMagic(line string) bool {
+	if strings.HasPrefix(line, "FSH24") {
+		return true
+	}
+	magic, _, _ := strings.Cut(line, " ")
+	return magic == MagicSettings || algorithmForMagic(magic) != ""
+}
+
+// Digest lengths in bytes. The default of 24 is the "24" in FSH24.
+const (
+	DefaultDigestBytes = 24
+	MinDigestBytes     = 16
+	MaxDigestBytes     = 64
+
+	xxh3DigestBytes = 16 // XXH3-128 can't be truncated or extended
+)
+
+// ValidateDigestBytes checks a digest length is usable with algo. 0 means the default.
+func ValidateDigestBytes(algo string, size int) error {
+	if size == 0 {
+		return nil
+	}
+	a := algorithm(algo)
+	if a == nil {
+		return fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	if a.DigestBytes != 0 {
+		if size != a.DigestBytes {
+			return fmt.Errorf("%s digests are always %d bytes", a.Name, a.DigestBytes)
+		}
+		return nil
+	}
+	if size < MinDigestBytes || size > MaxDigestBytes {
+		return fmt.Errorf("digest length must be between %d and %d bytes, got %d", MinDigestBytes, MaxDigestBytes, size)
+	}
+	return nil
+}
+
+// defaultDigestBytes is the digest length of algo when none is given.
+func defaultDigestBytes(algo string) int {
+	if a := algorithm(algo); a != nil && a.DigestBytes != 0 {
+		return a.DigestBytes
+	}
+	return DefaultDigestBytes
+}
+
+// MaxKeyBytes is the longest key accepted for keyed hashing, BLAKE2b's limit.
+const MaxKeyBytes = 64
+
+// blake3KeyContext is the BLAKE3 key derivation context used to turn a user
+// key of any length into the 32 byte key BLAKE3 needs.
+const blake3KeyContext = "fsh24 2025 keyed sample hash"
+
+// ValidateKey checks a key can be used for keyed hashing with algo.
+func ValidateKey(algo string, key []byte) error {
+	if len(key) == 0 {
+		return nil
+	}
+	a := algorithm(algo)
+	if a == nil {
+		return fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	if !a.Keyed {
+		return fmt.Errorf("%s does not support keyed hashing", a.Name)
+	}
+	if len(key) > MaxKeyBytes {
+		return fmt.Errorf("key is %d bytes, the limit is %d", len(key), MaxKeyBytes)
+	}
+	return nil
+}
+
+// newDigest creates the underlying hasher for algo with a size byte output.
+// An empty algo means BLAKE2b and a size of 0 means its default length.
+// A non empty key makes it a keyed hash (MAC).
+func newDigest(algo string, size int, key []byte) (hash.Hash, error) {
+	a := algorithm(algo)
+	if a == nil {
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	if err := ValidateDigestBytes(a.Name, size); err != nil {
+		return nil, err
+	}
+	if err := ValidateKey(a.Name, key); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		size = defaultDigestBytes(a.Name)
+	}
+	return a.New(size, key)
+}
+
+func newBLAKE2b(size int, key []byte) (hash.Hash, error) {
+	hasher, err := blake2b.New(size, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blake2b hasher: %w", err)
+	}
+	return hasher, nil
+}
+
+func newBLAKE3(size int, key []byte) (hash.Hash, error) {
+	if len(key) == 0 {
+		return &blake3Digest{blake3.New(), size}, nil
+	}
+	derived := make([]byte, 32)
+	blake3.DeriveKey(blake3KeyContext, key, derived)
+	hasher, err := blake3.NewKeyed(derived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blake3 hasher: %w", err)
+	}
+	return &blake3Digest{hasher, size}, nil
+}
+
+func newXXH3(size int, key []byte) (hash.Hash, error) {
+	return &xxh3Digest{xxh3.New()}, nil
+}
+
+// blake3Digest reads size bytes of BLAKE3 output.
+// BLAKE3 output is extendable, so any length is a prefix of the same stream.
+type blake3Digest struct {
+	*blake3.Hasher
+	size int
+}
+
+func (b *blake3Digest) Size() int { return b.size }
+
+func (b *blake3Digest) Sum(p []byte) []byte {
+	out := make([]byte, b.size)
+	b.Hasher.Digest().Read(out)
+	return append(p, out...)
+}
+
+// xxh3Digest is the 128 bit XXH3, meant for quick local dedup scans where
+// the hash rather than the disk is the bottleneck.
+type xxh3Digest struct {
+	*xxh3.Hasher
+}
+
+func (x *xxh3Digest) Size() int { return xxh3DigestBytes }
+
+func (x *xxh3Digest) Sum(b []byte) []byte {
+	sum := x.Sum128().Bytes()
+	return append(b, sum[:]...)
+}
+
+func (b *blake3Digest) Clone() (hash.Hash, error) {
+	return &blake3Digest{b.Hasher.Clone(), b.size}, nil
+}
+
+func (x *xxh3Digest) Clone() (hash.Hash, error) {
+	clone := *x.Hasher // Plain value state, a copy is a clone
+	return &xxh3Digest{&clone}, nil
+}
+
+// cloneDigest copies the state of h, a hasher of algo, so Sum can finish a
+// copy instead of the original. Keyed BLAKE2b can't be cloned, x/crypto
+// refuses to export a MAC's state.
+func cloneDigest(algo string, h hash.Hash) (hash.Hash, error) {
+	switch d := h.(type) {
+	case interface{ Clone() (hash.Hash, error) }:
+		return d.Clone()
+	case encoding.BinaryMarshaler:
+		a := algorithm(algo)
+		if a == nil {
+			break
+		}
+		state, err := d.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		clone, err := a.New(h.Size(), nil)
+		if err != nil {
+			return nil, err
+		}
+		u, ok := clone.(encoding.BinaryUnmarshaler)
+		if !ok {
+			break
+		}
+		if err := u.UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+		return clone, nil
+	}
+	return nil, fmt.Errorf("can't clone %T", h)
+}
+
+// ValidAlgorithm reports whether algo is a supported algorithm name.
+func ValidAlgorithm(algo string) bool {
+	return algo != "" && algorithm(algo) != nil
+}
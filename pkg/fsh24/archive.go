@@ -0,0 +1,524 @@
+package fsh24
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveSep separates an archive from the path of a file in it,
+// "backup.zip!photos/cat.jpg". Those paths open like any other, see Open,
+// so files inside archives hash and verify without being extracted.
+const ArchiveSep = "!"
+
+// archiveFormat is a kind of archive the files in can be listed and opened.
+type archiveFormat struct {
+	exts []string // Lower case, with the dot
+
+	// list reads the table of contents of the archive f, the files in the
+	// order the archive has them. Formats that have to be read start to end
+	// to list them hash the files with h on the way through when h isn't
+	// nil, see Hasher.ListArchive.
+	list func(ctx context.Context, archive string, f File, h *Hasher) ([]archiveMember, []*sharedStream, error)
+}
+
+// archiveMember is a file inside an archive.
+type archiveMember struct {
+	name string // Slash separated, how the archive has it
+
+	// open opens the member of the archive at archive. Files stored as they
+	// are should read straight from the archive, so they can be sampled.
+	open func(ctx context.Context, archive string) (File, error)
+
+	// hash is the member hashed while it was listed, nil if it wasn't.
+	hash *FileHashResult
+}
+
+// archiveFormats are set up in init, listing goes through Open, which
+// looks them up again for archives in archives.
+var archiveFormats []archiveFormat
+
+func init() {
+	archiveFormats = []archiveFormat{
+		{exts: []string{".zip"}, list: listZip},
+		{exts: tarExts, list: listTar},
+		{exts: []string{".7z"}, list: listSevenZip},
+		{exts: []string{".iso"}, list: listISO},
+	}
+}
+
+// archiveFormatOf returns the format of the archive at path going by its
+// extension, nil if it's not one.
+func archiveFormatOf(path string) *archiveFormat {
+	name := strings.ToLower(baseName(path))
+	for i, format := range archiveFormats {
+		for _, ext := range format.exts {
+			if strings.HasSuffix(name, ext) {
+				return &archiveFormats[i]
+			}
+		}
+	}
+	return nil
+}
+
+// IsArchive reports whether path is an archive ListArchive can look into,
+// going by its name.
+func IsArchive(path string) bool {
+	return archiveFormatOf(path) != nil
+}
+
+// SplitArchivePath splits "backup.zip!photos/cat.jpg" into the archive and
+// the path inside it. ok is false for paths with no archive in them.
+func SplitArchivePath(p string) (archive, member string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(p[i:], ArchiveSep)
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		if IsArchive(p[:i]) {
+			return p[:i], filepath.ToSlash(p[i+len(ArchiveSep):]), true
+		}
+		i += len(ArchiveSep)
+	}
+}
+
+// IsArchiveMember reports whether path is a file inside an archive, that
+// only opens through Open.
+func IsArchiveMember(path string) bool {
+	_, _, ok := SplitArchivePath(path)
+	return ok
+}
+
+// ListArchive returns the paths of the files in the archive at path, with
+// ArchiveSep between the two, in the order the archive has them. Folders
+// are left out.
+//
+// Compressed tars and 7z files have to be read all the way through to get
+// at their files. h, if not nil, hashes them while it's at it, and HashFile
+// with the same Hasher then takes those hashes instead of reading the
+// archive again. That makes hashing everything in a .tar.gz one read of it.
+func (h *Hasher) ListArchive(ctx context.Context, path string) ([]string, error) {
+	members, err := archiveMembers(ctx, path, h)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(members.byName))
+	for _, name := range members.order {
+		paths = append(paths, path+ArchiveSep+name)
+	}
+	return paths, nil
+}
+
+// members is the table of contents of one archive.
+type members struct {
+	byName   map[string]archiveMember
+	order    []string
+	size     int64 // Of the archive, to tell when it changed
+	modTime  time.Time
+	hashedBy *Hasher // What the members with a hash were hashed with
+	settings hashSettings
+	streams  []*sharedStream
+}
+
+// hashSettings is what of a Hasher goes into the hashes it makes. --update
+// changes them to the hash file's after the archives were listed, hashes
+// from before that are no good.
+type hashSettings struct {
+	coverage       float64
+	sampleSize     int
+	algorithm      string
+	digestBytes    int
+	key            string
+	chunks         int
+	full, sha, crc bool
+	seed           uint64
+}
+
+func (h *Hasher) settings() hashSettings {
+	return hashSettings{h.TargetCoverage, h.SampleSize, h.Algorithm, h.DigestBytes, string(h.Key), h.Chunks, h.Full, h.SHA256, h.CRC32, h.Seed}
+}
+
+// maxCachedArchives is how many archives' tables of contents are kept, the
+// files of one are hashed one after the other and reading the table of a
+// big archive for every one of them adds up.
+const maxCachedArchives = 8
+
+var (
+	archiveCacheMu sync.Mutex
+	archiveCache   = map[string]*members{}
+)
+
+// archiveMembers reads the table of contents of the archive at path, or
+// takes it from the cache if the archive didn't change since. With h the
+// files of archives that are read through to list them are hashed too,
+// see Hasher.ListArchive.
+func archiveMembers(ctx context.Context, path string, h *Hasher) (*members, error) {
+	format := archiveFormatOf(path)
+	if format == nil {
+		return nil, fmt.Errorf("%s is not an archive fsh24 can read", path)
+	}
+	f, err := Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	archiveCacheMu.Lock()
+	cached := archiveCache[path]
+	archiveCacheMu.Unlock()
+	if cached != nil && cached.size == f.Size() && cached.modTime.Equal(f.ModTime()) &&
+		(h == nil || cached.hashedBy == h || len(cached.streams) == 0) {
+		return cached, nil
+	}
+
+	list, streams, err := format.list(ctx, path, f, h)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	m := &members{byName: map[string]archiveMember{}, size: f.Size(), modTime: f.ModTime(), hashedBy: h, streams: streams}
+	if h != nil {
+		m.settings = h.settings()
+	}
+	for _, am := range list {
+		if _, ok := m.byName[am.name]; !ok { // The same name twice, the first one is what tools extract
+			m.order = append(m.order, am.name)
+			m.byName[am.name] = am
+		}
+	}
+
+	archiveCacheMu.Lock()
+	defer archiveCacheMu.Unlock()
+	if old := archiveCache[path]; old != nil {
+		old.close()
+	} else if len(archiveCache) >= maxCachedArchives {
+		for k, old := range archiveCache {
+			old.close() // Any one will do
+			delete(archiveCache, k)
+			break
+		}
+	}
+	archiveCache[path] = m
+	return m, nil
+}
+
+// close lets go of the decompressions the members had going.
+func (m *members) close() {
+	for _, s := range m.streams {
+		s.close()
+	}
+}
+
+// openMember opens the file member inside the archive at archive.
+func openMember(ctx context.Context, archive, member string) (File, error) {
+	m, err := archiveMembers(ctx, archive, nil)
+	if err != nil {
+		return nil, err
+	}
+	am, ok := m.byName[path.Clean(member)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: archive + ArchiveSep + member, Err: fs.ErrNotExist}
+	}
+	f, err := am.open(ctx, archive)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s in %s: %w", member, archive, err)
+	}
+	return f, nil
+}
+
+// listedHash is the hash of the file at p, a member of an archive, from
+// when h listed the archive. ok is false if it wasn't hashed then.
+func (h *Hasher) listedHash(ctx context.Context, p string) (FileHashResult, bool) {
+	archive, member, ok := SplitArchivePath(p)
+	if !ok {
+		return FileHashResult{}, false
+	}
+	archiveCacheMu.Lock()
+	cached := archiveCache[archive]
+	archiveCacheMu.Unlock()
+	if cached == nil || cached.hashedBy != h || cached.settings != h.settings() {
+		return FileHashResult{}, false
+	}
+	m, err := archiveMembers(ctx, archive, h) // Still the same archive?
+	if err != nil || m != cached {
+		return FileHashResult{}, false
+	}
+	am, ok := m.byName[path.Clean(member)]
+	if !ok || am.hash == nil {
+		return FileHashResult{}, false
+	}
+	r := *am.hash
+	r.Filepath, r.Filename = p, baseName(p)
+	return r, true
+}
+
+// sectionFile is a member stored as it is, a part of the archive.
+type sectionFile struct {
+	*io.SectionReader
+	archive File
+	modTime time.Time
+}
+
+func (f *sectionFile) Close() error       { return f.archive.Close() }
+func (f *sectionFile) ModTime() time.Time { return f.modTime }
+
+// streamFile is a compressed member. ReadAt decompresses up to where it's
+// asked to read from and throws that away, going forward is as fast as the
+// decompression and going back starts it over. Hashing reads from the start
+// to the end, so a sampled hash decompresses the member once, and HashFile
+// and Verifier work out all their hashes of it in one read, see sumStream.
+// It's not safe for reads at the same time, hashing doesn't do that.
+type streamFile struct {
+	size    int64
+	modTime time.Time
+	start   func() (io.Reader, error) // The member from its first byte
+	done    func() error
+
+	r   io.Reader
+	pos int64
+}
+
+func (f *streamFile) Size() int64        { return f.size }
+func (f *streamFile) ModTime() time.Time { return f.modTime }
+func (f *streamFile) Close() error       { return f.done() }
+
+func (f *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	if f.r == nil || off < f.pos {
+		r, err := f.start()
+		if err != nil {
+			return 0, err
+		}
+		f.r, f.pos = r, 0
+	}
+	if off > f.pos {
+		skipped, err := io.CopyN(io.Discard, f.r, off-f.pos)
+		f.pos += skipped
+		if err != nil {
+			f.r = nil
+			return 0, unexpected(err)
+		}
+	}
+	want := min(int64(len(p)), f.size-off)
+	n, err := io.ReadFull(f.r, p[:want])
+	f.pos += int64(n)
+	if err != nil {
+		f.r = nil
+		return n, unexpected(err)
+	}
+	if want < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// unexpected turns an EOF inside a member into the error it is, the member
+// is shorter than the archive said.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// sharedStream is one decompression of an archive, or of a solid block of
+// one, handed on from one member to the next. Files in a .tar.gz can only
+// be got to by decompressing everything before them, starting over for
+// every one would read the archive once per file. Opening the members in
+// the order they're in, like hashing and verifying do, reads it once.
+type sharedStream struct {
+	begin func(ctx context.Context) (*cursor, error) // Starts a new decompression from byte 0
+	end   int64                                      // Where the last member ends, nothing to share after it
+
+	mu     sync.Mutex
+	parked *cursor       // Given back by the last member, waiting for the next
+	inUse  bool          // A member has the shared one
+	user   int64         // Where that member starts
+	back   chan struct{} // Closed when it's given back
+}
+
+// cursor is a decompression and how far it got.
+type cursor struct {
+	r      io.Reader
+	closer io.Closer
+	pos    int64
+	shared bool // Goes back to the sharedStream, rather than being closed
+}
+
+func (c *cursor) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	return n, err
+}
+
+// take gets a decompression that isn't past at yet. If a member before at
+// has the shared one, it waits for it rather than starting another.
+func (s *sharedStream) take(ctx context.Context, at int64) (*cursor, error) {
+	for {
+		s.mu.Lock()
+		if c := s.parked; c != nil {
+			s.parked = nil
+			if c.pos <= at {
+				s.inUse, s.user, s.back = true, at, make(chan struct{})
+				s.mu.Unlock()
+				return c, nil
+			}
+			c.closer.Close() // Gone past, start over
+		} else if s.inUse && s.user <= at {
+			back := s.back
+			s.mu.Unlock()
+			select {
+			case <-back:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		shared := !s.inUse
+		if shared {
+			s.inUse, s.user, s.back = true, at, make(chan struct{})
+		}
+		s.mu.Unlock()
+
+		c, err := s.begin(ctx)
+		if err != nil {
+			if shared {
+				s.give(nil)
+			}
+			return nil, err
+		}
+		c.shared = shared
+		return c, nil
+	}
+}
+
+// give hands c back for the next member, nil if it broke. One that's done
+// with the last member is closed.
+func (s *sharedStream) give(c *cursor) {
+	if c != nil && (!c.shared || c.pos >= s.end) {
+		c.closer.Close()
+		if !c.shared {
+			return
+		}
+		c = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parked, s.inUse = c, false
+	if s.back != nil {
+		close(s.back)
+		s.back = nil
+	}
+}
+
+// close closes the parked decompression, if there is one.
+func (s *sharedStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.parked != nil {
+		s.parked.closer.Close()
+		s.parked = nil
+	}
+}
+
+// file is the member at start to start+size of the decompressed stream.
+func (s *sharedStream) file(ctx context.Context, start, size int64, modTime time.Time) File {
+	var c *cursor
+	return &streamFile{
+		size:    size,
+		modTime: modTime,
+		start: func() (io.Reader, error) {
+			if c != nil {
+				s.give(c)
+			}
+			var err error
+			if c, err = s.take(ctx, start); err != nil {
+				c = nil
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, c, start-c.pos); err != nil {
+				c.closer.Close()
+				if c.shared {
+					s.give(nil)
+				}
+				c = nil
+				return nil, unexpected(err)
+			}
+			return io.LimitReader(c, size), nil
+		},
+		done: func() error {
+			if c != nil {
+				s.give(c)
+				c = nil
+			}
+			return nil
+		},
+	}
+}
+
+// listZip reads the central directory of a ZIP file.
+func listZip(ctx context.Context, archive string, f File, h *Hasher) ([]archiveMember, []*sharedStream, error) {
+	zr, err := zip.NewReader(f, f.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+	var list []archiveMember
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		// Where the data starts, after the local header. Worked out now,
+		// zf reads it through f and f is closed once the list is done
+		offset, err := zf.DataOffset()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", zf.Name, err)
+		}
+		list = append(list, archiveMember{
+			name: path.Clean(strings.ReplaceAll(zf.Name, `\`, "/")),
+			open: func(ctx context.Context, archive string) (File, error) {
+				f, err := Open(ctx, archive)
+				if err != nil {
+					return nil, err
+				}
+				mf, err := openZipMember(f, zf, offset)
+				if err != nil {
+					f.Close()
+				}
+				return mf, err
+			},
+		})
+	}
+	return list, nil, nil
+}
+
+// openZipMember opens zf, whose data starts at offset, in the ZIP file f.
+func openZipMember(f File, zf *zip.File, offset int64) (File, error) {
+	size := int64(zf.CompressedSize64)
+	switch {
+	case zf.Flags&0x1 != 0:
+		return nil, errors.New("it's encrypted")
+	case zf.Method == zip.Store:
+		return &sectionFile{SectionReader: io.NewSectionReader(f, offset, size), archive: f, modTime: zf.Modified}, nil
+	case zf.Method == zip.Deflate:
+		return &streamFile{
+			size:    int64(zf.UncompressedSize64),
+			modTime: zf.Modified,
+			start: func() (io.Reader, error) {
+				return flate.NewReader(io.NewSectionReader(f, offset, size)), nil
+			},
+			done: f.Close,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w %d", zip.ErrAlgorithm, zf.Method)
+}
@@ -0,0 +1,714 @@
+package fsh24
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// A 7z file is a signature header pointing at the header at the end, which
+// says where the packed streams are and how to unpack them. Files are laid
+// end to end in the unpacked stream of a folder, a solid archive has them
+// all in one. Only what's needed to get the files out is read, the CRCs are
+// skipped, hashing the files is the check.
+
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+// Property IDs of the 7z header.
+const (
+	szEnd               = 0x00
+	szHeader            = 0x01
+	szArchiveProperties = 0x02
+	szAdditionalStreams = 0x03
+	szMainStreams       = 0x04
+	szFilesInfo         = 0x05
+	szPackInfo          = 0x06
+	szUnpackInfo        = 0x07
+	szSubStreamsInfo    = 0x08
+	szSize              = 0x09
+	szCRC               = 0x0A
+	szFolders           = 0x0B
+	szCodersUnpackSize  = 0x0C
+	szNumUnpackStream   = 0x0D
+	szEmptyStream       = 0x0E
+	szEmptyFile         = 0x0F
+	szAnti              = 0x10
+	szName              = 0x11
+	szMTime             = 0x14
+	szWinAttributes     = 0x15
+	szEncodedHeader     = 0x17
+)
+
+// Methods of the coders fsh24 can unpack, and AES to tell encrypted ones apart.
+const (
+	szCopy    = "\x00"
+	szLZMA    = "\x03\x01\x01"
+	szLZMA2   = "\x21"
+	szDeflate = "\x04\x01\x08"
+	szBZip2   = "\x04\x02\x02"
+	szAES     = "\x06\xF1\x07\x01"
+)
+
+// szMaxHeader is the biggest header read into memory, a bigger one is a
+// broken archive rather than one with that many files.
+const szMaxHeader = 1 << 30
+
+type szCoder struct {
+	method string
+	props  []byte
+}
+
+type szFolder struct {
+	coders     []szCoder
+	packOffset int64 // From the start of the file
+	packSize   int64
+	size       int64   // Unpacked
+	files      []int64 // Sizes of the files in it, in order
+}
+
+type szFile struct {
+	name     string
+	modTime  time.Time
+	attrib   uint32
+	hasData  bool // Not an empty stream
+	empty    bool // An empty file, rather than a folder
+	anti     bool // Deletes the file, in update archives
+	isFolder bool
+}
+
+// listSevenZip reads the header of a 7z file. Files in an LZMA or LZMA2
+// folder are got to by unpacking the folder from the start, like a
+// compressed tar, so with h they're hashed while the header is read and
+// opened later through a sharedStream per folder. Files in a Copy folder
+// are read right out of the archive.
+func listSevenZip(ctx context.Context, archive string, f File, h *Hasher) ([]archiveMember, []*sharedStream, error) {
+	folders, files, err := readSevenZipHeader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	streams := make([]*sharedStream, len(folders))
+	for i := range folders {
+		folder := &folders[i]
+		streams[i] = &sharedStream{begin: func(ctx context.Context) (*cursor, error) {
+			f, err := Open(ctx, archive)
+			if err != nil {
+				return nil, err
+			}
+			r, err := folder.reader(&ctxReader{ctx: ctx, r: io.NewSectionReader(f, folder.packOffset, folder.packSize)})
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			return &cursor{r: r, closer: f}, nil
+		}}
+	}
+
+	var list []archiveMember
+	fi, next, start := 0, 0, int64(0) // The folder, its next file and where that starts
+	var unpacked *cursor              // Of folder fi, hashing on the way
+	for _, sf := range files {
+		if sf.hasData {
+			for fi < len(folders) && next >= len(folders[fi].files) {
+				fi, next, start = fi+1, 0, 0
+				unpacked = nil
+			}
+			if fi == len(folders) {
+				return nil, nil, errors.New("more files than there's data for")
+			}
+		}
+		link := sf.attrib&0x8000 != 0 && (sf.attrib>>16)&0xF000 == 0xA000 // Its data is where it points
+		if sf.anti || sf.isFolder || link {
+			if sf.hasData {
+				next, start = next+1, start+folders[fi].files[next]
+				unpacked = nil // Not hashed, the rest of the folder isn't either
+			}
+			continue
+		}
+		am := archiveMember{name: path.Clean(strings.ReplaceAll(sf.name, `\`, "/"))}
+		modTime := sf.modTime
+		if !sf.hasData {
+			am.open = func(context.Context, string) (File, error) {
+				return &streamFile{modTime: modTime, done: func() error { return nil }}, nil
+			}
+			list = append(list, am)
+			continue
+		}
+
+		folder, stream := &folders[fi], streams[fi]
+		size, at := folder.files[next], start
+		if len(folder.coders) == 1 && folder.coders[0].method == szCopy {
+			offset := folder.packOffset + at
+			am.open = func(ctx context.Context, archive string) (File, error) {
+				f, err := Open(ctx, archive)
+				if err != nil {
+					return nil, err
+				}
+				return &sectionFile{SectionReader: io.NewSectionReader(f, offset, size), archive: f, modTime: modTime}, nil
+			}
+		} else {
+			am.open = func(ctx context.Context, _ string) (File, error) {
+				return stream.file(ctx, at, size, modTime), nil
+			}
+			stream.end = at + size
+			if h != nil && next == 0 {
+				// A method that isn't supported leaves the hashes out, the
+				// files give the error when they're opened
+				if r, err := folder.reader(&ctxReader{ctx: ctx, r: io.NewSectionReader(f, folder.packOffset, folder.packSize)}); err == nil {
+					unpacked = &cursor{r: r}
+				}
+			}
+			if unpacked != nil {
+				result, err := h.hashStream(ctx, unpacked, size, modTime)
+				if err := ctx.Err(); err != nil {
+					return nil, nil, err
+				}
+				// So does a broken folder, from there on
+				if err == nil {
+					am.hash = &result
+				} else {
+					unpacked = nil
+				}
+			}
+		}
+		list = append(list, am)
+		next, start = next+1, start+size
+	}
+	return list, streams, nil
+}
+
+// reader unpacks the folder from packed, its packed stream.
+func (folder *szFolder) reader(packed io.Reader) (io.Reader, error) {
+	if len(folder.coders) != 1 {
+		for _, c := range folder.coders {
+			if c.method == szAES {
+				return nil, errors.New("it's encrypted")
+			}
+		}
+		return nil, fmt.Errorf("7z filters (%d methods in a row) aren't supported, only Copy, LZMA, LZMA2, Deflate and BZip2 on their own", len(folder.coders))
+	}
+	c := folder.coders[0]
+	switch c.method {
+	case szCopy:
+		return packed, nil
+	case szLZMA:
+		if len(c.props) != 5 {
+			return nil, errors.New("bad LZMA properties")
+		}
+		// The props and size make the header of a .lzma file
+		header := binary.LittleEndian.AppendUint64(append([]byte{}, c.props...), uint64(folder.size))
+		return lzma.NewReader(io.MultiReader(bytes.NewReader(header), packed))
+	case szLZMA2:
+		if len(c.props) != 1 || c.props[0] > 40 {
+			return nil, errors.New("bad LZMA2 properties")
+		}
+		dict := int64(0xFFFFFFFF)
+		if c.props[0] < 40 {
+			dict = int64(2|c.props[0]&1) << (c.props[0]/2 + 11)
+		}
+		// The dictionary never has to be bigger than what's unpacked
+		dict = max(min(dict, folder.size), lzma.MinDictCap)
+		return lzma.Reader2Config{DictCap: int(dict)}.NewReader2(packed)
+	case szDeflate:
+		return flate.NewReader(packed), nil
+	case szBZip2:
+		return bzip2.NewReader(packed), nil
+	case szAES:
+		return nil, errors.New("it's encrypted")
+	}
+	return nil, fmt.Errorf("7z method %X isn't supported", c.method)
+}
+
+// readSevenZipHeader reads the folders and files of the 7z file f,
+// unpacking the header first if it's packed, which it usually is.
+func readSevenZipHeader(f File) ([]szFolder, []szFile, error) {
+	var start [32]byte
+	if _, err := f.ReadAt(start[:], 0); err != nil {
+		return nil, nil, unexpected(err)
+	}
+	if !bytes.Equal(start[:6], sevenZipSignature) {
+		return nil, nil, errors.New("not a 7z file")
+	}
+	offset := binary.LittleEndian.Uint64(start[12:])
+	size := binary.LittleEndian.Uint64(start[20:])
+	if size == 0 {
+		return nil, nil, nil // Empty archive
+	}
+	if size > szMaxHeader || offset > uint64(f.Size()) || 32+offset+size > uint64(f.Size()) {
+		return nil, nil, errors.New("the header is past the end, the file is cut short")
+	}
+	header := make([]byte, size)
+	if _, err := f.ReadAt(header, int64(32+offset)); err != nil {
+		return nil, nil, unexpected(err)
+	}
+
+	for {
+		r := &szReader{b: header}
+		switch r.byte() {
+		case szHeader:
+			folders, files := r.header(f.Size())
+			if r.err != nil {
+				return nil, nil, r.err
+			}
+			return folders, files, nil
+		case szEncodedHeader:
+			folders := r.streamsInfo(f.Size())
+			if r.err != nil {
+				return nil, nil, r.err
+			}
+			if len(folders) == 0 || folders[0].size > szMaxHeader {
+				return nil, nil, errors.New("bad packed header")
+			}
+			unpack, err := folders[0].reader(io.NewSectionReader(f, folders[0].packOffset, folders[0].packSize))
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not unpack the header: %w", err)
+			}
+			header = make([]byte, folders[0].size)
+			if _, err := io.ReadFull(unpack, header); err != nil {
+				return nil, nil, fmt.Errorf("could not unpack the header: %w", unexpected(err))
+			}
+		default:
+			return nil, nil, errors.New("bad header")
+		}
+	}
+}
+
+// szReader reads the parts of a 7z header. The first thing wrong with it
+// sticks in err, and everything read after that is zero.
+type szReader struct {
+	b   []byte
+	err error
+}
+
+func (r *szReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("bad header")
+	}
+	r.b = nil
+}
+
+func (r *szReader) byte() byte {
+	if len(r.b) == 0 {
+		r.fail()
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *szReader) bytes(n int) []byte {
+	if n > len(r.b) {
+		r.fail()
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *szReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *szReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// number reads a 7z NUMBER, the ones in front of the first 0 bit of the
+// first byte say how many bytes follow.
+func (r *szReader) number() uint64 {
+	first := r.byte()
+	var n uint64
+	for i := 0; i < 8; i++ {
+		mask := byte(0x80) >> i
+		if first&mask == 0 {
+			return n | uint64(first&(mask-1))<<(8*i)
+		}
+		n |= uint64(r.byte()) << (8 * i)
+	}
+	return n
+}
+
+// count reads a number of things that each take at least a bit of what's
+// left, so a broken one can't make a huge slice.
+func (r *szReader) count() int {
+	n := r.number()
+	if n > uint64(len(r.b))*8+8 {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+// size reads a size that has to fit in the file, or in what a folder
+// unpacks to when that's bigger.
+func (r *szReader) size() int64 {
+	n := r.number()
+	if n > 1<<62 {
+		r.fail()
+		return 0
+	}
+	return int64(n)
+}
+
+func (r *szReader) expect(id byte) {
+	if r.byte() != id {
+		r.fail()
+	}
+}
+
+// bits reads n bits, the first one in the top bit of the first byte.
+func (r *szReader) bits(n int) []bool {
+	v := make([]bool, n)
+	var b byte
+	for i := range v {
+		if i%8 == 0 {
+			b = r.byte()
+		}
+		v[i] = b&(0x80>>(i%8)) != 0
+	}
+	return v
+}
+
+// defined reads which of n things are there, all of them or a bit each.
+func (r *szReader) defined(n int) []bool {
+	if r.byte() == 0 {
+		return r.bits(n)
+	}
+	v := make([]bool, n)
+	for i := range v {
+		v[i] = true
+	}
+	return v
+}
+
+// digests reads past the CRCs of n streams and returns which ones had one.
+func (r *szReader) digests(n int) []bool {
+	defined := r.defined(n)
+	for _, d := range defined {
+		if d {
+			r.uint32()
+		}
+	}
+	return defined
+}
+
+// header reads a Header, everything in the archive but where the packed
+// streams of the ones in AdditionalStreams go, which nothing uses.
+func (r *szReader) header(fileSize int64) ([]szFolder, []szFile) {
+	id := r.byte()
+	if id == szArchiveProperties {
+		for r.byte() != 0 && r.err == nil {
+			r.bytes(r.count())
+		}
+		id = r.byte()
+	}
+	if id == szAdditionalStreams {
+		r.streamsInfo(fileSize)
+		id = r.byte()
+	}
+	var folders []szFolder
+	if id == szMainStreams {
+		folders = r.streamsInfo(fileSize)
+		id = r.byte()
+	}
+	var files []szFile
+	if id == szFilesInfo {
+		files = r.filesInfo()
+		id = r.byte()
+	}
+	if id != szEnd {
+		r.fail()
+	}
+	return folders, files
+}
+
+// streamsInfo reads the packed streams and the folders they unpack to,
+// with the sizes of the files in each.
+func (r *szReader) streamsInfo(fileSize int64) []szFolder {
+	var packPos int64
+	var packSizes []int64
+	id := r.byte()
+	if id == szPackInfo {
+		packPos = r.size()
+		packSizes = make([]int64, r.count())
+		id = r.byte()
+		if id == szSize {
+			for i := range packSizes {
+				packSizes[i] = r.size()
+			}
+			id = r.byte()
+		}
+		if id == szCRC {
+			r.digests(len(packSizes))
+			id = r.byte()
+		}
+		if id != szEnd {
+			r.fail()
+		}
+		id = r.byte()
+	}
+
+	var folders []szFolder
+	var folderCRC []bool
+	if id == szUnpackInfo {
+		r.expect(szFolders)
+		folders = make([]szFolder, r.count())
+		r.expect(0) // Not kept somewhere else
+		outs := make([]int, len(folders))
+		bound := make([]map[uint64]bool, len(folders))
+		pack := 0
+		offset := 32 + packPos
+		for i := range folders {
+			var packed int
+			outs[i], bound[i], packed = r.folder(&folders[i])
+			if pack+packed > len(packSizes) {
+				r.fail()
+				return nil
+			}
+			folders[i].packOffset = offset
+			folders[i].packSize = packSizes[pack]
+			for _, s := range packSizes[pack : pack+packed] {
+				offset += s
+			}
+			pack += packed
+			if offset > fileSize || offset < 0 {
+				r.fail()
+				return nil
+			}
+		}
+		r.expect(szCodersUnpackSize)
+		for i := range folders {
+			// The folder unpacks to the one stream no other coder takes
+			for out := 0; out < outs[i]; out++ {
+				if size := r.size(); !bound[i][uint64(out)] {
+					folders[i].size = size
+				}
+			}
+		}
+		id = r.byte()
+		if id == szCRC {
+			folderCRC = r.digests(len(folders))
+			id = r.byte()
+		}
+		if id != szEnd {
+			r.fail()
+		}
+		id = r.byte()
+	}
+	for i := range folders {
+		folders[i].files = []int64{folders[i].size}
+	}
+
+	if id == szSubStreamsInfo {
+		r.subStreamsInfo(folders, folderCRC)
+		id = r.byte()
+	}
+	if id != szEnd {
+		r.fail()
+	}
+	return folders
+}
+
+// folder reads a Folder, its coders and how they're tied together, and
+// returns how many out streams it has, the ones bound to another coder and
+// how many packed streams it takes.
+func (r *szReader) folder(folder *szFolder) (outs int, bound map[uint64]bool, packed int) {
+	ins := 0
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		flags := r.byte()
+		if flags&0x80 != 0 {
+			r.fail() // Alternative methods, 7-Zip never writes them
+			return
+		}
+		c := szCoder{method: string(r.bytes(int(flags & 0x0F)))}
+		in, out := 1, 1
+		if flags&0x10 != 0 {
+			in, out = r.count(), r.count()
+		}
+		if flags&0x20 != 0 {
+			c.props = r.bytes(r.count())
+		}
+		folder.coders = append(folder.coders, c)
+		ins, outs = ins+in, outs+out
+	}
+	if outs == 0 {
+		r.fail()
+		return
+	}
+	bound = map[uint64]bool{}
+	for i := 0; i < outs-1; i++ {
+		r.number() // The in stream
+		bound[r.number()] = true
+	}
+	packed = ins - (outs - 1)
+	if packed < 1 {
+		r.fail()
+		return
+	}
+	if packed > 1 {
+		for i := 0; i < packed; i++ {
+			r.number()
+		}
+	}
+	return
+}
+
+// subStreamsInfo reads how many files are in each folder and their sizes,
+// the last one in a folder is what's left of it.
+func (r *szReader) subStreamsInfo(folders []szFolder, folderCRC []bool) {
+	counts := make([]int, len(folders))
+	for i := range counts {
+		counts[i] = 1
+	}
+	id := r.byte()
+	if id == szNumUnpackStream {
+		for i := range counts {
+			counts[i] = r.count()
+		}
+		id = r.byte()
+	}
+	for i := range folders {
+		folders[i].files = make([]int64, 0, counts[i])
+		if counts[i] == 0 {
+			continue
+		}
+		left := folders[i].size
+		if id == szSize {
+			for j := 1; j < counts[i]; j++ {
+				size := r.size()
+				if size > left {
+					r.fail()
+					return
+				}
+				folders[i].files = append(folders[i].files, size)
+				left -= size
+			}
+		} else if counts[i] > 1 {
+			r.fail()
+			return
+		}
+		folders[i].files = append(folders[i].files, left)
+	}
+	if id == szSize {
+		id = r.byte()
+	}
+	if id == szCRC {
+		n := 0
+		for i, c := range counts {
+			if c != 1 || folderCRC == nil || !folderCRC[i] {
+				n += c
+			}
+		}
+		r.digests(n)
+		id = r.byte()
+	}
+	if id != szEnd {
+		r.fail()
+	}
+}
+
+// filesInfo reads the names and times of the files, and which ones have
+// no data, being empty or folders.
+func (r *szReader) filesInfo() []szFile {
+	files := make([]szFile, r.count())
+	var emptyStreams []int // The files with no data, the empty and anti bits go by them
+	for i := range files {
+		files[i].hasData = true
+	}
+	for r.err == nil {
+		id := r.byte()
+		if id == szEnd {
+			break
+		}
+		p := &szReader{b: r.bytes(r.count())}
+		switch id {
+		case szEmptyStream:
+			emptyStreams = emptyStreams[:0]
+			for i, empty := range p.bits(len(files)) {
+				files[i].hasData = !empty
+				if empty {
+					emptyStreams = append(emptyStreams, i)
+				}
+			}
+		case szEmptyFile:
+			for i, empty := range p.bits(len(emptyStreams)) {
+				files[emptyStreams[i]].empty = empty
+			}
+		case szAnti:
+			for i, anti := range p.bits(len(emptyStreams)) {
+				files[emptyStreams[i]].anti = anti
+			}
+		case szName:
+			p.expect(0)
+			for i := range files {
+				files[i].name = p.name()
+			}
+		case szMTime:
+			defined := p.defined(len(files))
+			p.expect(0)
+			for i, d := range defined {
+				if d {
+					files[i].modTime = filetime(p.uint64())
+				}
+			}
+		case szWinAttributes:
+			defined := p.defined(len(files))
+			p.expect(0)
+			for i, d := range defined {
+				if d {
+					files[i].attrib = p.uint32()
+				}
+			}
+		}
+		if p.err != nil {
+			r.err = p.err
+		}
+	}
+	for i := range files {
+		// A no data entry that isn't an empty file is a folder
+		files[i].isFolder = files[i].attrib&0x10 != 0 || !files[i].hasData && !files[i].empty
+	}
+	return files
+}
+
+// name reads a UTF-16 name up to the 0 after it.
+func (r *szReader) name() string {
+	var u []uint16
+	for r.err == nil {
+		c := uint16(r.byte()) | uint16(r.byte())<<8
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// filetime turns a Windows FILETIME, 100ns steps since 1601, into a time.
+func filetime(ft uint64) time.Time {
+	const unixEpoch = 116444736000000000
+	if ft < unixEpoch {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-unixEpoch)*100)
+}
@@ -0,0 +1,388 @@
+package fsh24
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// isoSector is the size of a sector on a CD, DVD or Blu-ray, and of the
+// blocks of the file systems on them.
+const isoSector = 2048
+
+// isoMaxDir is the biggest folder read into memory, a bigger one is a
+// broken image rather than one with that many files.
+const isoMaxDir = 64 << 20
+
+// isoMaxDepth is how deep the folders of a disc image are followed, to
+// stop at broken ones that loop.
+const isoMaxDepth = 64
+
+// discFile is a file on a disc image, where its bytes are on it.
+type discFile struct {
+	name    string // Slash separated, from the root
+	size    int64
+	modTime time.Time
+	extents []extent
+}
+
+// extent is a run of the bytes of a file on a disc image. offset -1 is a
+// run of zeros that isn't on the disc, a hole in a sparse UDF file.
+type extent struct {
+	offset, size int64
+}
+
+// listISO lists the files on a disc image. It goes by the UDF file system
+// when there is one, DVDs and Blu-rays have their files in it and the ISO
+// 9660 one next to it can be cut down or just a note to get a newer OS.
+// The files are stored as they are, they're sampled right off the image.
+func listISO(ctx context.Context, archive string, f File, _ *Hasher) ([]archiveMember, []*sharedStream, error) {
+	var files []discFile
+	var err error
+	if hasUDF(f) {
+		files, err = readUDF(ctx, f)
+	} else {
+		files, err = readISO9660(ctx, f)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	list := make([]archiveMember, 0, len(files))
+	for _, df := range files {
+		list = append(list, archiveMember{name: df.name, open: extentsMember(df.extents, df.size, df.modTime)})
+	}
+	return list, nil, nil
+}
+
+// extentsMember opens a file made of extents of the image at archive.
+func extentsMember(extents []extent, size int64, modTime time.Time) func(context.Context, string) (File, error) {
+	return func(ctx context.Context, archive string) (File, error) {
+		f, err := Open(ctx, archive)
+		if err != nil {
+			return nil, err
+		}
+		if len(extents) == 1 && extents[0].offset >= 0 && extents[0].size >= size {
+			return &sectionFile{SectionReader: io.NewSectionReader(f, extents[0].offset, size), archive: f, modTime: modTime}, nil
+		}
+		return &extentsFile{archive: f, extents: extents, size: size, modTime: modTime}, nil
+	}
+}
+
+// extentsFile is a file in pieces around a disc image, a big one on ISO
+// 9660 or a fragmented or sparse one on UDF.
+type extentsFile struct {
+	archive File
+	extents []extent
+	size    int64
+	modTime time.Time
+}
+
+func (f *extentsFile) Size() int64        { return f.size }
+func (f *extentsFile) ModTime() time.Time { return f.modTime }
+func (f *extentsFile) Close() error       { return f.archive.Close() }
+
+func (f *extentsFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n, at := 0, int64(0)
+	for _, e := range f.extents {
+		if n == len(p) || off == f.size {
+			break
+		}
+		if off >= at+e.size {
+			at += e.size
+			continue
+		}
+		want := min(int64(len(p)-n), at+e.size-off, f.size-off)
+		chunk := p[n : n+int(want)]
+		if e.offset < 0 {
+			clear(chunk)
+		} else if _, err := f.archive.ReadAt(chunk, e.offset+off-at); err != nil {
+			return n, unexpected(err)
+		}
+		n, off, at = n+int(want), off+want, at+e.size
+	}
+	if n < len(p) && off < f.size {
+		return n, io.ErrUnexpectedEOF // The extents don't add up to the size
+	} else if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readSectors reads size bytes at off of the image f, for the parts of a
+// file system that are read into memory.
+func readSectors(f File, off, size int64) ([]byte, error) {
+	if size > isoMaxDir || off < 0 || size < 0 {
+		return nil, errors.New("the file system is broken")
+	}
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, off); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// isoReader goes through the folders of an ISO 9660 file system.
+type isoReader struct {
+	f         File
+	blockSize int64
+	joliet    bool // UCS-2 names from the Joliet folders
+	rockRidge bool // POSIX names from Rock Ridge entries
+	suspSkip  int  // Bytes before the Rock Ridge entries of every record
+	visited   map[int64]bool
+	files     []discFile
+}
+
+// readISO9660 lists the files of the ISO 9660 file system of the image f.
+// Names come from Rock Ridge if the disc has it, Joliet if not, and the
+// 8.3 names only if it has neither.
+func readISO9660(ctx context.Context, f File) ([]discFile, error) {
+	var primary, joliet []byte
+	for i := int64(16); i < 16+64; i++ {
+		sector, err := readSectors(f, i*isoSector, isoSector)
+		if err != nil || string(sector[1:6]) != "CD001" || sector[0] == 255 {
+			break
+		}
+		switch sector[0] {
+		case 1:
+			if primary == nil {
+				primary = sector
+			}
+		case 2:
+			if esc := string(sector[88:91]); esc == "%/@" || esc == "%/C" || esc == "%/E" {
+				joliet = sector
+			}
+		}
+	}
+	if primary == nil {
+		return nil, errors.New("not an ISO 9660 or UDF disc image")
+	}
+
+	r := &isoReader{f: f, blockSize: int64(binary.LittleEndian.Uint16(primary[128:])), visited: map[int64]bool{}}
+	if r.blockSize == 0 {
+		r.blockSize = isoSector
+	}
+	root := isoRecord(primary[156:190])
+	if skip, ok := r.hasRockRidge(root); ok {
+		r.rockRidge, r.suspSkip = true, skip
+	} else if joliet != nil {
+		r.joliet, root = true, isoRecord(joliet[156:190])
+	}
+	if err := r.walk(ctx, "", root, 0); err != nil {
+		return nil, err
+	}
+	return r.files, nil
+}
+
+// isoRecord is a directory record, a file or folder in a folder.
+type isoRecord []byte
+
+func (d isoRecord) offset(blockSize int64) int64 {
+	return (int64(binary.LittleEndian.Uint32(d[2:])) + int64(d[1])) * blockSize // After the extended attributes
+}
+func (d isoRecord) size() int64     { return int64(binary.LittleEndian.Uint32(d[10:])) }
+func (d isoRecord) flags() byte     { return d[25] }
+func (d isoRecord) rawName() []byte { return d[33 : 33+int(d[32])] }
+
+// systemUse is what comes after the name, the Rock Ridge entries.
+func (d isoRecord) systemUse() []byte {
+	start := 33 + int(d[32])
+	if d[32]%2 == 0 {
+		start++ // Padding to keep it even
+	}
+	if start >= len(d) {
+		return nil
+	}
+	return d[start:]
+}
+
+// modTime is the recording time, which disc writers set to the file's.
+func (d isoRecord) modTime() time.Time {
+	b := d[18:25]
+	if b[0] == 0 && b[1] == 0 {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, zone)
+}
+
+// hasRockRidge looks for the SP entry in the first record of the root
+// folder, which says there are Rock Ridge entries and how far into each
+// record's system use area they start.
+func (r *isoReader) hasRockRidge(root isoRecord) (int, bool) {
+	data, err := readSectors(r.f, root.offset(r.blockSize), min(root.size(), isoSector))
+	if err != nil || len(data) < 34 || int(data[0]) < 34 || int(data[0]) > len(data) {
+		return 0, false
+	}
+	su := isoRecord(data[:data[0]]).systemUse()
+	if len(su) >= 7 && string(su[:2]) == "SP" && su[4] == 0xBE && su[5] == 0xEF {
+		return int(su[6]), true
+	}
+	return 0, false
+}
+
+// isoSUSP is what the Rock Ridge entries of a record say about it.
+type isoSUSP struct {
+	name      string
+	symlink   bool
+	relocated bool  // A deep folder moved here, it shows up where its CL is
+	childLink int64 // Where the moved folder is, for its CL in its real place, -1 if not
+}
+
+func (r *isoReader) susp(d isoRecord) isoSUSP {
+	s := isoSUSP{childLink: -1}
+	if !r.rockRidge {
+		return s
+	}
+	area := d.systemUse()
+	if len(area) < r.suspSkip {
+		return s
+	}
+	area = area[r.suspSkip:]
+	var name []byte
+	var next []byte // Where a CE entry says the entries go on
+	for hops := 0; hops < 16; {
+		if len(area) < 4 || int(area[2]) < 4 || int(area[2]) > len(area) {
+			if next == nil {
+				break
+			}
+			area, next = next, nil
+			hops++
+			continue
+		}
+		e := area[:area[2]]
+		area = area[area[2]:]
+		switch string(e[:2]) {
+		case "NM":
+			if len(e) > 5 && e[4]&0x06 == 0 { // Not . or ..
+				name = append(name, e[5:]...)
+			}
+		case "SL":
+			s.symlink = true
+		case "RE":
+			s.relocated = true
+		case "CL":
+			if len(e) >= 12 {
+				s.childLink = int64(binary.LittleEndian.Uint32(e[4:]))
+			}
+		case "CE":
+			if len(e) >= 28 {
+				block, off, size := binary.LittleEndian.Uint32(e[4:]), binary.LittleEndian.Uint32(e[12:]), binary.LittleEndian.Uint32(e[20:])
+				next, _ = readSectors(r.f, int64(block)*r.blockSize+int64(off), min(int64(size), isoSector))
+			}
+		case "ST":
+			area = nil
+		}
+	}
+	s.name = string(name)
+	return s
+}
+
+// nameOf is the name of the record d, s being its Rock Ridge entries.
+func (r *isoReader) nameOf(d isoRecord, s isoSUSP) string {
+	if s.name != "" {
+		return s.name
+	}
+	raw := d.rawName()
+	var name string
+	if r.joliet {
+		u := make([]uint16, len(raw)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(raw[2*i:])
+		}
+		name = string(utf16.Decode(u))
+	} else {
+		name = string(raw)
+	}
+	if i := strings.LastIndex(name, ";"); i >= 0 {
+		name = name[:i] // The version, always 1
+	}
+	if !r.joliet && d.flags()&0x02 == 0 {
+		name = strings.TrimSuffix(name, ".") // "README." for a name with no extension
+	}
+	return name
+}
+
+// walk lists the files in the folder d, at dir, and the folders in it.
+func (r *isoReader) walk(ctx context.Context, dir string, d isoRecord, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	offset := d.offset(r.blockSize)
+	if depth > isoMaxDepth || r.visited[offset] {
+		return nil
+	}
+	r.visited[offset] = true
+	data, err := readSectors(r.f, offset, d.size())
+	if err != nil {
+		return err
+	}
+
+	var pending *discFile // A file in more than one extent, the records of it follow each other
+	for off := 0; off < len(data); {
+		n := int(data[off])
+		if n == 0 {
+			off = (off/isoSector + 1) * isoSector // Records don't cross sectors
+			continue
+		}
+		if n < 34 || off+n > len(data) || 33+int(data[off+32]) > n {
+			return fmt.Errorf("bad directory record in %q", "/"+dir)
+		}
+		rec := isoRecord(data[off : off+n])
+		off += n
+		if raw := rec.rawName(); len(raw) == 1 && raw[0] <= 1 {
+			continue // . and ..
+		}
+		s := r.susp(rec)
+		name := r.nameOf(rec, s)
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+			continue
+		}
+		full := path.Join(dir, name)
+
+		switch {
+		case s.relocated:
+			continue
+		case s.childLink >= 0:
+			child, err := readSectors(r.f, s.childLink*r.blockSize, isoSector)
+			if err != nil {
+				return err
+			}
+			if int(child[0]) < 34 {
+				continue
+			}
+			if err := r.walk(ctx, full, isoRecord(child[:child[0]]), depth+1); err != nil {
+				return err
+			}
+			continue
+		case rec.flags()&0x02 != 0:
+			if err := r.walk(ctx, full, rec, depth+1); err != nil {
+				return err
+			}
+			continue
+		case s.symlink, rec.flags()&0x04 != 0: // Associated files are Mac resource forks
+			continue
+		}
+
+		e := extent{offset: rec.offset(r.blockSize), size: rec.size()}
+		if pending != nil && pending.name == full {
+			pending.extents = append(pending.extents, e)
+			pending.size += e.size
+		} else {
+			pending = &discFile{name: full, size: e.size, modTime: rec.modTime(), extents: []extent{e}}
+		}
+		if rec.flags()&0x80 == 0 { // The last extent of it
+			r.files = append(r.files, *pending)
+			pending = nil
+		}
+	}
+	return nil
+}
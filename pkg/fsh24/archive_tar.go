@@ -0,0 +1,183 @@
+package fsh24
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+// tarExts are the tar files ListArchive can look into, compressed or not.
+var tarExts = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".tar.xz", ".txz"}
+
+// tarDecompressor returns what decompresses the tar at archive, nil for a
+// plain .tar.
+func tarDecompressor(archive string) func(io.Reader) (io.Reader, error) {
+	name := strings.ToLower(baseName(archive))
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return func(r io.Reader) (io.Reader, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr, nil
+		}
+	case strings.HasSuffix(name, ".bz2"), strings.HasSuffix(name, ".tbz2"), strings.HasSuffix(name, ".tbz"):
+		return func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }
+	case strings.HasSuffix(name, ".xz"), strings.HasSuffix(name, ".txz"):
+		return func(r io.Reader) (io.Reader, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return xr, nil
+		}
+	}
+	return nil
+}
+
+// isSparse reports whether the tar entry hdr is a sparse file, whose data
+// in the tar isn't the file as it is.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// errSparse is what opening a sparse file in a plain tar gives. Its data is
+// only the parts that aren't holes, sampling it would hash the wrong bytes.
+var errSparse = errors.New("sparse files in a tar can't be read")
+
+// listTar goes through the headers of a tar. A plain one is read header to
+// header, skipping over the files, and the files can be sampled right out
+// of it. A compressed one has to be decompressed all the way through, the
+// files are hashed with h on the way if it's given and opened later by
+// decompressing it again, once for all of them, see sharedStream.
+func listTar(ctx context.Context, archive string, f File, h *Hasher) ([]archiveMember, []*sharedStream, error) {
+	decompress := tarDecompressor(archive)
+	if decompress == nil {
+		return listPlainTar(f)
+	}
+
+	stream := &sharedStream{begin: func(ctx context.Context) (*cursor, error) {
+		f, err := Open(ctx, archive)
+		if err != nil {
+			return nil, err
+		}
+		r, err := decompress(&ctxReader{ctx: ctx, r: io.NewSectionReader(f, 0, f.Size())})
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &cursor{r: r, closer: f}, nil
+	}}
+
+	r, err := decompress(&ctxReader{ctx: ctx, r: io.NewSectionReader(f, 0, f.Size())})
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &cursor{r: r}
+	tr := tar.NewReader(c)
+	var list []archiveMember
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		am := archiveMember{name: path.Clean(hdr.Name)}
+		if isSparse(hdr) {
+			// Reading it through tr fills the holes in, but only going
+			// through the whole tar again
+			am.open = func(context.Context, string) (File, error) { return nil, errSparse }
+		} else {
+			start, size, modTime := c.pos, hdr.Size, hdr.ModTime
+			am.open = func(ctx context.Context, _ string) (File, error) {
+				return stream.file(ctx, start, size, modTime), nil
+			}
+			stream.end = start + size
+		}
+		if h != nil && !isSparse(hdr) {
+			result, err := h.hashStream(ctx, tr, hdr.Size, hdr.ModTime)
+			if err != nil {
+				return nil, nil, err
+			}
+			am.hash = &result
+		}
+		list = append(list, am)
+	}
+	return list, []*sharedStream{stream}, nil
+}
+
+// listPlainTar lists an uncompressed tar, see listTar.
+func listPlainTar(f File) ([]archiveMember, []*sharedStream, error) {
+	sr := io.NewSectionReader(f, 0, f.Size())
+	tr := tar.NewReader(sr) // Skips over the files with Seek
+	var list []archiveMember
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		am := archiveMember{name: path.Clean(hdr.Name)}
+		if isSparse(hdr) {
+			am.open = func(context.Context, string) (File, error) { return nil, errSparse }
+		} else {
+			offset, _ := sr.Seek(0, io.SeekCurrent)
+			size, modTime := hdr.Size, hdr.ModTime
+			am.open = func(ctx context.Context, archive string) (File, error) {
+				f, err := Open(ctx, archive)
+				if err != nil {
+					return nil, err
+				}
+				return &sectionFile{SectionReader: io.NewSectionReader(f, offset, size), archive: f, modTime: modTime}, nil
+			}
+		}
+		list = append(list, am)
+	}
+	return list, nil, nil
+}
+
+// hashStream hashes the next size bytes of r, a file in an archive read
+// from start to end, the same as HashFile would. The paths are left for
+// the caller to fill in.
+func (h *Hasher) hashStream(ctx context.Context, r io.Reader, size int64, modTime time.Time) (FileHashResult, error) {
+	start := time.Now()
+	hashHex, chunks, fullHex, crcHex, err := h.sumOnce(ctx, r, size)
+	if err != nil {
+		return FileHashResult{}, err
+	}
+	return FileHashResult{
+		FileSize:        size,
+		FSH24:           strings.ToUpper(hashHex),
+		SHA256:          strings.ToUpper(fullHex),
+		CRC32:           strings.ToUpper(crcHex),
+		Chunks:          chunks,
+		CoveragePercent: h.coverage(chunks, size),
+		ProcessingTime:  time.Since(start).Seconds(),
+		ModTime:         modTime,
+	}, nil
+}
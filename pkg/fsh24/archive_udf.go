@@ -0,0 +1,439 @@
+package fsh24
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// UDF is read as far as the files on DVDs, Blu-rays and images made with
+// mkudffs or ImgBurn go: plain and metadata partitions, file entries and
+// extended ones, short, long and embedded allocation descriptors. Sparing
+// tables are ignored, an image is already what was read off the disc, and
+// CD-R style virtual partitions aren't supported.
+
+// Descriptor tags.
+const (
+	udfAnchor       = 2
+	udfPartition    = 5
+	udfLogicalVol   = 6
+	udfTerminating  = 8
+	udfFileSet      = 256
+	udfFileID       = 257
+	udfAllocExtent  = 258
+	udfFileEntry    = 261
+	udfExtFileEntry = 266
+)
+
+// udfTypeFile is the file type of a file in an ICB tag, rather than a
+// folder or a link.
+const udfTypeFile = 5
+
+// hasUDF reports whether the image f has a UDF file system, going by the
+// volume recognition sequence after the ISO 9660 descriptors.
+func hasUDF(f File) bool {
+	for i := int64(16); i < 16+64; i++ {
+		sector, err := readSectors(f, i*isoSector, 8)
+		if err != nil {
+			return false
+		}
+		switch string(sector[1:6]) {
+		case "NSR02", "NSR03":
+			return true
+		case "CD001", "BEA01", "TEA01", "BOOT2", "CDW02":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// udfPart is a partition, where its blocks are on the image. A metadata
+// partition has its blocks in the extents of the metadata file.
+type udfPart struct {
+	start    int64 // Sector of block 0
+	metadata []extent
+}
+
+// udfReader goes through the folders of a UDF file system.
+type udfReader struct {
+	f       File
+	parts   []udfPart
+	visited map[int64]bool
+	files   []discFile
+}
+
+// readUDF lists the files of the UDF file system of the image f.
+func readUDF(ctx context.Context, f File) ([]discFile, error) {
+	r := &udfReader{f: f, visited: map[int64]bool{}}
+
+	// The anchor is at sector 256, or at the end if it was left out there
+	var anchor []byte
+	last := f.Size()/isoSector - 1
+	for _, s := range []int64{256, last, last - 256} {
+		if b, err := readSectors(f, s*isoSector, isoSector); err == nil && udfTag(b) == udfAnchor {
+			anchor = b
+			break
+		}
+	}
+	if anchor == nil {
+		return nil, errors.New("no UDF anchor, the image is broken or cut short")
+	}
+	vdsLen, vdsLoc := binary.LittleEndian.Uint32(anchor[16:]), binary.LittleEndian.Uint32(anchor[20:])
+
+	// The volume descriptors say where the partitions are and which
+	// block has the file set in it
+	starts := map[uint16]int64{}
+	var lvd []byte
+	for i := int64(0); i < int64(vdsLen)/isoSector && i < 256; i++ {
+		d, err := readSectors(f, (int64(vdsLoc)+i)*isoSector, isoSector)
+		if err != nil {
+			return nil, err
+		}
+		tag := udfTag(d)
+		if tag == udfTerminating || tag == 0 {
+			break
+		}
+		switch tag {
+		case udfPartition:
+			starts[binary.LittleEndian.Uint16(d[22:])] = int64(binary.LittleEndian.Uint32(d[188:]))
+		case udfLogicalVol:
+			lvd = d
+		}
+	}
+	if lvd == nil {
+		return nil, errors.New("no UDF logical volume")
+	}
+	if bs := int64(binary.LittleEndian.Uint32(lvd[212:])); bs != isoSector {
+		return nil, fmt.Errorf("UDF blocks of %d bytes aren't supported", bs)
+	}
+
+	// Partition maps, in the order partition references count them
+	var numbers []uint16 // The partition each map is on
+	var metadataFiles []struct {
+		ref int
+		loc uint32
+	}
+	maps, n := lvd[440:], int(binary.LittleEndian.Uint32(lvd[268:]))
+	for i := 0; i < n; i++ {
+		if len(maps) < 2 || int(maps[1]) < 2 || int(maps[1]) > len(maps) {
+			return nil, errors.New("bad UDF partition map")
+		}
+		m := maps[:maps[1]]
+		maps = maps[maps[1]:]
+		switch {
+		case m[0] == 1 && len(m) >= 6:
+			numbers = append(numbers, binary.LittleEndian.Uint16(m[4:]))
+		case m[0] == 2 && len(m) >= 48:
+			switch id := string(bytes.TrimRight(m[5:28], "\x00")); id {
+			case "*UDF Metadata Partition":
+				metadataFiles = append(metadataFiles, struct {
+					ref int
+					loc uint32
+				}{len(numbers), binary.LittleEndian.Uint32(m[40:])})
+			case "*UDF Sparable Partition":
+			default:
+				return nil, fmt.Errorf("UDF %s isn't supported", strings.TrimPrefix(id, "*UDF "))
+			}
+			numbers = append(numbers, binary.LittleEndian.Uint16(m[38:]))
+		default:
+			return nil, errors.New("bad UDF partition map")
+		}
+		r.parts = append(r.parts, udfPart{start: starts[numbers[len(numbers)-1]]})
+	}
+	for _, mf := range metadataFiles {
+		// The metadata file is in the plain partition the metadata one is on top of
+		under := slices.Index(numbers, numbers[mf.ref])
+		if under == mf.ref {
+			return nil, errors.New("no UDF partition under the metadata one")
+		}
+		ref := uint16(under)
+		off, err := r.address(ref, mf.loc)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := readSectors(f, off, isoSector)
+		if err != nil {
+			return nil, err
+		}
+		file, err := r.fileEntry(entry, off, ref)
+		if err != nil {
+			return nil, fmt.Errorf("UDF metadata file: %w", err)
+		}
+		r.parts[mf.ref].metadata = file.extents
+	}
+
+	// The file set descriptor has the root folder
+	fsdAddr := lvd[248+4:]
+	fsdOff, err := r.address(binary.LittleEndian.Uint16(fsdAddr[4:]), binary.LittleEndian.Uint32(fsdAddr))
+	if err != nil {
+		return nil, err
+	}
+	fsd, err := readSectors(f, fsdOff, isoSector)
+	if err != nil {
+		return nil, err
+	}
+	if udfTag(fsd) != udfFileSet {
+		return nil, errors.New("no UDF file set")
+	}
+	root := fsd[400:416]
+	if err := r.walk(ctx, "", binary.LittleEndian.Uint16(root[8:]), binary.LittleEndian.Uint32(root[4:]), 0); err != nil {
+		return nil, err
+	}
+	return r.files, nil
+}
+
+// udfTag is the tag identifier of the descriptor d, 0 if d isn't one.
+func udfTag(d []byte) uint16 {
+	if len(d) < 16 {
+		return 0
+	}
+	var sum byte
+	for i, b := range d[:16] {
+		if i != 4 {
+			sum += b
+		}
+	}
+	if sum != d[4] {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(d)
+}
+
+// address is where block lbn of partition part is on the image.
+func (r *udfReader) address(part uint16, lbn uint32) (int64, error) {
+	if int(part) >= len(r.parts) {
+		return 0, fmt.Errorf("no UDF partition %d", part)
+	}
+	p := r.parts[part]
+	if p.metadata == nil {
+		return (p.start + int64(lbn)) * isoSector, nil
+	}
+	off := int64(lbn) * isoSector
+	for _, e := range p.metadata {
+		if off < e.size {
+			if e.offset < 0 {
+				break
+			}
+			return e.offset + off, nil
+		}
+		off -= e.size
+	}
+	return 0, fmt.Errorf("UDF metadata block %d is past the end", lbn)
+}
+
+// walk lists the files in the folder whose file entry is block lbn of
+// partition part, at dir, and the folders in it.
+func (r *udfReader) walk(ctx context.Context, dir string, part uint16, lbn uint32, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	off, err := r.address(part, lbn)
+	if err != nil {
+		return err
+	}
+	if depth > isoMaxDepth || r.visited[off] {
+		return nil
+	}
+	r.visited[off] = true
+	entry, err := readSectors(r.f, off, isoSector)
+	if err != nil {
+		return err
+	}
+	folder, err := r.fileEntry(entry, off, part)
+	if err != nil {
+		return fmt.Errorf("%q: %w", "/"+dir, err)
+	}
+	data, err := r.read(folder)
+	if err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		if len(data) < 38 || udfTag(data) != udfFileID {
+			return fmt.Errorf("bad UDF folder %q", "/"+dir)
+		}
+		chars, nameLen, iuLen := data[18], int(data[19]), int(binary.LittleEndian.Uint16(data[36:]))
+		size := (38 + iuLen + nameLen + 3) &^ 3
+		if 38+iuLen+nameLen > len(data) {
+			return fmt.Errorf("bad UDF folder %q", "/"+dir)
+		}
+		icb := data[20:36]
+		name := udfName(data[38+iuLen : 38+iuLen+nameLen])
+		data = data[min(size, len(data)):]
+		if chars&0x0C != 0 || name == "" || strings.Contains(name, "/") {
+			continue // Deleted, or the parent
+		}
+		full := path.Join(dir, name)
+		childPart, childLBN := binary.LittleEndian.Uint16(icb[8:]), binary.LittleEndian.Uint32(icb[4:])
+		if chars&0x02 != 0 {
+			if err := r.walk(ctx, full, childPart, childLBN, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		childOff, err := r.address(childPart, childLBN)
+		if err != nil {
+			return err
+		}
+		childEntry, err := readSectors(r.f, childOff, isoSector)
+		if err != nil {
+			return err
+		}
+		file, err := r.fileEntry(childEntry, childOff, childPart)
+		if err != nil {
+			return fmt.Errorf("%q: %w", "/"+full, err)
+		}
+		if file.fileType == udfTypeFile {
+			file.name = full
+			r.files = append(r.files, file.discFile)
+		}
+	}
+	return nil
+}
+
+// udfFile is a file entry, where its data is and what it is.
+type udfFile struct {
+	discFile
+	fileType byte
+}
+
+// fileEntry reads the file entry e, which is at off on the image, in
+// partition part. Short allocation descriptors count blocks of that one,
+// long ones name theirs.
+func (r *udfReader) fileEntry(e []byte, off int64, part uint16) (udfFile, error) {
+	var file udfFile
+	var eaLen, adLen uint32
+	var adStart int
+	switch udfTag(e) {
+	case udfFileEntry:
+		file.size = int64(binary.LittleEndian.Uint64(e[56:]))
+		file.modTime = udfTime(e[84:96])
+		eaLen, adLen = binary.LittleEndian.Uint32(e[168:]), binary.LittleEndian.Uint32(e[172:])
+		adStart = 176
+	case udfExtFileEntry:
+		file.size = int64(binary.LittleEndian.Uint64(e[56:]))
+		file.modTime = udfTime(e[92:104])
+		eaLen, adLen = binary.LittleEndian.Uint32(e[208:]), binary.LittleEndian.Uint32(e[212:])
+		adStart = 216
+	default:
+		return file, errors.New("bad UDF file entry")
+	}
+	file.fileType = e[27]
+	if int64(adStart)+int64(eaLen)+int64(adLen) > int64(len(e)) {
+		return file, errors.New("bad UDF file entry")
+	}
+	ads := e[adStart+int(eaLen) : adStart+int(eaLen)+int(adLen)]
+
+	adType := binary.LittleEndian.Uint16(e[34:]) & 0x07
+	if adType == 3 { // The data is right there in the entry
+		file.extents = []extent{{offset: off + int64(adStart) + int64(eaLen), size: int64(adLen)}}
+		return file, nil
+	}
+	if adType > 1 {
+		return file, fmt.Errorf("UDF allocation descriptors of type %d aren't supported", adType)
+	}
+	for hops := 0; len(ads) > 0 && hops < 1024; {
+		adSize := 8
+		if adType == 1 {
+			adSize = 16
+		}
+		if len(ads) < adSize {
+			break
+		}
+		length := binary.LittleEndian.Uint32(ads)
+		lbn := binary.LittleEndian.Uint32(ads[4:])
+		p := part
+		if adType == 1 {
+			p = binary.LittleEndian.Uint16(ads[8:])
+		}
+		ads = ads[adSize:]
+		size, kind := int64(length&0x3FFFFFFF), length>>30
+		if size == 0 {
+			break
+		}
+		switch kind {
+		case 0:
+			at, err := r.address(p, lbn)
+			if err != nil {
+				return file, err
+			}
+			file.extents = append(file.extents, extent{offset: at, size: size})
+		case 1, 2: // Allocated and not written yet, or not allocated, zeros either way
+			file.extents = append(file.extents, extent{offset: -1, size: size})
+		case 3: // The descriptors go on in an allocation extent descriptor
+			at, err := r.address(p, lbn)
+			if err != nil {
+				return file, err
+			}
+			aed, err := readSectors(r.f, at, isoSector)
+			if err != nil {
+				return file, err
+			}
+			if udfTag(aed) != udfAllocExtent {
+				return file, errors.New("bad UDF allocation extent")
+			}
+			ads = aed[24:min(24+int(binary.LittleEndian.Uint32(aed[20:])), len(aed))]
+			hops++
+		}
+	}
+	return file, nil
+}
+
+// read reads all of a file into memory, for folders.
+func (r *udfReader) read(file udfFile) ([]byte, error) {
+	if file.size > isoMaxDir {
+		return nil, errors.New("the file system is broken")
+	}
+	f := &extentsFile{archive: r.f, extents: file.extents, size: file.size}
+	b := make([]byte, file.size)
+	if _, err := f.ReadAt(b, 0); err != nil && file.size > 0 {
+		return nil, err
+	}
+	return b, nil
+}
+
+// udfName decodes a file identifier, OSTA compressed Unicode: a byte that
+// says 8 or 16 bits per character, then the characters.
+func udfName(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8, 254:
+		r := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			r[i] = rune(c)
+		}
+		return string(r)
+	case 16, 255:
+		u := make([]uint16, (len(b)-1)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(b[1+2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+// udfTime decodes a UDF timestamp, zero if it's not set.
+func udfTime(b []byte) time.Time {
+	typeAndZone := binary.LittleEndian.Uint16(b)
+	year := int(int16(binary.LittleEndian.Uint16(b[2:])))
+	if year == 0 {
+		return time.Time{}
+	}
+	zone := time.UTC
+	if minutes := int(int16(typeAndZone<<4) >> 4); minutes != -2047 && typeAndZone>>12 == 1 {
+		zone = time.FixedZone("", minutes*60)
+	}
+	ns := (int(b[9])*10000 + int(b[10])*100 + int(b[11])) * 1000
+	return time.Date(year, time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), ns, zone)
+}
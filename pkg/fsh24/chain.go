@@ -0,0 +1,32 @@
+package fsh24
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrBrokenChain is returned when reading a chained manifest where a line
+// doesn't match the chain, it or a line before it was edited, removed or
+// moved after it was written.
+var ErrBrokenChain = errors.New("broken chain")
+
+// A chained manifest (Manifest.Chained) has an extra column on every line,
+// the link: a SHA-256 of the link of the line before and the rest of the line.
+// The first line links to the header. Changing any line changes its link and
+// so every link after it, so the only edit that doesn't show is adding lines
+// at the end, or taking them off the end. Keep a copy of the last link
+// somewhere else, or sign the file, to catch that too.
+
+// chainStart is the link the first line follows on from.
+func chainStart(header string) string {
+	sum := sha256.Sum256([]byte(header))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// chainLink is the link of line, the line as it would be without the link column.
+func chainLink(prev, line string) string {
+	sum := sha256.Sum256([]byte(prev + "\n" + line))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,154 @@
+package fsh24
+
+// Plain checksum files, what md5sum, sha1sum, sha256sum, sha512sum and b2sum
+// write, look just like the gnu format but have a hash of the whole file
+// instead of an FSH24. Those entries have Entry.Checksum set and are checked
+// by reading the whole file, no sampling. The tagged ones (sha256sum --tag,
+// BSD sha256, openssl dgst) say which hash it is on every line, so a file can
+// mix them. The untagged ones don't, there it's worked out from the name of
+// the file and the length of the hashes, see Manifest.DetectChecksums.
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Whole file hashes plain checksum files can have, see Entry.Checksum.
+const (
+	ChecksumMD5     = "md5"
+	ChecksumSHA1    = "sha1"
+	ChecksumSHA224  = "sha224"
+	ChecksumSHA256  = "sha256"
+	ChecksumSHA384  = "sha384"
+	ChecksumSHA512  = "sha512"
+	ChecksumBLAKE2b = "blake2b" // b2sum, any length it can do with -l
+)
+
+// checksumTypes are the checksums with their bsd tag, hex length and the
+// names checksum files of them usually have, eg. SHA256SUMS or file.sha256.
+var checksumTypes = []struct {
+	name   string
+	tag    string
+	length int
+	files  []string
+}{
+	{ChecksumMD5, "MD5", 32, []string{"md5"}},
+	{ChecksumSHA1, "SHA1", 40, []string{"sha1"}},
+	{ChecksumSHA224, "SHA224", 56, []string{"sha224"}},
+	{ChecksumSHA256, "SHA256", 64, []string{"sha256"}},
+	{ChecksumSHA384, "SHA384", 96, []string{"sha384"}},
+	{ChecksumSHA512, "SHA512", 128, []string{"sha512"}},
+	{ChecksumBLAKE2b, "BLAKE2b", 128, []string{"b2", "blake2", "blake2b"}},
+}
+
+// newChecksum returns the hash for checksum, size is the digest length in
+// bytes for BLAKE2b, the others only come in one.
+func newChecksum(checksum string, size int) (hash.Hash, error) {
+	switch checksum {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumSHA224:
+		return sha256.New224(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA384:
+		return sha512.New384(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	case ChecksumBLAKE2b:
+		return blake2b.New(size, nil)
+	}
+	return nil, fmt.Errorf("unsupported checksum %q", checksum)
+}
+
+// checksumTag is the bsd tag for a checksum of hexLen hex digits. b2sum
+// puts the length in the tag when it's not the full 512 bits, "BLAKE2b-256".
+func checksumTag(checksum string, hexLen int) string {
+	for _, c := range checksumTypes {
+		if c.name != checksum {
+			continue
+		}
+		if checksum == ChecksumBLAKE2b && hexLen != c.length {
+			return c.tag + "-" + strconv.Itoa(hexLen*4)
+		}
+		return c.tag
+	}
+	return bsdTag
+}
+
+// checksumFromTag is the checksum a bsd tag is for, "" if it's not one
+// or a hash of hexLen hex digits can't be one.
+func checksumFromTag(tag string, hexLen int) string {
+	if bits, ok := strings.CutPrefix(tag, "BLAKE2b-"); ok {
+		if n, err := strconv.Atoi(bits); err == nil && n%8 == 0 && n <= 512 && n == hexLen*4 {
+			return ChecksumBLAKE2b
+		}
+		return ""
+	}
+	for _, c := range checksumTypes {
+		if c.tag == tag && c.length == hexLen {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// checksumFromName is the checksum a file name says it has, like
+// MD5SUMS, sha256sums.txt, file.sha1 or file.b2. "" if it doesn't say.
+func checksumFromName(name string) string {
+	name = strings.ToLower(filepath.Base(name))
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	for _, c := range checksumTypes {
+		for _, f := range c.files {
+			if ext == f || ext == f+"sum" || ext == f+"sums" || strings.HasPrefix(name, f+"sum") {
+				return c.name
+			}
+		}
+	}
+	return ""
+}
+
+// IsChecksumFile reports whether name looks like a plain checksum file going
+// by its name, eg. SHA256SUMS or file.md5.
+func IsChecksumFile(name string) bool {
+	return checksumFromName(name) != ""
+}
+
+// DetectChecksums works out which whole file hash the entries of a gnu
+// manifest read from the file name have, if any. The hash length picks it,
+// the name only helps where two have the same length, a sha512sum and a
+// b2sum file look the same. A hash no checksum has the length of stays an
+// FSH24, and so does everything in a .fsh24 file, that's one of ours.
+// ReadManifestFile does this, call it yourself after ParseManifest.
+func (m *Manifest) DetectChecksums(name string) {
+	if m.Format != FormatGNU || strings.EqualFold(filepath.Ext(name), ".fsh24") {
+		return
+	}
+	named := checksumFromName(name)
+	for i, e := range m.Entries {
+		if e.Checksum != "" {
+			continue
+		}
+		if named == ChecksumBLAKE2b {
+			m.Entries[i].Checksum = named
+			continue
+		}
+		for _, c := range checksumTypes {
+			if c.length == len(e.Hash) {
+				m.Entries[i].Checksum = c.name
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fsh24
+
+// Coverage is how much of a file its FSH24 reads, and so how much room a
+// change has to hide in. A change is only noticed if it touches a sample,
+// or changes the size, which is hashed too. Damage that fits completely in
+// the stretches in between goes unnoticed.
+type Coverage struct {
+	Size    int64   // Of the file
+	Sampled int64   // Bytes read, all of them for full hashes
+	Gaps    []int64 // The stretches never read, in file order
+}
+
+// Coverage works out the Coverage of a file of fileSize, sampled the way h
+// samples, see ApplySettings, and Chunks and Offsets for replaying a
+// manifest entry.
+func (h *Hasher) Coverage(fileSize int64) Coverage {
+	spans, _ := h.samples(fileSize)
+	c := Coverage{Size: fileSize}
+	end := int64(0)
+	for _, sp := range spans {
+		if sp.off > end {
+			c.Gaps = append(c.Gaps, sp.off-end)
+		}
+		if sp.off+sp.n > end {
+			c.Sampled += sp.off + sp.n - max(sp.off, end)
+			end = sp.off + sp.n
+		}
+	}
+	if end < fileSize {
+		c.Gaps = append(c.Gaps, fileSize-end)
+	}
+	return c
+}
+
+// FullCoverage is the Coverage of a file of fileSize that's read to the
+// last byte, one with a SHA-256, CRC32 or whole file checksum.
+func FullCoverage(fileSize int64) Coverage {
+	return Coverage{Size: fileSize, Sampled: fileSize}
+}
+
+// EntryCoverage is the Coverage of the file of e when it's verified with
+// h, which has the settings of its manifest, see Manifest.ApplySettings.
+// Entries with a full hash are read to the last byte. Unknown sizes (-1)
+// have none.
+func (h *Hasher) EntryCoverage(e Entry) Coverage {
+	if e.Size < 0 {
+		return Coverage{}
+	}
+	if readsAll(h, e) {
+		return FullCoverage(e.Size)
+	}
+	entryHasher := *h
+	entryHasher.Chunks = e.Chunks
+	entryHasher.Offsets = e.Offsets
+	return entryHasher.Coverage(e.Size)
+}
+
+// readsAll reports whether verifying e with h reads every byte of the file,
+// it's a --full manifest or e has a whole file hash of any kind.
+func readsAll(h *Hasher, e Entry) bool {
+	return h.Full || e.Hash == "" || e.Checksum != "" || e.SHA256 != "" || e.CRC32 != ""
+}
+
+// Add counts o in with c, for the coverage of a set of files.
+func (c *Coverage) Add(o Coverage) {
+	c.Size += o.Size
+	c.Sampled += o.Sampled
+	c.Gaps = append(c.Gaps, o.Gaps...)
+}
+
+// Percent is how much of the file is read, 0 to 100. 100 for empty files.
+func (c Coverage) Percent() float64 {
+	if c.Size <= 0 {
+		return 100
+	}
+	return float64(c.Sampled) / float64(c.Size) * 100
+}
+
+// LargestGap is the longest stretch never read. Damage longer than it is
+// always caught, anything up to it can be missed.
+func (c Coverage) LargestGap() int64 {
+	largest := int64(0)
+	for _, g := range c.Gaps {
+		largest = max(largest, g)
+	}
+	return largest
+}
+
+// MissChance is the chance, 0 to 1, that n damaged bytes in a row,
+// anywhere in the file with the same odds, all land in a gap and aren't
+// noticed. For a set of files it leaves out damage across two of them.
+func (c Coverage) MissChance(n int64) float64 {
+	if n <= 0 || n > c.Size {
+		return 0
+	}
+	fits := int64(0) // Places the damage can start and still be in one gap
+	for _, g := range c.Gaps {
+		fits += max(0, g-n+1)
+	}
+	return float64(fits) / float64(c.Size-n+1)
+}
@@ -0,0 +1,42 @@
+//go:build darwin
+
+package fsh24
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The ioctls from <sys/disk.h> that give the size of a disk, x/sys doesn't
+// have them.
+const (
+	dkiocGetBlockSize  = 0x40046418
+	dkiocGetBlockCount = 0x40086419
+)
+
+// isDevicePath is for Windows, devices here are found by Stat.
+func isDevicePath(path string) bool {
+	return false
+}
+
+// deviceSize asks the kernel how big the disk f is, its block count times
+// the block size. Works on /dev/diskN and the faster /dev/rdiskN.
+func deviceSize(f *os.File) (int64, error) {
+	var blockSize uint32
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), dkiocGetBlockSize, uintptr(unsafe.Pointer(&blockSize))); errno != 0 {
+		return 0, errno
+	}
+	var blocks uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), dkiocGetBlockCount, uintptr(unsafe.Pointer(&blocks))); errno != 0 {
+		return 0, errno
+	}
+	return int64(blocks) * int64(blockSize), nil
+}
+
+// wrapDevice makes reads of the raw /dev/rdiskN line up to its blocks, it
+// won't read anything else. The buffered /dev/diskN doesn't mind.
+func wrapDevice(f *localFile) File {
+	return &directFile{localFile: f}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package fsh24
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// isDevicePath is for Windows, devices here are found by Stat.
+func isDevicePath(path string) bool {
+	return false
+}
+
+// deviceSize asks the kernel how big the block device f is, BLKGETSIZE64.
+// Char devices like /dev/zero don't have a size, that's an error.
+func deviceSize(f *os.File) (int64, error) {
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), unix.BLKGETSIZE64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, errno
+	}
+	return int64(size), nil
+}
+
+// wrapDevice is for Windows, devices read like any file here.
+func wrapDevice(f *localFile) File {
+	return f
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !windows
+
+package fsh24
+
+import (
+	"io"
+	"os"
+)
+
+// isDevicePath is for Windows, devices here are found by Stat.
+func isDevicePath(path string) bool {
+	return false
+}
+
+// deviceSize goes by where the end of f is, which is the size of a disk on
+// the BSDs.
+func deviceSize(f *os.File) (int64, error) {
+	return f.Seek(0, io.SeekEnd)
+}
+
+// wrapDevice makes reads line up to the sectors, BSD disks are raw devices
+// that won't read anything else.
+func wrapDevice(f *localFile) File {
+	return &directFile{localFile: f}
+}
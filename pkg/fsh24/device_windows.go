@@ -0,0 +1,40 @@
+//go:build windows
+
+package fsh24
+
+import (
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ioctlDiskGetLengthInfo is IOCTL_DISK_GET_LENGTH_INFO, the size of a
+// disk or volume in bytes.
+const ioctlDiskGetLengthInfo = 0x7405C
+
+// isDevicePath reports whether path is a disk or volume, \\.\PhysicalDrive1
+// or \\.\E:, which can be opened but not Stat'd. Pipes aren't.
+func isDevicePath(path string) bool {
+	path = strings.ReplaceAll(path, "/", `\`)
+	return strings.HasPrefix(path, `\\.\`) && !strings.HasPrefix(strings.ToLower(path), `\\.\pipe\`)
+}
+
+// deviceSize asks Windows how big the disk or volume f is.
+func deviceSize(f *os.File) (int64, error) {
+	var size int64
+	var returned uint32
+	err := windows.DeviceIoControl(windows.Handle(f.Fd()), ioctlDiskGetLengthInfo, nil, 0,
+		(*byte)(unsafe.Pointer(&size)), uint32(unsafe.Sizeof(size)), &returned, nil)
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// wrapDevice makes reads line up to the sectors, Windows won't read a disk
+// any other way.
+func wrapDevice(f *localFile) File {
+	return &directFile{localFile: f}
+}
@@ -0,0 +1,139 @@
+package fsh24
+
+import "hash"
+
+// Digest is a hash.Hash that computes an FSH24 over data written to it in order.
+// Because the sample positions depend on the file size, the size has to be
+// known up front. Bytes outside the sampled regions are simply skipped.
+type Digest struct {
+	spans  []span
+	next   int // index of the next span to hash
+	chunks int
+	size   int64
+	pos    int64
+	hasher hash.Hash
+	algo   string
+	summed bool // set once Sum had to finish the live hasher
+
+	// kept are the bytes from keepFrom on, when the span after the current
+	// one starts before it ends. They get hashed again for that one.
+	// keepFrom is -1 when nothing needs keeping.
+	kept     []byte
+	keepFrom int64
+}
+
+// New returns a Digest for a stream of exactly size bytes.
+func (h *Hasher) New(size int64) (*Digest, error) {
+	hasher, err := newDigest(h.Algorithm, h.DigestBytes, h.Key)
+	if err != nil {
+		return nil, err
+	}
+	spans, chunks := h.samples(size)
+	d := &Digest{spans: spans, chunks: chunks, size: size, hasher: hasher, algo: h.Algorithm}
+	d.keepOverlap()
+	return d, nil
+}
+
+// Write feeds the next len(p) bytes of the stream. It never returns an error.
+// Bytes in two samples, where the last one reaches back into the one before
+// it, are hashed for both, same as reading them from a file.
+func (d *Digest) Write(p []byte) (int, error) {
+	if d.summed {
+		panic("fsh24: Write after Sum on a keyed BLAKE2b Digest")
+	}
+	written := len(p)
+	for len(p) > 0 && d.next < len(d.spans) {
+		sp := d.spans[d.next]
+		if d.pos < sp.off { // Skip up to the next sample
+			skip := min(int64(len(p)), sp.off-d.pos)
+			p = p[skip:]
+			d.pos += skip
+			continue
+		}
+		take := min(int64(len(p)), sp.off+sp.n-d.pos)
+		d.hasher.Write(p[:take])
+		if d.keepFrom >= 0 && d.pos+take > d.keepFrom {
+			d.kept = append(d.kept, p[max(0, d.keepFrom-d.pos):take]...)
+		}
+		p = p[take:]
+		d.pos += take
+		if d.pos == sp.off+sp.n {
+			d.nextSpan()
+		}
+	}
+	d.pos += int64(len(p))
+	return written, nil
+}
+
+// nextSpan moves on from the current span, which is done. Spans that start
+// in bytes already written get those from kept, and are done too if they
+// end there.
+func (d *Digest) nextSpan() {
+	for d.next++; d.next < len(d.spans); d.next++ {
+		sp := d.spans[d.next]
+		if sp.off >= d.pos {
+			break
+		}
+		end := min(sp.off+sp.n, d.pos)
+		d.hasher.Write(d.kept[sp.off-d.keepFrom : end-d.keepFrom])
+		if end < sp.off+sp.n {
+			break // The rest comes with the next Write
+		}
+	}
+	d.keepOverlap()
+}
+
+// keepOverlap works out what to keep of the current span, the bytes from
+// where the span after it starts if that's before the current one ends.
+func (d *Digest) keepOverlap() {
+	from := int64(-1)
+	if d.next+1 < len(d.spans) {
+		sp, after := d.spans[d.next], d.spans[d.next+1]
+		if after.off < sp.off+sp.n {
+			from = after.off
+		}
+	}
+	if from >= 0 && from < d.pos {
+		d.kept = d.kept[from-d.keepFrom:] // Written already, and kept for the span before
+	} else {
+		d.kept = d.kept[:0]
+	}
+	d.keepFrom = from
+}
+
+// Sum appends the FSH24 of the data written so far to b.
+// Like every hash.Hash it does not change the state, so more data can still be written.
+// The exception is keyed BLAKE2b, whose state can't be copied: there Sum
+// finishes the stream and only Sum or Reset may be called afterwards.
+func (d *Digest) Sum(b []byte) []byte {
+	if d.summed {
+		return d.hasher.Sum(b)
+	}
+	clone, err := cloneDigest(d.algo, d.hasher)
+	if err != nil {
+		d.hasher.Write(sizeTrailer(d.size))
+		d.summed = true
+		return d.hasher.Sum(b)
+	}
+	clone.Write(sizeTrailer(d.size))
+	return clone.Sum(b)
+}
+
+// Reset starts the stream over from byte 0.
+func (d *Digest) Reset() {
+	d.hasher.Reset()
+	d.summed = false
+	d.next = 0
+	d.pos = 0
+	d.kept = d.kept[:0]
+	d.keepOverlap()
+}
+
+// Size returns the number of bytes Sum will append.
+func (d *Digest) Size() int { return d.hasher.Size() }
+
+// BlockSize returns the hash's underlying block size.
+func (d *Digest) BlockSize() int { return d.hasher.BlockSize() }
+
+// Chunks returns how many chunks are sampled for this stream.
+func (d *Digest) Chunks() int { return d.chunks }
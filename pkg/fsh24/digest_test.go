@@ -0,0 +1,69 @@
+package fsh24
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Between 8 and 32 MB the last sample overlaps the one before it, the
+// stream has to hash those bytes twice like a read from disk does.
+func TestSumReaderMatchesSum(t *testing.T) {
+	data := make([]byte, 33<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	dir := t.TempDir()
+	h := NewHasher()
+	ctx := context.Background()
+
+	for mb := int64(8); mb <= 32; mb++ {
+		for _, size := range []int64{mb << 20, mb<<20 + 12345} {
+			file := filepath.Join(dir, "data")
+			if err := os.WriteFile(file, data[:size], 0644); err != nil {
+				t.Fatal(err)
+			}
+			want, wantChunks, err := h.Sum(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, gotChunks, err := h.SumReader(ctx, bytes.NewReader(data[:size]), size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want || gotChunks != wantChunks {
+				t.Errorf("size %d: SumReader %s (%d chunks), Sum %s (%d chunks)", size, got, gotChunks, want, wantChunks)
+			}
+		}
+	}
+}
+
+// Writes that end in the middle of the overlap, or of a sample, give the
+// same hash as one big write.
+func TestDigestWriteSizes(t *testing.T) {
+	size := int64(20 << 20)
+	data := make([]byte, size)
+	rand.New(rand.NewSource(2)).Read(data)
+	h := NewHasher()
+	want, _, err := h.SumReaderAt(context.Background(), bytes.NewReader(data), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []int{1 << 10, 65521, 3 << 20, 7 << 20} {
+		d, err := h.New(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for p := data; len(p) > 0; {
+			n := min(step, len(p))
+			d.Write(p[:n])
+			p = p[n:]
+		}
+		if got := hex.EncodeToString(d.Sum(nil)); got != want {
+			t.Errorf("writes of %d: %s, want %s", step, got, want)
+		}
+	}
+}
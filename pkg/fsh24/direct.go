@@ -0,0 +1,69 @@
+package fsh24
+
+import (
+	"io"
+	"unsafe"
+)
+
+// directAlign is what direct reads get lined up to, offsets, lengths and the
+// buffer in memory. 4096 covers disks with 512 byte and 4K sectors.
+const directAlign = 4096
+
+// directFile is a local file opened to read around the OS page cache, see
+// Hasher.Direct. Direct reads have to start and end on directAlign, so every
+// read is widened to that and the part asked for copied out. Not safe for
+// reads at the same time, hashing doesn't do that.
+type directFile struct {
+	*localFile
+	buf []byte
+}
+
+// openDirect opens f again without the page cache and closes the cached
+// handle. If that doesn't work, some filesystems like tmpfs can't do it,
+// it's an error and f is left as it is.
+func openDirect(f *localFile) (*directFile, error) {
+	uncached, err := openUncached(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	f.File.Close()
+	return &directFile{localFile: &localFile{File: uncached, size: f.size, modTime: f.modTime}}, nil
+}
+
+func (f *directFile) ReadAt(p []byte, off int64) (int, error) {
+	size := f.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	start := off &^ (directAlign - 1)
+	end := (min(off+int64(len(p)), size) + directAlign - 1) &^ (directAlign - 1)
+	buf := f.buffer(int(end - start))
+
+	n, err := f.File.ReadAt(buf, start)
+	if err == io.EOF || err != nil && (start+int64(n))%directAlign != 0 {
+		// A short read at the end of the file, the read after it wasn't lined up any more
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	avail := int64(n) - (off - start)
+	if avail <= 0 {
+		return 0, io.EOF
+	}
+	copied := copy(p, buf[off-start:off-start+avail])
+	if copied < len(p) {
+		return copied, io.EOF
+	}
+	return copied, nil
+}
+
+// buffer returns n bytes starting on directAlign in memory, reused between reads.
+func (f *directFile) buffer(n int) []byte {
+	if cap(f.buf) < n {
+		raw := make([]byte, n+directAlign)
+		pad := (directAlign - int(uintptr(unsafe.Pointer(&raw[0]))%directAlign)) % directAlign
+		f.buf = raw[pad : pad+n]
+	}
+	return f.buf[:n]
+}
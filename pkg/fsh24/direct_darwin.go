@@ -0,0 +1,22 @@
+//go:build darwin
+
+package fsh24
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openUncached opens path for reading with F_NOCACHE, macOS has no O_DIRECT.
+func openUncached(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_NOCACHE, 1); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "fcntl", Path: path, Err: err}
+	}
+	return f, nil
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package fsh24
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openUncached opens path for reading with O_DIRECT.
+func openUncached(path string) (*os.File, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECT|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
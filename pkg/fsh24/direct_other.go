@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package fsh24
+
+import (
+	"errors"
+	"os"
+)
+
+// openUncached can't do it here, files are always read through the cache.
+func openUncached(path string) (*os.File, error) {
+	return nil, errors.New("direct reads are not supported on this system")
+}
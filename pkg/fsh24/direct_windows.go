@@ -0,0 +1,24 @@
+//go:build windows
+
+package fsh24
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// openUncached opens path for reading with FILE_FLAG_NO_BUFFERING.
+func openUncached(path string) (*os.File, error) {
+	// CreateFile doesn't get the long path help the os package gives
+	name, err := windows.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_NO_BUFFERING, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
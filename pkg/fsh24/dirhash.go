@@ -0,0 +1,97 @@
+package fsh24
+
+import (
+	"encoding/hex"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MetaDirHash is the FSH24-2 header key the folder hash of the whole
+// manifest is stored under, see DirHashes.
+const MetaDirHash = "dirhash"
+
+// DirHash is the hash of everything in a folder, see DirHashes.
+type DirHash struct {
+	Path  string `json:"path" yaml:"path"` // Like the entry paths, with / between folders. "." for the top of relative ones
+	FSH24 string `json:"fsh24" yaml:"fsh24"`
+	Files int    `json:"files" yaml:"files"` // Files under it, sub folders included
+}
+
+// DirHashes works out a hash for every folder of the manifest, from the one
+// all the entries are in down. It's the BLAKE2b-192 of a "HASH|path" line
+// for every file under the folder, sub folders included, with the path
+// relative to the folder and the lines sorted by it. Two copies of a tree
+// hashed with the same settings get the same hash wherever they are, so
+// they can be compared with one value. Entries without a hash are left out.
+// The top folder comes first, the rest are sorted by path.
+func DirHashes(m *Manifest) []DirHash {
+	type file struct{ path, hash string }
+	var files []file
+	for _, e := range m.Entries {
+		if e.Hash != "" {
+			files = append(files, file{filepath.ToSlash(e.Path), strings.ToUpper(e.Hash)})
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	// The folders every file is in, up to the one they all share. A mix of
+	// relative and absolute paths share none, each goes up as far as it can
+	top := path.Dir(files[0].path)
+	for _, f := range files[1:] {
+		for !inDir(f.path, top) && !isTopDir(top) {
+			top = path.Dir(top)
+		}
+	}
+	byDir := map[string][]file{}
+	for _, f := range files {
+		for dir := path.Dir(f.path); ; dir = path.Dir(dir) {
+			rel := strings.TrimPrefix(f.path[len(dir):], "/")
+			if dir == "." {
+				rel = f.path
+			}
+			byDir[dir] = append(byDir[dir], file{rel, f.hash})
+			if dir == top || isTopDir(dir) {
+				break
+			}
+		}
+	}
+
+	dirs := make([]DirHash, 0, len(byDir))
+	for dir, files := range byDir {
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+		h, _ := blake2b.New(DefaultDigestBytes, nil)
+		for _, f := range files {
+			h.Write([]byte(f.hash + "|" + f.path + "\n"))
+		}
+		dirs = append(dirs, DirHash{Path: dir, FSH24: strings.ToUpper(hex.EncodeToString(h.Sum(nil))), Files: len(files)})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if (dirs[i].Path == top) != (dirs[j].Path == top) {
+			return dirs[i].Path == top
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	return dirs
+}
+
+// isTopDir reports whether there's no going further up from dir.
+func isTopDir(dir string) bool {
+	return dir == "." || dir == "/" || path.Dir(dir) == dir
+}
+
+// inDir reports whether the slash separated p is somewhere under dir.
+func inDir(p, dir string) bool {
+	switch dir {
+	case ".":
+		return !path.IsAbs(p)
+	case "/":
+		return path.IsAbs(p)
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
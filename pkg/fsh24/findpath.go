@@ -0,0 +1,47 @@
+package fsh24
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FoldPath returns path in lower case, for comparing paths that only differ
+// in case, like ones from a hash file made on Windows checked on Linux.
+func FoldPath(path string) string {
+	return strings.ToLower(path)
+}
+
+// findPath finds the file at path when the names on disk are written
+// differently than in path, another Unicode form or another case, going
+// through it a folder at a time. Names are compared by what key makes of
+// them. It returns the path as it is on disk, false if there's no such file
+// or more than one name could be it, Photo.jpg and photo.jpg on Linux.
+func findPath(path string, key func(string) string) (string, bool) {
+	if _, err := os.Lstat(path); err == nil {
+		return path, true
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if dir == path { // The root, or "." and nothing left to look through
+		return "", false
+	}
+	dir, ok := findPath(dir, key)
+	if !ok {
+		return "", false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	want := key(name)
+	found := ""
+	for _, entry := range entries {
+		if key(entry.Name()) == want {
+			if found != "" {
+				return "", false
+			}
+			found = filepath.Join(dir, entry.Name())
+		}
+	}
+	return found, found != ""
+}
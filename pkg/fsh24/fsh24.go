@@ -0,0 +1,165 @@
+// FSH24 - Fast Sample Hash 24-byte
+// Super fast integrity hash using strategic 4MB sampling
+
+// Package fsh24 is the importable core of the fsh24 tool.
+// It holds the sampled hashing (Hasher), the .fsh24 file format (Manifest)
+// and manifest verification (Verifier) so other Go programs can embed FSH24
+// without shelling out to the CLI.
+package fsh24
+
+import "time"
+
+const (
+	// SampleSize is the default size of each sampled chunk.
+	SampleSize = 4 * 1024 * 1024 // 4MB
+
+	// Limits for a custom Hasher.SampleSize.
+	MinSampleSize = 64 * 1024         // 64KB
+	MaxSampleSize = 256 * 1024 * 1024 // 256MB
+
+	// DefaultTargetCoverage is the fraction of a file we aim to sample (1%).
+	DefaultTargetCoverage = 0.01
+
+	// Magic is the header line of a version 1 .fsh24 file.
+	Magic = "FSH24-1"
+
+	// MagicSettings starts FSH24-1 files made with anything but the default
+	// settings, the settings follow it. Tools from before there were any
+	// take every line starting with FSH24 for a plain FSH24-1 file, they'd
+	// ignore the settings and fail every file. This way they refuse it.
+	MagicSettings = "FSHS-1"
+)
+
+// The json names of the result types below are the fsh24 -j output, which
+// has a published JSON Schema (schema/ in the repo). Add fields, don't
+// rename or remove them.
+
+// Result struct for a single file's hash information
+type FileHashResult struct {
+	Filename        string    `json:"filename" yaml:"filename"`
+	Filepath        string    `json:"filepath" yaml:"filepath"`
+	FileSize        int64     `json:"file_size" yaml:"file_size"`
+	FSH24           string    `json:"fsh24" yaml:"fsh24"`
+	SHA256          string    `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	CRC32           string    `json:"crc32,omitempty" yaml:"crc32,omitempty"`
+	Chunks          int       `json:"chunks" yaml:"chunks"`
+	CoveragePercent float64   `json:"coverage_percent" yaml:"coverage_percent"`
+	ProcessingTime  float64   `json:"processing_time" yaml:"processing_time"`
+	ModTime         time.Time `json:"mtime,omitzero" yaml:"mtime,omitempty"`
+	Metadata        `yaml:",inline"`
+
+	// Offsets are where the middle samples went, for files hashed with
+	// Hasher.Adaptive that it moved them in. Empty is evenly spaced.
+	Offsets []int64 `json:"offsets,omitempty" yaml:"offsets,omitempty"`
+}
+
+// VerificationResult struct for a single file's verification outcome
+type FileVerificationResult struct {
+	Filepath       string  `json:"filepath" yaml:"filepath"`
+	Filename       string  `json:"filename" yaml:"filename"`
+	ExpectedHash   string  `json:"expected_hash" yaml:"expected_hash"`
+	ExpectedSize   int64   `json:"expected_size" yaml:"expected_size"`
+	ActualSize     int64   `json:"actual_size,omitempty" yaml:"actual_size,omitempty"`
+	ActualHash     string  `json:"actual_hash,omitempty" yaml:"actual_hash,omitempty"`
+	ExpectedSHA256 string  `json:"expected_sha256,omitempty" yaml:"expected_sha256,omitempty"`
+	ActualSHA256   string  `json:"actual_sha256,omitempty" yaml:"actual_sha256,omitempty"`
+	ExpectedCRC32  string  `json:"expected_crc32,omitempty" yaml:"expected_crc32,omitempty"`
+	ActualCRC32    string  `json:"actual_crc32,omitempty" yaml:"actual_crc32,omitempty"`
+	Status         string  `json:"status" yaml:"status"`
+	ProcessingTime float64 `json:"processing_time,omitempty" yaml:"processing_time,omitempty"`
+	HashedSize     int64   `json:"hashed_size,omitempty" yaml:"hashed_size,omitempty"`
+
+	// MetadataChanges lists what's different about a verified file besides
+	// its content, a new modification time or the changes Metadata.Changes
+	// finds for manifests with metadata.
+	MetadataChanges []string `json:"metadata_changes,omitempty" yaml:"metadata_changes,omitempty"`
+
+	// ActualModTime is the file's modification time now, zero if unknown.
+	ActualModTime time.Time `json:"actual_mtime,omitzero" yaml:"actual_mtime,omitempty"`
+
+	// Change is what happened to the file since it was hashed, one of the
+	// Change* values. Empty when the manifest has no modification times.
+	Change string `json:"change,omitempty" yaml:"change,omitempty"`
+
+	// Depth is how much of a verified file was checked, DepthSampled or
+	// DepthFull. Empty for files that weren't verified.
+	Depth string `json:"depth,omitempty" yaml:"depth,omitempty"`
+}
+
+// VerificationSummary struct for overall verification statistics
+type VerificationSummary struct {
+	Verified              int     `json:"verified" yaml:"verified"`
+	Failed                int     `json:"failed" yaml:"failed"`
+	Total                 int     `json:"total" yaml:"total"`
+	Success               bool    `json:"success" yaml:"success"`
+	TotalTime             float64 `json:"total_time" yaml:"total_time"`
+	AverageTimePerFile    float64 `json:"average_time_per_file" yaml:"average_time_per_file"`
+	TotalSize             int64   `json:"total_size" yaml:"total_size"`
+	TotalHashedSize       int64   `json:"total_hashed_size" yaml:"total_hashed_size"`
+	TotalHashedPercentage float64 `json:"total_hashed_percentage" yaml:"total_hashed_percentage"`
+	MetadataChanged       int     `json:"metadata_changed,omitempty" yaml:"metadata_changed,omitempty"`
+	Modified              int     `json:"modified,omitempty" yaml:"modified,omitempty"`
+	Corrupted             int     `json:"corrupted,omitempty" yaml:"corrupted,omitempty"`
+	Locked                int     `json:"locked,omitempty" yaml:"locked,omitempty"`
+	VerifiedFull          int     `json:"verified_full,omitempty" yaml:"verified_full,omitempty"` // Of Verified, the ones checked to the last byte
+}
+
+// TotalHashSummary for the overall hashing process
+type TotalHashSummary struct {
+	Magic               string           `json:"magic" yaml:"magic"`
+	Algorithm           string           `json:"algorithm" yaml:"algorithm"`
+	SampleSize          int              `json:"sample_size" yaml:"sample_size"`
+	Full                bool             `json:"full,omitempty" yaml:"full,omitempty"`
+	Seed                string           `json:"seed,omitempty" yaml:"seed,omitempty"` // Hasher.Seed in hex, see FormatSeed
+	Keyed               bool             `json:"keyed,omitempty" yaml:"keyed,omitempty"`
+	TotalFiles          int              `json:"total_files" yaml:"total_files"`
+	TotalProcessingTime float64          `json:"total_processing_time" yaml:"total_processing_time"`
+	AverageTimePerFile  float64          `json:"average_time_per_file" yaml:"average_time_per_file"`
+	Files               []FileHashResult `json:"files" yaml:"files"`
+
+	// Directories are the folder hashes of the files, top folder first,
+	// when asked for. See DirHashes.
+	Directories []DirHash `json:"directories,omitempty" yaml:"directories,omitempty"`
+}
+
+// Verification statuses reported in FileVerificationResult.Status
+const (
+	StatusVerified             = "verified"
+	StatusMissing              = "missing"
+	StatusSizeMismatch         = "size_mismatch"
+	StatusHashMismatch         = "hash_mismatch"
+	StatusSHA256Mismatch       = "sha256_mismatch"
+	StatusCRC32Mismatch        = "crc32_mismatch"
+	StatusHashError            = "hash_error"
+	StatusLocked               = "locked" // Skipped, another program kept it locked through every retry
+	StatusInvalidLineFormat    = "invalid_line_format"
+	StatusInvalidChunksValue   = "invalid_chunks_value"
+	StatusInvalidFileSizeValue = "invalid_file_size_value"
+)
+
+// What happened to a file since it was hashed, FileVerificationResult.Change.
+// It takes the modification time to tell, so only manifests that record it
+// (FSH24-2 files, a --db) have them.
+const (
+	ChangeUntouched = "untouched" // Same content, same modification time
+	ChangeTouched   = "touched"   // Same content, new modification time, eg. copied without keeping times
+	ChangeModified  = "modified"  // New content and a new modification time, someone edited it
+	ChangeCorrupted = "corrupted" // New content but the same modification time, nothing should do that
+)
+
+// How much of a file was checked, FileVerificationResult.Depth.
+const (
+	DepthSampled = "sampled" // Only the samples the FSH24 is made of
+	DepthFull    = "full"    // Every byte, against a SHA-256, CRC32 or whole file hash, or a --full FSH24
+)
+
+// FileError ties an error to the file it happened on, so callers can
+// report "Skipping file X" style warnings.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string { return e.Path + ": " + e.Err.Error() }
+
+func (e *FileError) Unwrap() error { return e.Err }
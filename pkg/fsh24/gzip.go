@@ -0,0 +1,56 @@
+package fsh24
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GzipExt ends the name of a gzipped hash file, checksums.fsh24.gz. A hash
+// file of millions of lines is mostly paths and hex, it gzips to a fifth.
+const GzipExt = ".gz"
+
+// maxGunzipped is the most a gzipped hash file is unpacked to, 1GB is
+// millions of lines. A few KB of gzip can unpack to more than fits in
+// memory, and hash files come from stdin, URLs and serve's POST /verify.
+var maxGunzipped int64 = 1 << 30
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// IsGzipName reports whether filename ends in .gz.
+func IsGzipName(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), GzipExt)
+}
+
+// TrimGzipExt returns filename without its .gz, the name of what's in it.
+func TrimGzipExt(filename string) string {
+	if IsGzipName(filename) {
+		return filename[:len(filename)-len(GzipExt)]
+	}
+	return filename
+}
+
+// gunzip decompresses content if it's gzipped, going by the magic rather
+// than the name so hash files from stdin or a URL work too. It returns
+// content as it is, and false, if it isn't.
+func gunzip(content []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(content, gzipMagic) {
+		return content, false, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, false, fmt.Errorf("broken gzipped hash file: %w", err)
+	}
+	defer zr.Close()
+	plain, err := io.ReadAll(io.LimitReader(zr, maxGunzipped+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("broken gzipped hash file: %w", err)
+	}
+	if int64(len(plain)) > maxGunzipped {
+		return nil, false, fmt.Errorf("gzipped hash file unpacks to more than %d MB, not reading it", maxGunzipped>>20)
+	}
+	return plain, true, nil
+}
@@ -0,0 +1,35 @@
+package fsh24
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+// A gzipped hash file that unpacks to more than maxGunzipped is refused,
+// not read into memory.
+func TestGunzipLimit(t *testing.T) {
+	defer func(limit int64) { maxGunzipped = limit }(maxGunzipped)
+	maxGunzipped = 1 << 20
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(make([]byte, maxGunzipped+1))
+	zw.Close()
+	if _, err := ParseManifest(&buf); err == nil || !strings.Contains(err.Error(), "unpacks to more than") {
+		t.Fatalf("got %v, want it refused", err)
+	}
+
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	zw.Write([]byte("FSH24-1\n" + strings.Repeat("0", 48) + "|1|10|file.iso\n"))
+	zw.Close()
+	m, err := ParseManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Gzip || len(m.Entries) != 1 {
+		t.Fatalf("gzip %v, %d entries, want true and 1", m.Gzip, len(m.Entries))
+	}
+}
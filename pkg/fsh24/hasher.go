@@ -0,0 +1,691 @@
+package fsh24
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hasher calculates sampled FSH24 hashes.
+// The zero value is not usable, use NewHasher.
+type Hasher struct {
+	// TargetCoverage is the fraction of the file to sample (0.01 = 1%).
+	TargetCoverage float64
+
+	// SampleSize is the size of each sampled chunk, 0 means the default 4MB.
+	// Smaller helps on slow network shares, bigger reads more of each file.
+	SampleSize int
+
+	// Algorithm is the hash used over the samples, one of Algorithms.
+	Algorithm string
+
+	// DigestBytes is the length of the hash in bytes, 0 means DefaultDigestBytes.
+	DigestBytes int
+
+	// Key turns the hash into a keyed hash (MAC), so only holders of the key
+	// can produce or check valid hashes. Empty means unkeyed.
+	Key []byte
+
+	// Chunks fixes the total number of samples instead of deriving it from
+	// TargetCoverage. Verification sets it from the manifest so the sample
+	// offsets are replayed from what was recorded, not from today's heuristics.
+	Chunks int
+
+	// Full hashes every byte of the file instead of sampling it.
+	// Same output, just no longer fast.
+	Full bool
+
+	// Seed, if not 0, puts the middle samples at places picked from it
+	// instead of evenly spaced, see seededLayout. Anyone who knows where
+	// the samples go can damage a file between them and it still verifies,
+	// with a random seed they can't know before the file is hashed. It's
+	// recorded in the manifest, so verifying takes the same samples.
+	// Files placed by Adaptive don't use it.
+	Seed uint64
+
+	// Adaptive makes HashFile have a quick look over each file first and
+	// put the middle samples where the data is, rather than in long runs
+	// of zeros or other filler, see adaptiveOffsets. Where they went is in
+	// FileHashResult.Offsets, it has to be stored with the hash. Files in
+	// compressed archives can't be looked over and stay evenly spaced.
+	Adaptive bool
+
+	// Offsets fixes where the middle samples go, like Chunks fixes how many.
+	// Verification sets it from Entry.Offsets. Nil is evenly spaced, or
+	// placed by Seed.
+	Offsets []int64
+
+	// SHA256 makes HashFile also read the whole file for a full SHA-256.
+	// Slow, but gives archives a strong digest next to the quick one.
+	SHA256 bool
+
+	// CRC32 makes HashFile also read the whole file for a CRC32, for .sfv files.
+	CRC32 bool
+
+	// Jobs is how many files HashFiles and Verifier.Verify work on at once,
+	// 0 means DefaultJobs. Keep it low on spinning disks, seeking between
+	// files costs more than the hashing.
+	Jobs int
+
+	// NetMode is how files are read. Network shares and URLs are slow to
+	// seek around in, net mode reads samples close together in one go and
+	// can retry failed reads. The zero value NetAuto picks it by file.
+	NetMode NetMode
+
+	// Retries is how many times a read that failed is tried again in net
+	// mode, waiting a bit longer each time. 0 means none.
+	Retries int
+
+	// LockRetries is how many times opening a file another program has
+	// locked is tried again, waiting LockDelay, then twice that and so on.
+	// Files still locked after that fail with ErrLocked. 0 means no retries.
+	LockRetries int
+
+	// LockDelay is the wait before the first retry of a locked file,
+	// 0 means DefaultLockDelay.
+	LockDelay time.Duration
+
+	// Mmap maps local files into memory instead of reading them, the samples
+	// are hashed straight from the page cache without a copy into a buffer.
+	// Quicker on fast SSDs. Files that can't be mapped (empty, bigger than
+	// the address space, URLs, or read in net mode) are read as usual.
+	Mmap bool
+
+	// IOUring reads the samples of local files through io_uring, a batch
+	// of them queued up at once instead of one read after the other. SSDs
+	// that can do lots of reads at the same time get through them quicker.
+	// Linux 5.6 and newer only, elsewhere files are read as usual.
+	IOUring bool
+
+	// Direct reads local files around the OS page cache (O_DIRECT, or
+	// FILE_FLAG_NO_BUFFERING on Windows), so going through a huge archive
+	// doesn't push out what everything else on the machine has cached.
+	// Filesystems that can't do it are read as usual.
+	Direct bool
+
+	// Metadata makes HashFile also record the mode, owner and xattrs of
+	// local files, see Metadata. Verifying reports changes to them apart
+	// from changes to the content.
+	Metadata bool
+
+	// OnResult, if set, is called by HashFiles as soon as each file is done,
+	// in the order they finish. Calls never overlap.
+	OnResult func(r FileHashResult)
+}
+
+// NewHasher returns a BLAKE2b Hasher using the default 1% target coverage.
+func NewHasher() *Hasher {
+	return &Hasher{TargetCoverage: DefaultTargetCoverage, Algorithm: AlgoBLAKE2b}
+}
+
+// CalculateOptimalChunks determines the number of middle chunks.
+func CalculateOptimalChunks(fileSize int64, sampleSize int, targetCoverage float64) int {
+	fileSizeMB := float64(fileSize) / (1024 * 1024)
+
+	if fileSizeMB < 100 {
+		return 2
+	}
+
+	// Calculate total chunks needed to achieve at least target coverage
+	targetTotalChunksFloat := (targetCoverage * float64(fileSize)) / float64(sampleSize)
+	targetTotalChunks := int(math.Ceil(targetTotalChunksFloat))
+
+	// Ensure at least 4 total chunks
+	targetTotalChunks = max(4, targetTotalChunks)
+
+	middleChunks := targetTotalChunks - 2
+	middleChunks = max(2, middleChunks) // Ensure middle chunks is at least 2
+
+	return middleChunks
+}
+
+// ValidateSampleSize checks a custom sample size. 0 means the default.
+func ValidateSampleSize(size int) error {
+	if size == 0 {
+		return nil
+	}
+	if size < MinSampleSize || size > MaxSampleSize {
+		return fmt.Errorf("sample size must be between %d and %d bytes, got %d", MinSampleSize, MaxSampleSize, size)
+	}
+	return nil
+}
+
+// NewSeed picks a random Hasher.Seed.
+func NewSeed() uint64 {
+	for {
+		var b [8]byte
+		rand.Read(b[:])
+		if seed := binary.BigEndian.Uint64(b[:]); seed != 0 { // 0 is no seed
+			return seed
+		}
+	}
+}
+
+// FormatSeed writes a Hasher.Seed the way manifests record it, 16 hex digits.
+func FormatSeed(seed uint64) string {
+	return fmt.Sprintf("%016x", seed)
+}
+
+// seedString is FormatSeed, or "" for no seed.
+func seedString(seed uint64) string {
+	if seed == 0 {
+		return ""
+	}
+	return FormatSeed(seed)
+}
+
+// ParseSeed reads a seed written by FormatSeed.
+func ParseSeed(s string) (uint64, error) {
+	seed, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || seed == 0 {
+		return 0, fmt.Errorf("not a seed: %q", s)
+	}
+	return seed, nil
+}
+
+// sampleSize returns the chunk size in use.
+func (h *Hasher) sampleSize() int {
+	if h.SampleSize == 0 {
+		return SampleSize
+	}
+	return h.SampleSize
+}
+
+// TotalChunks returns how many chunks (first + middle + last) are sampled for a file of fileSize.
+func (h *Hasher) TotalChunks(fileSize int64) int {
+	_, chunks := h.samples(fileSize)
+	return chunks
+}
+
+// span is one sampled region of a file.
+type span struct {
+	off int64
+	n   int64
+}
+
+// samples works out which regions of a file of fileSize get hashed.
+// The spans are in file order of where they start. On files of less than
+// two samples per chunk the last one reaches back into the one before it,
+// see evenLayout, and the bytes in both are hashed twice. Digest keeps them
+// for that when reading a stream.
+func (h *Hasher) samples(fileSize int64) ([]span, int) {
+	sampleSize := int64(h.sampleSize())
+
+	// Full mode is one span over the whole file, counted in sample sized chunks
+	if h.Full {
+		chunks := max(1, int((fileSize+sampleSize-1)/sampleSize))
+		return []span{{off: 0, n: fileSize}}, chunks
+	}
+
+	totalChunks := h.Chunks
+	if totalChunks <= 0 {
+		totalChunks = CalculateOptimalChunks(fileSize, int(sampleSize), h.TargetCoverage) + 2 // first + middle + last
+	}
+	spans := evenLayout(fileSize, sampleSize, totalChunks)
+	if h.Offsets != nil {
+		placedLayout(spans, fileSize, sampleSize, h.Offsets)
+	} else if h.Seed != 0 {
+		seededLayout(spans, fileSize, sampleSize, h.Seed)
+	}
+	return spans, totalChunks
+}
+
+// seededLayout moves the middle samples of an evenLayout to places picked
+// from seed. The file between the first and last samples is cut into as
+// many equal slots as there are middle samples, and each goes somewhere in
+// its slot, so they stay in file order, never overlap and cover as much as
+// before. Where in its slot is the next SplitMix64 number, starting from
+// seed XOR the file size, modulo the room there is:
+//
+//	slot = (fileSize - 2*sampleSize) / middle
+//	off[i] = sampleSize + i*slot + splitmix64() % (slot - sampleSize + 1)
+//
+// SplitMix64 is a few lines in any language, so other tools can take the
+// same samples.
+func seededLayout(spans []span, fileSize, sampleSize int64, seed uint64) {
+	middle := int64(len(spans) - 2)
+	if middle < 1 {
+		return
+	}
+	slot := (fileSize - 2*sampleSize) / middle
+	room := uint64(slot - sampleSize + 1)
+	state := seed ^ uint64(fileSize)
+	for i := range middle {
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		z ^= z >> 31
+		spans[i+1].off = sampleSize + i*slot + int64(z%room)
+	}
+}
+
+// evenLayout places totalChunks samples: the first chunk, evenly spread
+// middle chunks and the last chunk. Everything is derived from the file size,
+// sample size and chunk count, all of which the manifest records, so a file
+// can always be re-sampled exactly as it was hashed.
+func evenLayout(fileSize, sampleSize int64, totalChunks int) []span {
+	middleChunks := max(0, totalChunks-2)
+
+	// First chunk
+	spans := []span{{off: 0, n: min(fileSize, sampleSize)}}
+
+	// Multiple middle chunks for better coverage, plus the last chunk.
+	// Only apply if file is large enough to contain distinct middle chunks
+	if fileSize > sampleSize*int64(totalChunks) {
+		for i := 0; i < middleChunks; i++ {
+			// Distribute middle chunks evenly across the file
+			position := fileSize * int64(i+2) / int64(middleChunks+2)
+			spans = append(spans, span{off: position, n: sampleSize})
+		}
+		// Last chunk is one sample from the end, ensuring it's not before the start of the file.
+		// Under two samples per chunk it overlaps the last middle one, the bytes in both count twice
+		position := max(0, fileSize-sampleSize)
+		spans = append(spans, span{off: position, n: fileSize - position})
+	}
+	return spans
+}
+
+// ChunkOffsets returns the start offset of every sample taken from a file of
+// fileSize, handy for checking what a manifest entry actually covers.
+func (h *Hasher) ChunkOffsets(fileSize int64) []int64 {
+	spans, _ := h.samples(fileSize)
+	offsets := make([]int64, len(spans))
+	for i, sp := range spans {
+		offsets[i] = sp.off
+	}
+	return offsets
+}
+
+// sizeTrailer is the big endian file size that gets hashed after the samples.
+func sizeTrailer(fileSize int64) []byte {
+	sizeBytes := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		sizeBytes[7-i] = byte(fileSize >> (8 * i))
+	}
+	return sizeBytes
+}
+
+// Sum calculates the sampled hash of a file, or of a URL with a registered scheme.
+// It returns the lowercase hex digest and the number of chunks sampled.
+// Cancelling ctx stops the hash between chunks.
+func (h *Hasher) Sum(ctx context.Context, filepath string) (string, int, error) {
+	f, net, err := h.open(ctx, filepath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open file %s: %w", filepath, err)
+	}
+	defer f.Close()
+
+	hashHex, chunks, err := h.sumReaderAt(ctx, f, f.Size(), net)
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %w", filepath, err)
+	}
+	return hashHex, chunks, nil
+}
+
+// SumReaderAt calculates the sampled hash of size bytes of data read from r.
+// This is what Sum uses for files, but r can be anything seekable,
+// for example an archive entry or a ranged network reader.
+// With NetMode NetOn samples close together are read in one go.
+func (h *Hasher) SumReaderAt(ctx context.Context, r io.ReaderAt, size int64) (string, int, error) {
+	return h.sumReaderAt(ctx, r, size, h.NetMode == NetOn)
+}
+
+// sumReaderAt is SumReaderAt, with samples close together read at once in net mode.
+func (h *Hasher) sumReaderAt(ctx context.Context, r io.ReaderAt, size int64, net bool) (string, int, error) {
+	hasher, err := newDigest(h.Algorithm, h.DigestBytes, h.Key)
+	if err != nil {
+		return "", 0, err
+	}
+
+	spans, totalChunks := h.samples(size)
+	if m, ok := r.(*mappedFile); ok {
+		if err := hashMapped(ctx, hasher, m.data, spans, int64(h.sampleSize())); err != nil {
+			return "", 0, err
+		}
+		hasher.Write(sizeTrailer(size))
+		return hex.EncodeToString(hasher.Sum(nil)), totalChunks, nil
+	}
+
+	if u, ok := r.(*uringFile); ok {
+		if ok, err := hashUring(ctx, hasher, u, spans, int64(h.sampleSize())); ok {
+			if err != nil {
+				return "", 0, err
+			}
+			hasher.Write(sizeTrailer(size))
+			return hex.EncodeToString(hasher.Sum(nil)), totalChunks, nil
+		}
+	}
+
+	bufferSize := h.sampleSize()
+	if net && h.Full {
+		bufferSize = max(bufferSize, netGap) // Fewer, bigger reads
+	}
+	pooled := getBuffer(bufferSize)
+	defer putBuffer(pooled)
+	buffer := *pooled
+	var group []byte
+
+	for i := 0; i < len(spans); i++ {
+		if net && !h.Full {
+			// Take in the samples after this one that are close enough
+			last := i
+			for last+1 < len(spans) && spans[last+1].off-(spans[last].off+spans[last].n) <= netGap &&
+				spans[last+1].off+spans[last+1].n-spans[i].off <= netMaxRead {
+				last++
+			}
+			if last > i {
+				if err := ctx.Err(); err != nil {
+					return "", 0, err
+				}
+				start, end := spans[i].off, spans[last].off+spans[last].n
+				if int64(len(group)) < end-start {
+					pooledGroup := getBuffer(int(end - start))
+					defer putBuffer(pooledGroup)
+					group = *pooledGroup
+				}
+				n, err := r.ReadAt(group[:end-start], start)
+				if err != nil && err != io.EOF {
+					return "", 0, fmt.Errorf("failed to read chunks %d to %d at offset %d: %w", i, last, start, err)
+				}
+				for _, sp := range spans[i : last+1] {
+					from := sp.off - start
+					to := min(from+sp.n, int64(n))
+					if from < to {
+						hasher.Write(group[from:to])
+					}
+				}
+				i = last
+				continue
+			}
+		}
+
+		sp := spans[i]
+		// A span is one sample, except in full mode where it's read a buffer at a time
+		for off, end := sp.off, sp.off+sp.n; ; {
+			if err := ctx.Err(); err != nil {
+				return "", 0, err
+			}
+			// The last chunk might be smaller than a sample, read to EOF
+			n, err := r.ReadAt(buffer[:min(end-off, int64(len(buffer)))], off)
+			if err != nil && err != io.EOF {
+				return "", 0, fmt.Errorf("failed to read chunk %d at offset %d: %w", i, off, err)
+			}
+			hasher.Write(buffer[:n])
+			off += int64(n)
+			if off >= end || n == 0 {
+				break
+			}
+		}
+	}
+
+	// Include file size in hash for extra integrity
+	hasher.Write(sizeTrailer(size))
+
+	return hex.EncodeToString(hasher.Sum(nil)), totalChunks, nil
+}
+
+// SumReader calculates the sampled hash of a non-seekable stream of size bytes.
+// The data between samples is read and thrown away, so this is only as fast
+// as the stream itself, but gives the same hash as SumReaderAt.
+func (h *Hasher) SumReader(ctx context.Context, r io.Reader, size int64) (string, int, error) {
+	d, err := h.New(size)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(d, &ctxReader{ctx: ctx, r: io.LimitReader(r, size)}); err != nil {
+		return "", 0, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return hex.EncodeToString(d.Sum(nil)), d.Chunks(), nil
+}
+
+// sumStream reads size bytes of r once, for the FSH24 and for every hash in
+// whole. Files that can only be read start to end, in compressed archives,
+// get all their hashes this way instead of being read again for each one.
+func (h *Hasher) sumStream(ctx context.Context, r io.Reader, size int64, whole ...hash.Hash) (string, int, error) {
+	d, err := h.New(size)
+	if err != nil {
+		return "", 0, err
+	}
+	w := io.Writer(d)
+	if len(whole) > 0 {
+		writers := []io.Writer{d}
+		for _, wh := range whole {
+			writers = append(writers, wh)
+		}
+		w = io.MultiWriter(writers...)
+	}
+	buffer := getBuffer(SampleSize)
+	defer putBuffer(buffer)
+	n, err := io.CopyBuffer(w, &ctxReader{ctx: ctx, r: io.LimitReader(r, size)}, *buffer)
+	if err == nil && n < size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(d.Sum(nil)), d.Chunks(), nil
+}
+
+// SumSHA256 calculates the SHA-256 of the whole file, every byte of it.
+func SumSHA256(ctx context.Context, filepath string) (string, error) {
+	return sumFile(ctx, filepath, sha256.New())
+}
+
+// SumCRC32 calculates the CRC32 (IEEE, same as .sfv files) of the whole file.
+func SumCRC32(ctx context.Context, filepath string) (string, error) {
+	return sumFile(ctx, filepath, crc32.NewIEEE())
+}
+
+// sumFile opens a file for sumWhole.
+func sumFile(ctx context.Context, filepath string, hasher hash.Hash) (string, error) {
+	f, err := Open(ctx, filepath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", filepath, err)
+	}
+	defer f.Close()
+
+	sum, err := sumWhole(ctx, f, hasher)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filepath, err)
+	}
+	return sum, nil
+}
+
+// sumWhole feeds every byte of f to hasher. It reads a sample at a time,
+// remote files get a request per read.
+func sumWhole(ctx context.Context, f File, hasher hash.Hash) (string, error) {
+	r := &ctxReader{ctx: ctx, r: io.NewSectionReader(f, 0, f.Size())}
+	buffer := getBuffer(SampleSize)
+	defer putBuffer(buffer)
+	if _, err := io.CopyBuffer(hasher, r, *buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ctxReader stops a stream copy once its context is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// HashFile calculates and returns hash results for a single file,
+// or a URL with a registered scheme.
+func (h *Hasher) HashFile(ctx context.Context, filepath string) (FileHashResult, error) {
+	if r, ok := h.listedHash(ctx, filepath); ok {
+		return r, nil // Hashed when its archive was listed
+	}
+	f, net, err := h.open(ctx, filepath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return FileHashResult{}, fmt.Errorf("file not found: %s", filepath)
+	} else if err != nil {
+		return FileHashResult{}, err // Has the path in it already
+	}
+	defer f.Close()
+
+	fileSize := f.Size()
+
+	startTime := time.Now()
+	var hashHex, fullHex, crcHex string
+	var chunks int
+	var offsets []int64
+	if _, ok := f.(*streamFile); ok {
+		hashHex, chunks, fullHex, crcHex, err = h.sumOnce(ctx, io.NewSectionReader(f, 0, fileSize), fileSize)
+	} else {
+		placed := *h
+		if h.Adaptive && !h.Full {
+			if offsets, err = h.adaptiveOffsets(ctx, f, fileSize); err != nil {
+				return FileHashResult{}, fmt.Errorf("error looking over %s: %w", filepath, err)
+			}
+			placed.Offsets = offsets
+		}
+		hashHex, chunks, fullHex, crcHex, err = placed.sumEach(ctx, f, fileSize, net)
+	}
+	if err != nil {
+		return FileHashResult{}, fmt.Errorf("error hashing %s: %w", filepath, err)
+	}
+	var md Metadata
+	if h.Metadata && !IsRemote(filepath) && !IsArchiveMember(filepath) && !IsDevice(filepath) {
+		md, err = ReadMetadata(filepath)
+		if err != nil {
+			return FileHashResult{}, err
+		}
+	}
+	return FileHashResult{
+		Filename:        baseName(filepath),
+		Filepath:        filepath,
+		FileSize:        fileSize,
+		FSH24:           strings.ToUpper(hashHex),
+		SHA256:          strings.ToUpper(fullHex),
+		CRC32:           strings.ToUpper(crcHex),
+		Chunks:          chunks,
+		CoveragePercent: h.coverage(chunks, fileSize),
+		ProcessingTime:  time.Since(startTime).Seconds(),
+		ModTime:         f.ModTime(),
+		Metadata:        md,
+		Offsets:         offsets,
+	}, nil
+}
+
+// sumEach works out the FSH24 of f, then reads it again for the SHA-256
+// and CRC32 if h wants them.
+func (h *Hasher) sumEach(ctx context.Context, f File, size int64, net bool) (hashHex string, chunks int, fullHex, crcHex string, err error) {
+	if hashHex, chunks, err = h.sumReaderAt(ctx, f, size, net); err != nil {
+		return
+	}
+	if h.SHA256 {
+		if fullHex, err = sumWhole(ctx, f, sha256.New()); err != nil {
+			return
+		}
+	}
+	if h.CRC32 {
+		crcHex, err = sumWhole(ctx, f, crc32.NewIEEE())
+	}
+	return
+}
+
+// sumOnce is sumEach for a file that can only be read start to end, all
+// of it in one go, see sumStream.
+func (h *Hasher) sumOnce(ctx context.Context, r io.Reader, size int64) (hashHex string, chunks int, fullHex, crcHex string, err error) {
+	var whole []hash.Hash
+	full, crc := sha256.New(), crc32.NewIEEE()
+	if h.SHA256 {
+		whole = append(whole, full)
+	}
+	if h.CRC32 {
+		whole = append(whole, crc)
+	}
+	if hashHex, chunks, err = h.sumStream(ctx, r, size, whole...); err != nil {
+		return
+	}
+	if h.SHA256 {
+		fullHex = hex.EncodeToString(full.Sum(nil))
+	}
+	if h.CRC32 {
+		crcHex = hex.EncodeToString(crc.Sum(nil))
+	}
+	return
+}
+
+// coverage is how much of a file of size bytes chunks samples are, in percent.
+func (h *Hasher) coverage(chunks int, size int64) float64 {
+	if h.Full {
+		return 100
+	} else if size > 0 {
+		return (float64(chunks) * float64(h.sampleSize()) / float64(size)) * 100
+	}
+	return 0
+}
+
+// HashFiles hashes files concurrently.
+// Results are sorted by filepath; files that failed are returned as *FileError.
+// If ctx is cancelled the files finished so far are returned and the rest are
+// left out without an error, check ctx.Err() to tell a partial run apart.
+func (h *Hasher) HashFiles(ctx context.Context, filepaths []string) ([]FileHashResult, []error) {
+	var (
+		mu      sync.Mutex
+		results = make([]FileHashResult, 0, len(filepaths))
+		errs    []error
+	)
+
+	runJobs(len(filepaths), h.Jobs, func(i int) {
+		filePath := filepaths[i]
+		result, err := h.HashFile(ctx, filePath)
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil && err != nil {
+			return
+		}
+		if err != nil {
+			errs = append(errs, &FileError{Path: filePath, Err: err})
+			return
+		}
+		results = append(results, result)
+		if h.OnResult != nil {
+			h.OnResult(result)
+		}
+	})
+
+	sort.Slice(results, func(i, j int) bool { // Sort results by filepath for consistent output
+		return results[i].Filepath < results[j].Filepath
+	})
+	return results, errs
+}
+
+// HashSummary wraps hash results in the JSON summary structure.
+func (h *Hasher) HashSummary(results []FileHashResult, totalProcessingTime float64) TotalHashSummary {
+	return TotalHashSummary{
+		Magic:               MagicFor(h.Algorithm),
+		Algorithm:           h.Algorithm,
+		SampleSize:          h.sampleSize(),
+		Full:                h.Full,
+		Seed:                seedString(h.Seed),
+		Keyed:               len(h.Key) > 0,
+		TotalFiles:          len(results),
+		TotalProcessingTime: totalProcessingTime,
+		AverageTimePerFile:  totalProcessingTime / float64(len(results)),
+		Files:               results,
+	}
+}
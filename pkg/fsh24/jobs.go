@@ -0,0 +1,42 @@
+package fsh24
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MaxDefaultJobs caps DefaultJobs. Sampling is mostly seeking, more than a
+// few files at once just makes a hard drive jump around.
+const MaxDefaultJobs = 4
+
+// DefaultJobs is the number of files worked on at once when Hasher.Jobs is 0,
+// the CPU count but no more than MaxDefaultJobs.
+func DefaultJobs() int {
+	return min(runtime.NumCPU(), MaxDefaultJobs)
+}
+
+// runJobs calls fn for every index below n, with at most jobs calls running
+// at the same time. jobs < 1 means DefaultJobs.
+func runJobs(n, jobs int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = DefaultJobs()
+	}
+	jobs = min(jobs, n)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,50 @@
+package fsh24
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLocked is what a file another program kept locked through every
+// retry fails with, wrapped around the error the system gave.
+var ErrLocked = errors.New("locked by another program")
+
+// DefaultLockDelay is the wait before the first retry of a locked file,
+// when Hasher.LockDelay isn't set.
+const DefaultLockDelay = 2 * time.Second
+
+// IsLocked reports whether err is because another program has the file
+// locked, a sharing or lock violation on Windows, rather than it being
+// missing or unreadable. Those are worth trying again later.
+func IsLocked(err error) bool {
+	return errors.Is(err, ErrLocked) || isLockErr(err)
+}
+
+// openLocked opens path like Open, but if another program has it locked it
+// tries again LockRetries times, waiting LockDelay, then twice that and so
+// on. Backups and virus scanners only hold on to files for a bit, an
+// overnight run shouldn't lose a file to them.
+func (h *Hasher) openLocked(ctx context.Context, path string) (File, error) {
+	delay := h.LockDelay
+	if delay <= 0 {
+		delay = DefaultLockDelay
+	}
+	for try := 0; ; try++ {
+		f, err := Open(ctx, path)
+		if err == nil || !isLockErr(err) {
+			return f, err
+		}
+		if try >= h.LockRetries {
+			return nil, fmt.Errorf("%w: %w", ErrLocked, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
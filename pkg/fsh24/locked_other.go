@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package fsh24
+
+// isLockErr is false, there are no file locks to run into here.
+func isLockErr(err error) bool {
+	return false
+}
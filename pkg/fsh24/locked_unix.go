@@ -0,0 +1,15 @@
+//go:build unix
+
+package fsh24
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// isLockErr is EBUSY, or EAGAIN from a file under a mandatory lock. Unix
+// locks are mostly advisory, reading a file someone has locked just works.
+func isLockErr(err error) bool {
+	return errors.Is(err, unix.EBUSY) || errors.Is(err, unix.EAGAIN)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package fsh24
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isLockErr is a sharing violation, the file is open somewhere without
+// FILE_SHARE_READ, or a lock violation, part of it is locked with LockFileEx.
+func isLockErr(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package fsh24
+
+// LongPath returns path, only Windows has a length limit to get around.
+func LongPath(path string) string {
+	return path
+}
+
+// PlainPath returns path, only Windows has a \\?\ form to undo.
+func PlainPath(path string) string {
+	return path
+}
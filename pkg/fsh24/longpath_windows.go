@@ -0,0 +1,43 @@
+//go:build windows
+
+package fsh24
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShortPath is how long a path can get before Windows wants the \\?\
+// form, MAX_PATH minus room for an 8.3 file name.
+const maxShortPath = 248
+
+// LongPath returns path in the extended length form Windows takes past
+// MAX_PATH, \\?\C:\... or \\?\UNC\server\share\..., if it's long enough to
+// need it. The os package does this by itself, this is for the Windows
+// APIs called straight. Anywhere but Windows path comes back as it is.
+func LongPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxShortPath {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
+
+// PlainPath undoes LongPath, \\?\C:\x is C:\x and \\?\UNC\server\share
+// is \\server\share again. Paths typed in that form are stored without
+// it, so they stay relative to the hash file and work on other machines.
+func PlainPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, `\\?\UNC\`); ok {
+		return `\\` + rest
+	}
+	if rest, ok := strings.CutPrefix(path, `\\?\`); ok && filepath.VolumeName(rest) != "" {
+		return rest
+	}
+	return path
+}
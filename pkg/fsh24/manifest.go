@@ -0,0 +1,663 @@
+package fsh24
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manifest file formats.
+const (
+	FormatFSH24   = "fsh24"     // FSH24-1, the original and the default
+	FormatFSH24v2 = "fsh24-2"   // FSH24-2, see manifest_v2.go
+	FormatGNU     = "gnu"       // md5sum/sha256sum style "HASH  path" lines
+	FormatBSD     = "bsd"       // BSD md5/openssl style "FSH24 (path) = HASH" lines
+	FormatSFV     = "sfv"       // "path CRC32" lines, CRC32 only, see manifest_sfv.go
+	FormatBinary  = "fsh24-bin" // FSH24-B, FSH24-2 in binary, see manifest_bin.go
+)
+
+// Formats lists the format names accepted by Manifest.Format.
+var Formats = []string{FormatFSH24, FormatFSH24v2, FormatGNU, FormatBSD, FormatSFV, FormatBinary}
+
+// Entry is one hashed file line of a .fsh24 file.
+// Formats without the columns leave Chunks at 0 and Size at -1.
+type Entry struct {
+	Hash   string
+	Chunks int
+	Size   int64
+	Path   string
+
+	// SHA256 is the full file digest, only set in manifests made with SHA256 on.
+	SHA256 string
+
+	// CRC32 is the full file CRC32 of .sfv files. Those have no Hash.
+	CRC32 string
+
+	// Checksum is set for plain md5sum/sha256sum style lines, where Hash is
+	// that whole file hash (one of the Checksum* values) and not an FSH24.
+	// See checksum.go.
+	Checksum string
+
+	// ModTime is the file's modification time. Only FSH24-2 files store it.
+	ModTime time.Time
+
+	// Metadata is the file's mode, owner and xattrs, for manifests made
+	// with Hasher.Metadata. Only FSH24-2 files store it.
+	Metadata
+
+	// Offsets are where the middle samples of a file hashed with
+	// Hasher.Adaptive went, nil for evenly spaced. Only FSH24-2 and FSH24-B
+	// files store them, they're needed to verify it.
+	Offsets []int64
+
+	// Extra holds FSH24-2 columns this version doesn't know about, by field name,
+	// so they survive a read and write.
+	Extra map[string]string
+}
+
+// InvalidLine is a manifest line that could not be parsed.
+// Status is one of the StatusInvalid* values.
+type InvalidLine struct {
+	Line   string
+	Status string
+}
+
+// Manifest is the in-memory form of a .fsh24 file.
+type Manifest struct {
+	// Format is the file format, one of the Format* values. Empty means FSH24-1.
+	Format string
+
+	// Algorithm the entries were hashed with. Empty means BLAKE2b, like every
+	// FSH24-1 file written before the header carried it.
+	Algorithm string
+
+	// DigestBytes is the hash length, 0 means the default 24 bytes.
+	DigestBytes int
+
+	// SampleSize is the chunk size the entries were sampled with, 0 means the default 4MB.
+	SampleSize int
+
+	// Full marks a manifest of whole file hashes made in full mode.
+	Full bool
+
+	// Seed is the Hasher.Seed the entries were sampled with, 0 for evenly
+	// spaced samples.
+	Seed uint64
+
+	// Keyed marks a manifest hashed with a secret key. The key itself is never stored.
+	Keyed bool
+
+	// SHA256 adds a full file SHA-256 column before the path of every line.
+	SHA256 bool
+
+	// Chained adds a link column before the path of every line, a hash of
+	// the line before it, so edits to earlier lines show. See chain.go.
+	// FSH24-1 only, reading fails with ErrBrokenChain when a link is wrong.
+	Chained bool
+
+	// Metadata adds the mode, owner and xattrs columns to FSH24-2 files,
+	// see Entry.Metadata. Add sets it for results that have them.
+	Metadata bool
+
+	// Adaptive adds the offsets column to FSH24-2 files, see Entry.Offsets.
+	// Add sets it for results that have them.
+	Adaptive bool
+
+	// Fields is the FSH24-2 column order, nil means the default for the settings above.
+	Fields []string
+
+	// Meta is free form FSH24-2 header metadata, eg. created=... or tool=...
+	Meta map[string]string
+
+	// NullTerminated ends gnu and bsd lines with a NUL instead of a newline and
+	// leaves paths unescaped, like sha256sum -z. For paths with newlines in them.
+	NullTerminated bool
+
+	// Gzip compresses the file WriteFile writes, for hash files with
+	// millions of lines. ParseManifest sets it when what it read was
+	// gzipped, so a .fsh24.gz stays one when it's updated. See GzipExt.
+	Gzip bool
+
+	// Comments are the FSH24-2 header "# ..." lines, without the "# ".
+	// In .sfv files they are the "; ..." lines.
+	Comments []string
+
+	Entries []Entry
+	Invalid []InvalidLine
+
+	// magic is the first word of the FSH24-1 file this was read from, kept
+	// for chained ones, see header.
+	magic string
+
+	// SignKey, if set, signs the manifest when it's written, adding a
+	// signature trailer line at the end. See SignedBy.
+	SignKey ed25519.PrivateKey
+
+	// SignedBy is the public key of a manifest read with a good signature,
+	// nil for unsigned ones. A bad signature fails the read with ErrBadSignature.
+	// Anyone can sign, check it's a key you trust.
+	SignedBy ed25519.PublicKey
+}
+
+// hasSettings reports whether the format records the hash settings.
+// The gnu, bsd and sfv formats have no header, so they don't.
+func (m *Manifest) hasSettings() bool {
+	return m.Format != FormatGNU && m.Format != FormatBSD && m.Format != FormatSFV
+}
+
+// ApplySettings sets the algorithm, digest length, sample size and mode
+// recorded in m on h, so files hashed with it match the manifest.
+// The key is dropped for unkeyed manifests. Formats without settings
+// (FormatGNU, FormatBSD, FormatSFV) leave h as it is.
+func (m *Manifest) ApplySettings(h *Hasher) {
+	if !m.hasSettings() {
+		return
+	}
+	h.Algorithm = m.Algorithm
+	h.DigestBytes = m.DigestBytes
+	h.SampleSize = m.SampleSize
+	h.Full = m.Full
+	h.Seed = m.Seed
+	if !m.Keyed {
+		h.Key = nil
+	}
+}
+
+// header builds the first line of the file. Anything beyond the default
+// BLAKE2b settings is recorded as space separated key=value pairs after the
+// magic, which is then MagicSettings, eg. "FSHS-1 algo=blake3". Plain
+// FSH24-1 is only for files any FSH24-1 reader gets right.
+func (m *Manifest) header() string {
+	magic := MagicFor(m.Algorithm)
+	header := ""
+	if m.Algorithm != "" && m.Algorithm != AlgoBLAKE2b && magic == Magic {
+		header += " algo=" + m.Algorithm
+	}
+	if m.DigestBytes != 0 && m.DigestBytes != defaultDigestBytes(m.Algorithm) {
+		header += " bytes=" + strconv.Itoa(m.DigestBytes)
+	}
+	if m.SampleSize != 0 && m.SampleSize != SampleSize {
+		header += " sample=" + strconv.Itoa(m.SampleSize)
+	}
+	if m.Full {
+		header += " mode=full"
+	}
+	if m.Seed != 0 {
+		header += " seed=" + FormatSeed(m.Seed)
+	}
+	if m.Keyed {
+		header += " keyed=1"
+	}
+	if m.SHA256 {
+		header += " sha256=1"
+	}
+	if m.Chained {
+		header += " chain=1"
+	}
+	if magic == Magic && header != "" {
+		magic = MagicSettings
+	}
+	if m.Chained && m.magic != "" {
+		magic = m.magic // The links hash the header, a new magic would change every one
+	}
+	return magic + header
+}
+
+// parseHeader reads the settings from the first line of a FSH24-1 file.
+func (m *Manifest) parseHeader(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		m.magic = fields[0]
+		if fields[0] != Magic && fields[0] != MagicSettings {
+			m.Algorithm = algorithmForMagic(fields[0])
+		}
+	}
+	for _, field := range fields[1:] { // Skip magic
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("invalid header field: %s", field)
+		}
+		known, err := m.SetParam(key, value)
+		if err != nil {
+			return err
+		}
+		if !known {
+			return fmt.Errorf("unknown header field: %s", key)
+		}
+	}
+	return nil
+}
+
+// Param is one key=value hash setting, as written in the .fsh24 headers.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params returns the hash settings of the manifest with the defaults spelled out.
+// SetParam reads them back, so other stores (like a database) can keep them too.
+func (m *Manifest) Params() []Param {
+	algo := m.Algorithm
+	if algo == "" {
+		algo = AlgoBLAKE2b
+	}
+	digestBytes := m.DigestBytes
+	if digestBytes == 0 {
+		digestBytes = defaultDigestBytes(algo)
+	}
+	sampleSize := m.SampleSize
+	if sampleSize == 0 {
+		sampleSize = SampleSize
+	}
+	mode := "sample"
+	if m.Full {
+		mode = "full"
+	}
+
+	params := []Param{
+		{"algo", algo},
+		{"bytes", strconv.Itoa(digestBytes)},
+		{"sample", strconv.Itoa(sampleSize)},
+		{"mode", mode},
+	}
+	if m.Seed != 0 {
+		params = append(params, Param{"seed", FormatSeed(m.Seed)})
+	}
+	if m.Keyed {
+		params = append(params, Param{"keyed", "1"})
+	}
+	if m.SHA256 {
+		params = append(params, Param{"sha256", "1"})
+	}
+	if m.Chained {
+		params = append(params, Param{"chain", "1"})
+	}
+	return params
+}
+
+// SetParam applies one key=value hash setting, as found in the .fsh24 headers.
+// It reports false for keys it doesn't know.
+func (m *Manifest) SetParam(key, value string) (bool, error) {
+	switch key {
+	case "algo":
+		if !ValidAlgorithm(value) {
+			return true, fmt.Errorf("unsupported hash algorithm in header: %s", value)
+		}
+		m.Algorithm = value
+	case "bytes":
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return true, fmt.Errorf("invalid digest length in header: %s", value)
+		}
+		if err := ValidateDigestBytes(m.Algorithm, size); err != nil {
+			return true, err
+		}
+		m.DigestBytes = size
+	case "sample":
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return true, fmt.Errorf("invalid sample size in header: %s", value)
+		}
+		if err := ValidateSampleSize(size); err != nil {
+			return true, err
+		}
+		m.SampleSize = size
+	case "mode":
+		if value != "full" && value != "sample" {
+			return true, fmt.Errorf("unknown hash mode in header: %s", value)
+		}
+		m.Full = value == "full"
+	case "seed":
+		seed, err := ParseSeed(value)
+		if err != nil {
+			return true, fmt.Errorf("invalid seed in header: %w", err)
+		}
+		m.Seed = seed
+	case "keyed":
+		m.Keyed = value == "1"
+	case "sha256":
+		m.SHA256 = value == "1"
+	case "chain":
+		m.Chained = value == "1"
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Add appends a hash result to the manifest.
+// If relTo is not empty the recorded path is made relative to it, unless it's
+// a URL. When that fails the entry is still added with the original path and
+// the error is returned so the caller can warn about it.
+func (m *Manifest) Add(r FileHashResult, relTo string) error {
+	entry := Entry{
+		Hash:     strings.ToUpper(r.FSH24),
+		Chunks:   r.Chunks,
+		Size:     r.FileSize,
+		Path:     r.Filepath,
+		SHA256:   strings.ToUpper(r.SHA256),
+		CRC32:    strings.ToUpper(r.CRC32),
+		ModTime:  r.ModTime,
+		Metadata: r.Metadata,
+		Offsets:  r.Offsets,
+	}
+	if !r.Metadata.IsZero() {
+		m.Metadata = true
+	}
+	if r.Offsets != nil {
+		m.Adaptive = true
+	}
+
+	var relErr error
+	if relTo != "" && !IsRemote(r.Filepath) {
+		// Rel needs both sides absolute, the input path may be relative to cwd
+		relPath, err := filepath.Abs(r.Filepath)
+		if err == nil {
+			relPath, err = filepath.Rel(relTo, relPath)
+		}
+		if err != nil {
+			relErr = fmt.Errorf("could not make path %s relative to %s: %w", r.Filepath, relTo, err)
+		} else {
+			entry.Path = relPath
+		}
+	}
+
+	m.Entries = append(m.Entries, entry)
+	return relErr
+}
+
+// WriteTo writes the manifest in the text format picked by Format,
+// signed if SignKey is set.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	if m.SignKey == nil {
+		return m.writeTo(w)
+	}
+	var body bytes.Buffer
+	if _, err := m.writeTo(&body); err != nil {
+		return 0, err
+	}
+	end := "\n"
+	if m.NullTerminated && (m.Format == FormatGNU || m.Format == FormatBSD) {
+		end = "\x00"
+	}
+	body.WriteString(signManifest(body.Bytes(), m.SignKey, end))
+	return body.WriteTo(w)
+}
+
+// writeTo is WriteTo without the signature.
+func (m *Manifest) writeTo(w io.Writer) (int64, error) {
+	if i := slices.IndexFunc(m.Entries, func(e Entry) bool { return e.Offsets != nil }); i >= 0 && !HasFields(m.Format) {
+		// Writing it without would make it fail to verify
+		return 0, fmt.Errorf("%s was hashed with adaptive sampling, only %s and %s files can hold where its samples are", m.Entries[i].Path, FormatFSH24v2, FormatBinary)
+	}
+	if m.Format == FormatBinary {
+		return m.writeBinary(w)
+	}
+	bw := bufio.NewWriter(w)
+	var total int64
+
+	var header string
+	formatLine := m.formatLine
+	end := "\n"
+	switch m.Format {
+	case FormatFSH24v2:
+		header = m.headerV2()
+		formatLine = m.formatLineV2
+	case FormatGNU:
+		formatLine = formatLineGNU // No header, coreutils wouldn't understand it
+		if m.NullTerminated {
+			formatLine = func(e Entry) string { return strings.ToLower(e.Hash) + "  " + e.Path }
+			end = "\x00"
+		}
+	case FormatBSD:
+		formatLine = formatLineBSD
+		if m.NullTerminated {
+			end = "\x00"
+		}
+	case FormatSFV:
+		header = m.headerSFV()
+		formatLine = formatLineSFV
+	default:
+		header = m.header() + "\n"
+		if m.Chained {
+			prev := chainStart(m.header())
+			formatLine = func(e Entry) string {
+				prev = chainLink(prev, m.formatLine(e))
+				return m.lineColumns(e) + prev + "|" + e.Path
+			}
+		}
+	}
+	n, err := bw.WriteString(header)
+	total += int64(n)
+	if err != nil {
+		return total, err
+	}
+
+	for _, e := range m.Entries {
+		n, err = bw.WriteString(formatLine(e) + end)
+		total += int64(n)
+		if err != nil {
+			return total, fmt.Errorf("failed to write line for %s: %w", e.Path, err)
+		}
+	}
+	return total, bw.Flush()
+}
+
+// formatLine builds the HASH|CHUNKS|SIZE|[SHA256|]PATH line for an entry.
+// The path always goes last so it can't be confused with the other columns.
+// Chained manifests have the link before the path, see WriteTo.
+func (m *Manifest) formatLine(e Entry) string {
+	return m.lineColumns(e) + e.Path
+}
+
+// lineColumns is the start of formatLine, every column up to the path.
+func (m *Manifest) lineColumns(e Entry) string {
+	line := fmt.Sprintf("%s|%d|%d|", strings.ToUpper(e.Hash), e.Chunks, e.Size)
+	if m.SHA256 {
+		line += strings.ToUpper(e.SHA256) + "|"
+	}
+	return line
+}
+
+// WriteFile writes the manifest to a .fsh24 file, gzipped if m.Gzip is set
+// or filename ends in .gz.
+func (m *Manifest) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if m.Gzip || IsGzipName(filename) {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	if _, err := m.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+	}
+	return f.Close()
+}
+
+// ParseManifest reads a .fsh24 file from r, FSH24-1, FSH24-2 or FSH24-B going by the magic.
+// Files without a magic that look like md5sum/sha256sum output are read as FormatGNU,
+// "FSH24 (path) = HASH" lines as FormatBSD and .sfv files as FormatSFV.
+// Tagged lines of other hashes, "SHA256 (path) = HASH", are read as FormatBSD
+// entries with a Checksum. Untagged ones are all taken as FSH24 hashes, see
+// Manifest.DetectChecksums for telling them apart.
+// Lines that can't be parsed are collected in Manifest.Invalid rather than failing the whole read.
+// A gzipped file is decompressed first, whatever it's called, see Manifest.Gzip.
+// A GPG clear-signed file is read without its signature, which isn't checked here.
+// An Ed25519 signature trailer is checked, see Manifest.SignedBy.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content, gzipped, err := gunzip(content)
+	if err != nil {
+		return nil, err
+	}
+	content = unwrapClearSigned(content)
+	content, signedBy, err := splitSignature(content)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseManifest(content)
+	if err != nil {
+		return nil, err
+	}
+	m.SignedBy = signedBy
+	m.Gzip = gzipped
+	return m, nil
+}
+
+// parseManifest is ParseManifest, after the signatures are off.
+func parseManifest(content []byte) (*Manifest, error) {
+	if bytes.HasPrefix(content, magicBinary) {
+		return parseManifestBinary(content)
+	}
+	lines := strings.Split(string(content), "\n")
+	if strings.Contains(string(content), "\x00") {
+		lines = strings.Split(string(content), "\x00") // sha256sum -z style
+	}
+
+	header := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(header, MagicV2) {
+		return parseManifestV2(lines)
+	}
+	if isLineGNU(header) {
+		return parseManifestGNU(lines), nil
+	}
+	if isLineBSD(header) {
+		return parseManifestBSD(lines), nil
+	}
+	if strings.HasPrefix(header, ";") || isLineSFV(header) {
+		return parseManifestSFV(lines), nil
+	}
+	if !isMagic(header) {
+		return nil, fmt.Errorf("invalid checksum file. This file is not a FSH24 checksum file")
+	}
+
+	m := &Manifest{Format: FormatFSH24}
+	if err := m.parseHeader(header); err != nil {
+		return nil, err
+	}
+
+	prev := chainStart(header)
+	for n, line := range lines[1:] { // Skip header
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		columns := 4
+		if m.SHA256 {
+			columns++
+		}
+		if m.Chained {
+			columns++
+		}
+		parts := strings.Split(line, "|")
+		if m.Chained {
+			// The link covers the line as written, so it's checked before anything else
+			if len(parts) != columns {
+				return nil, fmt.Errorf("%w at line %d, it doesn't have a link", ErrBrokenChain, n+2)
+			}
+			link := parts[columns-2]
+			parts = slices.Delete(parts, columns-2, columns-1)
+			columns--
+			prev = chainLink(prev, strings.Join(parts, "|"))
+			if !strings.EqualFold(link, prev) {
+				return nil, fmt.Errorf("%w at line %d, it or a line before it was changed after the hash file was written", ErrBrokenChain, n+2)
+			}
+		}
+		if len(parts) != columns {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidLineFormat})
+			continue
+		}
+
+		chunks, err := strconv.Atoi(parts[1])
+		if err != nil || chunks < 1 {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidChunksValue})
+			continue
+		}
+		fileSize, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidFileSizeValue})
+			continue
+		}
+
+		entry := Entry{
+			Hash:   parts[0],
+			Chunks: chunks,
+			Size:   fileSize,
+			Path:   parts[columns-1],
+		}
+		if m.SHA256 {
+			entry.SHA256 = parts[3]
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return m, nil
+}
+
+// pgpSignedHeader starts a GPG clear-signed file, see unwrapClearSigned.
+const pgpSignedHeader = "-----BEGIN PGP SIGNED MESSAGE-----"
+
+// unwrapClearSigned returns the signed text of a clear-signed file, content
+// itself for anything else. The text starts after the Hash: headers and the
+// blank line, ends before the signature, and has "- " escapes taken off the
+// lines that started with a dash.
+func unwrapClearSigned(content []byte) []byte {
+	text, ok := bytes.CutPrefix(content, []byte(pgpSignedHeader))
+	if !ok {
+		return content
+	}
+	text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+	if _, rest, ok := bytes.Cut(text, []byte("\n\n")); ok {
+		text = rest
+	}
+	text, _, _ = bytes.Cut(text, []byte("\n-----BEGIN PGP SIGNATURE-----"))
+
+	lines := bytes.Split(text, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimPrefix(line, []byte("- "))
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
+
+// ReadManifestFile reads and parses a .fsh24 file from disk.
+func ReadManifestFile(filename string) (*Manifest, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return nil, fmt.Errorf("hash file not found: %s", filename)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash file %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	m, err := ParseManifest(f)
+	if err != nil {
+		return nil, err
+	}
+	m.DetectChecksums(TrimGzipExt(filename))
+	return m, nil
+}
@@ -0,0 +1,224 @@
+package fsh24
+
+// FSH24-B is FSH24-2 in binary, for collections of millions of files where
+// reading and writing the text is what takes the time, and the space:
+//
+//	FSH24-B\n        magic
+//	uvarint, bytes   the FSH24-2 header block, FSH24-2 line to --- line
+//	uvarint          number of files
+//	files            the fields of each, in the order of the fields header
+//	\n               end, so a signature line can follow like in the text formats
+//
+// hash is the digest's raw bytes, as many as the bytes setting says, and
+// sha256 its 32. chunks is a uvarint, size a varint and mtime a varint of
+// Unix nanoseconds, 0 for none. Every other field, path included, is a
+// uvarint length and the text FSH24-2 would have in that column.
+// It converts to and from FSH24-2 without losing anything.
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MagicBinary is the header line of a binary .fsh24 file.
+const MagicBinary = "FSH24-B"
+
+// magicBinary is how a binary .fsh24 file starts.
+var magicBinary = []byte(MagicBinary + "\n")
+
+// errBrokenBinary is wrapped by every error reading a damaged FSH24-B file.
+var errBrokenBinary = errors.New("broken FSH24-B file")
+
+// HasFields reports whether format has the FSH24-2 fields header, and room
+// for modification times, metadata and the like. FSH24-2 and FSH24-B do.
+func HasFields(format string) bool {
+	return format == FormatFSH24v2 || format == FormatBinary
+}
+
+// digestBytes is the length of the hashes in m.
+func (m *Manifest) digestBytes() int {
+	if m.DigestBytes != 0 {
+		return m.DigestBytes
+	}
+	return defaultDigestBytes(cmp.Or(m.Algorithm, AlgoBLAKE2b))
+}
+
+// writeBinary is writeTo for FormatBinary.
+func (m *Manifest) writeBinary(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var total int64
+	write := func(b []byte) error {
+		n, err := bw.Write(b)
+		total += int64(n)
+		return err
+	}
+
+	header := m.headerV2()
+	buf := append([]byte(nil), magicBinary...)
+	buf = binary.AppendUvarint(buf, uint64(len(header)))
+	buf = append(buf, header...)
+	buf = binary.AppendUvarint(buf, uint64(len(m.Entries)))
+	if err := write(buf); err != nil {
+		return total, err
+	}
+
+	fields := m.fields()
+	size := m.digestBytes()
+	for _, e := range m.Entries {
+		var err error
+		if buf, err = appendEntryBinary(buf[:0], e, fields, size); err != nil {
+			return total, fmt.Errorf("can't write %s as FSH24-B: %w", e.Path, err)
+		}
+		if err := write(buf); err != nil {
+			return total, fmt.Errorf("failed to write line for %s: %w", e.Path, err)
+		}
+	}
+	if err := write([]byte("\n")); err != nil {
+		return total, err
+	}
+	return total, bw.Flush()
+}
+
+// appendEntryBinary appends the fields of e to buf, hashes of size bytes.
+func appendEntryBinary(buf []byte, e Entry, fields []string, size int) ([]byte, error) {
+	for _, field := range fields {
+		switch field {
+		case FieldHash:
+			digest, err := hex.DecodeString(e.Hash)
+			if err != nil || len(digest) != size {
+				return nil, fmt.Errorf("its hash isn't a %d byte FSH24", size)
+			}
+			buf = append(buf, digest...)
+		case FieldChunks:
+			if e.Chunks < 1 {
+				return nil, errors.New("it has no chunk count")
+			}
+			buf = binary.AppendUvarint(buf, uint64(e.Chunks))
+		case FieldSize:
+			buf = binary.AppendVarint(buf, e.Size)
+		case FieldSHA256:
+			digest, err := hex.DecodeString(e.SHA256)
+			if err != nil || len(digest) != 32 {
+				return nil, errors.New("it has no SHA-256")
+			}
+			buf = append(buf, digest...)
+		case FieldMtime:
+			var nanos int64
+			if !e.ModTime.IsZero() {
+				nanos = e.ModTime.UnixNano()
+			}
+			buf = binary.AppendVarint(buf, nanos)
+		default:
+			text := formatColumn(e, field)
+			buf = binary.AppendUvarint(buf, uint64(len(text)))
+			buf = append(buf, text...)
+		}
+	}
+	return buf, nil
+}
+
+// binaryReader reads the parts of an FSH24-B file, the first error sticks.
+type binaryReader struct {
+	b   []byte
+	err error
+}
+
+func (r *binaryReader) fail(what string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s", errBrokenBinary, what)
+	}
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail("bad number")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.fail("bad number")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binaryReader) next(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.b)) {
+		r.fail("it ends too soon")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *binaryReader) text() string {
+	return string(r.next(r.uvarint()))
+}
+
+// parseManifestBinary reads an FSH24-B file, see the top of this file.
+func parseManifestBinary(content []byte) (*Manifest, error) {
+	r := &binaryReader{b: content[len(magicBinary):]}
+	header := r.text()
+	if r.err != nil {
+		return nil, r.err
+	}
+	m, err := parseManifestV2(strings.Split(header, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	m.Format = FormatBinary
+
+	count := r.uvarint()
+	fields := m.fields()
+	size := uint64(m.digestBytes())
+	m.Entries = make([]Entry, 0, min(count, uint64(len(r.b)))) // A broken count shouldn't eat all the memory
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		var e Entry
+		for _, field := range fields {
+			switch field {
+			case FieldHash:
+				e.Hash = strings.ToUpper(hex.EncodeToString(r.next(size)))
+			case FieldChunks:
+				if e.Chunks = int(r.uvarint()); e.Chunks < 1 {
+					r.fail("a file has no chunk count")
+				}
+			case FieldSize:
+				e.Size = r.varint()
+			case FieldSHA256:
+				e.SHA256 = strings.ToUpper(hex.EncodeToString(r.next(32)))
+			case FieldMtime:
+				if nanos := r.varint(); nanos != 0 {
+					e.ModTime = time.Unix(0, nanos).UTC()
+				}
+			default:
+				if status := e.parseColumn(field, r.text()); status != "" {
+					r.fail(fmt.Sprintf("the %s of a file is %s", field, status))
+				}
+			}
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !bytes.Equal(r.b, []byte("\n")) {
+		return nil, fmt.Errorf("%w: it doesn't end after the last file", errBrokenBinary)
+	}
+	return m, nil
+}
@@ -0,0 +1,78 @@
+package fsh24
+
+// The bsd format is the tagged one BSD md5/sha256 and openssl dgst write:
+//
+//	FSH24 (test/100MB.7z) = 4614fb52e03e2b62c99a4f2425e6e7fe85b9c31e77025358
+//
+// Same as gnu there is no header, chunk count or size, so it's verified with
+// whatever the Hasher is set to. Lines with another tag, like
+// "SHA256 (path) = hash", are plain checksums, see checksum.go.
+
+import (
+	"strings"
+)
+
+// bsdTag is the algorithm name at the start of every bsd line.
+const bsdTag = "FSH24"
+
+// formatLineBSD builds the "FSH24 (path) = hash" line for an entry.
+func formatLineBSD(e Entry) string {
+	tag := bsdTag
+	if e.Checksum != "" {
+		tag = checksumTag(e.Checksum, len(e.Hash))
+	}
+	return tag + " (" + e.Path + ") = " + strings.ToLower(e.Hash)
+}
+
+// parseLineBSD splits a bsd line into hash and path, and the checksum for
+// lines that aren't tagged FSH24.
+// The hash is after the last ") = " so a path with brackets in it still works.
+// openssl leaves out the space before the "(", that is accepted too.
+func parseLineBSD(line string) (hash, path, checksum string, ok bool) {
+	tag, rest, ok := strings.Cut(line, "(")
+	tag = strings.TrimSuffix(tag, " ")
+	if !ok {
+		return "", "", "", false
+	}
+
+	i := strings.LastIndex(rest, ")")
+	if i < 1 {
+		return "", "", "", false
+	}
+	path = rest[:i]
+	hash, ok = strings.CutPrefix(strings.TrimLeft(rest[i+1:], " "), "=")
+	hash = strings.TrimSpace(hash)
+	if !ok || len(hash) == 0 || len(hash)%2 != 0 || !isHex(hash) {
+		return "", "", "", false
+	}
+	if tag != bsdTag {
+		if checksum = checksumFromTag(tag, len(hash)); checksum == "" {
+			return "", "", "", false
+		}
+	}
+	return hash, path, checksum, true
+}
+
+// isLineBSD reports whether line looks like a bsd checksum line.
+func isLineBSD(line string) bool {
+	_, _, _, ok := parseLineBSD(line)
+	return ok
+}
+
+// parseManifestBSD reads a bsd checksum file already split into lines.
+func parseManifestBSD(lines []string) *Manifest {
+	m := &Manifest{Format: FormatBSD}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		hash, path, checksum, ok := parseLineBSD(line)
+		if !ok {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidLineFormat})
+			continue
+		}
+		m.Entries = append(m.Entries, Entry{Hash: hash, Size: -1, Path: path, Checksum: checksum})
+	}
+	return m
+}
@@ -0,0 +1,85 @@
+package fsh24
+
+// The gnu format is what md5sum, sha256sum and friends write and read with -c:
+//
+//	4614fb52e03e2b62c99a4f2425e6e7fe85b9c31e77025358  test/100MB.7z
+//
+// There is no header, chunk count or size, so verifying one of these uses
+// whatever the Hasher is set to and works the chunk count out from the file size.
+// Like coreutils, a path with a backslash or newline in it is escaped and the
+// line starts with a "\". NUL terminated files (sha256sum -z) aren't escaped.
+
+import (
+	"strings"
+)
+
+// formatLineGNU builds the "hash  path" line for an entry.
+func formatLineGNU(e Entry) string {
+	path := e.Path
+	prefix := ""
+	if strings.ContainsAny(path, "\\\n\r") {
+		prefix = "\\"
+		path = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(path)
+	}
+	return prefix + strings.ToLower(e.Hash) + "  " + path
+}
+
+// parseLineGNU splits a gnu line into hash and path.
+// The separator is two spaces, or " *" for files coreutils read in binary mode.
+func parseLineGNU(line string) (hash, path string, ok bool) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	hash, path, ok = strings.Cut(line, " ")
+	if !ok || len(hash) == 0 || len(hash)%2 != 0 || !isHex(hash) {
+		return "", "", false
+	}
+	if !strings.HasPrefix(path, " ") && !strings.HasPrefix(path, "*") {
+		return "", "", false
+	}
+	path = path[1:]
+	if path == "" {
+		return "", "", false
+	}
+
+	if escaped {
+		path = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r").Replace(path)
+	}
+	return hash, path, true
+}
+
+// isLineGNU reports whether line looks like a gnu checksum line.
+func isLineGNU(line string) bool {
+	_, _, ok := parseLineGNU(line)
+	return ok
+}
+
+// parseManifestGNU reads a gnu checksum file already split into lines.
+func parseManifestGNU(lines []string) *Manifest {
+	m := &Manifest{Format: FormatGNU}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		hash, path, ok := parseLineGNU(line)
+		if !ok {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidLineFormat})
+			continue
+		}
+		m.Entries = append(m.Entries, Entry{Hash: hash, Size: -1, Path: path})
+	}
+	return m
+}
+
+// isHex reports whether s is only hex digits.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package fsh24
+
+// .sfv (Simple File Verification) files are what a lot of ROM and scene
+// tools still use:
+//
+//	; Generated by fsh24
+//	test\100MB.7z 1A2B3C4D
+//
+// Every line is a path then the CRC32 of the whole file, lines starting
+// with ";" are comments. There are no FSH24 hashes in them, so checking
+// one reads every byte, no sampling.
+
+import (
+	"strings"
+)
+
+// headerSFV writes the comments as ";" lines.
+func (m *Manifest) headerSFV() string {
+	var b strings.Builder
+	for _, c := range m.Comments {
+		b.WriteString("; " + c + "\n")
+	}
+	return b.String()
+}
+
+// formatLineSFV builds the "path CRC32" line for an entry.
+func formatLineSFV(e Entry) string {
+	return e.Path + " " + strings.ToUpper(e.CRC32)
+}
+
+// parseLineSFV splits an sfv line into path and CRC32.
+// The CRC is after the last space, the path can have spaces in it.
+func parseLineSFV(line string) (path, crc string, ok bool) {
+	i := strings.LastIndexAny(line, " \t")
+	if i < 1 {
+		return "", "", false
+	}
+	path = strings.TrimRight(line[:i], " \t")
+	crc = line[i+1:]
+	if path == "" || len(crc) != 8 || !isHex(crc) {
+		return "", "", false
+	}
+	return path, crc, true
+}
+
+// isLineSFV reports whether line looks like an sfv line.
+func isLineSFV(line string) bool {
+	_, _, ok := parseLineSFV(line)
+	return ok
+}
+
+// parseManifestSFV reads an sfv file already split into lines.
+func parseManifestSFV(lines []string) *Manifest {
+	m := &Manifest{Format: FormatSFV}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ";") {
+			m.Comments = append(m.Comments, strings.TrimSpace(strings.TrimPrefix(line, ";")))
+			continue
+		}
+		path, crc, ok := parseLineSFV(line)
+		if !ok {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidLineFormat})
+			continue
+		}
+		m.Entries = append(m.Entries, Entry{CRC32: crc, Size: -1, Path: path})
+	}
+	return m
+}
@@ -0,0 +1,297 @@
+package fsh24
+
+// FSH24-2 is the structured version of the .fsh24 format:
+//
+//	FSH24-2
+//	algo=blake2b
+//	bytes=24
+//	sample=4194304
+//	mode=sample
+//	fields=hash|chunks|size|mtime|path
+//	created=2025-07-15T10:00:00Z
+//	# free text comment
+//	---
+//	4614FB52E03E2B62C99A4F2425E6E7FE85B9C31E77025358|4|104864215|2025-07-01T12:00:00Z|test\100MB.7z
+//
+// The header is a block of key=value lines ended by "---". Settings are always
+// written out, unknown keys are kept as metadata. The fields line names the
+// columns of every file line, path is always the last one so it can hold "|".
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MagicV2 is the header line of a version 2 .fsh24 file.
+const MagicV2 = "FSH24-2"
+
+// headerEndV2 ends the FSH24-2 header block.
+const headerEndV2 = "---"
+
+// MetaTool and MetaVersion are the FSH24-2 header keys for the program that
+// wrote the file and its version, so a reader can tell a file came from a
+// newer release than itself.
+const (
+	MetaTool    = "tool"
+	MetaVersion = "version"
+)
+
+// MetaIndex marks a hash file whose entries are other hash files, to be
+// verified in turn. Its value says what kind of index it is.
+const MetaIndex = "index"
+
+// FSH24-2 column names.
+const (
+	FieldHash    = "hash"
+	FieldChunks  = "chunks"
+	FieldSize    = "size"
+	FieldOffsets = "offsets"
+	FieldSHA256  = "sha256"
+	FieldMtime   = "mtime"
+	FieldMode    = "mode"
+	FieldOwner   = "owner"
+	FieldXattrs  = "xattrs"
+	FieldPath    = "path"
+)
+
+// fields returns the FSH24-2 column order for this manifest.
+func (m *Manifest) fields() []string {
+	if m.Fields != nil {
+		if m.Adaptive && !slices.Contains(m.Fields, FieldOffsets) {
+			// Read without it, then Add got files that have offsets
+			m.Fields = slices.Insert(m.Fields, len(m.Fields)-1, FieldOffsets)
+		}
+		return m.Fields
+	}
+	fields := []string{FieldHash, FieldChunks, FieldSize}
+	if m.Adaptive {
+		fields = append(fields, FieldOffsets)
+	}
+	if m.SHA256 {
+		fields = append(fields, FieldSHA256)
+	}
+	if m.Metadata {
+		fields = append(fields, FieldMode, FieldOwner, FieldXattrs)
+	}
+	return append(fields, FieldMtime, FieldPath)
+}
+
+// headerV2 builds the FSH24-2 header block, "---" line included.
+func (m *Manifest) headerV2() string {
+	var b strings.Builder
+	b.WriteString(MagicV2 + "\n")
+
+	for _, p := range m.Params() {
+		if p.Key == "sha256" {
+			continue // The fields line says that
+		}
+		if p.Key == "chain" {
+			continue // Only FSH24-1 files are chained
+		}
+		b.WriteString(p.Key + "=" + p.Value + "\n")
+	}
+	b.WriteString("fields=" + strings.Join(m.fields(), "|") + "\n")
+
+	keys := make([]string, 0, len(m.Meta))
+	for k := range m.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, m.Meta[k])
+	}
+	for _, c := range m.Comments {
+		b.WriteString("# " + c + "\n")
+	}
+	b.WriteString(headerEndV2 + "\n")
+	return b.String()
+}
+
+// formatLineV2 builds a file line in the column order of the fields header.
+func (m *Manifest) formatLineV2(e Entry) string {
+	fields := m.fields()
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = formatColumn(e, field)
+	}
+	return strings.Join(columns, "|")
+}
+
+// formatColumn is the text of one field of e.
+func formatColumn(e Entry, field string) string {
+	switch field {
+	case FieldHash:
+		return strings.ToUpper(e.Hash)
+	case FieldChunks:
+		return strconv.Itoa(e.Chunks)
+	case FieldSize:
+		return strconv.FormatInt(e.Size, 10)
+	case FieldOffsets:
+		return formatOffsets(e.Offsets)
+	case FieldSHA256:
+		return strings.ToUpper(e.SHA256)
+	case FieldMtime:
+		if !e.ModTime.IsZero() {
+			return e.ModTime.UTC().Format(time.RFC3339Nano)
+		}
+		return ""
+	case FieldMode:
+		return e.Mode
+	case FieldOwner:
+		return e.Owner
+	case FieldXattrs:
+		return formatXattrs(e.Xattrs)
+	case FieldPath:
+		return e.Path
+	default:
+		return e.Extra[field]
+	}
+}
+
+// parseManifestV2 reads an FSH24-2 file already split into lines.
+func parseManifestV2(lines []string) (*Manifest, error) {
+	m := &Manifest{Format: FormatFSH24v2}
+
+	// Header block
+	i := 1
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == headerEndV2 {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			m.Comments = append(m.Comments, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid FSH24-2 header line: %s", line)
+		}
+		if key == "fields" {
+			m.Fields = strings.Split(value, "|")
+			continue
+		}
+		known, err := m.SetParam(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			if m.Meta == nil {
+				m.Meta = map[string]string{}
+			}
+			m.Meta[key] = value
+		}
+	}
+	if i == len(lines) {
+		return nil, fmt.Errorf("invalid FSH24-2 file: header is not closed with %q", headerEndV2)
+	}
+
+	fields := m.fields()
+	for _, required := range []string{FieldHash, FieldChunks, FieldSize} {
+		if !slices.Contains(fields, required) {
+			return nil, fmt.Errorf("invalid FSH24-2 file: fields is missing %s", required)
+		}
+	}
+	if fields[len(fields)-1] != FieldPath {
+		return nil, fmt.Errorf("invalid FSH24-2 file: path has to be the last field")
+	}
+	m.SHA256 = slices.Contains(fields, FieldSHA256)
+	m.Metadata = slices.Contains(fields, FieldMode) || slices.Contains(fields, FieldOwner) || slices.Contains(fields, FieldXattrs)
+	m.Adaptive = slices.Contains(fields, FieldOffsets)
+
+	// File lines
+	for _, line := range lines[i+1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "|", len(fields))
+		if len(parts) != len(fields) {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: StatusInvalidLineFormat})
+			continue
+		}
+
+		entry, status := parseEntryV2(fields, parts)
+		if status != "" {
+			m.Invalid = append(m.Invalid, InvalidLine{Line: line, Status: status})
+			continue
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return m, nil
+}
+
+// parseEntryV2 fills an Entry from the columns of one line.
+// On failure it returns the StatusInvalid* value describing why.
+func parseEntryV2(fields, parts []string) (Entry, string) {
+	var entry Entry
+	for i, field := range fields {
+		if status := entry.parseColumn(field, parts[i]); status != "" {
+			return entry, status
+		}
+	}
+	return entry, ""
+}
+
+// parseColumn sets the field of e from its text, see formatColumn.
+// On failure it returns the StatusInvalid* value describing why.
+func (e *Entry) parseColumn(field, value string) string {
+	switch field {
+	case FieldHash:
+		e.Hash = value
+	case FieldChunks:
+		chunks, err := strconv.Atoi(value)
+		if err != nil || chunks < 1 {
+			return StatusInvalidChunksValue
+		}
+		e.Chunks = chunks
+	case FieldSize:
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return StatusInvalidFileSizeValue
+		}
+		e.Size = size
+	case FieldOffsets:
+		offsets, err := parseOffsets(value)
+		if err != nil {
+			return StatusInvalidLineFormat
+		}
+		e.Offsets = offsets
+	case FieldSHA256:
+		e.SHA256 = value
+	case FieldMtime:
+		if value != "" {
+			mtime, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return StatusInvalidLineFormat
+			}
+			e.ModTime = mtime
+		}
+	case FieldMode:
+		e.Mode = value
+	case FieldOwner:
+		e.Owner = value
+	case FieldXattrs:
+		xattrs, err := parseXattrs(value)
+		if err != nil {
+			return StatusInvalidLineFormat
+		}
+		e.Xattrs = xattrs
+	case FieldPath:
+		e.Path = value
+	default:
+		if e.Extra == nil {
+			e.Extra = map[string]string{}
+		}
+		e.Extra[field] = value
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+package fsh24
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MetaMerkle is the FSH24-2 header key the Merkle root of a manifest is
+// stored under, see MerkleTree.
+const MetaMerkle = "merkle"
+
+// ErrBadProof is returned by MerkleProof.Check when the proof doesn't lead
+// to its root.
+var ErrBadProof = errors.New("the proof doesn't lead to the Merkle root")
+
+// MerkleTree is a binary hash tree over the files of a manifest. The leaves
+// are the BLAKE2b-192 of a 0 byte and "HASH|path", sorted by path, and every
+// node above is the BLAKE2b-192 of a 1 byte and its two children. A level
+// with an odd number of nodes moves the last one up as it is.
+//
+// Publish the root and a file can be shown to be in the manifest with a
+// handful of hashes, see Proof, without handing out the whole manifest.
+type MerkleTree struct {
+	paths  []string   // Leaf order, slash separated
+	hashes []string   // FSH24 of every leaf, upper case
+	levels [][][]byte // levels[0] are the leaves, the last one is the root
+}
+
+// NewMerkleTree builds the tree over the entries of m that have a FSH24.
+func NewMerkleTree(m *Manifest) *MerkleTree {
+	t := &MerkleTree{}
+	entries := make([]Entry, 0, len(m.Entries))
+	for _, e := range m.Entries {
+		if e.Hash != "" {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return filepath.ToSlash(entries[i].Path) < filepath.ToSlash(entries[j].Path)
+	})
+
+	var level [][]byte
+	for _, e := range entries {
+		p, hash := filepath.ToSlash(e.Path), strings.ToUpper(e.Hash)
+		t.paths = append(t.paths, p)
+		t.hashes = append(t.hashes, hash)
+		level = append(level, merkleLeaf(p, hash))
+	}
+	t.levels = append(t.levels, level)
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i]) // Odd one out goes up as it is
+			} else {
+				next = append(next, merkleNode(level[i], level[i+1]))
+			}
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// merkleLeaf is the leaf hash of a file. The 0 and 1 in front keep a leaf
+// from passing for a node and the other way around.
+func merkleLeaf(path, hash string) []byte {
+	h, _ := blake2b.New(DefaultDigestBytes, nil)
+	h.Write([]byte{0})
+	h.Write([]byte(hash + "|" + path))
+	return h.Sum(nil)
+}
+
+// merkleNode is the hash of a node from its two children.
+func merkleNode(left, right []byte) []byte {
+	h, _ := blake2b.New(DefaultDigestBytes, nil)
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Len is how many files the tree has.
+func (t *MerkleTree) Len() int {
+	return len(t.paths)
+}
+
+// Root is the root hash in upper case hex, "" for a tree without files.
+func (t *MerkleTree) Root() string {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(top[0]))
+}
+
+// MerkleProof shows a file is in a manifest with a given Merkle root. It's
+// the file's hash and the hashes next to it on the way up to the root.
+type MerkleProof struct {
+	Root     string   `json:"root"`
+	Path     string   `json:"path"`
+	FSH24    string   `json:"fsh24"`
+	Index    int      `json:"index"`    // Where the file is in the sorted leaves
+	Leaves   int      `json:"leaves"`   // How many files the tree has, that gives its shape
+	Siblings []string `json:"siblings"` // Bottom up, levels where the node had no sibling are left out
+
+	// Settings are the manifest's hash settings, see Manifest.Params, so
+	// the file can be hashed again the same way to check it has FSH24.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Proof is the proof that the file at path is in the tree. path is the way
+// the manifest has it.
+func (t *MerkleTree) Proof(path string) (*MerkleProof, error) {
+	p := filepath.ToSlash(path)
+	i := sort.SearchStrings(t.paths, p)
+	if i == len(t.paths) || t.paths[i] != p {
+		return nil, fmt.Errorf("%s is not in the hash file", path)
+	}
+	proof := &MerkleProof{Root: t.Root(), Path: p, FSH24: t.hashes[i], Index: i, Leaves: t.Len(), Siblings: []string{}}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		if sibling := i ^ 1; sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, strings.ToUpper(hex.EncodeToString(level[sibling])))
+		}
+		i /= 2
+	}
+	return proof, nil
+}
+
+// Check works the root out again from the proof, ErrBadProof if it's not
+// the proof's Root. Compare Root with the one that was published too, a
+// proof made up from scratch checks out against its own made up root.
+func (p *MerkleProof) Check() error {
+	if p.Index < 0 || p.Index >= p.Leaves {
+		return fmt.Errorf("%w, file %d of %d", ErrBadProof, p.Index, p.Leaves)
+	}
+	node := merkleLeaf(p.Path, strings.ToUpper(p.FSH24))
+	i, n, next := p.Index, p.Leaves, 0
+	for n > 1 {
+		if sibling := i ^ 1; sibling < n {
+			if next >= len(p.Siblings) {
+				return fmt.Errorf("%w, it's missing hashes", ErrBadProof)
+			}
+			s, err := hex.DecodeString(p.Siblings[next])
+			if err != nil {
+				return fmt.Errorf("%w, bad hash %q", ErrBadProof, p.Siblings[next])
+			}
+			next++
+			if i%2 == 0 {
+				node = merkleNode(node, s)
+			} else {
+				node = merkleNode(s, node)
+			}
+		}
+		i, n = i/2, (n+1)/2
+	}
+	root, err := hex.DecodeString(p.Root)
+	if err != nil || next != len(p.Siblings) || !bytes.Equal(node, root) {
+		return ErrBadProof
+	}
+	return nil
+}
+
+// Manifest is an empty manifest with the proof's hash settings, for
+// Manifest.ApplySettings.
+func (p *MerkleProof) Manifest() (*Manifest, error) {
+	m := &Manifest{}
+	for _, key := range []string{"algo", "bytes", "sample", "mode", "keyed"} { // algo before bytes, bytes checks against it
+		if value, ok := p.Settings[key]; ok {
+			if _, err := m.SetParam(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// Diff lists the files that differ between t and other: changed, or only in
+// one of them. Trees with the same files in them are walked down from the
+// root, only into the parts whose hashes differ, so a few changed files in
+// a big tree are found without going through all of it.
+func (t *MerkleTree) Diff(other *MerkleTree) []string {
+	if slices.Equal(t.paths, other.paths) {
+		var changed []string
+		var walk func(level, i int)
+		walk = func(level, i int) {
+			if bytes.Equal(t.levels[level][i], other.levels[level][i]) {
+				return
+			}
+			if level == 0 {
+				changed = append(changed, t.paths[i])
+				return
+			}
+			// The children of i, or the one node moved up from below
+			below := len(t.levels[level-1])
+			for c := 2 * i; c < min(2*i+2, below); c++ {
+				walk(level-1, c)
+			}
+		}
+		if t.Len() > 0 {
+			walk(len(t.levels)-1, 0)
+		}
+		return changed
+	}
+
+	// Files were added or taken out, the trees have different shapes
+	hashes := make(map[string]string, other.Len())
+	for i, p := range other.paths {
+		hashes[p] = other.hashes[i]
+	}
+	var changed []string
+	for i, p := range t.paths {
+		if hash, ok := hashes[p]; !ok || hash != t.hashes[i] {
+			changed = append(changed, p)
+		}
+		delete(hashes, p)
+	}
+	for p := range hashes {
+		changed = append(changed, p)
+	}
+	sort.Strings(changed)
+	return changed
+}
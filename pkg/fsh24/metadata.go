@@ -0,0 +1,135 @@
+package fsh24
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Metadata is what Hasher.Metadata records about a file besides its
+// content. Empty fields weren't recorded, or the system doesn't have them
+// (there are no owners or xattrs on Windows).
+type Metadata struct {
+	// Mode is the unix permission bits in octal, setuid and friends included, eg. "0644".
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+
+	// Owner is the numeric owner and group, "1000:1000".
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	// Xattrs are the extended attributes by name.
+	Xattrs map[string][]byte `json:"xattrs,omitempty" yaml:"xattrs,omitempty"`
+}
+
+// IsZero reports whether nothing was recorded.
+func (md Metadata) IsZero() bool {
+	return md.Mode == "" && md.Owner == "" && len(md.Xattrs) == 0
+}
+
+// ReadMetadata reads the metadata of a local file, following symlinks like
+// hashing does. Attributes the filesystem can't have are left empty.
+func ReadMetadata(path string) (Metadata, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	md := Metadata{Mode: formatMode(fi.Mode()), Owner: fileOwner(fi)}
+	md.Xattrs, err = readXattrs(path)
+	if err != nil {
+		return md, fmt.Errorf("failed to read xattrs of %s: %w", path, err)
+	}
+	return md, nil
+}
+
+// formatMode is the permission bits of mode the way chmod takes them.
+func formatMode(mode fs.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// Changes lists how now differs from md, eg. "mode 0644 -> 0600" or
+// "xattr user.origin removed". Only what md recorded is compared, a
+// manifest without owners doesn't complain about them.
+func (md Metadata) Changes(now Metadata) []string {
+	var changes []string
+	if md.Mode != "" && md.Mode != now.Mode {
+		changes = append(changes, fmt.Sprintf("mode %s -> %s", md.Mode, now.Mode))
+	}
+	if md.Owner != "" && md.Owner != now.Owner {
+		changes = append(changes, fmt.Sprintf("owner %s -> %s", md.Owner, now.Owner))
+	}
+	if md.Xattrs != nil {
+		names := slices.Sorted(maps.Keys(md.Xattrs))
+		for name := range now.Xattrs {
+			if _, ok := md.Xattrs[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			was, had := md.Xattrs[name]
+			is, has := now.Xattrs[name]
+			switch {
+			case !has:
+				changes = append(changes, "xattr "+name+" removed")
+			case !had:
+				changes = append(changes, "xattr "+name+" added")
+			case string(was) != string(is):
+				changes = append(changes, "xattr "+name+" changed")
+			}
+		}
+	}
+	return changes
+}
+
+// formatXattrs is the FSH24-2 xattrs column, name=base64 pairs url encoded
+// so nothing in them can be taken for a "|". Files without any get "-",
+// an empty column is one that wasn't recorded.
+func formatXattrs(xattrs map[string][]byte) string {
+	if xattrs == nil {
+		return ""
+	}
+	if len(xattrs) == 0 {
+		return "-"
+	}
+	values := url.Values{}
+	for name, value := range xattrs {
+		values.Set(name, base64.StdEncoding.EncodeToString(value))
+	}
+	return values.Encode()
+}
+
+// parseXattrs reads the FSH24-2 xattrs column back.
+func parseXattrs(column string) (map[string][]byte, error) {
+	if column == "" {
+		return nil, nil
+	}
+	xattrs := map[string][]byte{}
+	if column == "-" {
+		return xattrs, nil
+	}
+	values, err := url.ParseQuery(column)
+	if err != nil {
+		return nil, err
+	}
+	for name, v := range values {
+		value, err := base64.StdEncoding.DecodeString(strings.Join(v, ""))
+		if err != nil {
+			return nil, err
+		}
+		xattrs[name] = value
+	}
+	return xattrs, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fsh24
+
+import "io/fs"
+
+// fileOwner has no uid and gid to give here.
+func fileOwner(fi fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package fsh24
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner is "uid:gid" of fi.
+func fileOwner(fi fs.FileInfo) string {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(st.Uid), 10) + ":" + strconv.FormatUint(uint64(st.Gid), 10)
+}
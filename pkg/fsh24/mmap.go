@@ -0,0 +1,85 @@
+package fsh24
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"runtime/debug"
+)
+
+// mappedFile is a local file mapped into memory, see Hasher.Mmap. The
+// samples are hashed straight out of data, no reading into a buffer first.
+type mappedFile struct {
+	*localFile
+	data   []byte
+	unmap  func() error
+	closed bool
+}
+
+// mapFile maps f into memory. Empty files and files too big for the address
+// space (on 32 bit) aren't mapped, that's an error and f is read normally.
+func mapFile(f *localFile) (*mappedFile, error) {
+	size := f.Size()
+	if size <= 0 || size > math.MaxInt {
+		return nil, fmt.Errorf("can't map %d bytes", size)
+	}
+	data, unmap, err := mmap(f.File, int(size))
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{localFile: f, data: data, unmap: unmap}, nil
+}
+
+func (f *mappedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *mappedFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.unmap()
+	if closeErr := f.localFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// hashMapped writes the spans of a mapped file to w, a sample size at a
+// time so cancelling ctx still stops a --full hash of a big file quickly.
+// A file cut shorter while it's mapped makes reading past its new end a
+// SIGBUS, that comes back as an error instead of taking everything down.
+func hashMapped(ctx context.Context, w io.Writer, data []byte, spans []span, step int64) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, fault := r.(interface{ Addr() uintptr }); !fault {
+				panic(r)
+			}
+			err = fmt.Errorf("file changed while it was being hashed: %v", r)
+		}
+	}()
+
+	for _, sp := range spans {
+		end := min(sp.off+sp.n, int64(len(data)))
+		for off := sp.off; off < end; off += step {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			w.Write(data[off:min(off+step, end)])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package fsh24
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap isn't a thing here, files are always read normally.
+func mmap(f *os.File, size int) (data []byte, unmap func() error, err error) {
+	return nil, nil, errors.New("memory mapped files are not supported on this system")
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package fsh24
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// isNetworkFS reports whether path is on a network share.
+func isNetworkFS(path string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false
+	}
+	switch strings.TrimRight(string(st.Fstypename[:]), "\x00") {
+	case "smbfs", "nfs", "afpfs", "webdav", "cifs":
+		return true
+	}
+	return false
+}
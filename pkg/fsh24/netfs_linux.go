@@ -0,0 +1,27 @@
+//go:build linux
+
+package fsh24
+
+import "golang.org/x/sys/unix"
+
+// networkFS are the filesystem types of network shares, by their statfs magic.
+// FUSE is left out, it's sshfs and rclone but also ntfs-3g on a local disk.
+var networkFS = map[int64]bool{
+	unix.NFS_SUPER_MAGIC:  true,
+	unix.SMB_SUPER_MAGIC:  true,
+	unix.SMB2_SUPER_MAGIC: true,
+	unix.CIFS_SUPER_MAGIC: true,
+	unix.AFS_SUPER_MAGIC:  true,
+	unix.CEPH_SUPER_MAGIC: true,
+	unix.CODA_SUPER_MAGIC: true,
+	unix.V9FS_MAGIC:       true,
+}
+
+// isNetworkFS reports whether path is on a network share.
+func isNetworkFS(path string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false
+	}
+	return networkFS[int64(uint32(st.Type))]
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package fsh24
+
+// isNetworkFS can't tell on this system, use NetOn for network shares.
+func isNetworkFS(path string) bool {
+	return false
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package fsh24
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// isNetworkFS reports whether path is on a network share, a \\server\share
+// path or a mapped drive.
+func isNetworkFS(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	volume := filepath.VolumeName(abs)
+	if strings.HasPrefix(volume, `\\?\UNC\`) {
+		return true
+	}
+	volume = strings.TrimPrefix(volume, `\\?\`) // Long path form of a drive
+	if strings.HasPrefix(volume, `\\`) {
+		return true
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
@@ -0,0 +1,140 @@
+package fsh24
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// NetMode picks how a Hasher reads files, see Hasher.NetMode.
+// It never changes the hash, only how the bytes are fetched.
+type NetMode int
+
+const (
+	// NetAuto uses net mode for files on network shares (SMB, NFS and so on)
+	// and remote URLs, and reads local disks the normal way.
+	NetAuto NetMode = iota
+
+	// NetOn always uses net mode.
+	NetOn
+
+	// NetOff never does, every sample is its own read.
+	NetOff
+)
+
+// NetModes are the names ParseNetMode takes.
+var NetModes = []string{"auto", "on", "off"}
+
+func (m NetMode) String() string {
+	if m < 0 || int(m) >= len(NetModes) {
+		return fmt.Sprintf("NetMode(%d)", int(m))
+	}
+	return NetModes[m]
+}
+
+// ParseNetMode turns auto, on or off into a NetMode.
+func ParseNetMode(s string) (NetMode, error) {
+	for i, name := range NetModes {
+		if s == name {
+			return NetMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown net mode %q, use auto, on or off", s)
+}
+
+const (
+	// netGap is how far apart samples can be and still be read together in
+	// net mode. Over a network every read is a round trip, reading the bytes
+	// in between and throwing them away is quicker than asking again.
+	netGap = 16 * 1024 * 1024 // 16MB
+
+	// netMaxRead caps a read of samples put together, memory is still a thing.
+	netMaxRead = 64 * 1024 * 1024 // 64MB
+
+	// retryDelay is the wait before the first retry of a failed read,
+	// doubling every time after.
+	retryDelay = time.Second
+)
+
+// useNet reports whether the file at path is read in net mode.
+func (h *Hasher) useNet(path string) bool {
+	switch h.NetMode {
+	case NetOn:
+		return true
+	case NetOff:
+		return false
+	}
+	if archive, _, ok := SplitArchivePath(path); ok {
+		path = archive // Reads of a file in it go to the archive
+	}
+	return IsRemote(path) || isNetworkFS(path)
+}
+
+// open opens a file for hashing, waiting for it if it's locked, see
+// openLocked. In net mode with Retries reads that fail
+// are tried again, see retryFile. With Mmap local files are mapped, see mappedFile,
+// with IOUring they are read through io_uring and with Direct around the cache.
+func (h *Hasher) open(ctx context.Context, path string) (File, bool, error) {
+	f, err := h.openLocked(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	net := h.useNet(path)
+	if net && h.Retries > 0 {
+		f = &retryFile{File: f, ctx: ctx, path: path, retries: h.Retries}
+	}
+	if local, ok := f.(*localFile); ok && !net {
+		if h.Mmap {
+			if m, err := mapFile(local); err == nil {
+				f = m
+			}
+		} else if h.IOUring {
+			f = &uringFile{local}
+		} else if h.Direct {
+			if d, err := openDirect(local); err == nil {
+				f = d
+			}
+		}
+	}
+	return f, net, nil
+}
+
+// retryFile tries failed reads again, waiting retryDelay, then twice that
+// and so on. Before each retry the file is opened again, after a network
+// share drops out the old handle is often no good any more.
+// It's not safe for reads at the same time, hashing doesn't do that.
+type retryFile struct {
+	File
+	ctx     context.Context
+	path    string
+	retries int
+}
+
+func (f *retryFile) ReadAt(p []byte, off int64) (int, error) {
+	delay := retryDelay
+	for try := 0; ; try++ {
+		n, err := f.File.ReadAt(p, off)
+		if err == nil || err == io.EOF || try >= f.retries || f.ctx.Err() != nil {
+			return n, err
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return n, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		reopened, openErr := Open(f.ctx, f.path)
+		if openErr != nil {
+			continue // Still gone, maybe next time
+		}
+		if reopened.Size() != f.File.Size() {
+			reopened.Close()
+			return n, fmt.Errorf("%w, and the file changed size since", err)
+		}
+		f.File.Close()
+		f.File = reopened
+	}
+}
@@ -0,0 +1,74 @@
+package fsh24
+
+import (
+	"errors"
+	"strings"
+)
+
+// PathMap rewrites the paths of a manifest that start with From to start
+// with To instead, for checking files that live somewhere else now, like a
+// hash file of D:\Archive made on Windows checked on the Linux box it's
+// mounted on as /mnt/archive.
+type PathMap struct {
+	From string
+	To   string
+}
+
+// ParsePathMap reads a "from=to" rule, D:\Archive=/mnt/archive.
+func ParsePathMap(rule string) (PathMap, error) {
+	from, to, ok := strings.Cut(rule, "=")
+	if !ok || from == "" {
+		return PathMap{}, errors.New("path map has to be from=to, eg. D:\\Archive=/mnt/archive")
+	}
+	return PathMap{From: from, To: to}, nil
+}
+
+// Apply rewrites path if it's From or in it. / and \ are the same, and so
+// are upper and lower case when From is a Windows path. The rest of the
+// path gets To's slashes.
+func (m PathMap) Apply(path string) (string, bool) {
+	from := strings.TrimRight(strings.ReplaceAll(m.From, `\`, "/"), "/")
+	p := strings.ReplaceAll(path, `\`, "/")
+	if from == "" { // From is /, anything absolute
+		if !strings.HasPrefix(p, "/") {
+			return "", false
+		}
+	} else if len(p) < len(from) {
+		return "", false
+	}
+	prefix, rest := p[:len(from)], p[len(from):]
+	if prefix != from && !(windowsPath(m.From) && strings.EqualFold(prefix, from)) {
+		return "", false
+	}
+	if rest != "" && rest[0] != '/' && !strings.HasSuffix(from, ":") {
+		return "", false // D:\Archive2 isn't in D:\Archive
+	}
+	rest = strings.TrimLeft(rest, "/")
+	if rest == "" {
+		return m.To, true
+	}
+	sep := "/"
+	if strings.Contains(m.To, `\`) && !IsRemote(m.To) {
+		sep = `\`
+	}
+	if rest = strings.ReplaceAll(rest, "/", sep); m.To == "" {
+		return rest, true
+	}
+	return strings.TrimRight(m.To, `/\`) + sep + rest, true
+}
+
+// windowsPath reports whether p looks like a Windows path, C:\ or \\server.
+func windowsPath(p string) bool {
+	return len(p) >= 2 && p[1] == ':' || strings.HasPrefix(p, `\\`)
+}
+
+// MapPath rewrites path with the first of maps it's in, path as it is if
+// it's in none of them.
+func MapPath(maps []PathMap, path string) string {
+	for _, m := range maps {
+		if mapped, ok := m.Apply(path); ok {
+			return mapped
+		}
+	}
+	return path
+}
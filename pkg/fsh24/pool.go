@@ -0,0 +1,26 @@
+package fsh24
+
+import "sync"
+
+// buffers are the read buffers of finished hashes, for the next one to use.
+// A fresh 4MB buffer for every file adds up quick with millions of small
+// files, the GC spends more time cleaning up after us than we spend hashing.
+// Holds *[]byte, a plain []byte would allocate going in and out of the pool.
+var buffers sync.Pool
+
+// getBuffer returns a buffer of n bytes, from the pool if one there is big
+// enough. Give it back with putBuffer once nothing uses it anymore.
+func getBuffer(n int) *[]byte {
+	if b, ok := buffers.Get().(*[]byte); ok && cap(*b) >= n {
+		*b = (*b)[:n]
+		return b
+	}
+	// Too small ones get dropped, the pool ends up with the size in use
+	b := make([]byte, n)
+	return &b
+}
+
+// putBuffer gives a buffer from getBuffer back to the pool.
+func putBuffer(b *[]byte) {
+	buffers.Put(b)
+}
@@ -0,0 +1,196 @@
+package fsh24
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File is an open file to hash, on the local disk or somewhere an Opener can reach.
+// Remote ones only fetch the bytes ReadAt asks for, which is the whole point:
+// a sampled hash of a 50GB file needs a few MB of it.
+type File interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	ModTime() time.Time // Zero when unknown
+}
+
+// Opener opens the files of a URL scheme, see RegisterScheme.
+// A file that isn't there should give an error that wraps fs.ErrNotExist,
+// verifying reports it as missing rather than as a read error.
+type Opener interface {
+	Open(ctx context.Context, url string) (File, error)
+}
+
+// Lister is an Opener that can also list files, for schemes like s3:// where
+// a URL can be a folder: the bucket itself, or a prefix ending in a /.
+type Lister interface {
+	Opener
+
+	// List returns the URLs of every file under folder, sub folders included.
+	List(ctx context.Context, folder string) ([]string, error)
+}
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]Opener{}
+)
+
+// RegisterScheme makes paths starting with scheme:// open through o, for
+// hashing, verifying and everything else that takes a path in this package.
+// Nothing is registered by default, see HTTPOpener.
+func RegisterScheme(scheme string, o Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[strings.ToLower(scheme)] = o
+}
+
+// opener returns the Opener for path, nil for local files.
+func opener(path string) Opener {
+	scheme, _, ok := strings.Cut(path, "://")
+	if !ok {
+		return nil
+	}
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	return openers[strings.ToLower(scheme)]
+}
+
+// IsRemote reports whether path is a URL with a registered scheme rather than a local file.
+func IsRemote(path string) bool {
+	return opener(path) != nil
+}
+
+// IsRemoteFolder reports whether url is a folder of a scheme that can be
+// listed, see Lister. Those end in a / or have nothing after the host.
+func IsRemoteFolder(url string) bool {
+	if _, ok := opener(url).(Lister); !ok {
+		return false
+	}
+	_, rest, _ := strings.Cut(url, "://")
+	return strings.HasSuffix(rest, "/") || !strings.Contains(rest, "/")
+}
+
+// List returns the URLs of every file under a remote folder, see IsRemoteFolder.
+func List(ctx context.Context, folder string) ([]string, error) {
+	l, ok := opener(folder).(Lister)
+	if !ok {
+		return nil, fmt.Errorf("can't list %s", folder)
+	}
+	return l.List(ctx, folder)
+}
+
+// Open opens a local file or a URL with a registered scheme, or a file in
+// an archive, see ArchiveSep. The context is the one the file's reads
+// happen under, for remote files.
+func Open(ctx context.Context, path string) (File, error) {
+	f, err := open(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A file really named "a.zip!b" comes first, there's no telling otherwise
+		if archive, member, ok := SplitArchivePath(path); ok {
+			return openMember(ctx, archive, member)
+		}
+	}
+	return f, err
+}
+
+// open is Open without looking into archives.
+func open(ctx context.Context, path string) (File, error) {
+	if o := opener(path); o != nil {
+		return o.Open(ctx, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if isDevicePath(path) { // Stat doesn't work on these at all
+		return openDevice(f)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Mode()&fs.ModeDevice != 0 {
+		return openDevice(f)
+	}
+	return &localFile{File: f, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+// openDevice makes a File of a disk or partition, /dev/sdb or
+// \\.\PhysicalDrive1. Stat says 0 bytes for those, the size has to come
+// from the OS. Devices have no modified time worth keeping.
+func openDevice(f *os.File) (File, error) {
+	size, err := deviceSize(f)
+	if err == nil && size <= 0 {
+		err = errors.New("it says it's empty")
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("can't tell the size of %s: %w", f.Name(), err)
+	}
+	return wrapDevice(&localFile{File: f, size: size}), nil
+}
+
+// IsDevice reports whether path is a disk, partition or other device rather
+// than a file. Folders are walked without them, they're hashed when named.
+func IsDevice(path string) bool {
+	if IsRemote(path) {
+		return false
+	} else if isDevicePath(path) {
+		return true
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&fs.ModeDevice != 0
+}
+
+// localFile is a File on the local disk.
+type localFile struct {
+	*os.File
+	size    int64
+	modTime time.Time
+}
+
+func (f *localFile) Size() int64 { return f.size }
+
+func (f *localFile) ModTime() time.Time { return f.modTime }
+
+// JoinPath joins a relative manifest path to dir, which can be a URL.
+func JoinPath(dir, rel string) string {
+	if IsRemote(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	}
+	return filepath.Join(dir, rel)
+}
+
+// baseName is the file name at the end of a path or URL, or of the path
+// inside an archive.
+func baseName(p string) string {
+	if _, member, ok := SplitArchivePath(p); ok {
+		return path.Base(member)
+	}
+	if IsRemote(p) {
+		p, _, _ = strings.Cut(p, "?")
+		return path.Base(p)
+	}
+	return filepath.Base(p)
+}
+
+// notFound is the error for a remote file that isn't there.
+func notFound(url string) error {
+	return &fs.PathError{Op: "open", Path: url, Err: fs.ErrNotExist}
+}
+
+// remoteError is the error for anything else a server said no to.
+func remoteError(url, status string) error {
+	return fmt.Errorf("%s answered %s", url, status)
+}
@@ -0,0 +1,120 @@
+package fsh24
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GCSOpener opens gs://bucket/object URLs on Google Cloud Storage, reading
+// objects with ranged GETs, and lists gs://bucket/prefix/ folders. Register it with
+//
+//	fsh24.RegisterScheme("gs", fsh24.GCSOpener{})
+//
+// The access token comes from GOOGLE_OAUTH_ACCESS_TOKEN, eg. set to the output
+// of gcloud auth print-access-token. Without one the requests are anonymous,
+// which is fine for public buckets.
+type GCSOpener struct {
+	// Client makes the requests, nil means http.DefaultClient.
+	Client *http.Client
+
+	// Endpoint is where the API is, empty means STORAGE_EMULATOR_HOST (the
+	// same as the official libraries use for the emulator) or Google itself.
+	Endpoint string
+}
+
+// Open reads the first byte of the object to find out its size, and that it's there.
+func (o GCSOpener) Open(ctx context.Context, gsURL string) (File, error) {
+	bucket, object, err := splitBucketURL(gsURL, "gs")
+	if err != nil {
+		return nil, err
+	}
+	if object == "" || strings.HasSuffix(object, "/") {
+		return nil, fmt.Errorf("%s is a folder, not an object", gsURL)
+	}
+	reqURL := o.endpoint() + "/" + url.PathEscape(bucket) + "/" + escapePath(object)
+	return openRanged(ctx, o.Client, gsURL, reqURL, o.sign)
+}
+
+// List returns every object under the prefix with the JSON API, a page of
+// up to 1000 at a time.
+func (o GCSOpener) List(ctx context.Context, folder string) ([]string, error) {
+	bucket, prefix, err := splitBucketURL(folder, "gs")
+	if err != nil {
+		return nil, err
+	}
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var (
+		files []string
+		token string
+	)
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		reqURL := o.endpoint() + "/storage/v1/b/" + url.PathEscape(bucket) + "/o?" + query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		o.sign(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items         []struct{ Name string }
+			NextPageToken string
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			err = notFound(folder)
+		case resp.StatusCode != http.StatusOK:
+			err = remoteError(folder, resp.Status)
+		default:
+			if err = json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				err = fmt.Errorf("bad listing from %s: %w", folder, err)
+			}
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			if !strings.HasSuffix(item.Name, "/") { // Empty "folder" objects the console makes
+				files = append(files, "gs://"+bucket+"/"+item.Name)
+			}
+		}
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// endpoint is the base URL of the API, without a trailing slash.
+func (o GCSOpener) endpoint() string {
+	endpoint := cmp.Or(o.Endpoint, os.Getenv("STORAGE_EMULATOR_HOST"), "https://storage.googleapis.com")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint // The emulator variable is just host:port
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// sign adds the access token to req, if there is one.
+func (o GCSOpener) sign(req *http.Request) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
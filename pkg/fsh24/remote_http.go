@@ -0,0 +1,142 @@
+package fsh24
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPOpener opens http:// and https:// URLs, reading them with Range requests
+// so only the sampled bytes come over the network. Register it with
+//
+//	fsh24.RegisterScheme("http", fsh24.HTTPOpener{})
+//	fsh24.RegisterScheme("https", fsh24.HTTPOpener{})
+//
+// The server has to support Range requests, most static file servers and
+// mirrors do. A user:password@ in the URL is sent as basic auth.
+type HTTPOpener struct {
+	// Client makes the requests, nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// ErrNoRanges is returned for servers that answer Range requests with the whole file.
+var ErrNoRanges = errors.New("server doesn't support range requests")
+
+// Open asks for the first byte of url to find out its size, and that it's there.
+func (o HTTPOpener) Open(ctx context.Context, url string) (File, error) {
+	return openRanged(ctx, o.Client, url, url, nil)
+}
+
+// openRanged opens a file read with Range requests to url, which is name
+// as far as error messages go. sign, if set, adds the authentication to every request.
+func openRanged(ctx context.Context, client *http.Client, name, url string, sign func(*http.Request)) (File, error) {
+	f := &httpFile{ctx: ctx, client: client, name: name, url: url, sign: sign}
+	if f.client == nil {
+		f.client = http.DefaultClient
+	}
+
+	resp, err := f.get("bytes=0-0", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if f.size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s: no size in Content-Range %q", name, resp.Header.Get("Content-Range"))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		f.size = 0 // Not even a first byte, an empty file
+	case http.StatusOK:
+		return nil, fmt.Errorf("%s: %w", name, ErrNoRanges)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, notFound(name)
+	default:
+		return nil, remoteError(name, resp.Status)
+	}
+
+	f.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	// A weak ETag never matches If-Match, those files just don't get the check
+	if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		f.etag = etag
+	}
+	return f, nil
+}
+
+// httpFile is a File read over HTTP, one Range request per ReadAt.
+type httpFile struct {
+	ctx     context.Context
+	client  *http.Client
+	name    string // What the user gave, s3://bucket/key say
+	url     string // What gets requested
+	sign    func(*http.Request)
+	size    int64
+	modTime time.Time
+	etag    string // Sent with every read, so a file replaced halfway fails rather than mixing versions
+}
+
+func (f *httpFile) Size() int64 { return f.size }
+
+func (f *httpFile) ModTime() time.Time { return f.modTime }
+
+func (f *httpFile) Close() error { return nil }
+
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), f.size)
+	if end == off {
+		return 0, nil
+	}
+
+	resp, err := f.get(fmt.Sprintf("bytes=%d-%d", off, end-1), f.etag)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusPreconditionFailed:
+		return 0, fmt.Errorf("%s changed while reading it", f.name)
+	case http.StatusOK:
+		return 0, fmt.Errorf("%s: %w", f.name, ErrNoRanges)
+	default:
+		return 0, remoteError(f.name, resp.Status)
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-off])
+	if err != nil {
+		return n, fmt.Errorf("reading %s: %w", f.name, err)
+	}
+	if end < off+int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// get sends a GET for a byte range of the file.
+func (f *httpFile) get(byteRange, ifMatch string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", byteRange)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	// No gzip, the byte offsets have to be into the file itself
+	req.Header.Set("Accept-Encoding", "identity")
+	if f.sign != nil {
+		f.sign(req)
+	}
+	return f.client.Do(req)
+}
@@ -0,0 +1,382 @@
+package fsh24
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Opener opens s3://bucket/key URLs, reading objects with ranged GETs, and
+// lists s3://bucket/prefix/ folders. Register it with
+//
+//	fsh24.RegisterScheme("s3", &fsh24.S3Opener{})
+//
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, or the AWS_PROFILE (default "default") profile in
+// ~/.aws/credentials. Without any the requests aren't signed, which is
+// fine for public buckets.
+type S3Opener struct {
+	// Client makes the requests, nil means http.DefaultClient.
+	Client *http.Client
+
+	// Endpoint is an S3 compatible service to use instead of AWS, like MinIO
+	// at http://localhost:9000. Empty means AWS_ENDPOINT_URL, or AWS itself.
+	Endpoint string
+
+	// Region is where the buckets are. Empty means AWS_REGION or
+	// AWS_DEFAULT_REGION, or asking AWS for each bucket.
+	Region string
+
+	once    sync.Once
+	creds   *awsCredentials
+	credErr error
+
+	mu      sync.Mutex
+	regions map[string]string // By bucket, looked up
+}
+
+// awsCredentials sign requests with AWS Signature Version 4.
+type awsCredentials struct {
+	AccessKey, SecretKey, SessionToken string
+}
+
+// emptySHA256 is the payload hash of a request without a body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Open reads the first byte of the object to find out its size, and that it's there.
+func (o *S3Opener) Open(ctx context.Context, s3URL string) (File, error) {
+	bucket, key, err := splitBucketURL(s3URL, "s3")
+	if err != nil {
+		return nil, err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("%s is a folder, not an object", s3URL)
+	}
+	reqURL, sign, err := o.request(ctx, bucket, "/"+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return openRanged(ctx, o.Client, s3URL, reqURL, sign)
+}
+
+// List returns every object under the prefix with ListObjectsV2, a page of
+// up to 1000 at a time.
+func (o *S3Opener) List(ctx context.Context, folder string) ([]string, error) {
+	bucket, prefix, err := splitBucketURL(folder, "s3")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		files []string
+		token string
+	)
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		reqURL, sign, err := o.request(ctx, bucket, "/", query)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents              []struct{ Key string }
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := getXML(ctx, o.client(), folder, reqURL, sign, &page); err != nil {
+			return nil, err
+		}
+		for _, c := range page.Contents {
+			if !strings.HasSuffix(c.Key, "/") { // Empty "folder" objects the console makes
+				files = append(files, "s3://"+bucket+"/"+c.Key)
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (o *S3Opener) client() *http.Client {
+	if o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
+
+// request works out the URL for path in bucket, path style for custom
+// endpoints and virtual host style for AWS, and the signing for it.
+func (o *S3Opener) request(ctx context.Context, bucket, path string, query map[string]string) (string, func(*http.Request), error) {
+	o.once.Do(func() { o.creds, o.credErr = loadAWSCredentials() })
+	if o.credErr != nil {
+		return "", nil, o.credErr
+	}
+
+	endpoint := cmp.Or(o.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	region := cmp.Or(o.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	var base string
+	if endpoint != "" {
+		base = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+		region = cmp.Or(region, "us-east-1")
+	} else {
+		if region == "" {
+			var err error
+			if region, err = o.bucketRegion(ctx, bucket); err != nil {
+				return "", nil, err
+			}
+		}
+		base = "https://" + bucket + ".s3." + region + ".amazonaws.com"
+		if strings.Contains(bucket, ".") { // Would break the TLS certificate
+			base = "https://s3." + region + ".amazonaws.com/" + bucket
+		}
+	}
+
+	reqURL := base + escapePath(path)
+	if q := awsQuery(query); q != "" {
+		reqURL += "?" + q
+	}
+	var sign func(*http.Request)
+	if o.creds != nil {
+		sign = func(req *http.Request) { o.creds.sign(req, region, "s3", time.Now()) }
+	}
+	return reqURL, sign, nil
+}
+
+// bucketRegion asks AWS which region a bucket is in. It answers in a header
+// even when we aren't allowed to see the bucket.
+func (o *S3Opener) bucketRegion(ctx context.Context, bucket string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if region, ok := o.regions[bucket]; ok {
+		return region, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://s3.amazonaws.com/"+url.PathEscape(bucket), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	region := resp.Header.Get("X-Amz-Bucket-Region")
+	if region == "" {
+		if resp.StatusCode == http.StatusNotFound {
+			return "", notFound("s3://" + bucket)
+		}
+		return "", fmt.Errorf("could not find the region of bucket %s (%s), set AWS_REGION", bucket, resp.Status)
+	}
+	if o.regions == nil {
+		o.regions = map[string]string{}
+	}
+	o.regions[bucket] = region
+	return region, nil
+}
+
+// loadAWSCredentials reads the credentials from the environment, or the
+// shared credentials file. No credentials at all is nil, not an error.
+func loadAWSCredentials() (*awsCredentials, error) {
+	if key := os.Getenv("AWS_ACCESS_KEY_ID"); key != "" {
+		return &awsCredentials{
+			AccessKey:    key,
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		file = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profile := cmp.Or(os.Getenv("AWS_PROFILE"), "default")
+	var (
+		creds   awsCredentials
+		section string
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", file, err)
+	}
+	if creds.AccessKey == "" {
+		return nil, nil
+	}
+	return &creds, nil
+}
+
+// sign adds a Signature Version 4 Authorization header to req, which has no body.
+// The host, the range and every x-amz- header are signed.
+func (c *awsCredentials) sign(req *http.Request, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery, // Built by awsQuery, already canonical
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent encodes everything but the unreserved characters, the
+// way Signature Version 4 wants it.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// escapePath is awsEscape for a path, leaving the slashes.
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = awsEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// awsQuery builds a query string sorted and escaped the way it's signed.
+func awsQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = awsEscape(k) + "=" + awsEscape(query[k])
+	}
+	return strings.Join(parts, "&")
+}
+
+// getXML GETs reqURL and decodes the XML answer into v.
+func getXML(ctx context.Context, client *http.Client, name, reqURL string, sign func(*http.Request), v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	if sign != nil {
+		sign(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notFound(name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return remoteError(name, resp.Status)
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("bad listing from %s: %w", name, err)
+	}
+	return nil
+}
+
+// splitBucketURL splits scheme://bucket/key into the bucket and the key.
+func splitBucketURL(u, scheme string) (bucket, key string, err error) {
+	_, rest, _ := strings.Cut(u, "://")
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("bad URL %s, should be %s://bucket/path", u, scheme)
+	}
+	return bucket, key, nil
+}
@@ -0,0 +1,111 @@
+package fsh24
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBadSignature is returned when reading a manifest whose signature
+// trailer doesn't match what's above it, it was changed after signing.
+var ErrBadSignature = errors.New("bad signature, the hash file was changed after it was signed")
+
+// signaturePrefix starts the signature trailer, the last line of a signed
+// manifest: "#signature ed25519 <public key> <signature>", both base64.
+// The signature covers every byte before that line.
+const signaturePrefix = "#signature ed25519 "
+
+// signManifest returns the trailer line that signs body with key.
+func signManifest(body []byte, key ed25519.PrivateKey, end string) string {
+	sig := ed25519.Sign(key, body)
+	pub := key.Public().(ed25519.PublicKey)
+	return signaturePrefix + base64.StdEncoding.EncodeToString(pub) + " " + base64.StdEncoding.EncodeToString(sig) + end
+}
+
+// splitSignature takes the signature trailer off content and checks it.
+// It returns content as is and a nil key for manifests that aren't signed.
+func splitSignature(content []byte) ([]byte, ed25519.PublicKey, error) {
+	trimmed := bytes.TrimRight(content, "\r\n\x00")
+	start := bytes.LastIndexAny(trimmed, "\n\x00") + 1
+	trailer, ok := strings.CutPrefix(string(trimmed[start:]), signaturePrefix)
+	if !ok {
+		return content, nil, nil
+	}
+
+	pubText, sigText, _ := strings.Cut(strings.TrimSpace(trailer), " ")
+	pub, err := base64.StdEncoding.DecodeString(pubText)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, nil, fmt.Errorf("%w: the public key in it is broken", ErrBadSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil || !ed25519.Verify(pub, content[:start], sig) {
+		return nil, nil, ErrBadSignature
+	}
+	return content[:start], pub, nil
+}
+
+// KeyID is a short name for a public key, the first 8 bytes of its SHA-256
+// in hex. Handy for telling keys apart, too short to trust on its own.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return strings.ToUpper(hex.EncodeToString(sum[:8]))
+}
+
+// MarshalSigningKey encodes a private key as PKCS #8 PEM, the same as openssl uses.
+func MarshalSigningKey(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseSigningKey reads a private key written by MarshalSigningKey.
+func ParseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return ed, nil
+}
+
+// MarshalPublicKey encodes a public key as PKIX PEM, the same as openssl uses.
+func MarshalPublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKey reads a public key written by MarshalPublicKey.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("not a PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return ed, nil
+}
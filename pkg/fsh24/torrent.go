@@ -0,0 +1,214 @@
+package fsh24
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MinPieceLength and MaxPieceLength are the piece lengths torrent
+	// clients take without complaining, 16KB is also the block size they
+	// ask each other for.
+	MinPieceLength = 16 << 10
+	MaxPieceLength = 16 << 20
+
+	// targetPieces is about how many pieces TorrentPieceLength aims for.
+	// More makes the .torrent big, fewer makes every bad piece cost more.
+	targetPieces = 1500
+)
+
+// TorrentFile is a file that goes in a torrent.
+type TorrentFile struct {
+	Path   string // Where to read it, a local path or URL
+	Name   string // Its path in the torrent, with / between folders
+	Length int64  // Its size when it was hashed, it has to still be that
+}
+
+// Torrent is a BitTorrent v1 metainfo file, see Hasher.Torrent. A torrent
+// with one file named Name is written as a single file torrent, anything
+// else has its files in a folder named Name.
+type Torrent struct {
+	Name         string
+	PieceLength  int64
+	Pieces       []byte // The SHA-1 of every piece one after the other, 20 bytes each
+	Files        []TorrentFile
+	Announce     string // Tracker URL, "" for one found through DHT
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time
+}
+
+// TorrentPieceLength picks a piece length for a torrent of total bytes, the
+// smallest power of two that keeps it around targetPieces pieces, between
+// MinPieceLength and MaxPieceLength.
+func TorrentPieceLength(total int64) int64 {
+	n := int64(MinPieceLength)
+	for n < MaxPieceLength && total/n > targetPieces {
+		n *= 2
+	}
+	return n
+}
+
+// ValidatePieceLength checks a piece length is one clients will take, a
+// power of two from MinPieceLength to MaxPieceLength.
+func ValidatePieceLength(n int64) error {
+	if n < MinPieceLength || n > MaxPieceLength || n&(n-1) != 0 {
+		return fmt.Errorf("piece length has to be a power of two from %dKB to %dMB", MinPieceLength>>10, MaxPieceLength>>20)
+	}
+	return nil
+}
+
+// Torrent reads every byte of files, in the order given, and works out the
+// piece hashes of them laid end to end the way BitTorrent does, pieces
+// going on from one file into the next. A file that isn't the Length it
+// was given any more is an error, the pieces after it would all be wrong.
+// pieceLength 0 picks one with
+// TorrentPieceLength. The files are opened like for hashing, Mmap, Direct,
+// net mode retries and waiting for locked files all apply.
+func (h *Hasher) Torrent(ctx context.Context, name string, files []TorrentFile, pieceLength int64) (*Torrent, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no files to put in the torrent")
+	}
+	t := &Torrent{Name: name, Files: make([]TorrentFile, len(files))}
+	copy(t.Files, files)
+
+	total := int64(0)
+	for _, tf := range files {
+		total += tf.Length
+	}
+	if pieceLength == 0 {
+		pieceLength = TorrentPieceLength(total)
+	}
+	if err := ValidatePieceLength(pieceLength); err != nil {
+		return nil, err
+	}
+	t.PieceLength = pieceLength
+
+	buf := getBuffer(int(min(pieceLength, SampleSize)))
+	defer putBuffer(buf)
+	piece, filled := sha1.New(), int64(0)
+	for _, tf := range files {
+		// One at a time, a torrent of a million files can't have them all open
+		f, _, err := h.open(ctx, tf.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("file not found: %s", tf.Path)
+		} else if err != nil {
+			return nil, err
+		}
+		if f.Size() != tf.Length {
+			f.Close()
+			return nil, fmt.Errorf("%s changed size since it was hashed", tf.Path)
+		}
+		for off := int64(0); off < tf.Length; {
+			if err := ctx.Err(); err != nil {
+				f.Close()
+				return nil, err
+			}
+			// Never read past the end of the piece, it's hashed on its own
+			n := min(int64(len(*buf)), tf.Length-off, pieceLength-filled)
+			if _, err := f.ReadAt((*buf)[:n], off); err != nil && !(err == io.EOF && n > 0) {
+				f.Close()
+				return nil, fmt.Errorf("error reading %s: %w", tf.Path, err)
+			}
+			piece.Write((*buf)[:n])
+			off += n
+			if filled += n; filled == pieceLength {
+				t.Pieces = piece.Sum(t.Pieces)
+				piece.Reset()
+				filled = 0
+			}
+		}
+		f.Close()
+	}
+	if filled > 0 {
+		t.Pieces = piece.Sum(t.Pieces) // The last piece is short
+	}
+	return t, nil
+}
+
+// NumPieces is how many pieces the torrent has.
+func (t *Torrent) NumPieces() int {
+	return len(t.Pieces) / sha1.Size
+}
+
+// WriteTo writes the .torrent file, bencoded.
+func (t *Torrent) WriteTo(w io.Writer) (int64, error) {
+	info := map[string]any{
+		"name":         t.Name,
+		"piece length": t.PieceLength,
+		"pieces":       string(t.Pieces),
+	}
+	if len(t.Files) == 1 && t.Files[0].Name == t.Name {
+		info["length"] = t.Files[0].Length
+	} else {
+		files := make([]any, 0, len(t.Files))
+		for _, f := range t.Files {
+			var path []any
+			for _, p := range strings.Split(f.Name, "/") {
+				path = append(path, p)
+			}
+			files = append(files, map[string]any{"length": f.Length, "path": path})
+		}
+		info["files"] = files
+	}
+
+	meta := map[string]any{"info": info}
+	if t.Announce != "" {
+		meta["announce"] = t.Announce
+	}
+	if t.Comment != "" {
+		meta["comment"] = t.Comment
+	}
+	if t.CreatedBy != "" {
+		meta["created by"] = t.CreatedBy
+	}
+	if !t.CreationDate.IsZero() {
+		meta["creation date"] = t.CreationDate.Unix()
+	}
+
+	var buf bytes.Buffer
+	bencode(&buf, meta)
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// bencode writes v the way .torrent files are, for the strings, integers,
+// lists and dictionaries Torrent.WriteTo builds. Dictionary keys are sorted,
+// clients work out the info hash from the bytes and need them in order.
+func bencode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		buf.WriteString(strconv.Itoa(len(v)) + ":" + v)
+	case int64:
+		buf.WriteString("i" + strconv.FormatInt(v, 10) + "e")
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			bencode(buf, item)
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			bencode(buf, k)
+			bencode(buf, v[k])
+		}
+		buf.WriteByte('e')
+	default:
+		panic(fmt.Sprintf("bencode: can't encode %T", v))
+	}
+}
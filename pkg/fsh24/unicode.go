@@ -0,0 +1,22 @@
+package fsh24
+
+import "golang.org/x/text/unicode/norm"
+
+// Unicode has two ways to write most accented letters, é as one character
+// (NFC) or as an e followed by a combining accent (NFD). They look the same
+// but are different bytes, so a path from a hash file made on a Mac, which
+// likes NFD, doesn't match the same name on Linux or Windows, which keep
+// whatever they were given, NFC most of the time.
+
+// NormalizePath returns path in NFC, the form hash files made with
+// NormalizeUnicode store.
+func NormalizePath(path string) string {
+	return norm.NFC.String(path)
+}
+
+// NormalizeUnicode puts every entry path in NFC, see NormalizePath.
+func (m *Manifest) NormalizeUnicode() {
+	for i, e := range m.Entries {
+		m.Entries[i].Path = NormalizePath(e.Path)
+	}
+}
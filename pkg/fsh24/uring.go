@@ -0,0 +1,8 @@
+package fsh24
+
+// uringFile is a local file to read through io_uring, see Hasher.IOUring.
+// It's an ordinary file otherwise, if io_uring can't be set up it's read
+// like one.
+type uringFile struct {
+	*localFile
+}
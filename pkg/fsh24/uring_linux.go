@@ -0,0 +1,240 @@
+//go:build linux
+
+package fsh24
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Just enough io_uring to queue up reads, the structs are the ones from
+// linux/io_uring.h. No liburing, no cgo.
+
+const (
+	uringOpRead         = 22     // IORING_OP_READ, Linux 5.6+
+	uringEnterGetEvents = 1      // IORING_ENTER_GETEVENTS
+	uringFeatRWCurPos   = 1 << 3 // IORING_FEAT_RW_CUR_POS, came with IORING_OP_READ
+
+	uringOffSQRing = 0          // IORING_OFF_SQ_RING
+	uringOffCQRing = 0x8000000  // IORING_OFF_CQ_RING
+	uringOffSQEs   = 0x10000000 // IORING_OFF_SQES
+)
+
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  uringSQOffsets
+	cqOff                                                                  uringCQOffsets
+}
+
+type uringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type uringSQE struct {
+	opcode, flags uint8
+	ioprio        uint16
+	fd            int32
+	off, addr     uint64
+	len, rwFlags  uint32
+	userData      uint64
+	bufIndex      uint16
+	personality   uint16
+	spliceFDIn    int32
+	addr3, pad    uint64
+}
+
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is one io_uring instance with its rings mapped.
+type uring struct {
+	fd             int
+	sqRing, cqRing []byte
+	sqes           []byte
+
+	sqTail, sqMask *uint32
+	sqArray        []uint32
+	cqHead, cqTail *uint32
+	cqMask         uint32
+	cqes           []uringCQE
+}
+
+// newUring sets up an io_uring with room for entries reads at once.
+func newUring(entries int) (*uring, error) {
+	var p uringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	u := &uring{fd: int(fd)}
+	if p.features&uringFeatRWCurPos == 0 {
+		u.Close()
+		return nil, fmt.Errorf("io_uring needs Linux 5.6 or newer")
+	}
+	var err error
+	if u.sqRing, err = unix.Mmap(u.fd, uringOffSQRing, int(p.sqOff.array+p.sqEntries*4), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		u.Close()
+		return nil, fmt.Errorf("io_uring mmap: %w", err)
+	}
+	if u.cqRing, err = unix.Mmap(u.fd, uringOffCQRing, int(p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(uringCQE{}))), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		u.Close()
+		return nil, fmt.Errorf("io_uring mmap: %w", err)
+	}
+	if u.sqes, err = unix.Mmap(u.fd, uringOffSQEs, int(p.sqEntries*uint32(unsafe.Sizeof(uringSQE{}))), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		u.Close()
+		return nil, fmt.Errorf("io_uring mmap: %w", err)
+	}
+
+	u.sqTail = (*uint32)(unsafe.Pointer(&u.sqRing[p.sqOff.tail]))
+	u.sqMask = (*uint32)(unsafe.Pointer(&u.sqRing[p.sqOff.ringMask]))
+	u.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&u.sqRing[p.sqOff.array])), p.sqEntries)
+	u.cqHead = (*uint32)(unsafe.Pointer(&u.cqRing[p.cqOff.head]))
+	u.cqTail = (*uint32)(unsafe.Pointer(&u.cqRing[p.cqOff.tail]))
+	u.cqMask = *(*uint32)(unsafe.Pointer(&u.cqRing[p.cqOff.ringMask]))
+	u.cqes = unsafe.Slice((*uringCQE)(unsafe.Pointer(&u.cqRing[p.cqOff.cqes])), p.cqEntries)
+	return u, nil
+}
+
+// Close unmaps the rings and closes the io_uring.
+func (u *uring) Close() error {
+	for _, m := range [][]byte{u.sqes, u.cqRing, u.sqRing} {
+		if m != nil {
+			unix.Munmap(m)
+		}
+	}
+	return unix.Close(u.fd)
+}
+
+// readAll reads every bufs[i] from offs[i] of fd with one submit, and
+// returns how many bytes each read got. len(bufs) can't be more than the
+// entries the ring was made with.
+func (u *uring) readAll(fd int, bufs [][]byte, offs []int64) ([]int, error) {
+	sqes := unsafe.Slice((*uringSQE)(unsafe.Pointer(&u.sqes[0])), len(u.sqArray))
+	tail := atomic.LoadUint32(u.sqTail)
+	mask := atomic.LoadUint32(u.sqMask)
+	for i, buf := range bufs {
+		idx := (tail + uint32(i)) & mask
+		sqes[idx] = uringSQE{
+			opcode:   uringOpRead,
+			fd:       int32(fd),
+			off:      uint64(offs[i]),
+			addr:     uint64(uintptr(unsafe.Pointer(unsafe.SliceData(buf)))),
+			len:      uint32(len(buf)),
+			userData: uint64(i),
+		}
+		u.sqArray[idx] = idx
+	}
+	atomic.StoreUint32(u.sqTail, tail+uint32(len(bufs)))
+
+	got := make([]int, len(bufs))
+	var readErr error
+	submit := len(bufs)
+	for done := 0; done < len(bufs); {
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(u.fd), uintptr(submit), 1, uringEnterGetEvents, 0, 0)
+		if errno == syscall.EINTR || errno == syscall.EAGAIN || errno == syscall.EBUSY {
+			continue // Reads are in flight, keep waiting for them
+		}
+		if errno != 0 {
+			return nil, fmt.Errorf("io_uring_enter: %w", errno)
+		}
+		submit = 0
+
+		head := atomic.LoadUint32(u.cqHead)
+		for ; head != atomic.LoadUint32(u.cqTail); head++ {
+			cqe := u.cqes[head&u.cqMask]
+			if cqe.res < 0 && readErr == nil {
+				readErr = syscall.Errno(-cqe.res)
+			}
+			got[cqe.userData] = max(0, int(cqe.res))
+			done++
+		}
+		atomic.StoreUint32(u.cqHead, head)
+	}
+	runtime.KeepAlive(bufs)
+	return got, readErr
+}
+
+// uringBatch is how many samples are read at once. Each needs its own buffer
+// until the batch is hashed, 8 of the default 4MB is 32MB a file.
+const uringBatch = 8
+
+// hashUring reads the spans of f through io_uring a batch at a time and
+// writes them to w in order. ok is false if io_uring can't be set up, read
+// the file the normal way then.
+func hashUring(ctx context.Context, w io.Writer, f *uringFile, spans []span, step int64) (ok bool, err error) {
+	u, err := newUring(uringBatch)
+	if err != nil {
+		return false, nil
+	}
+	defer u.Close()
+
+	// Full mode spans are the whole file, cut them into sample sized reads
+	type read struct{ off, n int64 }
+	var reads []read
+	for _, sp := range spans {
+		for off, end := sp.off, sp.off+sp.n; off < end; off += step {
+			reads = append(reads, read{off, min(step, end-off)})
+		}
+	}
+
+	pooled := make([]*[]byte, min(uringBatch, len(reads)))
+	for i := range pooled {
+		pooled[i] = getBuffer(int(step))
+		defer putBuffer(pooled[i])
+	}
+	fd := int(f.Fd())
+	for start := 0; start < len(reads); start += uringBatch {
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+		batch := reads[start:min(start+uringBatch, len(reads))]
+		bufs := make([][]byte, len(batch))
+		offs := make([]int64, len(batch))
+		for i, r := range batch {
+			bufs[i] = (*pooled[i])[:r.n]
+			offs[i] = r.off
+		}
+		got, err := u.readAll(fd, bufs, offs)
+		if err != nil {
+			return true, fmt.Errorf("failed to read chunk at offset %d: %w", batch[0].off, err)
+		}
+		for i, buf := range bufs {
+			// A short read isn't EOF with io_uring, get the rest the normal way
+			if got[i] < len(buf) {
+				n, err := f.ReadAt(buf[got[i]:], offs[i]+int64(got[i]))
+				if err != nil && err != io.EOF {
+					return true, fmt.Errorf("failed to read chunk at offset %d: %w", offs[i], err)
+				}
+				got[i] += n
+			}
+			w.Write(buf[:got[i]])
+		}
+	}
+	return true, nil
+}
+
+// IOUringError is why io_uring can't be used here, nil if it can.
+func IOUringError() error {
+	u, err := newUring(1)
+	if err != nil {
+		return err
+	}
+	return u.Close()
+}
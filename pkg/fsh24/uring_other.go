@@ -0,0 +1,19 @@
+//go:build !linux
+
+package fsh24
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// hashUring never works here, io_uring is Linux only.
+func hashUring(ctx context.Context, w io.Writer, f *uringFile, spans []span, step int64) (ok bool, err error) {
+	return false, nil
+}
+
+// IOUringError is why io_uring can't be used here, nil if it can.
+func IOUringError() error {
+	return errors.New("io_uring is Linux only")
+}
@@ -0,0 +1,575 @@
+package fsh24
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyRequired is returned when verifying a keyed manifest without a key.
+var ErrKeyRequired = errors.New("this checksum file is keyed, a key is needed to verify it")
+
+// Verifier checks the files listed in a Manifest against their recorded hashes.
+type Verifier struct {
+	Hasher *Hasher
+
+	// Rebase puts baseDir in front of absolute entry paths too (minus any
+	// drive letter), for checking files restored under a different root.
+	Rebase bool
+
+	// NormalizeUnicode finds files whose names are in another Unicode form
+	// on disk than in the manifest, NFD from a Mac against NFC. See NormalizePath.
+	NormalizeUnicode bool
+
+	// IgnoreCase finds files whose names are in another case on disk than
+	// in the manifest, for hash files made on Windows checked on Linux. A
+	// name that matches more than one file that way is missing.
+	IgnoreCase bool
+
+	// Maps rewrite the entry paths before they're looked up, the first one
+	// that matches, see PathMap. Relative results are joined with baseDir.
+	Maps []PathMap
+
+	// ShortHashes lets the FSH24 of an entry be just the start of the hash,
+	// at least 8 characters, like the tags people put in file names. The
+	// rest of a short hash isn't checked, so it's that much weaker.
+	ShortHashes bool
+
+	// FailFast stops at the first file that isn't verified, for when one bad
+	// file is all you need to know. Files still being checked are left out.
+	FailFast bool
+
+	// Deep checks in two passes, first the size and sampled FSH24 of every
+	// file, then the full SHA-256 or CRC32 of the ones that passed and have
+	// one. The quick answer for everything comes before the slow one, and a
+	// file that fails on its samples isn't read all the way. Without it
+	// they're checked in one go. Each result's Depth says how far it got.
+	Deep bool
+
+	// OnCaseMismatch, if set, is called with IgnoreCase when a file was only
+	// found with its name in another case, path being what it's called on
+	// disk. It is called from multiple goroutines.
+	OnCaseMismatch func(e Entry, path string)
+
+	// OnCheck, if set, is called just before a file is hashed.
+	OnCheck func(e Entry, path string)
+
+	// OnResult, if set, is called as soon as a file's verification finishes.
+	// It is called from multiple goroutines, but calls never overlap. With
+	// Deep it's called again for the files of the second pass.
+	OnResult func(e Entry, r FileVerificationResult)
+
+	// OnDeep, if set, is called with Deep between the passes, with the
+	// entries the second one checks. It isn't called if there are none.
+	OnDeep func(entries []Entry)
+}
+
+// NewVerifier returns a Verifier using a default Hasher.
+func NewVerifier() *Verifier {
+	return &Verifier{Hasher: NewHasher()}
+}
+
+// VerifyFile reads a .fsh24 file and verifies the files it lists.
+// Relative paths are resolved against the directory the .fsh24 file lives in.
+func (v *Verifier) VerifyFile(ctx context.Context, hashFilename string) (VerificationSummary, []FileVerificationResult, error) {
+	m, err := ReadManifestFile(hashFilename)
+	if err != nil {
+		return VerificationSummary{}, nil, err
+	}
+	return v.Verify(ctx, m, filepath.Dir(hashFilename))
+}
+
+// Verify verifies every entry of m. Relative entry paths are joined with baseDir.
+// Invalid manifest lines are counted as failures.
+// Files are hashed with the algorithm, digest length and sample size recorded
+// in the manifest, not v.Hasher's, so the sampling is replayed exactly.
+// FormatGNU and FormatBSD manifests don't record any of that and use v.Hasher as is.
+// Entries with a Checksum are checked by hashing the whole file with it instead.
+// Keyed manifests use v.Hasher.Key and fail with ErrKeyRequired without one.
+// Up to v.Hasher.Jobs files are checked at once.
+// With v.FailFast the results stop at the first failure, invalid lines included.
+// If ctx is cancelled the summary and results cover only the files that
+// finished, and ctx.Err() is returned.
+func (v *Verifier) Verify(ctx context.Context, m *Manifest, baseDir string) (VerificationSummary, []FileVerificationResult, error) {
+	results := make([]FileVerificationResult, 0, len(m.Entries)+len(m.Invalid))
+	for _, inv := range m.Invalid {
+		results = append(results, FileVerificationResult{Status: inv.Status})
+	}
+
+	if m.Keyed && len(v.Hasher.Key) == 0 {
+		return VerificationSummary{}, nil, ErrKeyRequired
+	}
+
+	hasher := *v.Hasher
+	m.ApplySettings(&hasher)
+
+	startTime := time.Now()
+
+	if v.FailFast && len(results) > 0 {
+		return Summarize(results, time.Since(startTime).Seconds()), results, nil
+	}
+
+	// Cancelled on the first failure with FailFast, the files in progress
+	// give up and nothing new gets started
+	jobCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var (
+		deep   []Entry // Files for the second pass of Deep
+		deepAt []int   // Where the first pass result of each of them is
+	)
+	v.verifyEntries(jobCtx, stop, &hasher, m.Entries, baseDir, v.Deep, func(i int, r FileVerificationResult) {
+		if e := m.Entries[i]; v.Deep && r.Status == StatusVerified && fullLater(e) {
+			deep = append(deep, e)
+			deepAt = append(deepAt, len(results))
+		}
+		results = append(results, r)
+	})
+	if len(deep) > 0 && jobCtx.Err() == nil {
+		if v.OnDeep != nil {
+			v.OnDeep(deep)
+		}
+		// A file the second pass doesn't get to keeps its sampled result.
+		// Names in another case were reported the first time round
+		second := *v
+		second.OnCaseMismatch = nil
+		second.verifyEntries(jobCtx, stop, &hasher, deep, baseDir, false, func(i int, r FileVerificationResult) {
+			results[deepAt[i]] = r
+		})
+	}
+
+	return Summarize(results, time.Since(startTime).Seconds()), results, ctx.Err()
+}
+
+// verifyEntries checks entries Hasher.Jobs at a time and calls record with
+// each result and the index of its entry, never more than one at once. quick leaves the full hashes of
+// files with an FSH24 out, see Deep. After a failure with FailFast it calls
+// stop and records nothing more.
+func (v *Verifier) verifyEntries(
+	ctx context.Context,
+	stop func(),
+	hasher *Hasher,
+	entries []Entry,
+	baseDir string,
+	quick bool,
+	record func(i int, r FileVerificationResult),
+) {
+	var mu sync.Mutex
+	runJobs(len(entries), hasher.Jobs, func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		e := entries[i]
+
+		// Resolve the file path: if it's relative, join it with the base directory,
+		// which can be a URL. URLs in the manifest are used as they are
+		currentPath := MapPath(v.Maps, e.Path)
+		switch {
+		case IsRemote(currentPath):
+		case v.Rebase && filepath.IsAbs(currentPath):
+			currentPath = JoinPath(baseDir, strings.TrimPrefix(currentPath, filepath.VolumeName(currentPath)))
+		case !filepath.IsAbs(currentPath):
+			currentPath = JoinPath(baseDir, currentPath)
+		}
+		if (v.NormalizeUnicode || v.IgnoreCase) && !IsRemote(currentPath) {
+			if found, ok := findPath(currentPath, v.nameKey); ok {
+				if v.IgnoreCase && v.OnCaseMismatch != nil && NormalizePath(found) != NormalizePath(currentPath) {
+					v.OnCaseMismatch(e, found)
+				}
+				currentPath = found
+			}
+		}
+
+		checked := e
+		if quick && fullLater(e) {
+			checked.SHA256, checked.CRC32 = "", ""
+		}
+		result, err := v.verifyEntry(ctx, hasher, checked, currentPath)
+		if err != nil {
+			return // Cancelled, leave it out of the partial results
+		}
+		result.ExpectedSHA256, result.ExpectedCRC32 = e.SHA256, e.CRC32
+		result.Change = changeOf(e, result)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return // Another file failed first
+		}
+		if v.FailFast && result.Status != StatusVerified {
+			stop()
+		}
+		if v.OnResult != nil {
+			v.OnResult(e, result)
+		}
+		record(i, result)
+	})
+}
+
+// fullLater reports whether the first pass of Deep leaves e's full hashes
+// for the second, it has an FSH24 to check first and a SHA-256 or CRC32.
+func fullLater(e Entry) bool {
+	return e.Hash != "" && e.Checksum == "" && (e.SHA256 != "" || e.CRC32 != "")
+}
+
+// nameKey is what file names on disk are matched by, see NormalizeUnicode
+// and IgnoreCase.
+func (v *Verifier) nameKey(name string) string {
+	if v.NormalizeUnicode {
+		name = NormalizePath(name)
+	}
+	if v.IgnoreCase {
+		name = FoldPath(name)
+	}
+	return name
+}
+
+// minShortHash is the shortest hash ShortHashes takes, anything less would
+// match by chance too often.
+const minShortHash = 8
+
+// hashMatches reports whether the FSH24 of a file is the one in the entry,
+// or starts with it when ShortHashes is on.
+func (v *Verifier) hashMatches(actual, want string) bool {
+	want = strings.ToUpper(want)
+	if v.ShortHashes && len(want) >= minShortHash {
+		return strings.HasPrefix(actual, want)
+	}
+	return actual == want
+}
+
+// errorStatus is the status of a file that couldn't be read to the end,
+// StatusLocked if another program has part of it locked.
+func errorStatus(err error) string {
+	if IsLocked(err) {
+		return StatusLocked
+	}
+	return StatusHashError
+}
+
+// verifyEntry checks a single file against its manifest entry.
+// The only error it returns is ctx's, when the check was cancelled.
+func (v *Verifier) verifyEntry(ctx context.Context, hasher *Hasher, e Entry, currentPath string) (FileVerificationResult, error) {
+	result := FileVerificationResult{
+		Filepath:       currentPath,
+		Filename:       baseName(currentPath),
+		ExpectedHash:   e.Hash,
+		ExpectedSize:   e.Size,
+		ExpectedSHA256: e.SHA256,
+		ExpectedCRC32:  e.CRC32,
+	}
+
+	f, net, err := hasher.open(ctx, currentPath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Status = StatusMissing
+		if IsLocked(err) {
+			result.Status = StatusLocked
+		}
+		return result, nil
+	}
+	defer f.Close()
+
+	result.ActualSize = f.Size()
+	result.ActualModTime = f.ModTime()
+
+	// Fast fail on size, no need to hash a file that's already broken
+	if e.Size >= 0 && result.ActualSize != e.Size {
+		result.Status = StatusSizeMismatch
+		return result, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if v.OnCheck != nil {
+		v.OnCheck(e, currentPath)
+	}
+
+	fileStartTime := time.Now()
+
+	// A file in a compressed archive can only be read start to end, all the
+	// hashes it's checked against are worked out in the one read
+	var once *streamSums
+	if _, ok := f.(*streamFile); ok {
+		once = sumsForEntry(ctx, hasher, e, f)
+	}
+
+	// .sfv entries have no FSH24 hash, only the full CRC32 further down,
+	// and md5sum style ones have a whole file hash instead
+	if e.Hash != "" && e.Checksum == "" {
+		// Replay the recorded chunk count rather than working it out again.
+		// Zero, when the format doesn't record it, works it out from the size.
+		entryHasher := *hasher
+		entryHasher.Chunks = e.Chunks
+		entryHasher.Offsets = e.Offsets
+
+		var currentHash string
+		var chunks int
+		var hashErr error
+		if once != nil {
+			currentHash, chunks, hashErr = once.hash, once.chunks, once.err
+		} else {
+			currentHash, chunks, hashErr = entryHasher.sumReaderAt(ctx, f, result.ActualSize, net)
+		}
+		result.ProcessingTime = time.Since(fileStartTime).Seconds()
+		result.HashedSize = int64(chunks) * int64(hasher.sampleSize())
+		if hasher.Full {
+			result.HashedSize = result.ActualSize
+		}
+
+		if hashErr != nil {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			result.Status = errorStatus(hashErr)
+			return result, nil
+		}
+
+		result.ActualHash = strings.ToUpper(currentHash)
+
+		if !v.hashMatches(result.ActualHash, e.Hash) {
+			result.Status = StatusHashMismatch
+			return result, nil
+		}
+	}
+
+	if e.Checksum != "" {
+		sum, err := newChecksum(e.Checksum, len(e.Hash)/2)
+		checksum := ""
+		if err == nil && once != nil {
+			checksum, err = once.checksum, once.err
+		} else if err == nil {
+			checksum, err = sumWhole(ctx, f, sum)
+		}
+		result.ProcessingTime = time.Since(fileStartTime).Seconds()
+		result.HashedSize = result.ActualSize
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			result.Status = errorStatus(err)
+			return result, nil
+		}
+		result.ActualHash = strings.ToUpper(checksum)
+		if result.ActualHash != strings.ToUpper(e.Hash) {
+			result.Status = StatusHashMismatch
+			return result, nil
+		}
+	}
+
+	// The quick check passed, now the full hash if the manifest has one
+	if e.SHA256 != "" {
+		var fullHash string
+		var err error
+		if once != nil {
+			fullHash, err = once.sha256, once.err
+		} else {
+			fullHash, err = sumWhole(ctx, f, sha256.New())
+		}
+		result.ProcessingTime = time.Since(fileStartTime).Seconds()
+		result.HashedSize = result.ActualSize
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			result.Status = errorStatus(err)
+			return result, nil
+		}
+		result.ActualSHA256 = strings.ToUpper(fullHash)
+		if result.ActualSHA256 != strings.ToUpper(e.SHA256) {
+			result.Status = StatusSHA256Mismatch
+			return result, nil
+		}
+	}
+
+	if e.CRC32 != "" {
+		var crc string
+		var err error
+		if once != nil {
+			crc, err = once.crc32, once.err
+		} else {
+			crc, err = sumWhole(ctx, f, crc32.NewIEEE())
+		}
+		result.ProcessingTime = time.Since(fileStartTime).Seconds()
+		result.HashedSize = result.ActualSize
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			result.Status = errorStatus(err)
+			return result, nil
+		}
+		result.ActualCRC32 = strings.ToUpper(crc)
+		if result.ActualCRC32 != strings.ToUpper(e.CRC32) {
+			result.Status = StatusCRC32Mismatch
+			return result, nil
+		}
+	}
+
+	result.Status = StatusVerified
+	result.Depth = DepthSampled
+	if readsAll(hasher, e) {
+		result.Depth = DepthFull
+	}
+	if !e.ModTime.IsZero() && !result.ActualModTime.IsZero() && !result.ActualModTime.Equal(e.ModTime) {
+		result.MetadataChanges = append(result.MetadataChanges, fmt.Sprintf("mtime %s -> %s",
+			e.ModTime.UTC().Format(time.RFC3339), result.ActualModTime.UTC().Format(time.RFC3339)))
+	}
+	if !e.Metadata.IsZero() && !IsRemote(currentPath) && !IsArchiveMember(currentPath) {
+		now, err := ReadMetadata(currentPath)
+		if err != nil {
+			result.MetadataChanges = append(result.MetadataChanges, err.Error())
+		} else {
+			result.MetadataChanges = append(result.MetadataChanges, e.Metadata.Changes(now)...)
+		}
+	}
+	return result, nil
+}
+
+// streamSums are the hashes of a file checked against an entry, all from
+// one read of it, see sumsForEntry.
+type streamSums struct {
+	hash                    string
+	chunks                  int
+	checksum, sha256, crc32 string
+	err                     error
+}
+
+// sumsForEntry reads f once for every hash e has.
+func sumsForEntry(ctx context.Context, hasher *Hasher, e Entry, f File) *streamSums {
+	o := &streamSums{}
+	var whole []hash.Hash
+	var sum, full, crc hash.Hash
+	if e.Checksum != "" {
+		if sum, o.err = newChecksum(e.Checksum, len(e.Hash)/2); o.err != nil {
+			return o
+		}
+		whole = append(whole, sum)
+	}
+	if e.SHA256 != "" {
+		full = sha256.New()
+		whole = append(whole, full)
+	}
+	if e.CRC32 != "" {
+		crc = crc32.NewIEEE()
+		whole = append(whole, crc)
+	}
+	entryHasher := *hasher
+	entryHasher.Chunks = e.Chunks
+	entryHasher.Offsets = e.Offsets
+	o.hash, o.chunks, o.err = entryHasher.sumStream(ctx, io.NewSectionReader(f, 0, f.Size()), f.Size(), whole...)
+	if o.err != nil {
+		return o
+	}
+	for _, s := range []struct {
+		h   hash.Hash
+		hex *string
+	}{{sum, &o.checksum}, {full, &o.sha256}, {crc, &o.crc32}} {
+		if s.h != nil {
+			*s.hex = hex.EncodeToString(s.h.Sum(nil))
+		}
+	}
+	return o
+}
+
+// changeOf works out what happened to a file since e was hashed, going by
+// whether its content and its modification time changed. "" if there's no
+// time to go by or the file couldn't be checked.
+func changeOf(e Entry, r FileVerificationResult) string {
+	if e.ModTime.IsZero() || r.ActualModTime.IsZero() {
+		return ""
+	}
+	sameTime := r.ActualModTime.Equal(e.ModTime)
+	switch r.Status {
+	case StatusVerified:
+		if sameTime {
+			return ChangeUntouched
+		}
+		return ChangeTouched
+	case StatusSizeMismatch, StatusHashMismatch, StatusSHA256Mismatch, StatusCRC32Mismatch:
+		if sameTime {
+			return ChangeCorrupted
+		}
+		return ChangeModified
+	}
+	return ""
+}
+
+// Summarize builds the overall statistics for a set of verification results.
+func Summarize(results []FileVerificationResult, totalTime float64) VerificationSummary {
+	var (
+		verified        int
+		full            int
+		failed          int
+		metadataChanged int
+		modified        int
+		corrupted       int
+		locked          int
+		totalSize       int64
+		totalHashedSize int64
+	)
+
+	for _, res := range results {
+		if res.Status == StatusVerified {
+			verified++
+			if res.Depth == DepthFull {
+				full++
+			}
+			if len(res.MetadataChanges) > 0 {
+				metadataChanged++
+			}
+		} else {
+			failed++
+		}
+		if res.Status == StatusLocked {
+			locked++
+		}
+		switch res.Change {
+		case ChangeModified:
+			modified++
+		case ChangeCorrupted:
+			corrupted++
+		}
+		if res.ActualSize > 0 { // Use ActualSize if available, otherwise ExpectedSize for calculation
+			totalSize += res.ActualSize
+		} else if res.ExpectedSize > 0 { // For missing files, use expected size for total size calculation
+			totalSize += res.ExpectedSize
+		}
+		totalHashedSize += res.HashedSize
+	}
+
+	totalHashedPercentage := 0.0
+	if totalSize > 0 {
+		totalHashedPercentage = (float64(totalHashedSize) / float64(totalSize)) * 100
+	}
+
+	return VerificationSummary{
+		Verified:              verified,
+		VerifiedFull:          full,
+		Failed:                failed,
+		Total:                 verified + failed,
+		Success:               failed == 0,
+		TotalTime:             totalTime,
+		AverageTimePerFile:    totalTime / float64(verified+failed),
+		TotalSize:             totalSize,
+		TotalHashedSize:       totalHashedSize,
+		TotalHashedPercentage: totalHashedPercentage,
+		MetadataChanged:       metadataChanged,
+		Modified:              modified,
+		Corrupted:             corrupted,
+		Locked:                locked,
+	}
+}
@@ -0,0 +1,8 @@
+//go:build linux
+
+package fsh24
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr is what getxattr gives for an attribute that isn't there.
+const errNoAttr = unix.ENODATA
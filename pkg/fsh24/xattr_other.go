@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package fsh24
+
+// readXattrs has no xattrs to read here, they aren't recorded.
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
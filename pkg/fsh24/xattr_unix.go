@@ -0,0 +1,45 @@
+//go:build linux || darwin
+
+package fsh24
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs reads every extended attribute of path. Filesystems without
+// them give an empty map, not an error.
+func readXattrs(path string) (map[string][]byte, error) {
+	xattrs := map[string][]byte{}
+	size, err := unix.Listxattr(path, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return xattrs, nil
+	}
+	if err != nil || size == 0 {
+		return xattrs, err
+	}
+	list := make([]byte, size)
+	size, err = unix.Listxattr(path, list)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(strings.TrimRight(string(list[:size]), "\x00"), "\x00") {
+		n, err := unix.Getxattr(path, name, nil)
+		if errors.Is(err, errNoAttr) {
+			continue // Gone since the list
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, n)
+		n, err = unix.Getxattr(path, name, value)
+		if err != nil {
+			return nil, err
+		}
+		xattrs[name] = value[:n]
+	}
+	return xattrs, nil
+}
@@ -0,0 +1,191 @@
+// Package fsh24db keeps FSH24 hashes in an SQLite database instead of a .fsh24 text file.
+// Past a few million files a flat text file gets slow to rewrite and search,
+// a database can be updated a file at a time and queried by hash or size.
+//
+// It's its own package so programs that only need .fsh24 files don't pull in SQLite.
+package fsh24db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fsh24/pkg/fsh24"
+
+	_ "modernc.org/sqlite" // Pure Go, the release builds have CGO turned off
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS settings (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	path    TEXT PRIMARY KEY,
+	hash    TEXT NOT NULL,
+	chunks  INTEGER NOT NULL,
+	size    INTEGER NOT NULL,
+	sha256  TEXT NOT NULL DEFAULT '',
+	crc32   TEXT NOT NULL DEFAULT '',
+	mtime   TEXT NOT NULL DEFAULT '',
+	updated TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_hash ON files (hash);
+CREATE INDEX IF NOT EXISTS files_size ON files (size);
+`
+
+// DB is an open FSH24 database.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if it doesn't exist yet.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set up database %s: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Write adds the entries of m to the database, replacing any already there
+// with the same path. The hash settings of m are saved on the first write,
+// after that they have to match, one database can't mix settings.
+func (d *DB) Write(m *fsh24.Manifest) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkSettings(tx, m); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO files
+		(path, hash, chunks, size, sha256, crc32, mtime, updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range m.Entries {
+		if e.Offsets != nil {
+			return fmt.Errorf("%s was hashed with adaptive sampling, a database has no room for where its samples are", e.Path)
+		}
+		mtime := ""
+		if !e.ModTime.IsZero() {
+			mtime = e.ModTime.UTC().Format(time.RFC3339Nano)
+		}
+		if _, err := stmt.Exec(e.Path, e.Hash, e.Chunks, e.Size, e.SHA256, e.CRC32, mtime, now); err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.Path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// checkSettings saves the settings of m into an empty database,
+// or makes sure they are the same as the ones already saved.
+func checkSettings(tx *sql.Tx, m *fsh24.Manifest) error {
+	saved, err := readSettings(tx)
+	if err != nil {
+		return err
+	}
+
+	params := m.Params()
+	if len(saved) == 0 {
+		for _, p := range params {
+			if _, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, p.Key, p.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(saved) != len(params) {
+		return fmt.Errorf("database was made with different settings")
+	}
+	for _, p := range params {
+		if saved[p.Key] != p.Value {
+			return fmt.Errorf("database was made with %s=%s, not %s", p.Key, saved[p.Key], p.Value)
+		}
+	}
+	return nil
+}
+
+// readSettings loads the settings table.
+func readSettings(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}) (map[string]string, error) {
+	rows, err := q.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		settings[key] = value
+	}
+	return settings, rows.Err()
+}
+
+// Manifest reads the whole database back as a Manifest, ready for fsh24.Verifier.
+func (d *DB) Manifest() (*fsh24.Manifest, error) {
+	settings, err := readSettings(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &fsh24.Manifest{}
+	if algo, ok := settings["algo"]; ok {
+		if _, err := m.SetParam("algo", algo); err != nil { // bytes is checked against it
+			return nil, err
+		}
+	}
+	for key, value := range settings {
+		known, err := m.SetParam(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown setting in database: %s", key)
+		}
+	}
+
+	rows, err := d.db.Query(`SELECT path, hash, chunks, size, sha256, crc32, mtime FROM files ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e     fsh24.Entry
+			mtime string
+		)
+		if err := rows.Scan(&e.Path, &e.Hash, &e.Chunks, &e.Size, &e.SHA256, &e.CRC32, &mtime); err != nil {
+			return nil, err
+		}
+		if mtime != "" {
+			e.ModTime, _ = time.Parse(time.RFC3339Nano, mtime)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return m, rows.Err()
+}
@@ -0,0 +1,923 @@
+// The gRPC API of "fsh24 serve --grpc". Regenerate fsh24.pb.go and
+// fsh24_grpc.pb.go after changing this with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	       --go-grpc_out=. --go-grpc_opt=paths=source_relative fsh24.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: fsh24.proto
+
+package fsh24pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type HashRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Files and folders on the server.
+	Paths []string `protobuf:"bytes,1,rep,name=paths,proto3" json:"paths,omitempty"`
+	// Also hash the files in sub folders.
+	Recursive     bool `protobuf:"varint,2,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HashRequest) Reset() {
+	*x = HashRequest{}
+	mi := &file_fsh24_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashRequest) ProtoMessage() {}
+
+func (x *HashRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashRequest.ProtoReflect.Descriptor instead.
+func (*HashRequest) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *HashRequest) GetPaths() []string {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+func (x *HashRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+type HashResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Files finished so far, and how many there are.
+	Done  int32 `protobuf:"varint,1,opt,name=done,proto3" json:"done,omitempty"`
+	Total int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*HashResponse_File
+	//	*HashResponse_Error
+	//	*HashResponse_Summary
+	Result        isHashResponse_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HashResponse) Reset() {
+	*x = HashResponse{}
+	mi := &file_fsh24_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashResponse) ProtoMessage() {}
+
+func (x *HashResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashResponse.ProtoReflect.Descriptor instead.
+func (*HashResponse) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HashResponse) GetDone() int32 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *HashResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *HashResponse) GetResult() isHashResponse_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *HashResponse) GetFile() *FileHash {
+	if x != nil {
+		if x, ok := x.Result.(*HashResponse_File); ok {
+			return x.File
+		}
+	}
+	return nil
+}
+
+func (x *HashResponse) GetError() *FileError {
+	if x != nil {
+		if x, ok := x.Result.(*HashResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *HashResponse) GetSummary() *HashSummary {
+	if x != nil {
+		if x, ok := x.Result.(*HashResponse_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isHashResponse_Result interface {
+	isHashResponse_Result()
+}
+
+type HashResponse_File struct {
+	File *FileHash `protobuf:"bytes,3,opt,name=file,proto3,oneof"`
+}
+
+type HashResponse_Error struct {
+	Error *FileError `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+}
+
+type HashResponse_Summary struct {
+	Summary *HashSummary `protobuf:"bytes,5,opt,name=summary,proto3,oneof"` // Always the last message
+}
+
+func (*HashResponse_File) isHashResponse_Result() {}
+
+func (*HashResponse_Error) isHashResponse_Result() {}
+
+func (*HashResponse_Summary) isHashResponse_Result() {}
+
+type FileHash struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Path           string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size           int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Fsh24          string                 `protobuf:"bytes,3,opt,name=fsh24,proto3" json:"fsh24,omitempty"`
+	Chunks         int32                  `protobuf:"varint,4,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	Sha256         string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`                                         // Only with --sha256
+	Crc32          string                 `protobuf:"bytes,6,opt,name=crc32,proto3" json:"crc32,omitempty"`                                           // Only with --sfv or --format sfv
+	ProcessingTime float64                `protobuf:"fixed64,7,opt,name=processing_time,json=processingTime,proto3" json:"processing_time,omitempty"` // Seconds
+	MtimeUnixNano  int64                  `protobuf:"varint,8,opt,name=mtime_unix_nano,json=mtimeUnixNano,proto3" json:"mtime_unix_nano,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileHash) Reset() {
+	*x = FileHash{}
+	mi := &file_fsh24_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileHash) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileHash) ProtoMessage() {}
+
+func (x *FileHash) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileHash.ProtoReflect.Descriptor instead.
+func (*FileHash) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FileHash) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileHash) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileHash) GetFsh24() string {
+	if x != nil {
+		return x.Fsh24
+	}
+	return ""
+}
+
+func (x *FileHash) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+func (x *FileHash) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileHash) GetCrc32() string {
+	if x != nil {
+		return x.Crc32
+	}
+	return ""
+}
+
+func (x *FileHash) GetProcessingTime() float64 {
+	if x != nil {
+		return x.ProcessingTime
+	}
+	return 0
+}
+
+func (x *FileHash) GetMtimeUnixNano() int64 {
+	if x != nil {
+		return x.MtimeUnixNano
+	}
+	return 0
+}
+
+type FileError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileError) Reset() {
+	*x = FileError{}
+	mi := &file_fsh24_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileError) ProtoMessage() {}
+
+func (x *FileError) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileError.ProtoReflect.Descriptor instead.
+func (*FileError) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FileError) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileError) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type HashSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Algorithm     string                 `protobuf:"bytes,1,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	SampleSize    int32                  `protobuf:"varint,2,opt,name=sample_size,json=sampleSize,proto3" json:"sample_size,omitempty"`
+	Full          bool                   `protobuf:"varint,3,opt,name=full,proto3" json:"full,omitempty"`
+	Hashed        int32                  `protobuf:"varint,4,opt,name=hashed,proto3" json:"hashed,omitempty"`
+	Failed        int32                  `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	TotalTime     float64                `protobuf:"fixed64,6,opt,name=total_time,json=totalTime,proto3" json:"total_time,omitempty"` // Seconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HashSummary) Reset() {
+	*x = HashSummary{}
+	mi := &file_fsh24_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashSummary) ProtoMessage() {}
+
+func (x *HashSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashSummary.ProtoReflect.Descriptor instead.
+func (*HashSummary) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HashSummary) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *HashSummary) GetSampleSize() int32 {
+	if x != nil {
+		return x.SampleSize
+	}
+	return 0
+}
+
+func (x *HashSummary) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *HashSummary) GetHashed() int32 {
+	if x != nil {
+		return x.Hashed
+	}
+	return 0
+}
+
+func (x *HashSummary) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *HashSummary) GetTotalTime() float64 {
+	if x != nil {
+		return x.TotalTime
+	}
+	return 0
+}
+
+type VerifyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The .fsh24 file, any format fsh24 reads.
+	HashFile []byte `protobuf:"bytes,1,opt,name=hash_file,json=hashFile,proto3" json:"hash_file,omitempty"`
+	// Where relative paths in it are looked up. The server's working folder if empty.
+	BaseDir string `protobuf:"bytes,2,opt,name=base_dir,json=baseDir,proto3" json:"base_dir,omitempty"`
+	// Stop at the first file that fails.
+	FailFast      bool `protobuf:"varint,3,opt,name=fail_fast,json=failFast,proto3" json:"fail_fast,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_fsh24_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *VerifyRequest) GetHashFile() []byte {
+	if x != nil {
+		return x.HashFile
+	}
+	return nil
+}
+
+func (x *VerifyRequest) GetBaseDir() string {
+	if x != nil {
+		return x.BaseDir
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetFailFast() bool {
+	if x != nil {
+		return x.FailFast
+	}
+	return false
+}
+
+type VerifyResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Files finished so far, and how many there are.
+	Done  int32 `protobuf:"varint,1,opt,name=done,proto3" json:"done,omitempty"`
+	Total int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*VerifyResponse_File
+	//	*VerifyResponse_Summary
+	Result        isVerifyResponse_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyResponse) Reset() {
+	*x = VerifyResponse{}
+	mi := &file_fsh24_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyResponse) ProtoMessage() {}
+
+func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyResponse.ProtoReflect.Descriptor instead.
+func (*VerifyResponse) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *VerifyResponse) GetDone() int32 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *VerifyResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *VerifyResponse) GetResult() isVerifyResponse_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *VerifyResponse) GetFile() *FileVerification {
+	if x != nil {
+		if x, ok := x.Result.(*VerifyResponse_File); ok {
+			return x.File
+		}
+	}
+	return nil
+}
+
+func (x *VerifyResponse) GetSummary() *VerifySummary {
+	if x != nil {
+		if x, ok := x.Result.(*VerifyResponse_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isVerifyResponse_Result interface {
+	isVerifyResponse_Result()
+}
+
+type VerifyResponse_File struct {
+	File *FileVerification `protobuf:"bytes,3,opt,name=file,proto3,oneof"`
+}
+
+type VerifyResponse_Summary struct {
+	Summary *VerifySummary `protobuf:"bytes,4,opt,name=summary,proto3,oneof"` // Always the last message
+}
+
+func (*VerifyResponse_File) isVerifyResponse_Result() {}
+
+func (*VerifyResponse_Summary) isVerifyResponse_Result() {}
+
+type FileVerification struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Path           string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Status         string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // verified, missing, size_mismatch, hash_mismatch and so on
+	ExpectedSize   int64                  `protobuf:"varint,3,opt,name=expected_size,json=expectedSize,proto3" json:"expected_size,omitempty"`
+	ActualSize     int64                  `protobuf:"varint,4,opt,name=actual_size,json=actualSize,proto3" json:"actual_size,omitempty"`
+	ExpectedHash   string                 `protobuf:"bytes,5,opt,name=expected_hash,json=expectedHash,proto3" json:"expected_hash,omitempty"`
+	ActualHash     string                 `protobuf:"bytes,6,opt,name=actual_hash,json=actualHash,proto3" json:"actual_hash,omitempty"`
+	ProcessingTime float64                `protobuf:"fixed64,7,opt,name=processing_time,json=processingTime,proto3" json:"processing_time,omitempty"` // Seconds
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileVerification) Reset() {
+	*x = FileVerification{}
+	mi := &file_fsh24_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVerification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVerification) ProtoMessage() {}
+
+func (x *FileVerification) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVerification.ProtoReflect.Descriptor instead.
+func (*FileVerification) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileVerification) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileVerification) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *FileVerification) GetExpectedSize() int64 {
+	if x != nil {
+		return x.ExpectedSize
+	}
+	return 0
+}
+
+func (x *FileVerification) GetActualSize() int64 {
+	if x != nil {
+		return x.ActualSize
+	}
+	return 0
+}
+
+func (x *FileVerification) GetExpectedHash() string {
+	if x != nil {
+		return x.ExpectedHash
+	}
+	return ""
+}
+
+func (x *FileVerification) GetActualHash() string {
+	if x != nil {
+		return x.ActualHash
+	}
+	return ""
+}
+
+func (x *FileVerification) GetProcessingTime() float64 {
+	if x != nil {
+		return x.ProcessingTime
+	}
+	return 0
+}
+
+type VerifySummary struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Verified        int32                  `protobuf:"varint,1,opt,name=verified,proto3" json:"verified,omitempty"`
+	Failed          int32                  `protobuf:"varint,2,opt,name=failed,proto3" json:"failed,omitempty"`
+	Total           int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Success         bool                   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	TotalTime       float64                `protobuf:"fixed64,5,opt,name=total_time,json=totalTime,proto3" json:"total_time,omitempty"` // Seconds
+	TotalSize       int64                  `protobuf:"varint,6,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	TotalHashedSize int64                  `protobuf:"varint,7,opt,name=total_hashed_size,json=totalHashedSize,proto3" json:"total_hashed_size,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *VerifySummary) Reset() {
+	*x = VerifySummary{}
+	mi := &file_fsh24_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifySummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifySummary) ProtoMessage() {}
+
+func (x *VerifySummary) ProtoReflect() protoreflect.Message {
+	mi := &file_fsh24_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifySummary.ProtoReflect.Descriptor instead.
+func (*VerifySummary) Descriptor() ([]byte, []int) {
+	return file_fsh24_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *VerifySummary) GetVerified() int32 {
+	if x != nil {
+		return x.Verified
+	}
+	return 0
+}
+
+func (x *VerifySummary) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *VerifySummary) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *VerifySummary) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *VerifySummary) GetTotalTime() float64 {
+	if x != nil {
+		return x.TotalTime
+	}
+	return 0
+}
+
+func (x *VerifySummary) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *VerifySummary) GetTotalHashedSize() int64 {
+	if x != nil {
+		return x.TotalHashedSize
+	}
+	return 0
+}
+
+var File_fsh24_proto protoreflect.FileDescriptor
+
+const file_fsh24_proto_rawDesc = "" +
+	"\n" +
+	"\vfsh24.proto\x12\bfsh24.v1\"A\n" +
+	"\vHashRequest\x12\x14\n" +
+	"\x05paths\x18\x01 \x03(\tR\x05paths\x12\x1c\n" +
+	"\trecursive\x18\x02 \x01(\bR\trecursive\"\xcc\x01\n" +
+	"\fHashResponse\x12\x12\n" +
+	"\x04done\x18\x01 \x01(\x05R\x04done\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12(\n" +
+	"\x04file\x18\x03 \x01(\v2\x12.fsh24.v1.FileHashH\x00R\x04file\x12+\n" +
+	"\x05error\x18\x04 \x01(\v2\x13.fsh24.v1.FileErrorH\x00R\x05error\x121\n" +
+	"\asummary\x18\x05 \x01(\v2\x15.fsh24.v1.HashSummaryH\x00R\asummaryB\b\n" +
+	"\x06result\"\xdf\x01\n" +
+	"\bFileHash\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x14\n" +
+	"\x05fsh24\x18\x03 \x01(\tR\x05fsh24\x12\x16\n" +
+	"\x06chunks\x18\x04 \x01(\x05R\x06chunks\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x14\n" +
+	"\x05crc32\x18\x06 \x01(\tR\x05crc32\x12'\n" +
+	"\x0fprocessing_time\x18\a \x01(\x01R\x0eprocessingTime\x12&\n" +
+	"\x0fmtime_unix_nano\x18\b \x01(\x03R\rmtimeUnixNano\"5\n" +
+	"\tFileError\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xaf\x01\n" +
+	"\vHashSummary\x12\x1c\n" +
+	"\talgorithm\x18\x01 \x01(\tR\talgorithm\x12\x1f\n" +
+	"\vsample_size\x18\x02 \x01(\x05R\n" +
+	"sampleSize\x12\x12\n" +
+	"\x04full\x18\x03 \x01(\bR\x04full\x12\x16\n" +
+	"\x06hashed\x18\x04 \x01(\x05R\x06hashed\x12\x16\n" +
+	"\x06failed\x18\x05 \x01(\x05R\x06failed\x12\x1d\n" +
+	"\n" +
+	"total_time\x18\x06 \x01(\x01R\ttotalTime\"d\n" +
+	"\rVerifyRequest\x12\x1b\n" +
+	"\thash_file\x18\x01 \x01(\fR\bhashFile\x12\x19\n" +
+	"\bbase_dir\x18\x02 \x01(\tR\abaseDir\x12\x1b\n" +
+	"\tfail_fast\x18\x03 \x01(\bR\bfailFast\"\xab\x01\n" +
+	"\x0eVerifyResponse\x12\x12\n" +
+	"\x04done\x18\x01 \x01(\x05R\x04done\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x120\n" +
+	"\x04file\x18\x03 \x01(\v2\x1a.fsh24.v1.FileVerificationH\x00R\x04file\x123\n" +
+	"\asummary\x18\x04 \x01(\v2\x17.fsh24.v1.VerifySummaryH\x00R\asummaryB\b\n" +
+	"\x06result\"\xf3\x01\n" +
+	"\x10FileVerification\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12#\n" +
+	"\rexpected_size\x18\x03 \x01(\x03R\fexpectedSize\x12\x1f\n" +
+	"\vactual_size\x18\x04 \x01(\x03R\n" +
+	"actualSize\x12#\n" +
+	"\rexpected_hash\x18\x05 \x01(\tR\fexpectedHash\x12\x1f\n" +
+	"\vactual_hash\x18\x06 \x01(\tR\n" +
+	"actualHash\x12'\n" +
+	"\x0fprocessing_time\x18\a \x01(\x01R\x0eprocessingTime\"\xdd\x01\n" +
+	"\rVerifySummary\x12\x1a\n" +
+	"\bverified\x18\x01 \x01(\x05R\bverified\x12\x16\n" +
+	"\x06failed\x18\x02 \x01(\x05R\x06failed\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x18\n" +
+	"\asuccess\x18\x04 \x01(\bR\asuccess\x12\x1d\n" +
+	"\n" +
+	"total_time\x18\x05 \x01(\x01R\ttotalTime\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x06 \x01(\x03R\ttotalSize\x12*\n" +
+	"\x11total_hashed_size\x18\a \x01(\x03R\x0ftotalHashedSize2\x7f\n" +
+	"\x05FSH24\x127\n" +
+	"\x04Hash\x12\x15.fsh24.v1.HashRequest\x1a\x16.fsh24.v1.HashResponse0\x01\x12=\n" +
+	"\x06Verify\x12\x17.fsh24.v1.VerifyRequest\x1a\x18.fsh24.v1.VerifyResponse0\x01B\x13Z\x11fsh24/pkg/fsh24pbb\x06proto3"
+
+var (
+	file_fsh24_proto_rawDescOnce sync.Once
+	file_fsh24_proto_rawDescData []byte
+)
+
+func file_fsh24_proto_rawDescGZIP() []byte {
+	file_fsh24_proto_rawDescOnce.Do(func() {
+		file_fsh24_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fsh24_proto_rawDesc), len(file_fsh24_proto_rawDesc)))
+	})
+	return file_fsh24_proto_rawDescData
+}
+
+var file_fsh24_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_fsh24_proto_goTypes = []any{
+	(*HashRequest)(nil),      // 0: fsh24.v1.HashRequest
+	(*HashResponse)(nil),     // 1: fsh24.v1.HashResponse
+	(*FileHash)(nil),         // 2: fsh24.v1.FileHash
+	(*FileError)(nil),        // 3: fsh24.v1.FileError
+	(*HashSummary)(nil),      // 4: fsh24.v1.HashSummary
+	(*VerifyRequest)(nil),    // 5: fsh24.v1.VerifyRequest
+	(*VerifyResponse)(nil),   // 6: fsh24.v1.VerifyResponse
+	(*FileVerification)(nil), // 7: fsh24.v1.FileVerification
+	(*VerifySummary)(nil),    // 8: fsh24.v1.VerifySummary
+}
+var file_fsh24_proto_depIdxs = []int32{
+	2, // 0: fsh24.v1.HashResponse.file:type_name -> fsh24.v1.FileHash
+	3, // 1: fsh24.v1.HashResponse.error:type_name -> fsh24.v1.FileError
+	4, // 2: fsh24.v1.HashResponse.summary:type_name -> fsh24.v1.HashSummary
+	7, // 3: fsh24.v1.VerifyResponse.file:type_name -> fsh24.v1.FileVerification
+	8, // 4: fsh24.v1.VerifyResponse.summary:type_name -> fsh24.v1.VerifySummary
+	0, // 5: fsh24.v1.FSH24.Hash:input_type -> fsh24.v1.HashRequest
+	5, // 6: fsh24.v1.FSH24.Verify:input_type -> fsh24.v1.VerifyRequest
+	1, // 7: fsh24.v1.FSH24.Hash:output_type -> fsh24.v1.HashResponse
+	6, // 8: fsh24.v1.FSH24.Verify:output_type -> fsh24.v1.VerifyResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_fsh24_proto_init() }
+func file_fsh24_proto_init() {
+	if File_fsh24_proto != nil {
+		return
+	}
+	file_fsh24_proto_msgTypes[1].OneofWrappers = []any{
+		(*HashResponse_File)(nil),
+		(*HashResponse_Error)(nil),
+		(*HashResponse_Summary)(nil),
+	}
+	file_fsh24_proto_msgTypes[6].OneofWrappers = []any{
+		(*VerifyResponse_File)(nil),
+		(*VerifyResponse_Summary)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fsh24_proto_rawDesc), len(file_fsh24_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fsh24_proto_goTypes,
+		DependencyIndexes: file_fsh24_proto_depIdxs,
+		MessageInfos:      file_fsh24_proto_msgTypes,
+	}.Build()
+	File_fsh24_proto = out.File
+	file_fsh24_proto_goTypes = nil
+	file_fsh24_proto_depIdxs = nil
+}
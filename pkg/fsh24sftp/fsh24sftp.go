@@ -0,0 +1,412 @@
+// Package fsh24sftp lets FSH24 hash and verify files on other machines over
+// SSH, with sftp://user@host/path URLs. Only the sampled bytes come over the
+// wire, same as the http:// and s3:// support in fsh24.
+//
+// It's its own package so programs that only need local files don't pull in SSH.
+package fsh24sftp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fsh24/pkg/fsh24"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultConns is how many SSH connections an Opener keeps per server by default.
+const DefaultConns = 4
+
+// Opener opens sftp:// URLs and lists the folders among them, the ones ending in a /.
+// Register it with
+//
+//	fsh24.RegisterScheme("sftp", &fsh24sftp.Opener{})
+//
+// sftp://user@host:port/srv/file is /srv/file on the server, sftp://host/~/file
+// is file in the home folder. The user defaults to the local one and the port to 22.
+//
+// It logs in with the SSH agent (SSH_AUTH_SOCK), the usual keys in ~/.ssh
+// that have no passphrase, and a password in the URL if there is one. The
+// server has to be in ~/.ssh/known_hosts, ssh to it once by hand first.
+//
+// Connections are kept open and shared between files, up to Conns per server,
+// so a folder of thousands of files doesn't log in thousands of times.
+// Close them with Close when done.
+type Opener struct {
+	// Conns is how many connections to keep per server, 0 means DefaultConns.
+	// More than one helps when hashing several files at once (Hasher.Jobs).
+	Conns int
+
+	// Timeout for connecting and logging in, 0 means 30 seconds.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	pools   map[string][]*conn // By user@host:port
+	dialing map[string]int     // Connections on their way, by user@host:port
+}
+
+// conn is a pooled connection.
+type conn struct {
+	ssh   *ssh.Client
+	sftp  *sftp.Client
+	users int // Files open on it
+	dead  bool
+}
+
+// remotePath is an sftp:// URL taken apart.
+type remotePath struct {
+	prefix string // sftp://user@host:port, as given
+	key    string // user@host:port, what connections are pooled by
+	user   string
+	pass   string
+	addr   string // host:port
+	path   string // On the server
+}
+
+// Open opens the file at an sftp:// URL.
+func (o *Opener) Open(ctx context.Context, rawURL string) (fsh24.File, error) {
+	p, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	f, err := o.open(ctx, p)
+	if err != nil && isConnError(err) && ctx.Err() == nil {
+		// The server may have hung up on a connection that sat idle in the
+		// pool, that one is gone now so try again on another
+		f, err = o.open(ctx, p)
+	}
+	if err != nil {
+		return nil, fileError(rawURL, err)
+	}
+	if f.info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%s is a folder, end it with a / to hash the files in it", rawURL)
+	}
+	return f, nil
+}
+
+// open opens a file on a pooled connection.
+func (o *Opener) open(ctx context.Context, p remotePath) (*file, error) {
+	c, err := o.get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.sftp.Open(p.path)
+	if err != nil {
+		o.put(c, err)
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		o.put(c, err)
+		return nil, err
+	}
+	return &file{File: f, info: fi, release: func(err error) { o.put(c, err) }}, nil
+}
+
+// List returns the URLs of every file under an sftp:// folder.
+// Symlinks aren't followed, same as hashing a local folder by default.
+func (o *Opener) List(ctx context.Context, folder string) ([]string, error) {
+	p, err := parseURL(folder)
+	if err != nil {
+		return nil, err
+	}
+	c, err := o.get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	walker := c.sftp.Walk(p.path)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			o.put(c, nil)
+			return nil, err
+		}
+		if err := walker.Err(); err != nil {
+			if walker.Path() == p.path {
+				o.put(c, err)
+				return nil, fileError(folder, err)
+			}
+			continue // A sub folder we can't read, same as walking locally
+		}
+		if walker.Stat().Mode().IsRegular() {
+			files = append(files, p.prefix+toURLPath(walker.Path()))
+		}
+	}
+	o.put(c, nil)
+	return files, nil
+}
+
+// Close closes every pooled connection. Files still open stop working.
+func (o *Opener) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, pool := range o.pools {
+		for _, c := range pool {
+			c.sftp.Close()
+			c.ssh.Close()
+		}
+	}
+	o.pools = nil
+	return nil
+}
+
+// get hands out the least busy connection to the server, connecting another
+// one while there are fewer than Conns.
+func (o *Opener) get(ctx context.Context, p remotePath) (*conn, error) {
+	conns := o.Conns
+	if conns <= 0 {
+		conns = DefaultConns
+	}
+
+	o.mu.Lock()
+	if o.pools == nil {
+		o.pools, o.dialing = map[string][]*conn{}, map[string]int{}
+	}
+	var best *conn
+	for _, c := range o.pools[p.key] {
+		if best == nil || c.users < best.users {
+			best = c
+		}
+	}
+	if best != nil && (best.users == 0 || len(o.pools[p.key])+o.dialing[p.key] >= conns) {
+		best.users++
+		o.mu.Unlock()
+		return best, nil
+	}
+	o.dialing[p.key]++
+	o.mu.Unlock()
+
+	// Connecting takes a while, don't hold everyone else up
+	c, err := o.dial(ctx, p)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dialing[p.key]--
+	if err != nil {
+		return nil, err
+	}
+	c.users = 1
+	o.pools[p.key] = append(o.pools[p.key], c)
+	return c, nil
+}
+
+// put gives a connection back. Connections that failed are dropped from
+// the pool and closed once nothing uses them.
+func (o *Opener) put(c *conn, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c.users--
+	if err != nil && isConnError(err) && !c.dead {
+		c.dead = true
+		for key, pool := range o.pools {
+			for i, pc := range pool {
+				if pc == c {
+					o.pools[key] = append(pool[:i:i], pool[i+1:]...)
+				}
+			}
+		}
+	}
+	if c.dead && c.users == 0 {
+		c.sftp.Close()
+		c.ssh.Close()
+	}
+}
+
+// dial connects and logs in to the server.
+func (o *Opener) dial(ctx context.Context, p remotePath) (*conn, error) {
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	hostKeys, err := knownHosts()
+	if err != nil {
+		return nil, err
+	}
+	auth, closeAgent := authMethods(p.pass)
+	defer closeAgent()
+
+	d := net.Dialer{Timeout: timeout}
+	tcp, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	tcp.SetDeadline(time.Now().Add(timeout)) // Logging in, once that's done no deadline
+	sshConn, chans, reqs, err := ssh.NewClientConn(tcp, p.addr, &ssh.ClientConfig{
+		User:            p.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		tcp.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("%s isn't in ~/.ssh/known_hosts, ssh to it once first to check and add its key", p.addr)
+		}
+		return nil, fmt.Errorf("could not log in to %s: %w", p.addr, err)
+	}
+	tcp.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	// Lots of requests in flight at once is what makes reads over a slow link fast
+	sc, err := sftp.NewClient(client, sftp.UseConcurrentReads(true))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s has no SFTP: %w", p.addr, err)
+	}
+	return &conn{ssh: client, sftp: sc}, nil
+}
+
+// knownHosts checks server keys against ~/.ssh/known_hosts.
+func knownHosts() (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(home, ".ssh", "known_hosts")
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no %s to check the server against, ssh to it once first", file)
+	}
+	return knownhosts.New(file)
+}
+
+// authMethods are the ways to log in we have: the agent, the keys without
+// a passphrase in ~/.ssh and the password. The returned func closes the agent.
+func authMethods(password string) ([]ssh.AuthMethod, func()) {
+	var methods []ssh.AuthMethod
+	closeAgent := func() {}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if a, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(a).Signers))
+			closeAgent = func() { a.Close() }
+		}
+	}
+
+	var signers []ssh.Signer
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			key, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+			if err != nil {
+				continue
+			}
+			if signer, err := ssh.ParsePrivateKey(key); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+	return methods, closeAgent
+}
+
+// parseURL takes an sftp:// URL apart.
+func parseURL(rawURL string) (remotePath, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return remotePath{}, fmt.Errorf("bad URL %s, should be sftp://user@host/path", rawURL)
+	}
+	p := remotePath{user: u.User.Username(), path: u.Path}
+	p.pass, _ = u.User.Password()
+	if p.user == "" {
+		if me, err := user.Current(); err == nil {
+			p.user = me.Username
+		}
+	}
+	p.addr = u.Host
+	if u.Port() == "" {
+		p.addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	p.key = p.user + "@" + p.addr
+
+	// Everything up to the path, exactly as given, so listed files look like the folder
+	scheme, rest, _ := strings.Cut(rawURL, "://")
+	authority, _, _ := strings.Cut(rest, "/")
+	p.prefix = scheme + "://" + authority
+
+	// /~/ is the home folder, SFTP paths without a leading / start there
+	switch {
+	case p.path == "" || p.path == "/~":
+		p.path = "."
+	case strings.HasPrefix(p.path, "/~/"):
+		p.path = "." + p.path[2:]
+	}
+	return p, nil
+}
+
+// toURLPath turns a path on the server back into the path part of a URL.
+func toURLPath(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+	return "/~/" + strings.TrimPrefix(path.Clean(p), "./")
+}
+
+// fileError makes the SFTP "no such file" error one fsh24 reports as missing.
+func fileError(rawURL string, err error) error {
+	var status *sftp.StatusError
+	if errors.Is(err, fs.ErrNotExist) || errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxNoSuchFile {
+		return &fs.PathError{Op: "open", Path: rawURL, Err: fs.ErrNotExist}
+	}
+	return fmt.Errorf("%s: %w", rawURL, err)
+}
+
+// isConnError reports whether err means the connection itself is broken,
+// rather than just one file having a problem.
+func isConnError(err error) bool {
+	var status *sftp.StatusError
+	return !errors.As(err, &status) && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission)
+}
+
+// file is an open remote file.
+type file struct {
+	*sftp.File
+	info    fs.FileInfo
+	release func(error)
+
+	mu  sync.Mutex
+	err error // The first read error, decides if the connection goes back in the pool
+}
+
+func (f *file) Size() int64 { return f.info.Size() }
+
+func (f *file) ModTime() time.Time { return f.info.ModTime() }
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		f.mu.Lock()
+		f.err = cmp.Or(f.err, err)
+		f.mu.Unlock()
+	}
+	return n, err
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.release(f.err)
+	return err
+}
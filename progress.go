@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progress keeps a "12/340 files, 1.20 GB of 4.00 GB, 85.3 MB/s, ETA 0:42" line
+// at the bottom of the console while a batch of files is hashed or verified.
+// A nil *progress does nothing, so callers don't have to check if it's on.
+type progress struct {
+	mu         sync.Mutex
+	totalFiles int
+	totalBytes int64
+	doneFiles  int
+	doneBytes  int64
+	start      time.Time
+	lineLen    int // Length of the line on screen, to blank it out again
+	stop       chan struct{}
+}
+
+// progressRefresh is how often the line is redrawn while a big file is being worked on.
+const progressRefresh = 500 * time.Millisecond
+
+// newProgress starts a progress line for files files of totalBytes bytes altogether.
+// It returns nil for a single file or when stdout isn't a console (piped or redirected).
+func newProgress(files int, totalBytes int64) *progress {
+	if files < 2 || !isTerminal(os.Stdout) {
+		return nil
+	}
+	p := &progress{
+		totalFiles: files,
+		totalBytes: totalBytes,
+		start:      time.Now(),
+		stop:       make(chan struct{}),
+	}
+	go func() {
+		ticker := time.NewTicker(progressRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				p.draw()
+				p.mu.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// isTerminal reports if f is a console rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// print runs print with the progress line out of the way, then puts it back.
+func (p *progress) print(print func()) {
+	if p == nil {
+		print()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	print()
+	p.draw()
+}
+
+// fileDone counts a finished file of size bytes, see print.
+func (p *progress) fileDone(size int64, print func()) {
+	if p == nil {
+		print()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	print()
+	p.doneFiles++
+	p.doneBytes += max(size, 0)
+	p.draw()
+}
+
+// finish stops redrawing and removes the line.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+// clear blanks out the line with spaces. Plain spaces rather than ANSI codes
+// since old Windows consoles print those as is. Call with mu held.
+func (p *progress) clear() {
+	if p.lineLen > 0 {
+		fmt.Print("\r" + strings.Repeat(" ", p.lineLen) + "\r")
+		p.lineLen = 0
+	}
+}
+
+// draw prints the line, without a new line so the next one can go over it.
+// Call with mu held.
+func (p *progress) draw() {
+	elapsed := time.Since(p.start).Seconds()
+	line := fmt.Sprintf("%d/%d files", p.doneFiles, p.totalFiles)
+	if p.totalBytes > 0 {
+		line += fmt.Sprintf(", %s of %s", formatBytes(p.doneBytes), formatBytes(p.totalBytes))
+	}
+
+	eta := "--"
+	if elapsed > 0 && p.doneBytes > 0 && p.totalBytes > 0 {
+		rate := float64(p.doneBytes) / elapsed
+		line += fmt.Sprintf(", %s/s", formatBytes(int64(rate)))
+		eta = formatDuration(float64(p.totalBytes-p.doneBytes) / rate)
+	} else if elapsed > 0 && p.doneFiles > 0 && p.totalBytes == 0 {
+		eta = formatDuration(float64(p.totalFiles-p.doneFiles) * elapsed / float64(p.doneFiles))
+	}
+	line += ", ETA " + eta
+
+	p.clear()
+	fmt.Print(line)
+	p.lineLen = len(line)
+}
+
+// formatBytes prints a byte count the short way, eg. 1.20 GB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	i := -1
+	for value >= unit && i < 4 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.2f %cB", value, "KMGTP"[i])
+}
+
+// formatDuration prints seconds as m:ss, or h:mm:ss once it's over an hour.
+func formatDuration(seconds float64) string {
+	s := int64(seconds + 0.5)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink makes dst a copy of src that shares its blocks, clonefile. Only
+// APFS can do it.
+func reflink(src, dst string) error {
+	if err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW); err != nil {
+		return &os.PathError{Op: "reflink", Path: src, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// reflink can't be done here, there's no way to ask for it.
+func reflink(src, dst string) error {
+	return errors.New("reflinks are only supported on Linux and macOS")
+}
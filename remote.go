@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+	"fsh24/pkg/fsh24sftp"
+)
+
+// The library leaves reading URLs up to the program, fsh24 takes them
+// anywhere it takes a file.
+func init() {
+	fsh24.RegisterScheme("http", fsh24.HTTPOpener{})
+	fsh24.RegisterScheme("https", fsh24.HTTPOpener{})
+	fsh24.RegisterScheme("s3", &fsh24.S3Opener{})
+	fsh24.RegisterScheme("gs", fsh24.GCSOpener{})
+	fsh24.RegisterScheme("sftp", &fsh24sftp.Opener{})
+}
+
+// walkRemote lists the files in a bucket or under a prefix going by the
+// options, the same as walk does for a local folder. Names starting with a
+// dot count as hidden, there are no symlinks.
+func (o walkOptions) walkRemote(root string) ([]string, error) {
+	maxDepth := o.MaxDepth
+	if !o.Recursive {
+		maxDepth = 1
+	}
+	if !strings.HasSuffix(root, "/") {
+		root += "/" // The bucket itself
+	}
+
+	urls, err := fsh24.List(context.Background(), root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, u := range urls {
+		rel := strings.TrimPrefix(u, root)
+		parts := strings.Split(rel, "/")
+		switch {
+		case maxDepth > 0 && len(parts) > maxDepth:
+			o.skip(u, "max depth")
+		case o.SkipHidden && slices.ContainsFunc(parts, func(p string) bool { return strings.HasPrefix(p, ".") }):
+			o.skip(u, "hidden")
+		case o.excludedRemote(parts):
+			o.skip(u, "excluded")
+		case !o.included(rel):
+			o.skip(u, "not included")
+		default:
+			files = append(files, u)
+		}
+	}
+	return files, nil
+}
+
+// excludedRemote reports whether a listed file, split on its slashes, or one
+// of the "folders" it's in matches an exclude pattern.
+func (o walkOptions) excludedRemote(parts []string) bool {
+	for i := range parts {
+		if o.excluded(strings.Join(parts[:i+1], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath is filepath.Abs, except URLs are left alone.
+func absPath(path string) (string, error) {
+	if fsh24.IsRemote(path) {
+		return path, nil
+	}
+	return filepath.Abs(path)
+}
+
+// statFile returns the size and modification time of a file or URL, or of
+// a file inside an archive. URLs without a Last-Modified header give a zero time.
+func statFile(path string) (int64, time.Time, error) {
+	if !fsh24.IsRemote(path) && !fsh24.IsDevice(path) { // Devices Stat as 0 bytes
+		fi, err := os.Stat(path)
+		if err == nil {
+			return fi.Size(), fi.ModTime(), nil
+		}
+		if !os.IsNotExist(err) || !fsh24.IsArchiveMember(path) {
+			return 0, time.Time{}, err
+		}
+	}
+	f, err := fsh24.Open(context.Background(), path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer f.Close()
+	return f.Size(), f.ModTime(), nil
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// reparseKind is always "", junctions and cloud placeholders are a Windows
+// thing. Linux and Mac mounts are just folders.
+func reparseKind(entry fs.DirEntry) string {
+	return ""
+}
+
+// realPath is the path dir really is, through symlinks. Absolute, or "."
+// and the link back to it would look like two folders.
+func realPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,66 @@
+//go:build windows
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"fsh24/pkg/fsh24"
+)
+
+// cloudAttributes are set on files that aren't really on the disk, OneDrive,
+// Dropbox and the like "Files On-Demand" placeholders, or anything offline.
+// Reading one downloads it.
+const cloudAttributes = windows.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS | windows.FILE_ATTRIBUTE_RECALL_ON_OPEN | windows.FILE_ATTRIBUTE_OFFLINE
+
+// reparseKind says if a folder entry is a junction or a cloud placeholder,
+// "" for anything else. Go doesn't call a junction a folder, it's an
+// irregular file with the Directory attribute set, and so are volume mount
+// points. Symlinks are ModeSymlink and handled on their own.
+func reparseKind(entry fs.DirEntry) string {
+	info, err := entry.Info()
+	if err != nil {
+		return ""
+	}
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return ""
+	}
+	mode := info.Mode()
+	if mode&fs.ModeSymlink != 0 {
+		return ""
+	}
+	if attrs.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT != 0 &&
+		attrs.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY != 0 && !mode.IsDir() {
+		return reparseJunction
+	}
+	if !mode.IsDir() && attrs.FileAttributes&cloudAttributes != 0 {
+		return reparseCloud
+	}
+	return ""
+}
+
+// realPath is the path dir really is, through junctions and symlinks, so
+// the walk can tell it's been somewhere before. filepath.EvalSymlinks leaves
+// junctions alone, the handle knows where it ended up.
+func realPath(dir string) (string, error) {
+	name, err := windows.UTF16PtrFromString(fsh24.LongPath(dir))
+	if err != nil {
+		return "", err
+	}
+	h, err := windows.CreateFile(name, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0) // Backup semantics to open a folder
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetFinalPathNameByHandle(h, &buf[0], uint32(len(buf)), 0)
+	if err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:n]), nil
+}
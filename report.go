@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"fsh24/pkg/fsh24"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Report formats for --format. These print the results (or save them with -o)
+// instead of writing a .fsh24 file, like -j does.
+const (
+	reportJSON   = "json"
+	reportCSV    = "csv"
+	reportNDJSON = "ndjson" // One object per file, printed as soon as it's done
+	reportYAML   = "yaml"   // Same structure as the JSON
+)
+
+var reportFormats = []string{reportJSON, reportCSV, reportNDJSON, reportYAML, reportHashdeep, reportDFXML}
+
+// hashOutput is the JSON document printed after hashing, see
+// schema/hash.schema.json.
+type hashOutput struct {
+	SchemaVersion          int `json:"schema_version" yaml:"schema_version"`
+	fsh24.TotalHashSummary `yaml:",inline"`
+}
+
+// verifyOutput is the JSON document printed after verifying, see
+// schema/verify.schema.json.
+type verifyOutput struct {
+	SchemaVersion int                            `json:"schema_version" yaml:"schema_version"`
+	Summary       fsh24.VerificationSummary      `json:"summary" yaml:"summary"`
+	Results       []fsh24.FileVerificationResult `json:"results" yaml:"results"`
+}
+
+// hashReport renders the hash results in the given report format.
+func hashReport(format string, summary fsh24.TotalHashSummary) ([]byte, error) {
+	switch format {
+	case reportCSV:
+		rows := [][]string{{"path", "size", "fsh24", "chunks", "coverage", "time"}}
+		for _, r := range summary.Files {
+			rows = append(rows, []string{
+				r.Filepath,
+				strconv.FormatInt(r.FileSize, 10),
+				r.FSH24,
+				strconv.Itoa(r.Chunks),
+				strconv.FormatFloat(r.CoveragePercent, 'f', 4, 64),
+				strconv.FormatFloat(r.ProcessingTime, 'f', 3, 64),
+			})
+		}
+		return csvBytes(rows)
+	case reportYAML:
+		return yaml.Marshal(hashOutput{schemaVersion, summary})
+	case reportHashdeep:
+		return hashdeepReport(summary), nil
+	case reportDFXML:
+		return dfxmlReport(summary)
+	default:
+		return json.MarshalIndent(hashOutput{schemaVersion, summary}, "", "  ")
+	}
+}
+
+// verifyReport renders the verification results in the given report format.
+func verifyReport(format string, summary fsh24.VerificationSummary, results []fsh24.FileVerificationResult) ([]byte, error) {
+	switch format {
+	case reportCSV:
+		rows := [][]string{{"path", "status", "expected_size", "actual_size", "expected_hash", "actual_hash", "time", "change"}}
+		for _, r := range results {
+			rows = append(rows, []string{
+				r.Filepath,
+				r.Status,
+				strconv.FormatInt(r.ExpectedSize, 10),
+				strconv.FormatInt(r.ActualSize, 10),
+				r.ExpectedHash,
+				r.ActualHash,
+				strconv.FormatFloat(r.ProcessingTime, 'f', 3, 64),
+				r.Change,
+			})
+		}
+		return csvBytes(rows)
+	case reportYAML:
+		return yaml.Marshal(verifyOutput{SchemaVersion: schemaVersion, Summary: summary, Results: results})
+	default:
+		return json.MarshalIndent(verifyOutput{SchemaVersion: schemaVersion, Summary: summary, Results: results}, "", "  ")
+	}
+}
+
+// cmpReport renders the cmp results in the given report format.
+// ndjson is one line per file, there is nothing to stream.
+func cmpReport(format string, out cmpOutput) ([]byte, error) {
+	switch format {
+	case reportCSV:
+		rows := [][]string{{"path", "status"}}
+		for _, f := range out.Files {
+			rows = append(rows, []string{f.Path, f.Status})
+		}
+		return csvBytes(rows)
+	case reportNDJSON:
+		var buf bytes.Buffer
+		w := &ndjsonWriter{w: &buf}
+		for _, f := range out.Files {
+			w.write(f)
+		}
+		return buf.Bytes(), nil
+	case reportYAML:
+		return yaml.Marshal(out)
+	default:
+		return json.MarshalIndent(out, "", "  ")
+	}
+}
+
+// coverageReport renders the coverage of fsh24 coverage in the given report
+// format, csv and ndjson have a line per file.
+func coverageReport(format string, out coverageOutput) ([]byte, error) {
+	switch format {
+	case reportCSV:
+		rows := [][]string{{"path", "size", "sampled", "sampled_percent", "largest_gap", "miss_byte", "miss_sector", "miss_mb"}}
+		for _, f := range out.Files {
+			rows = append(rows, []string{
+				f.Path,
+				strconv.FormatInt(f.Size, 10),
+				strconv.FormatInt(f.Sampled, 10),
+				strconv.FormatFloat(f.Percent, 'f', 4, 64),
+				strconv.FormatInt(f.LargestGap, 10),
+				strconv.FormatFloat(f.MissByte, 'f', 6, 64),
+				strconv.FormatFloat(f.MissSector, 'f', 6, 64),
+				strconv.FormatFloat(f.MissMB, 'f', 6, 64),
+			})
+		}
+		return csvBytes(rows)
+	case reportNDJSON:
+		var buf bytes.Buffer
+		w := &ndjsonWriter{w: &buf}
+		for _, f := range out.Files {
+			w.write(f)
+		}
+		return buf.Bytes(), nil
+	case reportYAML:
+		return yaml.Marshal(out)
+	default:
+		return json.MarshalIndent(out, "", "  ")
+	}
+}
+
+// dupesReport renders the --find-dupes results in the given report format.
+// csv and ndjson have a line per file with the number of the set it's in.
+func dupesReport(format string, out dupesOutput) ([]byte, error) {
+	switch format {
+	case reportCSV, reportNDJSON:
+		type dupeFile struct {
+			Set    int    `json:"set"`
+			Size   int64  `json:"size"`
+			FSH24  string `json:"fsh24"`
+			Path   string `json:"path"`
+			Linked bool   `json:"linked,omitempty"` // Replaced by --dedupe
+		}
+		var files []dupeFile
+		for i, set := range out.Sets {
+			for _, f := range set.Files {
+				files = append(files, dupeFile{i + 1, set.Size, set.FSH24, f, slices.Contains(set.Linked, f)})
+			}
+		}
+		if format == reportNDJSON {
+			var buf bytes.Buffer
+			w := &ndjsonWriter{w: &buf}
+			for _, f := range files {
+				w.write(f)
+			}
+			return buf.Bytes(), nil
+		}
+		rows := [][]string{{"set", "size", "fsh24", "path"}}
+		if out.Dedupe != "" {
+			rows[0] = append(rows[0], "linked")
+		}
+		for _, f := range files {
+			row := []string{strconv.Itoa(f.Set), strconv.FormatInt(f.Size, 10), f.FSH24, f.Path}
+			if out.Dedupe != "" {
+				row = append(row, strconv.FormatBool(f.Linked))
+			}
+			rows = append(rows, row)
+		}
+		return csvBytes(rows)
+	case reportYAML:
+		return yaml.Marshal(out)
+	default:
+		return json.MarshalIndent(out, "", "  ")
+	}
+}
+
+// jsonOut is --json-out, where to also save the JSON report.
+var jsonOut string
+
+// writeReportFile saves a report to a file, for --json-out.
+func writeReportFile(path string, data []byte) error {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// csvBytes writes rows out as CSV, header row first.
+func csvBytes(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ndjsonWriter writes one JSON object per line as the results come in,
+// so a long run can be watched or piped into jq while it's still going.
+type ndjsonWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write prints v as a single line. Safe to call from multiple goroutines.
+func (n *ndjsonWriter) write(v any) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshalling JSON: %v\n", err)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.w.Write(append(line, '\n'))
+}
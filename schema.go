@@ -0,0 +1,34 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// schemaVersion is the version of the JSON the hash and verify reports
+// print, their schema_version. Fields can be added without changing it,
+// renaming or removing one, or changing what it means, bumps it.
+const schemaVersion = 1
+
+// schemas are the JSON Schemas of the reports, fsh24 schema prints them.
+//
+//go:embed schema/*.schema.json
+var schemas embed.FS
+
+// schemaNames are the schemas fsh24 schema knows.
+var schemaNames = []string{"hash", "verify"}
+
+// printSchema prints the JSON Schema of the report called name.
+func printSchema(name string) error {
+	if !slices.Contains(schemaNames, name) {
+		return fmt.Errorf("no schema called %q, use one of: %s", name, strings.Join(schemaNames, ", "))
+	}
+	data, err := schemas.ReadFile("schema/" + name + ".schema.json")
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
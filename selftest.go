@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// testVectorsJSON are the known good hashes of the synthetic files, the same
+// list tests/vectors.py checks fsh24.py against, so the two can't drift apart.
+//
+//go:embed tests/vectors.json
+var testVectorsJSON []byte
+
+// testVector is one synthetic file and the FSH24 it has to get. The settings
+// left empty are the defaults. Python only does the defaults, the rest are
+// only checked here.
+type testVector struct {
+	Size   int64  `json:"size"`
+	Algo   string `json:"algo,omitempty"`
+	Bytes  int    `json:"bytes,omitempty"`
+	Sample int    `json:"sample,omitempty"`
+	Full   bool   `json:"full,omitempty"`
+	Key    string `json:"key,omitempty"`
+	FSH24  string `json:"fsh24"`
+	Chunks int    `json:"chunks"`
+}
+
+// String describes the vector for the selftest output, eg. "10,485,767 bytes blake3 full".
+func (v testVector) String() string {
+	desc := []string{formatNumber(v.Size) + " bytes"}
+	if v.Algo != "" {
+		desc = append(desc, v.Algo)
+	}
+	if v.Bytes != 0 {
+		desc = append(desc, fmt.Sprintf("%d byte digest", v.Bytes))
+	}
+	if v.Sample != 0 {
+		desc = append(desc, formatBytes(int64(v.Sample))+" samples")
+	}
+	if v.Full {
+		desc = append(desc, "full")
+	}
+	if v.Key != "" {
+		desc = append(desc, "keyed")
+	}
+	return strings.Join(desc, " ")
+}
+
+// hasher is a Hasher with the vector's settings.
+func (v testVector) hasher() *fsh24.Hasher {
+	h := fsh24.NewHasher()
+	if v.Algo != "" {
+		h.Algorithm = v.Algo
+	}
+	h.DigestBytes = v.Bytes
+	h.SampleSize = v.Sample
+	h.Full = v.Full
+	h.Key = []byte(v.Key)
+	return h
+}
+
+// selftestFileLimit is the biggest synthetic file written to disk and hashed
+// like any other file. The bigger ones are read straight from the generator,
+// gigabytes of test files would take longer to write than to check.
+const selftestFileLimit = 32 * 1024 * 1024
+
+// synthetic is the content of the test files, byte i is the low byte of
+// i ^ i>>8 ^ i>>16 ^ i>>24. Every sample of it is different, so a sample
+// taken from the wrong place gives the wrong hash, and it's quick to make
+// in any language.
+type synthetic int64
+
+func (s synthetic) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(s) {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), int64(s)-off)
+	for i := range n {
+		x := off + i
+		p[i] = byte(x ^ x>>8 ^ x>>16 ^ x>>24)
+	}
+	if n < int64(len(p)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+// selftest hashes the synthetic files and checks them against the vectors.
+// It returns how many didn't match.
+func selftest(ctx context.Context, quiet bool) (int, error) {
+	var vectors []testVector
+	if err := json.Unmarshal(testVectorsJSON, &vectors); err != nil {
+		return 0, fmt.Errorf("broken test vectors: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "fsh24-selftest-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	failed := 0
+	for i, v := range vectors {
+		var (
+			got    string
+			chunks int
+		)
+		h := v.hasher()
+		if v.Size <= selftestFileLimit {
+			path := filepath.Join(dir, fmt.Sprintf("vector%d.bin", i))
+			if err := writeSynthetic(path, v.Size); err != nil {
+				return failed, err
+			}
+			got, chunks, err = h.Sum(ctx, path)
+		} else {
+			got, chunks, err = h.SumReaderAt(ctx, synthetic(v.Size), v.Size)
+		}
+		if err != nil {
+			return failed, err
+		}
+
+		if strings.EqualFold(got, v.FSH24) && chunks == v.Chunks {
+			if !quiet {
+				fmt.Println(colorize(colorGreen, "OK      "+v.String()))
+			}
+			continue
+		}
+		failed++
+		fmt.Println(colorize(colorRed, "FAILED  "+v.String()))
+		fmt.Printf("        expected %s (%d chunks)\n        got      %s (%d chunks)\n", strings.ToUpper(v.FSH24), v.Chunks, strings.ToUpper(got), chunks)
+	}
+	run.Total, run.OK, run.Failed = len(vectors), len(vectors)-failed, failed
+	return failed, nil
+}
+
+// writeSynthetic writes a synthetic file of size bytes to path.
+func writeSynthetic(path string, size int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, io.NewSectionReader(synthetic(size), 0, size))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
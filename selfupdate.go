@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// releaseKey is the public key the release hash file is signed with, the
+// base64 line of its .pub. Release builds set it with -ldflags
+// "-X main.releaseKey=...", without it fsh24 self-update needs
+// --trusted-key.
+var releaseKey string
+
+// releasesURL is where fsh24 self-update looks for the latest release.
+const releasesURL = "https://api.github.com/repos/MobCat/fsh24/releases/latest"
+
+// releaseHashFile is the signed --sha256 hash file of the release zips,
+// published with every release.
+const releaseHashFile = "checksums.fsh24"
+
+// maxReleaseSize is the biggest download fsh24 self-update takes, a release
+// zip is a few MB.
+const maxReleaseSize = 256 << 20
+
+// releaseAssets is the zip each platform's build comes in.
+var releaseAssets = map[string]string{
+	"windows/amd64": "fsh24-Windows-x64.zip",
+	"linux/amd64":   "fsh24-linux-amd64.zip",
+	"darwin/arm64":  "fsh24-Mac-arm64.zip",
+	"linux/arm64":   "fsh24-RaspberryPi-arm64.zip",
+}
+
+// release is the bits of a GitHub release fsh24 self-update needs.
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL is the download link of the file called name, "" if the release
+// doesn't have one.
+func (r *release) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// selfUpdate replaces this fsh24 with the latest release, if it's newer.
+// The zip's SHA-256 has to match the release hash file, and that has to be
+// signed by releaseKey or one of the --trusted-key keys, or nothing is
+// touched. With dryRun it only says if there's an update.
+func selfUpdate(ctx context.Context, dryRun bool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	os.Remove(exe + ".old") // Left by the last update on Windows
+
+	keys := slices.Clone(trustedKeys)
+	if releaseKey != "" {
+		key, err := fsh24.ParsePublicKey([]byte("-----BEGIN PUBLIC KEY-----\n" + releaseKey + "\n-----END PUBLIC KEY-----\n"))
+		if err != nil {
+			return fmt.Errorf("the release key built in is broken: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return errors.New("this build has no release key to check updates with, give it one with --trusted-key")
+	}
+
+	fmt.Println("Checking for updates...")
+	body, err := download(ctx, releasesURL)
+	if err != nil {
+		return fmt.Errorf("could not check for updates: %w", err)
+	}
+	var rel release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return fmt.Errorf("could not check for updates: %w", err)
+	}
+	if !newerVersion(rel.TagName, version) {
+		fmt.Printf("fsh24 %s is up to date\n", version)
+		return nil
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	asset := releaseAssets[platform]
+	if asset == "" {
+		return fmt.Errorf("fsh24 %s is out, but there's no release build for %s, build it from source", rel.TagName, platform)
+	}
+	if dryRun {
+		fmt.Printf("fsh24 %s is out, you have %s. Run fsh24 self-update to get it\n", rel.TagName, version)
+		return nil
+	}
+
+	// The hash file first, no point downloading a zip there's nothing to check against
+	want, err := releaseSHA256(ctx, &rel, asset, keys)
+	if err != nil {
+		return err
+	}
+	zipURL := rel.assetURL(asset)
+	if zipURL == "" {
+		return fmt.Errorf("release %s has no %s", rel.TagName, asset)
+	}
+	fmt.Printf("Downloading %s %s...\n", asset, rel.TagName)
+	zipped, err := download(ctx, zipURL)
+	if err != nil {
+		return fmt.Errorf("could not download %s: %w", asset, err)
+	}
+	sum := sha256.Sum256(zipped)
+	if got := strings.ToUpper(hex.EncodeToString(sum[:])); got != want {
+		return fmt.Errorf("%s doesn't match the release hash file, SHA-256 %s instead of %s. Not updating", asset, got, want)
+	}
+
+	newExe, err := unzipExe(zipped)
+	if err != nil {
+		return fmt.Errorf("%s: %w", asset, err)
+	}
+	if err := replaceExe(exe, newExe); err != nil {
+		return fmt.Errorf("could not replace %s: %w", exe, err)
+	}
+	fmt.Printf("Updated fsh24 %s to %s\n", version, rel.TagName)
+	return nil
+}
+
+// releaseSHA256 gets the SHA-256 of asset from the hash file of rel, which
+// has to be signed by one of keys.
+func releaseSHA256(ctx context.Context, rel *release, asset string, keys []ed25519.PublicKey) (string, error) {
+	url := rel.assetURL(releaseHashFile)
+	if url == "" {
+		return "", fmt.Errorf("release %s has no %s to check the download with. Not updating", rel.TagName, releaseHashFile)
+	}
+	data, err := download(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("could not download %s: %w", releaseHashFile, err)
+	}
+	m, err := fsh24.ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("%s of release %s: %w. Not updating", releaseHashFile, rel.TagName, err)
+	}
+	if m.SignedBy == nil {
+		return "", fmt.Errorf("%s of release %s isn't signed. Not updating", releaseHashFile, rel.TagName)
+	}
+	if !slices.ContainsFunc(keys, func(k ed25519.PublicKey) bool { return k.Equal(m.SignedBy) }) {
+		return "", fmt.Errorf("%s of release %s is signed by key %s, which isn't the release key. Not updating", releaseHashFile, rel.TagName, fsh24.KeyID(m.SignedBy))
+	}
+	for _, e := range m.Entries {
+		if filepath.Base(filepath.FromSlash(e.Path)) == asset && e.SHA256 != "" {
+			return strings.ToUpper(e.SHA256), nil
+		}
+	}
+	return "", fmt.Errorf("%s of release %s has no SHA-256 for %s. Not updating", releaseHashFile, rel.TagName, asset)
+}
+
+// download gets url, up to maxReleaseSize.
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "fsh24/"+version)
+	client := http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxReleaseSize {
+		return nil, fmt.Errorf("%s is way too big for a release", url)
+	}
+	return data, nil
+}
+
+// unzipExe takes the fsh24 binary out of a release zip, the one file in it
+// that isn't a .bat.
+func unzipExe(zipped []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		name := strings.ToLower(filepath.Base(f.Name))
+		if f.FileInfo().IsDir() || !strings.HasPrefix(name, "fsh24") || strings.HasSuffix(name, ".bat") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(io.LimitReader(r, maxReleaseSize))
+	}
+	return nil, errors.New("no fsh24 in it")
+}
+
+// replaceExe swaps the binary at exe for data. The new one is written next
+// to it and renamed over it, so a failed update leaves the old one working.
+// Windows won't replace a running exe, but it will rename it, the old one is
+// left as exe.old and removed by the next update.
+func replaceExe(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, data, info.Mode().Perm()|0o100); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		if err := os.Rename(exe, exe+".old"); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, exe); err != nil {
+			os.Rename(exe+".old", exe)
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// versionNumbers are the runs of digits in a version, v1.2.10 is 1 2 10 and
+// 20250715 is just that.
+var versionNumbers = regexp.MustCompile(`\d+`)
+
+// newerVersion reports whether the release tagged tag is newer than current.
+// A dev build is older than any release.
+func newerVersion(tag, current string) bool {
+	if current == "dev" {
+		return tag != ""
+	}
+	a, b := versionNumbers.FindAllString(tag, -1), versionNumbers.FindAllString(current, -1)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, _ := strconv.Atoi(a[i])
+		y, _ := strconv.Atoi(b[i])
+		if x != y {
+			return x > y
+		}
+	}
+	return len(a) > len(b)
+}
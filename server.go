@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// defaultListen is where serve listens without --listen. Only this machine
+// by default, anyone who can reach it can hash any file the server can read.
+const defaultListen = "localhost:8080"
+
+// serveKeepJobs is how many finished jobs serve remembers, oldest go first.
+const serveKeepJobs = 100
+
+// maxVerifyBody is the biggest hash file POST /verify takes, gzipped or
+// not. Anyone who can reach serve can post one.
+const maxVerifyBody = 256 << 20
+
+// Job statuses.
+const (
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// job is a hash or verify request, running or finished. It's what the
+// /jobs endpoints return.
+type job struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"` // hash or verify
+	Status   string     `json:"status"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+
+	// Result is a hashOutput for hash jobs and a verifyOutput
+	// for verify jobs, once done.
+	Result any `json:"result,omitempty"`
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// hashRequest is the body of POST /hash.
+type hashRequest struct {
+	Paths     []string `json:"paths"` // Files and folders on the server
+	Recursive bool     `json:"recursive"`
+}
+
+// server is the HTTP API of serve.
+type server struct {
+	ctx    context.Context // Cancelled when the server stops, and every job with it
+	hasher *fsh24.Hasher
+	walk   walkOptions
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	order  []string // Job IDs, oldest first
+	nextID int
+}
+
+// serveHTTP runs the API on listen until ctx is cancelled:
+//
+//	POST   /hash         {"paths": [...], "recursive": true}, hash server side files
+//	POST   /verify       a .fsh24 file as the body, ?base_dir= for its relative paths
+//	GET    /jobs         every job, without results
+//	GET    /jobs/{id}    one job, with its results once done
+//	DELETE /jobs/{id}    cancel a running job
+//	GET    /metrics      Prometheus metrics, see metrics
+//
+// POST requests return the new job straight away, or once it's finished with ?wait=1.
+func serveHTTP(ctx context.Context, listen string, hasher *fsh24.Hasher, walk walkOptions) error {
+	s := &server{ctx: ctx, hasher: hasher, walk: walk, jobs: map[string]*job{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /hash", s.handleHash)
+	mux.HandleFunc("POST /verify", s.handleVerify)
+	mux.HandleFunc("GET /jobs", s.handleJobs)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	mux.Handle("GET /metrics", stats)
+
+	srv := &http.Server{Addr: listen, Handler: logRequests(mux)}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Listening on %s. Ctrl+C to stop\n", listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleHash starts hashing the files and folders in the request.
+func (s *server) handleHash(w http.ResponseWriter, r *http.Request) {
+	var req hashRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "bad request: %v", err)
+		return
+	}
+	if len(req.Paths) == 0 || slices.Contains(req.Paths, "-") {
+		httpError(w, http.StatusBadRequest, "paths needs at least one file or folder")
+		return
+	}
+
+	walk := s.walk
+	walk.Recursive = walk.Recursive || req.Recursive
+	hasher := *s.hasher
+	s.start(w, r, "hash", func(ctx context.Context) (any, error) {
+		files, err := expandFilePaths(req.Paths, walk)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, errors.New("no files found to hash")
+		}
+		startTime := time.Now()
+		results, errs := hasher.HashFiles(ctx, files)
+		stats.addHashed(&hasher, results, len(errs))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%d files couldn't be hashed, first: %w", len(errs), errs[0])
+		}
+		return hashOutput{schemaVersion, hasher.HashSummary(results, time.Since(startTime).Seconds())}, nil
+	})
+}
+
+// handleVerify starts verifying the .fsh24 file in the request body.
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	m, err := fsh24.ParseManifest(http.MaxBytesReader(w, r.Body, maxVerifyBody))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		httpError(w, http.StatusRequestEntityTooLarge, "hash file is bigger than %d MB", maxVerifyBody>>20)
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "bad hash file: %v", err)
+		return
+	}
+	baseDir := r.URL.Query().Get("base_dir")
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	verifier := &fsh24.Verifier{Hasher: s.hasher}
+	s.start(w, r, "verify", func(ctx context.Context) (any, error) {
+		summary, results, err := verifier.Verify(ctx, m, baseDir)
+		stats.addVerified(results)
+		if err != nil {
+			return nil, err
+		}
+		return verifyOutput{SchemaVersion: schemaVersion, Summary: summary, Results: results}, nil
+	})
+}
+
+// start runs fn as a new job and answers with it.
+func (s *server) start(w http.ResponseWriter, r *http.Request, kind string, fn func(ctx context.Context) (any, error)) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{Kind: kind, Status: jobRunning, Started: time.Now(), cancel: cancel, done: make(chan struct{})}
+
+	s.mu.Lock()
+	s.nextID++
+	j.ID = strconv.Itoa(s.nextID)
+	s.jobs[j.ID] = j
+	s.order = append(s.order, j.ID)
+	s.forgetOld()
+	s.mu.Unlock()
+
+	stats.jobs(1)
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		defer stats.jobs(-1)
+		result, err := fn(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now()
+		j.Finished = &now
+		switch {
+		case ctx.Err() != nil:
+			j.Status = jobCancelled
+		case err != nil:
+			j.Status, j.Error = jobFailed, err.Error()
+		default:
+			j.Status, j.Result = jobDone, result
+		}
+	}()
+
+	if wait, _ := strconv.ParseBool(r.URL.Query().Get("wait")); wait {
+		select {
+		case <-j.done:
+		case <-r.Context().Done():
+			cancel() // Nobody's waiting for it any more
+			return
+		}
+		s.writeJob(w, http.StatusOK, j, true)
+		return
+	}
+	s.writeJob(w, http.StatusAccepted, j, false)
+}
+
+// forgetOld drops the oldest finished jobs past serveKeepJobs. s.mu must be held.
+func (s *server) forgetOld() {
+	for i := 0; len(s.order) > serveKeepJobs && i < len(s.order); {
+		if s.jobs[s.order[i]].Status == jobRunning {
+			i++
+			continue
+		}
+		delete(s.jobs, s.order[i])
+		s.order = slices.Delete(s.order, i, i+1)
+	}
+}
+
+// handleJobs lists every job, without their results.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]job, 0, len(s.order))
+	for _, id := range s.order {
+		j := *s.jobs[id]
+		j.Result = nil
+		list = append(list, j)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleJob returns one job, results included.
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j != nil {
+		s.writeJob(w, http.StatusOK, j, true)
+	}
+}
+
+// handleCancel stops a running job.
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j := s.job(w, r)
+	if j == nil {
+		return
+	}
+	j.cancel()
+	<-j.done
+	s.writeJob(w, http.StatusOK, j, false)
+}
+
+// job looks up the job in the URL, answering 404 if there's no such job.
+func (s *server) job(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if j == nil {
+		httpError(w, http.StatusNotFound, "no job %s", r.PathValue("id"))
+	}
+	return j
+}
+
+// writeJob answers with a copy of j, taken under the lock.
+func (s *server) writeJob(w http.ResponseWriter, code int, j *job, withResult bool) {
+	s.mu.Lock()
+	out := *j
+	s.mu.Unlock()
+	if !withResult {
+		out.Result = nil
+	}
+	writeJSON(w, code, out)
+}
+
+// writeJSON answers with v as JSON.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// httpError answers with {"error": "..."}.
+func httpError(w http.ResponseWriter, code int, format string, args ...any) {
+	writeJSON(w, code, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// logRequests prints a timestamped line for every request.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("%s %s %s %s\n", time.Now().Format(logTime), r.RemoteAddr, r.Method, r.URL)
+		next.ServeHTTP(w, r)
+	})
+}
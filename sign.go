@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+var (
+	signKey          string // --sign, the GPG key to sign hash files with
+	clearSign        bool   // --clearsign, put the signature in the hash file instead of a .asc
+	requireSignature bool   // --require-signature
+
+	signingKey  ed25519.PrivateKey  // --sign-key, signs hash files with a trailer line
+	trustedKeys []ed25519.PublicKey // --trusted-key
+)
+
+// defaultSignKey is --sign without a key, gpg's default key.
+const defaultSignKey = "default"
+
+// writeHashFile saves m to filename, signed with --sign-key and --sign if set.
+func writeHashFile(m *fsh24.Manifest, filename string) error {
+	m.SignKey = signingKey
+	if normalizeUnicode {
+		m.NormalizeUnicode()
+	}
+	write := replaceFile // A write that fails halfway leaves the old file, -o can be one of the inputs
+	if info, err := os.Stat(filename); err == nil && !info.Mode().IsRegular() {
+		write = func(m *fsh24.Manifest, filename string) error { return m.WriteFile(filename) } // /dev/stdout and the like
+	}
+	if err := write(m, filename); err != nil {
+		return err
+	}
+	runHashFile = filename
+	return signHashFile(filename)
+}
+
+// signHashFile signs filename with gpg, if --sign is set. Normally that's a
+// detached filename.asc next to it, with --clearsign the file itself is
+// replaced by a clear-signed copy, which ParseManifest still reads.
+func signHashFile(filename string) error {
+	if signKey == "" {
+		return nil
+	}
+	if clearSign && fsh24.IsGzipName(filename) {
+		return fmt.Errorf("can't clear-sign %s, it's gzipped. Leave out --clearsign for a detached %s.asc", filename, filename)
+	}
+	args := []string{"--batch", "--yes", "--armor"}
+	if signKey != defaultSignKey {
+		args = append(args, "--local-user", signKey)
+	}
+	out := filename + ".asc"
+	if clearSign {
+		out = filename + ".tmp"
+		args = append(args, "--output", out, "--clearsign", filename)
+	} else {
+		args = append(args, "--output", out, "--detach-sign", filename)
+	}
+	if _, err := runGPG(nil, args...); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("could not sign %s: %w", filename, err)
+	}
+	if clearSign {
+		if err := os.Rename(out, filename); err != nil {
+			return err
+		}
+		os.Remove(filename + ".asc") // An old detached one wouldn't match any more
+	}
+	return nil
+}
+
+// readHashFile reads a hash file to verify. One signed with --sign-key has
+// had its signature checked by then, it's also looked up in --trusted-key.
+// With --require-signature it has to be signed by one of those, or with GPG.
+// It returns who signed it, empty for unsigned files.
+func readHashFile(filename string) (*fsh24.Manifest, string, error) {
+	m, err := fsh24.ReadManifestFile(filename)
+	if errors.Is(err, fsh24.ErrBadSignature) || errors.Is(err, fsh24.ErrBrokenChain) {
+		return nil, "", fmt.Errorf("%s: %w", filename, err)
+	} else if err != nil {
+		return nil, "", err
+	}
+	checkManifestVersion(filename, m)
+
+	if m.SignedBy != nil {
+		id := fsh24.KeyID(m.SignedBy)
+		trusted := slices.ContainsFunc(trustedKeys, func(k ed25519.PublicKey) bool { return k.Equal(m.SignedBy) })
+		if trusted {
+			return m, "key " + id, nil
+		}
+		if requireSignature {
+			return nil, "", fmt.Errorf("%s is signed by key %s, which isn't one of your --trusted-key keys", filename, id)
+		}
+		warnf(filename, "%s is signed by key %s, which isn't one of your --trusted-key keys. It's intact, but could be from anyone", filename, id)
+		return m, "", nil
+	}
+	if requireSignature {
+		return readSignedHashFile(filename)
+	}
+	return m, "", nil
+}
+
+// loadSigningKey reads a private key for --sign-key.
+func loadSigningKey(filename string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := fsh24.ParseSigningKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return key, nil
+}
+
+// loadTrustedKey reads a public key for --trusted-key.
+func loadTrustedKey(filename string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := fsh24.ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return key, nil
+}
+
+// keygen makes a new signing key pair, name.key and name.pub. It won't
+// overwrite existing keys, losing one means nothing signed with it can be
+// checked any more.
+func keygen(name string) error {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".key"), ".pub")
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := fsh24.MarshalSigningKey(key)
+	if err != nil {
+		return err
+	}
+	pubPEM, err := fsh24.MarshalPublicKey(pub)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{name + ".key", keyPEM, 0600}, // Secret, only for you
+		{name + ".pub", pubPEM, 0644},
+	} {
+		out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.perm)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(f.data)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Key %s saved\n", fsh24.KeyID(pub))
+	fmt.Printf("Private key: %s, sign with --sign-key %s and keep it to yourself\n", name+".key", name+".key")
+	fmt.Printf("Public key:  %s, check with --trusted-key %s, hand it out to anyone\n", name+".pub", name+".pub")
+	return nil
+}
+
+// readSignedHashFile reads a hash file that has to be signed, see
+// --require-signature. A clear-signed file is read as gpg hands it back,
+// so only what's covered by the signature counts. Anything else needs a good
+// detached signature in filename.asc (or .sig) over the bytes read.
+// It returns who signed it.
+func readSignedHashFile(filename string) (*fsh24.Manifest, string, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("hash file not found: %s", filename)
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to read hash file %s: %w", filename, err)
+	}
+
+	var status []byte
+	if bytes.HasPrefix(content, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		// gpg prints the signed text on stdout and the status lines on fd 2, mixed with its chatter
+		var signed []byte
+		signed, status, err = runGPGSplit(content, "--batch", "--status-fd", "2", "--decrypt")
+		if err != nil {
+			return nil, "", fmt.Errorf("bad signature on %s: %w", filename, err)
+		}
+		content = signed
+	} else {
+		sig := ""
+		for _, ext := range []string{".asc", ".sig"} {
+			if _, err := os.Stat(filename + ext); err == nil {
+				sig = filename + ext
+				break
+			}
+		}
+		if sig == "" {
+			return nil, "", fmt.Errorf("%s isn't signed, no %s.asc next to it", filename, filename)
+		}
+		status, err = runGPG(content, "--batch", "--status-fd", "1", "--verify", sig, "-")
+		if err != nil {
+			return nil, "", fmt.Errorf("bad signature on %s: %w", filename, err)
+		}
+	}
+
+	signer, ok := goodSigner(status)
+	if !ok {
+		return nil, "", fmt.Errorf("bad signature on %s", filename)
+	}
+	m, err := fsh24.ParseManifest(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", err
+	}
+	m.DetectChecksums(filename)
+	return m, signer, nil
+}
+
+// goodSigner finds who made the signature in gpg's --status-fd output,
+// "[GNUPG:] GOODSIG <key id> <user id>".
+func goodSigner(status []byte) (string, bool) {
+	sc := bufio.NewScanner(bytes.NewReader(status))
+	for sc.Scan() {
+		if rest, ok := strings.CutPrefix(sc.Text(), "[GNUPG:] GOODSIG "); ok {
+			keyID, user, _ := strings.Cut(rest, " ")
+			return fmt.Sprintf("%s (%s)", user, keyID), true
+		}
+	}
+	return "", false
+}
+
+// runGPG runs gpg with stdin and returns its stdout. A failure includes
+// what gpg said on stderr.
+func runGPG(stdin []byte, args ...string) ([]byte, error) {
+	stdout, _, err := runGPGSplit(stdin, args...)
+	return stdout, err
+}
+
+// runGPGSplit is runGPG, returning stderr too.
+func runGPGSplit(stdin []byte, args ...string) ([]byte, []byte, error) {
+	cmd := exec.Command("gpg", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, nil, errors.New("gpg not found, install GnuPG or put it on the PATH")
+		}
+		if msg := gpgMessage(stderr.Bytes()); msg != "" {
+			return nil, nil, errors.New(msg)
+		}
+		return nil, nil, err
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
+
+// gpgMessage is what gpg said on stderr, without the status lines.
+func gpgMessage(stderr []byte) string {
+	var lines []string
+	for line := range strings.SplitSeq(strings.TrimSpace(string(stderr)), "\n") {
+		if !strings.HasPrefix(line, "[GNUPG:]") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, "gpg: ")))
+		}
+	}
+	return strings.Join(lines, ", ")
+}
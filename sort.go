@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"fsh24/pkg/fsh24"
+)
+
+// Orders for --sort.
+const (
+	sortName    = "name"    // Byte order, each folder's files on their own, like it always was
+	sortNatural = "natural" // file2 before file10, case doesn't matter
+	sortSize    = "size"    // Smallest first
+	sortNone    = "none"    // The order the files were given or found in
+)
+
+var sortModes = []string{sortName, sortNatural, sortSize, sortNone}
+
+// sortFiles puts the files of a run in the --sort order. name and none
+// were taken care of while walking, natural and size sort the whole list.
+func sortFiles(files []string, mode string) {
+	switch mode {
+	case sortNatural:
+		slices.SortStableFunc(files, naturalCompare)
+	case sortSize:
+		sizes := make(map[string]int64, len(files))
+		for _, f := range files {
+			sizes[f] = localSize(f)
+		}
+		slices.SortStableFunc(files, func(a, b string) int {
+			return cmp.Or(cmp.Compare(sizes[a], sizes[b]), naturalCompare(a, b))
+		})
+	}
+}
+
+// sortWalked sorts the files found in one folder, unless the order they were
+// found in was asked for.
+func sortWalked(files []string, mode string) {
+	if mode != sortNone {
+		sort.Strings(files)
+	}
+}
+
+// inOrder puts hash results back in the order of files, the --sort order,
+// HashFiles sorts them by path.
+func inOrder(results []fsh24.FileHashResult, files []string) {
+	index := make(map[string]int, len(files))
+	for i, f := range files {
+		index[f] = i
+	}
+	slices.SortStableFunc(results, func(a, b fsh24.FileHashResult) int {
+		return cmp.Compare(index[a.Filepath], index[b.Filepath])
+	})
+}
+
+// naturalCompare compares paths the way people count: runs of digits by
+// their value, so file2 comes before file10, and letters without caring
+// about case. Paths that only differ in case or leading zeros fall back to
+// byte order, so the order is always the same.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			ai, bj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := trimZeros(a[ai:i]), trimZeros(b[bj:j])
+			if c := cmp.Or(cmp.Compare(len(x), len(y)), cmp.Compare(x, y)); c != 0 {
+				return c
+			}
+			continue
+		}
+		ra, na := utf8.DecodeRuneInString(a[i:])
+		rb, nb := utf8.DecodeRuneInString(b[j:])
+		if c := cmp.Compare(unicode.ToLower(ra), unicode.ToLower(rb)); c != 0 {
+			return c
+		}
+		i += na
+		j += nb
+	}
+	return cmp.Or(cmp.Compare(len(a)-i, len(b)-j), cmp.Compare(a, b))
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// trimZeros drops the leading zeros of a run of digits, keeping one digit.
+func trimZeros(digits string) string {
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"fsh24/pkg/fsh24"
+)
+
+// runSummary is the machine readable wrap-up of a run. It's written to
+// --summary-file, or as one JSON line on stderr when a report format is used,
+// so wrappers don't have to scrape the "Warning: ..." text.
+type runSummary struct {
+	Mode     string       `json:"mode,omitempty"` // "hash" or "verify"
+	ExitCode int          `json:"exit_code"`
+	Total    int          `json:"total"`  // Files found to hash, or listed to verify
+	OK       int          `json:"ok"`     // Hashed, or verified
+	Failed   int          `json:"failed"` // Skipped, or failed verification
+	Warnings []runWarning `json:"warnings"`
+	Error    string       `json:"error,omitempty"` // Why the run stopped early
+}
+
+// runWarning is one warning, Path is set when it's about a file.
+type runWarning struct {
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	runMu sync.Mutex
+	run   = runSummary{Warnings: []runWarning{}}
+
+	summaryFile string // --summary-file
+	structured  bool   // A report format is printing, keep free text off stderr
+)
+
+// warnf records a warning for the summary and prints it as "Warning: ..."
+// to stderr, unless a report format is in use.
+func warnf(path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	runMu.Lock()
+	defer runMu.Unlock()
+	run.Warnings = append(run.Warnings, runWarning{Path: path, Message: msg})
+	if !structured {
+		fmt.Fprintln(os.Stderr, colorizeStderr(colorYellow, "Warning: "+msg))
+	}
+}
+
+// warnSkipped warns that path wasn't hashed because of err. Files that were
+// locked the whole time get "Skipped: locked", they're fine, just try later.
+func warnSkipped(path string, err error) {
+	status := fsh24.StatusHashError
+	if fsh24.IsLocked(err) {
+		status = fsh24.StatusLocked
+	}
+	runMu.Lock()
+	runFailures = append(runFailures, daemonFailure{Path: path, Status: status})
+	runMu.Unlock()
+	if status == fsh24.StatusLocked {
+		warnf(path, "Skipped: locked: %s is in use by another program", path)
+		return
+	}
+	warnf(path, "Skipping file %s due to error: %v", path, err)
+}
+
+// fatalf prints "Error: ..." (or just records it, see warnf) and exits with code.
+func fatalf(code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	run.Error = msg
+	if !structured || summaryFile != "" {
+		fmt.Fprintln(os.Stderr, colorizeStderr(colorRed, "Error: "+msg))
+	}
+	exit(code)
+}
+
+// exit writes out the run summary, runs the --on-fail and --on-complete
+// hooks, mails it if asked to and exits with code.
+func exit(code int) {
+	run.ExitCode = code
+	runHooks(code)
+	mailRun(code)
+	line, err := json.Marshal(run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshalling summary: %v\n", err)
+		os.Exit(code)
+	}
+
+	if summaryFile != "" {
+		if err := os.WriteFile(summaryFile, append(line, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving summary file: %v\n", err)
+		}
+	} else if structured {
+		fmt.Fprintln(os.Stderr, string(line))
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// minTagLength is the shortest --tag-length, 8 hex digits is what CRC32
+// tags in anime and ROM names have always been and still hard to hit by chance.
+const minTagLength = 8
+
+// tagRe is a --tag-filename tag, ".[HASH]" at the end of a name without its extension.
+var tagRe = regexp.MustCompile(`\.\[([0-9A-Fa-f]{8,})\]$`)
+
+// splitTag splits a file name into the part before its tag, the tag and the
+// extension. "movie.[A1B2C3D4].mkv" is "movie", "A1B2C3D4" and ".mkv".
+// Names without a tag get "" for it.
+func splitTag(name string) (stem, tag, ext string) {
+	if m := tagRe.FindStringSubmatchIndex(name); m != nil {
+		return name[:m[0]], name[m[2]:m[3]], "" // No extension, "README.[A1B2C3D4]"
+	}
+	ext = filepath.Ext(name)
+	stem = strings.TrimSuffix(name, ext)
+	if stem == "" {
+		return name, "", "" // A dotfile, .bashrc is all name
+	}
+	if m := tagRe.FindStringSubmatchIndex(stem); m != nil {
+		return stem[:m[0]], stem[m[2]:m[3]], ext
+	}
+	return stem, "", ext
+}
+
+// tagName is name with tag in it, replacing the tag it had.
+func tagName(name, tag string) string {
+	stem, _, ext := splitTag(name)
+	return stem + ".[" + tag + "]" + ext
+}
+
+// tagFiles renames the hashed files to have the first length characters of
+// their FSH24 in the name, all of it for 0. Files tagged with another hash
+// are left alone, they changed since or were tagged with other settings, and
+// renaming them would hide that. It returns how many were renamed, how many
+// already had the right tag and how many couldn't be.
+func tagFiles(results []fsh24.FileHashResult, length int, quiet bool) (tagged, already, failed int) {
+	for _, r := range results {
+		if fsh24.IsRemote(r.Filepath) {
+			warnf(r.Filepath, "Can't rename %s, only local files can be tagged", r.Filepath)
+			failed++
+			continue
+		}
+		hash := strings.ToUpper(r.FSH24)
+		tag := hash
+		if length > 0 && length < len(hash) {
+			tag = hash[:length]
+		}
+
+		dir, name := filepath.Split(r.Filepath)
+		_, old, _ := splitTag(name)
+		old = strings.ToUpper(old)
+		switch {
+		case old == tag:
+			already++
+			continue
+		case old != "" && !strings.HasPrefix(hash, old):
+			warnf(r.Filepath, "%s has %s in its name but hashes to %s, it changed since it was tagged? Left as it is", r.Filepath, old, tag)
+			failed++
+			continue
+		}
+
+		newPath := filepath.Join(dir, tagName(name, tag))
+		if _, err := os.Lstat(newPath); err == nil {
+			warnf(r.Filepath, "Can't tag %s, %s is already there", r.Filepath, filepath.Base(newPath))
+			failed++
+			continue
+		}
+		if err := os.Rename(r.Filepath, newPath); err != nil {
+			warnf(r.Filepath, "Could not rename %s: %v", r.Filepath, err)
+			failed++
+			continue
+		}
+		tagged++
+		if !quiet {
+			fmt.Println(colorize(colorGreen, fmt.Sprintf("Tagged: %s -> %s", r.Filepath, filepath.Base(newPath))))
+		}
+	}
+	return tagged, already, failed
+}
+
+// nameHashRe is a hash in brackets anywhere in a file name, a --tag-filename
+// tag or the [A1B2C3D4] CRC32 of a release, with the dot before it if there is one.
+var nameHashRe = regexp.MustCompile(`(\.?)\[([0-9A-Fa-f]{8,})\]`)
+
+// nameHash finds the hash in the name of path, the last one if there are a
+// few. 8 characters in plain brackets is the CRC32 releases put in their
+// names, anything longer or after a dot is (the start of) a FSH24.
+func nameHash(path string) (hash string, crc bool) {
+	matches := nameHashRe.FindAllStringSubmatch(filepath.Base(path), -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	m := matches[len(matches)-1]
+	return strings.ToUpper(m[2]), m[1] == "" && len(m[2]) == 8
+}
+
+// tagManifest is a hash file for the files that have a hash in their name,
+// for --verify-tags. It also returns how many files had none.
+func tagManifest(files []string) (*fsh24.Manifest, int) {
+	m := &fsh24.Manifest{}
+	untagged := 0
+	for _, f := range files {
+		hash, crc := nameHash(f)
+		switch {
+		case hash == "":
+			untagged++
+		case crc:
+			m.Entries = append(m.Entries, fsh24.Entry{CRC32: hash, Size: -1, Path: f})
+		default:
+			m.Entries = append(m.Entries, fsh24.Entry{Hash: hash, Size: -1, Path: f})
+		}
+	}
+	return m, untagged
+}
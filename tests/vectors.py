@@ -0,0 +1,90 @@
+#!/env/Python3.10.4
+#/MobCat (2024)
+
+
+"""
+FSH24 Test Vectors
+
+Checks fsh24.py against the same test vectors `fsh24 selftest` checks the Go
+version against, so the two always make the same hash.
+The test files are made up on the fly, byte i of a file is the low byte of
+i ^ i>>8 ^ i>>16 ^ i>>24, nothing gets written to disk.
+Only the vectors with the default settings are checked, the python version
+doesn't have any others.
+
+Usage: python vectors.py
+"""
+
+import os
+import sys
+import json
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), ".."))
+import fsh24
+
+
+class SyntheticFile:
+    """A read only file of size bytes of the test pattern."""
+
+    def __init__(self, size):
+        self.size = size
+        self.pos = 0
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *args):
+        pass
+
+    def seek(self, pos):
+        self.pos = pos
+
+    def read(self, n=-1):
+        end = self.size if n < 0 else min(self.size, self.pos + n)
+        data = bytearray()
+        # Every 256 bytes is 0-255 xored with the same byte, so make it a block at a time
+        i = self.pos - self.pos % 256
+        while i < end:
+            c = ((i >> 8) ^ (i >> 16) ^ (i >> 24)) & 0xFF
+            data += bytes(x ^ c for x in range(256))
+            i += 256
+        data = bytes(data[self.pos % 256:self.pos % 256 + end - self.pos])
+        self.pos = end
+        return data
+
+
+def synthetic_hash(size):
+    """fast_sample_hash of a synthetic file, by swapping out the file it opens."""
+    getsize = os.path.getsize
+    os.path.getsize = lambda path: size
+    fsh24.open = lambda path, mode: SyntheticFile(size)
+    try:
+        return fsh24.fast_sample_hash("synthetic")
+    finally:
+        os.path.getsize = getsize
+        del fsh24.open
+
+
+def main():
+    with open(os.path.join(os.path.dirname(os.path.abspath(__file__)), "vectors.json")) as f:
+        vectors = json.load(f)
+
+    failed = 0
+    for vector in vectors:
+        if set(vector) != {"size", "fsh24", "chunks"}:
+            continue
+        got, chunks = synthetic_hash(vector["size"])
+        if got == vector["fsh24"].upper() and chunks == vector["chunks"]:
+            print(f"OK      {vector['size']:,} bytes")
+        else:
+            failed += 1
+            print(f"FAILED  {vector['size']:,} bytes")
+            print(f"        expected {vector['fsh24']} ({vector['chunks']} chunks)")
+            print(f"        got      {got} ({chunks} chunks)")
+
+    print(f"\n{failed} failed" if failed else "\nAll vectors OK")
+    sys.exit(1 if failed else 0)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+)
+
+// torrentFiles lines up the hashed files for a torrent. They go in a folder
+// named after the one they all share, with their paths under it, or on
+// their own with its name for a single file. URLs are left out, the torrent
+// has to be seeded from a local copy anyway.
+func torrentFiles(results []fsh24.FileHashResult) (string, []fsh24.TorrentFile, error) {
+	var files []fsh24.TorrentFile
+	for _, r := range results {
+		if fsh24.IsRemote(r.Filepath) {
+			warnf(r.Filepath, "Left %s out of the torrent, only local files can be seeded", r.Filepath)
+			continue
+		}
+		abs, err := filepath.Abs(r.Filepath)
+		if err != nil {
+			abs = r.Filepath
+		}
+		files = append(files, fsh24.TorrentFile{Path: r.Filepath, Name: filepath.ToSlash(abs), Length: r.FileSize})
+	}
+	if len(files) == 0 {
+		return "", nil, nil
+	}
+	if len(files) == 1 {
+		files[0].Name = path.Base(files[0].Name)
+		return files[0].Name, files, nil
+	}
+
+	top := path.Dir(files[0].Name)
+	for _, f := range files[1:] {
+		for !strings.HasPrefix(f.Name, strings.TrimSuffix(top, "/")+"/") && path.Dir(top) != top {
+			top = path.Dir(top)
+		}
+	}
+	prefix := strings.TrimSuffix(top, "/") + "/"
+	for i := range files {
+		if !strings.HasPrefix(files[i].Name, prefix) {
+			return "", nil, fmt.Errorf("%s and %s are on different drives, they can't go in one torrent", files[0].Path, files[i].Path)
+		}
+		files[i].Name = strings.TrimPrefix(files[i].Name, prefix)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	name := path.Base(top)
+	if name == "/" || name == "." || strings.HasSuffix(name, ":") {
+		name = "files" // The root of a drive has no name to give it
+	}
+	return name, files, nil
+}
+
+// torrentName is the .torrent written next to the hash file hashFile, like
+// the .sfv of --sfv.
+func torrentName(hashFile string) string {
+	hashFile = fsh24.TrimGzipExt(hashFile)
+	return strings.TrimSuffix(hashFile, filepath.Ext(hashFile)) + ".torrent"
+}
+
+// writeTorrent reads the hashed files in full and writes a .torrent of them
+// to out, with pieceLength pieces, 0 to pick one.
+func writeTorrent(ctx context.Context, hasher *fsh24.Hasher, results []fsh24.FileHashResult, out string, pieceLength int64, announce string) (*fsh24.Torrent, error) {
+	name, files, err := torrentFiles(results)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no local files to put in %s", out)
+	}
+	t, err := hasher.Torrent(ctx, name, files, pieceLength)
+	if err != nil {
+		return nil, err
+	}
+	t.Announce = announce
+	t.CreatedBy = "fsh24"
+	t.CreationDate = time.Now()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.WriteTo(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, f.Close()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"fsh24/pkg/fsh24"
+)
+
+// existingManifest is a .fsh24 file that --update adds to or --prune cleans up.
+type existingManifest struct {
+	*fsh24.Manifest
+
+	// dir is the absolute folder the file is in.
+	dir string
+
+	// index maps the absolute path of every entry to its place in Entries.
+	// Relative entries are resolved from the folder the file is in, same as verifying.
+	index map[string]int
+
+	// added and replaced count what add did, for the message at the end.
+	added, replaced int
+}
+
+// loadExisting reads the .fsh24 file to update. A file that doesn't exist yet
+// gives nil and no error, there is just nothing to add to.
+func loadExisting(filename string) (*existingManifest, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil
+	}
+	m, err := fsh24.ReadManifestFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Invalid) > 0 {
+		// Writing it back would quietly lose them
+		return nil, fmt.Errorf("%s has %d broken lines, fix them before updating it", filename, len(m.Invalid))
+	}
+	if i := slices.IndexFunc(m.Entries, func(e fsh24.Entry) bool { return e.Checksum != "" }); i >= 0 {
+		return nil, fmt.Errorf("%s is a %s checksum file, fsh24 can check it but not add FSH24 hashes to it", filename, m.Entries[i].Checksum)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	x := &existingManifest{Manifest: m, dir: dir}
+	x.reindex()
+	return x, nil
+}
+
+// resolve returns the absolute path of an entry.
+func (x *existingManifest) resolve(e fsh24.Entry) string {
+	if fsh24.IsRemote(e.Path) {
+		return e.Path
+	}
+	if filepath.IsAbs(e.Path) {
+		return filepath.Clean(e.Path)
+	}
+	return filepath.Join(x.dir, e.Path)
+}
+
+// reindex rebuilds index after Entries changed.
+func (x *existingManifest) reindex() {
+	x.index = make(map[string]int, len(x.Entries))
+	for i, e := range x.Entries {
+		x.index[indexKey(x.resolve(e))] = i
+	}
+}
+
+// indexKey is what the index is keyed by, with --normalize-unicode a file
+// hashed on a Mac is the same file as it is written anywhere else.
+func indexKey(abs string) string {
+	if normalizeUnicode {
+		return fsh24.NormalizePath(abs)
+	}
+	return abs
+}
+
+// prune drops the entries whose files are gone and returns them.
+func (x *existingManifest) prune() []fsh24.Entry {
+	var kept, removed []fsh24.Entry
+	for _, e := range x.Entries {
+		if gone(x.resolve(e)) {
+			removed = append(removed, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	x.Entries = kept
+	x.reindex()
+	return removed
+}
+
+// contains reports whether the file at path already has an entry.
+func (x *existingManifest) contains(path string) bool {
+	abs, err := absPath(path)
+	if err != nil {
+		return false
+	}
+	_, ok := x.index[indexKey(abs)]
+	return ok
+}
+
+// filesToHash returns the files that aren't in the manifest yet. With
+// incremental it also returns the listed ones that changed since, see changed.
+func (x *existingManifest) filesToHash(files []string, incremental bool) []string {
+	var todo []string
+	for _, f := range files {
+		if !x.contains(f) || incremental && x.changed(f) {
+			todo = append(todo, f)
+		}
+	}
+	return todo
+}
+
+// changed reports whether the size or modification time of a listed file no
+// longer match its entry. Entries without a recorded time count as changed.
+func (x *existingManifest) changed(path string) bool {
+	abs, err := absPath(path)
+	if err != nil {
+		return true
+	}
+	e := x.Entries[x.index[indexKey(abs)]]
+	size, modTime, err := statFile(path)
+	if err != nil {
+		return true
+	}
+	return size != e.Size || e.ModTime.IsZero() || !modTime.Equal(e.ModTime)
+}
+
+// gone reports whether the file at path has been deleted. A broken symlink
+// is still there, and so is a URL the server had a problem with. A file in
+// an archive is gone when the archive is or it's no longer in it.
+func gone(path string) bool {
+	if fsh24.IsRemote(path) {
+		_, _, err := statFile(path)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	_, err := os.Lstat(path)
+	if os.IsNotExist(err) && fsh24.IsArchiveMember(path) {
+		_, _, err = statFile(path)
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	return os.IsNotExist(err)
+}
+
+// remove drops the entry of the file at path, reporting whether it had one.
+func (x *existingManifest) remove(path string) bool {
+	abs, err := absPath(path)
+	if err != nil {
+		return false
+	}
+	i, ok := x.index[indexKey(abs)]
+	if !ok {
+		return false
+	}
+	x.Entries = slices.Delete(x.Entries, i, i+1)
+	x.reindex()
+	return true
+}
+
+// add puts a hash result in the manifest, replacing the entry of the same
+// file if there is one. Errors are the same as fsh24.Manifest.Add.
+func (x *existingManifest) add(r fsh24.FileHashResult, relTo string) error {
+	tmp := fsh24.Manifest{}
+	err := tmp.Add(r, relTo)
+	e := tmp.Entries[0]
+	if e.Offsets != nil {
+		x.Adaptive = true // Or the offsets column stays out
+	}
+
+	abs := indexKey(x.resolve(e))
+	if i, ok := x.index[abs]; ok {
+		x.Entries[i] = e
+		x.replaced++
+		return err
+	}
+	x.index[abs] = len(x.Entries)
+	x.Entries = append(x.Entries, e)
+	x.added++
+	return err
+}
+
+// setupHasher makes hasher produce hashes that fit in with the existing
+// entries, whatever the command line said.
+func (x *existingManifest) setupHasher(hasher *fsh24.Hasher) error {
+	if x.Keyed && len(hasher.Key) == 0 {
+		return fsh24.ErrKeyRequired
+	}
+	x.ApplySettings(hasher)
+	if hasher.SampleSize == 0 {
+		hasher.SampleSize = fsh24.SampleSize // The totals printed at the end go by it
+	}
+	hasher.SHA256 = x.SHA256
+	hasher.CRC32 = x.Format == fsh24.FormatSFV
+	if x.Adaptive {
+		hasher.Adaptive = true // New files get placed samples like the rest
+	}
+	return nil
+}
+
+// replaceFile writes m to filename through a temp file, so a crash halfway
+// through writing leaves the old file rather than half of the new one.
+func replaceFile(m *fsh24.Manifest, filename string) error {
+	tmp := filename + ".tmp"
+	if fsh24.IsGzipName(filename) {
+		m.Gzip = true // The .tmp name doesn't say so
+	}
+	if err := m.WriteFile(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// pruneHashFile drops the entries of a .fsh24 file whose files are gone and
+// saves it, listing what was removed unless quiet.
+func pruneHashFile(filename string, quiet bool) error {
+	x, err := loadExisting(filename)
+	if err != nil {
+		return err
+	}
+	if x == nil {
+		return fmt.Errorf("hash file not found: %s", filename)
+	}
+	if x.Chained {
+		return fmt.Errorf("%s is chained, it can only be added to, not pruned", filename)
+	}
+
+	run.Total = len(x.Entries)
+	removed := x.prune()
+	run.OK, run.Failed = len(x.Entries), 0
+	printPruned(removed, quiet)
+	if len(removed) == 0 {
+		fmt.Printf("Nothing to prune, every file in %s is still there\n", filename)
+		return nil
+	}
+	if err := writeHashFile(x.Manifest, filename); err != nil {
+		return fmt.Errorf("could not write hash file: %w", err)
+	}
+	fmt.Printf("Pruned %d missing files from: %s\n", len(removed), filename)
+	return nil
+}
+
+// printPruned lists the entries --prune removed.
+func printPruned(removed []fsh24.Entry, quiet bool) {
+	if quiet {
+		return
+	}
+	for _, e := range removed {
+		fmt.Println(colorize(colorYellow, "Removed: "+e.Path))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// version is the release this build is, the tag it's published under on
+// GitHub. commit and buildDate are the git commit it was built from and
+// when. Release builds set them with -ldflags "-X main.version=...", a go
+// build from a git checkout gets the commit and its date from Go instead.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// versionInfo is what fsh24 version prints.
+type versionInfo struct {
+	Version  string   `json:"version"`
+	Commit   string   `json:"commit,omitempty"`
+	Modified bool     `json:"modified,omitempty"` // Built with changes that aren't committed
+	Date     string   `json:"date,omitempty"`
+	Go       string   `json:"go"`
+	Platform string   `json:"platform"`
+	Writes   []string `json:"writes"` // Hash file formats, as --format takes them
+	Reads    []string `json:"reads"`  // Header lines of the .fsh24 versions it understands
+}
+
+// buildVersion gathers the version of this build, filling in what -ldflags
+// didn't set from what Go recorded.
+func buildVersion() versionInfo {
+	v := versionInfo{
+		Version:  version,
+		Commit:   commit,
+		Date:     buildDate,
+		Go:       runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		Writes:   fsh24.Formats,
+		Reads:    []string{fsh24.Magic, fsh24.MagicSettings, fsh24.MagicV2, fsh24.MagicBinary},
+	}
+	for _, name := range fsh24.Algorithms {
+		if algo, _ := fsh24.LookupAlgorithm(name); algo.Magic != "" {
+			v.Reads = append(v.Reads, algo.Magic)
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if v.Commit == "" {
+					v.Commit = s.Value
+				}
+			case "vcs.time":
+				if v.Date == "" {
+					v.Date = s.Value
+				}
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+	return v
+}
+
+// printVersion prints the version of this build, as JSON with -j.
+func printVersion(asJSON bool) {
+	v := buildVersion()
+	if asJSON {
+		out, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("fsh24 %s\n", v.Version)
+	if v.Commit != "" {
+		modified := ""
+		if v.Modified {
+			modified = " (with changes)"
+		}
+		fmt.Printf("Commit:   %s%s\n", v.Commit, modified)
+	}
+	if v.Date != "" {
+		fmt.Printf("Built:    %s\n", v.Date)
+	}
+	fmt.Printf("Go:       %s %s\n", v.Go, v.Platform)
+	fmt.Printf("Writes:   %s\n", strings.Join(v.Writes, ", "))
+	fmt.Printf("Reads:    %s .fsh24 files, and md5sum/sha256sum, BSD and .sfv hash files\n", strings.Join(v.Reads, ", "))
+}
+
+// checkManifestVersion warns when a hash file was written by a newer fsh24
+// than this one, it may use things this one doesn't know about.
+func checkManifestVersion(filename string, m *fsh24.Manifest) {
+	if m.Meta[fsh24.MetaTool] != "fsh24" || version == "dev" {
+		return
+	}
+	if made := m.Meta[fsh24.MetaVersion]; made != "" && made != "dev" && newerVersion(made, version) {
+		warnf(filename, "%s was made by fsh24 %s, this is %s. If anything in it doesn't make sense, run fsh24 self-update", filename, made, version)
+	}
+}
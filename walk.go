@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"fsh24/pkg/fsh24"
+)
+
+// walkOptions controls what expandFilePaths picks up from folders.
+type walkOptions struct {
+	Recursive bool
+
+	// Exclude skips files and folders matching any of these glob patterns.
+	// A pattern without a / matches the name at any depth (Thumbs.db, *.tmp),
+	// one with a / matches the path from the folder being walked (cache/**).
+	Exclude []string
+
+	// Include, if set, only picks up files matching one of these patterns.
+	// Same pattern rules as Exclude, folders are always walked.
+	Include []string
+
+	// Exts, if set, also picks up files ending in one of these extensions,
+	// whatever their case. Lower case without the dot, see normalizeExts.
+	Exts []string
+
+	// MaxDepth stops recursing this many levels down, 1 being only the files
+	// right in the folder given. 0 means no limit.
+	MaxDepth int
+
+	// FollowSymlinks also walks into symlinked folders, each real folder only
+	// once so link loops can't go on forever. By default linked files are
+	// hashed but linked folders are left alone.
+	FollowSymlinks bool
+
+	// SkipSymlinks ignores symlinks completely, files and folders.
+	SkipSymlinks bool
+
+	// FollowJunctions walks into Windows junctions and volume mount points,
+	// each real folder only once like FollowSymlinks. By default they're
+	// skipped, they loop back up the tree or count the same files twice.
+	FollowJunctions bool
+
+	// Hydrate hashes cloud placeholders, OneDrive files that aren't
+	// downloaded, which downloads them. By default they're skipped.
+	Hydrate bool
+
+	// SkipHidden ignores dotfiles, or on Windows anything with the Hidden attribute.
+	SkipHidden bool
+
+	// ArchiveContents also lists the files inside archives, after the
+	// archive itself, as "archive.zip!path/in/it", see Hasher.ListArchive.
+	// Exclude and Include go by the path inside the archive.
+	ArchiveContents bool
+
+	// Hasher, if set, hashes the files of compressed tars while they're
+	// listed, so they don't have to be decompressed twice. It has to be the
+	// one they're hashed with after.
+	Hasher *fsh24.Hasher
+
+	// NullInput reads the "-" list from stdin NUL separated, like find -print0 writes.
+	NullInput bool
+
+	// OnSkip, if set, is told about every file or folder left out and why.
+	OnSkip func(path, reason string)
+
+	// Sort is the --sort order of the files, see sortModes.
+	Sort string
+}
+
+// Skip reasons of the Windows reparse points reparseKind finds.
+const (
+	reparseJunction = "junction"
+	reparseCloud    = "cloud placeholder"
+)
+
+// skip tells OnSkip that path was left out.
+func (o walkOptions) skip(path, reason string) {
+	if o.OnSkip != nil {
+		o.OnSkip(path, reason)
+	}
+}
+
+// excluded reports whether rel, a path inside a walked folder, matches an exclude pattern.
+func (o walkOptions) excluded(rel string) bool {
+	return matchAny(o.Exclude, rel)
+}
+
+// included reports whether the file rel, inside a walked folder, passes the
+// include patterns or has one of the extensions.
+func (o walkOptions) included(rel string) bool {
+	if len(o.Include) == 0 && len(o.Exts) == 0 {
+		return true
+	}
+	return matchAny(o.Include, rel) || hasExt(o.Exts, rel)
+}
+
+// hasExt reports whether name ends in one of exts, ignoring case.
+func hasExt(exts []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range exts {
+		if strings.HasSuffix(name, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExts turns --ext values like ".MKV", "*.iso" or "tar.gz" into
+// the lower case, dotless form hasExt wants.
+func normalizeExts(exts []string) []string {
+	var out []string
+	for _, ext := range exts {
+		ext = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(ext), "*"), ".")
+		if ext != "" {
+			out = append(out, strings.ToLower(ext))
+		}
+	}
+	return out
+}
+
+// matchAny reports whether rel matches one of patterns, see walkOptions.Exclude.
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	name := path.Base(rel)
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		} else if matchGlob(strings.TrimPrefix(pattern, "./"), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandFilePaths processes input paths, expanding directories and handling recursion.
+func expandFilePaths(inputPaths []string, opts walkOptions) ([]string, error) {
+	expandedFiles := make([]string, 0)
+
+	// "-" is a list of paths on stdin, eg. find . -name '*.iso' | fsh24 -
+	if slices.Contains(inputPaths, "-") {
+		stdinPaths, err := readPathList(os.Stdin, opts.NullInput)
+		if err != nil {
+			return nil, fmt.Errorf("could not read paths from stdin: %w", err)
+		}
+		var withStdin []string
+		for _, inputPath := range inputPaths {
+			if inputPath == "-" {
+				for _, p := range stdinPaths {
+					withStdin = append(withStdin, fsh24.PlainPath(p))
+				}
+			} else {
+				withStdin = append(withStdin, inputPath)
+			}
+		}
+		inputPaths = withStdin
+	}
+
+	// Expand glob patterns ourselves, cmd on Windows doesn't
+	var globbed []string
+	for _, inputPath := range inputPaths {
+		matches, err := expandGlob(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		if matches == nil {
+			globbed = append(globbed, inputPath)
+			continue
+		}
+		if len(matches) == 0 {
+			warnf(inputPath, "Nothing matches: %s", inputPath)
+		}
+		globbed = append(globbed, matches...)
+	}
+	inputPaths = globbed
+
+	for _, inputPath := range inputPaths {
+		if fsh24.IsRemoteFolder(inputPath) {
+			files, err := opts.walkRemote(inputPath)
+			if err != nil {
+				return nil, fmt.Errorf("could not list %s: %w", inputPath, err)
+			}
+			sortWalked(files, opts.Sort)
+			expandedFiles = append(expandedFiles, files...)
+			continue
+		}
+		if fsh24.IsRemote(inputPath) {
+			expandedFiles = append(expandedFiles, inputPath) // Checked when it's hashed, one request less
+			continue
+		}
+		if fsh24.IsDevice(inputPath) {
+			expandedFiles = append(expandedFiles, inputPath) // A disk named on its own, \\.\PhysicalDrive1 can't be Stat'd
+			continue
+		}
+		fileInfo, err := os.Stat(inputPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				warnf(inputPath, "Path not found: %s", inputPath)
+				continue
+			}
+			return nil, fmt.Errorf("could not get file info for %s: %w", inputPath, err)
+		}
+
+		if fileInfo.IsDir() {
+			files, err := opts.walk(inputPath)
+			if err != nil {
+				return nil, fmt.Errorf("could not read directory %s: %w", inputPath, err)
+			}
+			sortWalked(files, opts.Sort) // Sort for consistent ordering
+			expandedFiles = append(expandedFiles, files...)
+		} else {
+			expandedFiles = append(expandedFiles, inputPath)
+		}
+	}
+	if opts.ArchiveContents {
+		expandedFiles = opts.withArchiveContents(expandedFiles)
+	}
+	sortFiles(expandedFiles, opts.Sort)
+	return expandedFiles, nil
+}
+
+// localSize is the size of a file found by expandFilePaths, 0 for URLs and
+// anything that can't be looked at. Those would take a request each.
+func localSize(fp string) int64 {
+	if fsh24.IsDevice(fp) {
+		size, _, _ := statFile(fp)
+		return size
+	}
+	if fi, err := os.Stat(fp); err == nil {
+		return fi.Size()
+	}
+	if fsh24.IsArchiveMember(fp) {
+		size, _, _ := statFile(fp)
+		return size
+	}
+	return 0
+}
+
+// withArchiveContents puts the files inside every archive in files right
+// after it. An archive that can't be read is warned about and hashed as a
+// file all the same.
+func (o walkOptions) withArchiveContents(files []string) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f)
+		if !fsh24.IsArchive(f) {
+			continue
+		}
+		members, err := o.Hasher.ListArchive(context.Background(), f)
+		if err != nil {
+			warnf(f, "Could not look inside %s: %v", f, err)
+			continue
+		}
+		for _, m := range members {
+			_, rel, _ := fsh24.SplitArchivePath(m)
+			switch {
+			case o.excluded(rel):
+				o.skip(m, "excluded")
+			case !o.included(rel):
+				o.skip(m, "not included")
+			default:
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+// walk lists the files in root going by the options.
+// Sub folders that can't be read are warned about and skipped.
+func (o walkOptions) walk(root string) ([]string, error) {
+	maxDepth := o.MaxDepth
+	if !o.Recursive {
+		maxDepth = 1
+	}
+
+	var files []string
+	visited := map[string]bool{} // Real paths of the folders walked, for FollowSymlinks and FollowJunctions
+	cloud := 0                   // Cloud placeholders skipped
+
+	var walkDir func(dir string, depth int) error
+	walkDir = func(dir string, depth int) error {
+		if o.FollowSymlinks || o.FollowJunctions {
+			if real, err := realPath(dir); err == nil {
+				if visited[real] {
+					return nil // Been here through another link
+				}
+				visited[real] = true
+			}
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if dir == root {
+				return err
+			}
+			warnf(dir, "Could not read directory %s: %v", dir, err)
+			return nil
+		}
+
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				continue
+			}
+
+			if o.SkipHidden && isHidden(entry) {
+				o.skip(p, "hidden")
+				continue
+			}
+
+			isDir := entry.IsDir()
+			device := entry.Type()&fs.ModeDevice != 0
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if o.SkipSymlinks {
+					o.skip(p, "symlink")
+					continue
+				}
+				info, err := os.Stat(p)
+				if err != nil {
+					o.skip(p, "broken symlink")
+					continue
+				}
+				isDir, device = info.IsDir(), info.Mode()&fs.ModeDevice != 0
+				if isDir && !o.FollowSymlinks {
+					o.skip(p, "symlinked folder")
+					continue
+				}
+			}
+			switch reparseKind(entry) {
+			case reparseJunction:
+				if !o.FollowJunctions {
+					o.skip(p, reparseJunction)
+					continue
+				}
+				isDir = true
+			case reparseCloud:
+				if !o.Hydrate {
+					o.skip(p, reparseCloud)
+					cloud++
+					continue
+				}
+			}
+
+			if device {
+				o.skip(p, "device") // Walking /dev shouldn't read every disk, name one to hash it
+				continue
+			}
+			if o.excluded(rel) {
+				o.skip(p, "excluded")
+				continue
+			}
+			if isDir {
+				if maxDepth > 0 && depth >= maxDepth {
+					o.skip(p, "max depth") // Its files would be one level too deep
+					continue
+				}
+				if err := walkDir(p, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if o.included(rel) {
+				files = append(files, p)
+			} else {
+				o.skip(p, "not included")
+			}
+		}
+		return nil
+	}
+
+	err := walkDir(root, 1)
+	if cloud > 0 {
+		warnf(root, "Skipped %d files in %s that are only in the cloud, --hydrate downloads and hashes them", cloud, root)
+	}
+	return files, err
+}
+
+// readPathList reads one path per line, or NUL separated if null is set, skipping blank ones.
+func readPathList(r io.Reader, null bool) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // Long paths are a thing
+	if null {
+		scanner.Split(scanNull)
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !null {
+			line = strings.TrimRight(line, "\r")
+		}
+		if line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// scanNull is a bufio.SplitFunc for NUL separated input.
+func scanNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// expandGlob expands a glob pattern like *.iso or games/**/*.iso into the paths
+// that match it. ** matches any number of folders, including none.
+// It returns nil if inputPath is not a pattern, is an existing file that
+// just happens to have a * in its name, or is a URL (a ? there is a query).
+func expandGlob(inputPath string) ([]string, error) {
+	if !strings.ContainsAny(inputPath, "*?[") || fsh24.IsRemote(inputPath) {
+		return nil, nil
+	}
+	if _, err := os.Stat(inputPath); err == nil {
+		return nil, nil
+	}
+
+	if !strings.Contains(inputPath, "**") {
+		matches, err := filepath.Glob(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %s: %w", inputPath, err)
+		}
+		if matches == nil {
+			matches = []string{}
+		}
+		return matches, nil
+	}
+
+	// Walk from the last folder before any wildcards and match the rest
+	base, pattern := globBase(inputPath)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %s: %w", inputPath, err)
+	}
+	matches := []string{}
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == base {
+				return err
+			}
+			return nil // Skip what we can't read, same as a shell would
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, p)
+		if err == nil && matchGlob(pattern, filepath.ToSlash(rel)) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not expand %s: %w", inputPath, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globBase splits a pattern into the folder before the first wildcard
+// and the rest of the pattern, in / form.
+func globBase(pattern string) (string, string) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(parts)-1 && !strings.ContainsAny(parts[i], "*?[") {
+		i++
+	}
+	base := strings.Join(parts[:i], "/")
+	if base == "" && i > 0 {
+		base = "/" // Pattern started at the root
+	} else if base == "" {
+		base = "."
+	}
+	return filepath.FromSlash(base), strings.Join(parts[i:], "/")
+}
+
+// matchGlob reports whether the / separated name matches pattern, with ** support.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// withoutFiles removes the files in skip from files, comparing absolute paths.
+func withoutFiles(files, skip []string) []string {
+	var skipAbs []string
+	for _, f := range skip {
+		if f == "" {
+			continue
+		}
+		if abs, err := absPath(f); err == nil {
+			skipAbs = append(skipAbs, abs)
+		}
+	}
+	return slices.DeleteFunc(files, func(f string) bool {
+		abs, err := absPath(f)
+		return err == nil && slices.Contains(skipAbs, abs)
+	})
+}
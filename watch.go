@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"fsh24/pkg/fsh24"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long a file has to be left alone before it's hashed.
+// Copying a big file in fires a write event for every block, no point
+// hashing it halfway.
+const watchDelay = 2 * time.Second
+
+// folderWatcher keeps a hash file up to date with a folder, see watchFolder.
+type folderWatcher struct {
+	root   string // Absolute
+	output string // Absolute
+	relTo  string // What the recorded paths are relative to, empty for absolute
+	walk   walkOptions
+	hasher *fsh24.Hasher
+	prune  bool
+	quiet  bool
+
+	x       *existingManifest
+	fs      *fsnotify.Watcher
+	pending map[string]time.Time // Files to hash, by when they last changed
+	watched map[string]bool      // Folders watched, by real path with FollowSymlinks so link loops end
+	dirty   bool                 // x has changes that aren't saved yet
+}
+
+// watchFolder hashes the files under root that output doesn't have yet, then
+// keeps watching root and hashes every file that is added or changed, saving
+// output after each batch. With prune deleted files are dropped from it.
+// It runs until ctx is cancelled.
+func watchFolder(ctx context.Context, hasher *fsh24.Hasher, walk walkOptions, root, output, format string, absolute, prune, quiet bool) error {
+	w := &folderWatcher{walk: walk, hasher: hasher, prune: prune, quiet: quiet, pending: map[string]time.Time{}, watched: map[string]bool{}}
+	w.walk.Recursive = true
+
+	var err error
+	if w.root, err = filepath.Abs(root); err != nil {
+		return err
+	}
+	if w.output, err = filepath.Abs(output); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(w.root); err != nil || !fi.IsDir() {
+		return fmt.Errorf("not a folder: %s", root)
+	}
+
+	if w.x, err = loadExisting(w.output); err != nil {
+		return err
+	}
+	if w.x == nil {
+		w.x = &existingManifest{Manifest: newManifest(hasher, format), dir: filepath.Dir(w.output)}
+		w.x.reindex()
+		w.dirty = true // Even an empty folder gets its hash file
+	} else if err := w.x.setupHasher(hasher); err != nil {
+		return err
+	}
+	if w.x.Chained {
+		return fmt.Errorf("%s is chained, watching would change lines already in it", w.output)
+	}
+	w.relTo = w.x.dir
+	if absolute {
+		w.relTo = ""
+	}
+
+	if w.fs, err = fsnotify.NewWatcher(); err != nil {
+		return fmt.Errorf("could not watch %s: %w", root, err)
+	}
+	defer w.fs.Close()
+	// Watch before the first pass, so nothing added during it gets missed
+	if err := w.addTree(w.root); err != nil {
+		return err
+	}
+
+	// Catch up with whatever happened while we weren't watching
+	if prune {
+		for _, e := range w.x.prune() {
+			w.log(colorYellow, "Removed", e.Path)
+			w.dirty = true
+		}
+	}
+	files, err := w.walk.walk(w.root)
+	if err != nil {
+		return fmt.Errorf("could not read directory %s: %w", root, err)
+	}
+	files = withoutFiles(files, w.ownFiles())
+	for _, f := range w.x.filesToHash(files, fsh24.HasFields(w.x.Format)) {
+		w.pending[f] = time.Time{} // No need to wait for these
+	}
+	w.hashPending(ctx)
+	if err := w.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Watching %s, %d files in %s. Ctrl+C to stop\n", root, len(w.x.Entries), output)
+
+	tick := time.NewTicker(watchDelay / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return w.save()
+		case ev, ok := <-w.fs.Events:
+			if !ok {
+				return w.save()
+			}
+			w.event(ev)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return w.save()
+			}
+			warnf(w.root, "Watch error: %v", err)
+		case <-tick.C:
+			w.hashPending(ctx)
+			if err := w.save(); err != nil {
+				warnf(w.output, "Could not save %s: %v", w.output, err)
+			}
+		}
+	}
+}
+
+// event handles a change reported by fsnotify.
+func (w *folderWatcher) event(ev fsnotify.Event) {
+	path := ev.Name
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		// A rename shows up as a remove here and a create under the new name
+		delete(w.pending, path)
+		delete(w.watched, path) // In case it was a folder and comes back
+		if !w.prune {
+			return
+		}
+		// A folder moved away only gets an event for itself, not its files
+		for _, e := range slices.Clone(w.x.Entries) {
+			abs := w.x.resolve(e)
+			if abs == path || strings.HasPrefix(abs, path+string(filepath.Separator)) {
+				w.x.remove(abs)
+				w.log(colorYellow, "Removed", abs)
+				w.dirty = true
+			}
+		}
+		return
+	}
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+		return
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil || !w.wanted(path, fi) {
+		return
+	}
+	if fi.IsDir() {
+		// A folder moved or copied in, its files don't get events of their own
+		if err := w.addTree(path); err != nil {
+			warnf(path, "Could not watch %s: %v", path, err)
+		}
+		files, _ := w.walk.walk(path)
+		for _, f := range withoutFiles(files, w.ownFiles()) {
+			w.pending[f] = time.Now()
+		}
+		return
+	}
+	w.pending[path] = time.Now()
+}
+
+// wanted reports whether the walk options would have picked up path,
+// a file or folder somewhere under root.
+func (w *folderWatcher) wanted(path string, fi fs.FileInfo) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return false
+	}
+	if len(withoutFiles([]string{path}, w.ownFiles())) == 0 {
+		return false
+	}
+	if w.walk.SkipHidden && isHidden(fs.FileInfoToDirEntry(fi)) {
+		return false
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if w.walk.SkipSymlinks {
+			return false
+		}
+		target, err := os.Stat(path)
+		if err != nil || target.IsDir() && !w.walk.FollowSymlinks {
+			return false
+		}
+		fi = target
+	}
+	if w.walk.excluded(rel) {
+		return false
+	}
+	if fi.IsDir() {
+		return w.walk.MaxDepth == 0 || depth(rel) < w.walk.MaxDepth
+	}
+	return w.walk.included(rel) && (w.walk.MaxDepth == 0 || depth(rel) <= w.walk.MaxDepth)
+}
+
+// depth is how many folders down rel is, a file right in root being 1.
+func depth(rel string) int {
+	n := 1
+	for _, c := range filepath.ToSlash(rel) {
+		if c == '/' {
+			n++
+		}
+	}
+	return n
+}
+
+// addTree watches dir and the folders under it that the walk options allow.
+func (w *folderWatcher) addTree(dir string) error {
+	key := dir
+	if w.walk.FollowSymlinks {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			key = real
+		}
+	}
+	if w.watched[key] {
+		return nil
+	}
+	w.watched[key] = true
+	if err := w.fs.Add(dir); err != nil {
+		return fmt.Errorf("could not watch %s: %w", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // Walking it warns about this
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		fi, err := os.Stat(path)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		lfi, err := os.Lstat(path)
+		if err != nil || !w.wanted(path, lfi) {
+			continue
+		}
+		if err := w.addTree(path); err != nil {
+			warnf(path, "%v", err)
+		}
+	}
+	return nil
+}
+
+// hashPending hashes the files that have been left alone for watchDelay.
+func (w *folderWatcher) hashPending(ctx context.Context) {
+	for _, path := range slices.Sorted(maps.Keys(w.pending)) {
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(w.pending[path]) < watchDelay {
+			continue
+		}
+		delete(w.pending, path)
+
+		known := w.x.contains(path)
+		if known && !w.x.changed(path) {
+			continue // Touched but not changed
+		}
+		result, err := w.hasher.HashFile(ctx, path)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, fs.ErrNotExist) {
+				warnSkipped(path, err)
+			}
+			continue
+		}
+		if err := w.x.add(result, w.relTo); err != nil {
+			warnf(path, "%v. Using absolute path.", err)
+		}
+		w.dirty = true
+		if known {
+			w.log(colorYellow, "Updated", path)
+		} else {
+			w.log(colorGreen, "Added", path)
+		}
+	}
+}
+
+// save writes the hash file if anything changed since last time.
+func (w *folderWatcher) save() error {
+	if !w.dirty {
+		return nil
+	}
+	w.x.SignKey = signingKey
+	if err := replaceFile(w.x.Manifest, w.output); err != nil {
+		return fmt.Errorf("could not write hash file: %w", err)
+	}
+	if err := signHashFile(w.output); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// ownFiles are the files watching writes, they mustn't end up in the hash file.
+func (w *folderWatcher) ownFiles() []string {
+	return []string{w.output, w.output + ".tmp", w.output + ".asc"}
+}
+
+// log prints a timestamped line about a file, unless quiet.
+func (w *folderWatcher) log(color, what, path string) {
+	if w.quiet {
+		return
+	}
+	fmt.Printf("%s %s\n", time.Now().Format(logTime), colorize(color, what+": "+path))
+}